@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// adminKeepAlive is how often an idle admin event stream gets a comment so
+// proxies keep it open
+const adminKeepAlive = 15 * time.Second
+
+// LiveSession describes a connected session on the admin API
+type LiveSession struct {
+	SessionID   string            `json:"session_id"`
+	UserID      string            `json:"user_id"`
+	RemoteAddr  string            `json:"remote_addr"`
+	State       State             `json:"state"`
+	ConnectedAt time.Time         `json:"connected_at"`
+	Turns       int               `json:"turns"`
+	CurrentTurn *LiveTurn         `json:"current_turn,omitempty"`
+	LastLatency *LatencyBreakdown `json:"last_latency,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+}
+
+// LiveTurn is the turn a session is in the middle of
+type LiveTurn struct {
+	Turn      int       `json:"turn"`
+	StartedAt time.Time `json:"started_at"`
+	WakeWord  string    `json:"wake_word,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+}
+
+// eventFeed fans the events of a session out to admin watchers. Watchers
+// that fall behind miss events rather than slowing the session down.
+type eventFeed struct {
+	watchers map[chan RecordedEvent]struct{}
+	closed   bool
+	mutex    sync.Mutex
+}
+
+// subscribe returns a channel receiving the events of the session until it
+// ends, and a function to stop watching
+func (f *eventFeed) subscribe() (<-chan RecordedEvent, func()) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	events := make(chan RecordedEvent, 64)
+	if f.closed {
+		close(events)
+		return events, func() {}
+	}
+	if f.watchers == nil {
+		f.watchers = make(map[chan RecordedEvent]struct{})
+	}
+	f.watchers[events] = struct{}{}
+
+	return events, func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if _, ok := f.watchers[events]; ok {
+			delete(f.watchers, events)
+			close(events)
+		}
+	}
+}
+
+// publish sends an event to every watcher
+func (f *eventFeed) publish(event RecordedEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for events := range f.watchers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// close ends every watch
+func (f *eventFeed) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+	for events := range f.watchers {
+		close(events)
+	}
+	f.watchers = nil
+}
+
+// liveSession describes the session for the admin API
+func (cs *ClientState) liveSession() LiveSession {
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+
+	session := LiveSession{
+		SessionID:   cs.sessionID,
+		UserID:      cs.userID,
+		RemoteAddr:  cs.conn.RemoteAddr().String(),
+		State:       cs.state,
+		ConnectedAt: cs.connectedAt,
+		Turns:       cs.turns,
+		LastError:   cs.lastError,
+	}
+	if cs.currentTurn != nil {
+		turn := *cs.currentTurn
+		session.CurrentTurn = &turn
+	}
+	if cs.lastLatency != nil {
+		latency := *cs.lastLatency
+		session.LastLatency = &latency
+	}
+	return session
+}
+
+// adminRoutes registers the admin page and API, which require the admin token
+func (app *App) adminRoutes(r *mux.Router) {
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(app.requireAdmin)
+	admin.HandleFunc("", app.handleAdminPage).Methods(http.MethodGet)
+	admin.HandleFunc("/api/sessions", app.handleAdminSessions).Methods(http.MethodGet)
+	admin.HandleFunc("/api/sessions/{session}", app.handleAdminSession).Methods(http.MethodGet)
+	admin.HandleFunc("/api/sessions/{session}", app.handleAdminDisconnect).Methods(http.MethodDelete)
+	admin.HandleFunc("/api/sessions/{session}/stop", app.handleAdminStop).Methods(http.MethodPost)
+	admin.HandleFunc("/api/sessions/{session}/events", app.handleAdminEvents).Methods(http.MethodGet)
+}
+
+// requireAdmin lets requests through that carry the admin token, either as a
+// bearer token or as the password of basic authentication so browsers can
+// open the page
+func (app *App) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := app.currentConfig().Admin.Token
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, provided, ok = r.BasicAuth()
+		}
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAdminPage serves the admin dashboard
+func (app *App) handleAdminPage(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "./static/admin.html")
+}
+
+// handleAdminSessions lists the connected sessions, oldest first
+func (app *App) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	clients := app.snapshotClients()
+	sessions := make([]LiveSession, 0, len(clients))
+	for _, client := range clients {
+		sessions = append(sessions, client.liveSession())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// handleAdminSession returns one connected session
+func (app *App) handleAdminSession(w http.ResponseWriter, r *http.Request) {
+	client := app.requireLiveSession(w, r)
+	if client == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, client.liveSession())
+}
+
+// handleAdminStop stops the turn a session is in, as its stop command would
+func (app *App) handleAdminStop(w http.ResponseWriter, r *http.Request) {
+	client := app.requireLiveSession(w, r)
+	if client == nil {
+		return
+	}
+	log.Printf("Admin: stopping the turn of session %s", client.sessionID)
+	client.recordEvent("admin", "stop", "turn stopped by an operator")
+	client.stopTurn()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminDisconnect closes the socket of a session
+func (app *App) handleAdminDisconnect(w http.ResponseWriter, r *http.Request) {
+	client := app.requireLiveSession(w, r)
+	if client == nil {
+		return
+	}
+	log.Printf("Admin: disconnecting session %s", client.sessionID)
+	client.closeWithReason(websocket.ClosePolicyViolation, "Disconnected by an operator")
+	app.removeClient(client.conn)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminEvents streams the events of a session as server-sent events
+// until the session ends or the watcher goes away
+func (app *App) handleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	client := app.requireLiveSession(w, r)
+	if client == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, stop := client.feed.subscribe()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(adminKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				fmt.Fprint(w, "event: end\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error marshaling admin event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+// requireLiveSession returns the connected session named in the request, or
+// answers 404
+func (app *App) requireLiveSession(w http.ResponseWriter, r *http.Request) *ClientState {
+	session := mux.Vars(r)["session"]
+	for _, client := range app.snapshotClients() {
+		if client.sessionID == session {
+			return client
+		}
+	}
+	http.Error(w, "session not connected", http.StatusNotFound)
+	return nil
+}
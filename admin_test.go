@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// adminServer serves an app with the admin API enabled
+func adminServer(t *testing.T) (*App, *httptest.Server) {
+	t.Helper()
+	config := DefaultConfig()
+	config.Admin.Enabled = true
+	config.Admin.Token = "secret"
+	app := newApp(config)
+	server := httptest.NewServer(app.Routes())
+	t.Cleanup(server.Close)
+	return app, server
+}
+
+// adminRequest sends a request to the admin API with the bearer token
+func adminRequest(t *testing.T, method, url string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestAdminAuth(t *testing.T) {
+	_, server := adminServer(t)
+	url := server.URL + "/admin/api/sessions"
+
+	tests := []struct {
+		name   string
+		setup  func(req *http.Request)
+		status int
+	}{
+		{"none", func(req *http.Request) {}, http.StatusUnauthorized},
+		{"wrong bearer", func(req *http.Request) { req.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"bearer", func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"basic", func(req *http.Request) { req.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"wrong basic", func(req *http.Request) { req.SetBasicAuth("admin", "secret2") }, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		test.setup(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+		if test.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no basic auth challenge", test.name)
+		}
+	}
+
+	// Without the admin API the routes don't exist
+	plain := httptest.NewServer(newApp(DefaultConfig()).Routes())
+	defer plain.Close()
+	if status, _, _ := request(t, http.MethodGet, plain.URL+"/admin/api/sessions"); status != http.StatusNotFound {
+		t.Errorf("disabled admin status = %d, want 404", status)
+	}
+}
+
+func TestAdminSessions(t *testing.T) {
+	app, server := adminServer(t)
+	conn := dialSession(t, server, "")
+	client := app.snapshotClients()[0]
+	api := server.URL + "/admin/api/sessions"
+
+	resp, body := adminRequest(t, http.MethodGet, api)
+	var sessions []LiveSession
+	if resp.StatusCode != http.StatusOK || json.Unmarshal([]byte(body), &sessions) != nil || len(sessions) != 1 {
+		t.Fatalf("list: %d %s", resp.StatusCode, body)
+	}
+	if sessions[0].SessionID != client.sessionID || sessions[0].State != StateIdle || sessions[0].RemoteAddr == "" {
+		t.Errorf("session = %+v", sessions[0])
+	}
+	if resp, _ := adminRequest(t, http.MethodGet, api+"/"+client.sessionID); resp.StatusCode != http.StatusOK {
+		t.Errorf("get status = %d", resp.StatusCode)
+	}
+	if resp, _ := adminRequest(t, http.MethodGet, api+"/unknown"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := adminRequest(t, http.MethodPost, api+"/"+client.sessionID+"/stop"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("stop status = %d, want 204", resp.StatusCode)
+	}
+
+	if resp, _ := adminRequest(t, http.MethodDelete, api+"/"+client.sessionID); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("disconnect status = %d, want 204", resp.StatusCode)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+				t.Errorf("read error = %v, want policy violation close", err)
+			}
+			break
+		}
+	}
+	if len(app.snapshotClients()) != 0 {
+		t.Error("disconnected session still listed")
+	}
+}
+
+func TestAdminEvents(t *testing.T) {
+	app, server := adminServer(t)
+	dialSession(t, server, "")
+	client := app.snapshotClients()[0]
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/api/sessions/"+client.sessionID+"/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("content type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	client.recordEvent("stt", "final", "play some jazz")
+	client.feed.close()
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 3 || !strings.Contains(lines[0], `"detail":"play some jazz"`) || lines[1] != "event: end" {
+		t.Errorf("stream = %q, want the event and the end", lines)
+	}
+}
+
+func TestEventFeed(t *testing.T) {
+	var feed eventFeed
+	events, stop := feed.subscribe()
+	other, _ := feed.subscribe()
+
+	feed.publish(RecordedEvent{Type: "start"})
+	if event := <-events; event.Type != "start" {
+		t.Errorf("event = %+v", event)
+	}
+
+	// Watchers that fall behind miss events
+	stop()
+	stop()
+	for i := 0; i < 100; i++ {
+		feed.publish(RecordedEvent{Type: "continue"})
+	}
+	if _, open := <-events; open {
+		t.Error("stopped watch still open")
+	}
+
+	feed.close()
+	count := 0
+	for range other {
+		count++
+	}
+	if count != cap(other) {
+		t.Errorf("slow watcher got %d events, want %d", count, cap(other))
+	}
+	if late, _ := feed.subscribe(); late != nil {
+		if _, open := <-late; open {
+			t.Error("watch of an ended session open")
+		}
+	}
+}
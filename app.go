@@ -1,88 +1,177 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
 )
 
-// AppConfig holds the application configuration
-type AppConfig struct {
-	VadServiceAddr     string
-	TriggerServiceAddr string
-	SttServiceAddr     string
-	TtsServiceAddr     string
-	LlmServiceAddr     string
-}
-
 // App represents the main application
 type App struct {
 	config        AppConfig
+	configMutex   sync.RWMutex
 	vadClient     VadClient
 	triggerClient TriggerClient
 	sttClient     SttClient
 	llmClient     LlmClient
 	ttsClient     TtsClient
+	tools         *ToolRegistry
+	history       TranscriptStore
+	recorder      *Recorder
+	metrics       *Metrics
+	breakers      []*CircuitBreaker
+	ttsBreaker    *CircuitBreaker
+	troubleAudio  []byte
 	upgrader      websocket.Upgrader
-	clients       map[*websocket.Conn]*ClientState
+	rtcAPI        *webrtc.API
+	clients       map[Transport]*ClientState
 	clientsMutex  sync.Mutex
+	draining      bool
+	drainMutex    sync.Mutex
 }
 
-// NewApp creates a new application instance
+// NewApp creates a new application instance connected to the backend services
 func NewApp(config AppConfig) *App {
-	// Create the upgrader with CheckOrigin disabled for development
-	upgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all connections in development
-		},
-	}
+	app := newApp(config)
 
-	// Initialize the app
-	app := &App{
-		config:       config,
-		upgrader:     upgrader,
-		clients:      make(map[*websocket.Conn]*ClientState),
-		clientsMutex: sync.Mutex{},
-	}
+	// Initialize clients for the AI services, over TLS where configured
 
-	// Initialize clients for the AI services
-	var err error
-
-	// Initialize VAD client
-	app.vadClient, err = NewVadClient(config.VadServiceAddr)
-	if err != nil {
-		log.Printf("Warning: Failed to connect to VAD service: %v\n", err)
+	// Initialize VAD client, falling back to the built-in VAD in auto mode
+	if config.Vad.Mode == VadModeLocal {
+		app.vadClient = NewLocalVadClient(config.Vad)
+	} else {
+		tlsConfig, err := clientTLSConfig(config.TLS.Vad, config.TLS.ReloadInterval)
+		if err == nil {
+			app.vadClient, err = NewVadClient(config.Services.Vad, config.Vad, config.Balancing, tlsConfig)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to connect to VAD service: %v\n", err)
+			if config.Vad.Mode == VadModeAuto {
+				log.Println("Using the built-in VAD")
+				app.vadClient = NewLocalVadClient(config.Vad)
+			}
+		}
 	}
 
 	// Initialize Trigger client
-	app.triggerClient, err = NewTriggerClient(config.TriggerServiceAddr)
+	tlsConfig, err := clientTLSConfig(config.TLS.Trigger, config.TLS.ReloadInterval)
+	if err == nil {
+		app.triggerClient, err = NewTriggerClient(config.Services.Trigger, config.Balancing, tlsConfig)
+	}
 	if err != nil {
 		log.Printf("Warning: Failed to connect to Trigger service: %v\n", err)
 	}
 
 	// Initialize STT client
-	app.sttClient, err = NewSttClient(config.SttServiceAddr)
+	tlsConfig, err = clientTLSConfig(config.TLS.Stt, config.TLS.ReloadInterval)
+	if err == nil {
+		app.sttClient, err = NewSttClient(config.Services.Stt, config.Balancing, tlsConfig)
+	}
 	if err != nil {
 		log.Printf("Warning: Failed to connect to STT service: %v\n", err)
 	}
 
 	// Initialize LLM client
-	app.llmClient = NewLlmClient(config.LlmServiceAddr)
+	tlsConfig, err = clientTLSConfig(config.TLS.Llm, config.TLS.ReloadInterval)
+	if err == nil {
+		app.llmClient, err = NewBalancedLlmClient(config.Services.Llm, config.Llm, config.Balancing, tlsConfig)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to create LLM client: %v\n", err)
+	}
 
 	// Initialize TTS client
-	app.ttsClient, err = NewTtsClient(config.TtsServiceAddr)
+	tlsConfig, err = clientTLSConfig(config.TLS.Tts, config.TLS.ReloadInterval)
+	if err == nil {
+		app.ttsClient, err = NewTtsClient(config.Services.Tts, config.Balancing, tlsConfig)
+	}
 	if err != nil {
 		log.Printf("Warning: Failed to connect to TTS service: %v\n", err)
 	}
 
+	// Set up the WebRTC transport
+	if config.Rtc.Enabled {
+		app.rtcAPI, err = newRtcAPI(config.Rtc)
+		if err != nil {
+			log.Printf("Warning: Failed to set up WebRTC: %v\n", err)
+		}
+	}
+
+	// Guard the backends with circuit breakers and fallbacks
+	app.setupResilience(config)
+
+	// Open the conversation history store
+	app.history, err = NewTranscriptStore(config.History)
+	if err != nil {
+		log.Printf("Warning: Failed to open history store: %v\n", err)
+	}
+
+	// Set up utterance recording
+	app.recorder, err = NewRecorder(config.Recording)
+	if err != nil {
+		log.Printf("Warning: Failed to set up recording: %v\n", err)
+	}
+
+	return app
+}
+
+// newApp creates an application without backend clients
+func newApp(config AppConfig) *App {
+	// Create the upgrader with CheckOrigin disabled for development
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  config.Server.ReadBufferSize,
+		WriteBufferSize: config.Server.WriteBufferSize,
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Allow all connections in development
+		},
+	}
+
+	// Initialize the app
+	app := &App{
+		config:       config,
+		tools:        NewToolRegistry(),
+		metrics:      NewMetrics(),
+		upgrader:     upgrader,
+		clients:      make(map[Transport]*ClientState),
+		clientsMutex: sync.Mutex{},
+	}
+
+	// Register the tools the LLM can call
+	RegisterBuiltinTools(app.tools)
+
 	return app
 }
 
+// currentConfig returns the configuration currently in effect
+func (app *App) currentConfig() AppConfig {
+	app.configMutex.RLock()
+	defer app.configMutex.RUnlock()
+	return app.config
+}
+
+// Reload applies the settings of config that can safely change at runtime.
+// Changes to other settings are logged and take effect after a restart.
+func (app *App) Reload(config AppConfig) {
+	app.configMutex.Lock()
+	defer app.configMutex.Unlock()
+
+	for _, name := range app.config.restartRequiredChanges(config) {
+		log.Printf("Config reload: %s changed, restart required to apply\n", name)
+	}
+	app.config = app.config.withReloadable(config)
+
+	level, _ := ParseLogLevel(app.config.LogLevel)
+	SetLogLevel(level)
+
+	log.Println("Configuration reloaded")
+}
+
 // Routes returns the router for the application
 func (app *App) Routes() http.Handler {
 	r := mux.NewRouter()
@@ -93,6 +182,27 @@ func (app *App) Routes() http.Handler {
 	// WebSocket route
 	r.HandleFunc("/ws", app.handleWebSocket)
 
+	// WebRTC signaling route
+	if app.rtcAPI != nil {
+		app.rtcRoutes(r)
+	}
+
+	// Health routes
+	r.HandleFunc("/healthz", app.handleHealth)
+	r.HandleFunc("/readyz", app.handleReady)
+	r.HandleFunc("/metrics", app.handleMetrics).Methods(http.MethodGet)
+
+	// Conversation history API
+	app.historyRoutes(r)
+
+	// Recording API
+	app.recordingRoutes(r)
+
+	// Admin dashboard and API for live sessions
+	if app.currentConfig().Admin.Enabled {
+		app.adminRoutes(r)
+	}
+
 	// Home route serves the index.html
 	r.HandleFunc("/", app.handleHome)
 
@@ -104,8 +214,43 @@ func (app *App) handleHome(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "./static/index.html")
 }
 
+// HealthStatus is the body of the health endpoint
+type HealthStatus struct {
+	Status   string                   `json:"status"`
+	Breakers map[string]BreakerStatus `json:"breakers"`
+}
+
+// handleHealth reports that the process is alive and the state of the
+// backend circuit breakers
+func (app *App) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := HealthStatus{
+		Status:   "ok",
+		Breakers: make(map[string]BreakerStatus),
+	}
+	for _, breaker := range app.breakers {
+		health.Breakers[breaker.name] = breaker.Status()
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+// handleReady reports whether the application accepts new sessions
+func (app *App) handleReady(w http.ResponseWriter, r *http.Request) {
+	if app.isDraining() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready"))
+}
+
 // handleWebSocket handles WebSocket connections
 func (app *App) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Refuse new sessions once the server is draining
+	if app.isDraining() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Upgrade the HTTP connection to a WebSocket connection
 	conn, err := app.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -114,15 +259,89 @@ func (app *App) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a new client state
-	clientState := NewClientState(conn, app)
+	clientState := NewClientState(conn, app, userFromRequest(r))
+	clientState.negotiateAudio(r.URL.Query().Get("audio"), app.currentConfig().Audio)
+	clientState.negotiateMic(r.URL.Query().Get("mic"), app.currentConfig().Audio)
+
+	// Add the client to the map and start handling it
+	app.addClient(clientState)
+	go clientState.handleClient()
+}
 
-	// Add the client to the map
+// Drain stops accepting new sessions, tells connected clients that the server
+// is going away and waits for in-flight turns to finish until ctx is done.
+// Every remaining socket is then closed with a going-away close frame.
+func (app *App) Drain(ctx context.Context) {
+	app.drainMutex.Lock()
+	app.draining = true
+	app.drainMutex.Unlock()
+
+	// Notify connected clients
+	for _, client := range app.snapshotClients() {
+		client.sendStatus(StateDraining, "Server going away")
+	}
+
+	// Wait for in-flight turns to finish
+	if busy := app.waitForTurns(ctx); busy > 0 {
+		log.Printf("Drain deadline reached, interrupting %d in-flight turn(s)\n", busy)
+	} else {
+		log.Println("All in-flight turns finished")
+	}
+
+	// Close the remaining sockets with a proper close frame
+	for _, client := range app.snapshotClients() {
+		client.closeWithReason(websocket.CloseGoingAway, "Server shutting down")
+	}
+}
+
+// waitForTurns polls the clients until none is busy or ctx is done and
+// returns the number of clients still in the middle of a turn
+func (app *App) waitForTurns(ctx context.Context) int {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		busy := app.busyClients()
+		if busy == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return busy
+		case <-ticker.C:
+		}
+	}
+}
+
+// isDraining reports whether the application is draining
+func (app *App) isDraining() bool {
+	app.drainMutex.Lock()
+	defer app.drainMutex.Unlock()
+	return app.draining
+}
+
+// snapshotClients returns the currently connected clients
+func (app *App) snapshotClients() []*ClientState {
 	app.clientsMutex.Lock()
-	app.clients[conn] = clientState
-	app.clientsMutex.Unlock()
+	defer app.clientsMutex.Unlock()
 
-	// Start handling the client
-	go clientState.handleClient()
+	clients := make([]*ClientState, 0, len(app.clients))
+	for _, client := range app.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// busyClients returns the number of clients in the middle of a turn
+func (app *App) busyClients() int {
+	busy := 0
+	for _, client := range app.snapshotClients() {
+		switch client.getState() {
+		case StateTriggered, StateProcessing, StateSpeaking:
+			busy++
+		}
+	}
+	return busy
 }
 
 // Close closes all connections and resources
@@ -148,12 +367,22 @@ func (app *App) Close() error {
 	if app.ttsClient != nil {
 		app.ttsClient.Close()
 	}
+	if app.history != nil {
+		app.history.Close()
+	}
 
 	return nil
 }
 
+// addClient adds a client to the clients map
+func (app *App) addClient(client *ClientState) {
+	app.clientsMutex.Lock()
+	defer app.clientsMutex.Unlock()
+	app.clients[client.conn] = client
+}
+
 // removeClient removes a client from the clients map
-func (app *App) removeClient(conn *websocket.Conn) {
+func (app *App) removeClient(conn Transport) {
 	app.clientsMutex.Lock()
 	defer app.clientsMutex.Unlock()
 
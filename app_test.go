@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialSession connects a WebSocket client to server and returns it once the
+// initial status and config messages arrived
+func dialSession(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if msg := readMessage(t, conn); msg["type"] != "status" || msg["status"] != "IDLE" {
+		t.Fatalf("first message = %v, want IDLE status", msg)
+	}
+	if msg := readMessage(t, conn); msg["type"] != "config" {
+		t.Fatalf("second message = %v, want config", msg)
+	}
+	return conn
+}
+
+// readMessage reads the next text message of conn as JSON
+func readMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("message %s: %v", data, err)
+		}
+		return msg
+	}
+}
+
+// frameTransport is a Transport keeping the binary messages written to it
+type frameTransport struct {
+	frames [][]byte
+	mutex  sync.Mutex
+}
+
+func (t *frameTransport) ReadMessage() (int, []byte, error) { select {} }
+
+func (t *frameTransport) WriteMessage(messageType int, data []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.frames = append(t.frames, bytes.Clone(data))
+	return nil
+}
+
+func (t *frameTransport) WriteControl(int, []byte, time.Time) error { return nil }
+func (t *frameTransport) RemoteAddr() net.Addr                      { return &net.TCPAddr{} }
+func (t *frameTransport) Close() error                              { return nil }
+
+// sentMessages returns the text messages of type written to conn
+func sentMessages(conn *frameTransport, messageType string) []map[string]interface{} {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	var messages []map[string]interface{}
+	for _, frame := range conn.frames {
+		var message map[string]interface{}
+		if json.Unmarshal(frame, &message) == nil && message["type"] == messageType {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// responses returns the text of the responses written to conn
+func responses(conn *frameTransport) []string {
+	var texts []string
+	for _, message := range sentMessages(conn, "response") {
+		texts = append(texts, message["text"].(string))
+	}
+	return texts
+}
+
+// triggeredSession returns a session listening to a request after the wake
+// word, with client as its STT service
+func triggeredSession(t *testing.T, config AppConfig, client SttClient) (*ClientState, *frameTransport) {
+	t.Helper()
+	app := newApp(config)
+	app.sttClient = client
+	conn := &frameTransport{}
+	cs := NewClientState(conn, app, "alice")
+	cs.stateMutex.Lock()
+	cs.beginTurn()
+	cs.state = StateTriggered
+	turn := cs.turn
+	cs.stateMutex.Unlock()
+	t.Cleanup(func() { turn.stop() })
+	return cs, conn
+}
+
+// scriptedStt answers each utterance with the next transcription
+type scriptedStt struct {
+	mutex   sync.Mutex
+	results []Transcription
+}
+
+func (c *scriptedStt) Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.results) == 0 {
+		return Transcription{}, errors.New("no transcription left")
+	}
+	result := c.results[0]
+	c.results = c.results[1:]
+	return result, nil
+}
+
+func (c *scriptedStt) Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error) {
+	return nil, errors.New("not streamed")
+}
+
+func (c *scriptedStt) Close() error { return nil }
+
+func TestDrainClosesIdleSessions(t *testing.T) {
+	app := newApp(DefaultConfig())
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+	conn := dialSession(t, server, "")
+
+	drained := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		app.Drain(ctx)
+		close(drained)
+	}()
+
+	if msg := readMessage(t, conn); msg["status"] != string(StateDraining) {
+		t.Fatalf("message = %v, want DRAINING status", msg)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("read error = %v, want going away close", err)
+	}
+	<-drained
+
+	// No new sessions once draining
+	resp, err := http.Get(server.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readyz status = %d, want 503", resp.StatusCode)
+	}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial while draining: err %v, want 503", err)
+	}
+}
+
+func TestDrainWaitsForTurns(t *testing.T) {
+	app := newApp(DefaultConfig())
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+	dialSession(t, server, "")
+
+	client := app.snapshotClients()[0]
+	client.setState(StateSpeaking)
+	if busy := app.busyClients(); busy != 1 {
+		t.Fatalf("busy clients = %d, want 1", busy)
+	}
+
+	// The turn ends shortly after the drain started
+	time.AfterFunc(200*time.Millisecond, func() { client.setState(StateIdle) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if busy := app.waitForTurns(ctx); busy != 0 {
+		t.Fatalf("busy after wait = %d, want 0", busy)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("waited %s, want at least the turn", elapsed)
+	}
+
+	// A deadline interrupts a turn that never ends
+	client.setState(StateProcessing)
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if busy := app.waitForTurns(ctx); busy != 1 {
+		t.Errorf("busy after deadline = %d, want 1", busy)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Audio formats used between the browser, the server and the backends
+const (
+	inputSampleRate = 16000 // Microphone audio, 16-bit mono PCM
+	ttsSampleRate   = 24000 // Synthesized audio, 16-bit mono PCM
+)
+
+// isWav reports whether audio starts with a RIFF/WAVE header
+func isWav(audio []byte) bool {
+	return len(audio) >= 12 && bytes.Equal(audio[0:4], []byte("RIFF")) && bytes.Equal(audio[8:12], []byte("WAVE"))
+}
+
+// wrapWav prepends a WAV header to 16-bit mono PCM
+func wrapWav(pcm []byte, sampleRate int) []byte {
+	const bitsPerSample = 16
+	const channels = 1
+	byteRate := sampleRate * channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16)) // fmt chunk size
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(byteRate))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// wavPCM returns the sample data and sample rate of a 16-bit mono WAV file
+func wavPCM(data []byte) ([]byte, int, error) {
+	if !isWav(data) {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	sampleRate := 0
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		if body+size > len(data) {
+			size = len(data) - body
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, fmt.Errorf("invalid fmt chunk")
+			}
+			format := binary.LittleEndian.Uint16(data[body : body+2])
+			channels := binary.LittleEndian.Uint16(data[body+2 : body+4])
+			bits := binary.LittleEndian.Uint16(data[body+14 : body+16])
+			if format != 1 || channels != 1 || bits != 16 {
+				return nil, 0, fmt.Errorf("only 16-bit mono PCM is supported")
+			}
+			sampleRate = int(binary.LittleEndian.Uint32(data[body+4 : body+8]))
+		case "data":
+			if sampleRate == 0 {
+				return nil, 0, fmt.Errorf("data chunk before fmt chunk")
+			}
+			return bytes.Clone(data[body : body+size]), sampleRate, nil
+		}
+		// Chunks are padded to an even size
+		offset = body + size + size%2
+	}
+	return nil, 0, fmt.Errorf("no data chunk")
+}
+
+// pcmBytes returns the size of d of microphone audio
+func pcmBytes(d time.Duration) int {
+	return int(d.Seconds()*inputSampleRate) * 2
+}
+
+// pcmSamples returns the samples of 16-bit mono PCM
+func pcmSamples(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return samples
+}
+
+// resamplePCM converts 16-bit mono PCM from one sample rate to another. Each
+// output sample averages the input samples it covers, or repeats the nearest
+// one when upsampling.
+func resamplePCM(pcm []byte, from, to int) []int16 {
+	in := len(pcm) / 2
+	out := make([]int16, in*to/from)
+	for i := range out {
+		start := i * from / to
+		end := max((i+1)*from/to, start+1)
+		sum := 0
+		for j := start; j < end; j++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[j*2:])))
+		}
+		out[i] = int16(sum / (end - start))
+	}
+	return out
+}
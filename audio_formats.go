@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Audio formats of the speech sent to clients
+const (
+	AudioFormatWav      = "wav"
+	AudioFormatOggOpus  = "ogg_opus"
+	AudioFormatWebmOpus = "webm_opus"
+)
+
+// Formats of the microphone audio sent by clients
+const (
+	MicFormatPCM  = "pcm"  // 16-bit mono PCM at 16kHz
+	MicFormatOpus = "opus" // One Opus packet per binary message
+)
+
+// audioCodecIDs identify the format of an audio frame in its header
+var audioCodecIDs = map[string]byte{
+	AudioFormatWav:      1,
+	AudioFormatOggOpus:  2,
+	AudioFormatWebmOpus: 3,
+}
+
+// Audio frame header: its size, version, codec ID and channel count, then
+// the sample rate and, since version 2, the frame id as little endian
+// uint32s. Clients skip the header by its size, so later versions can append
+// fields.
+const (
+	audioFrameHeaderSize = 12
+	audioFrameVersion    = 2
+)
+
+// AudioInfo tells the client how the speech of its session is encoded
+type AudioInfo struct {
+	Format     string `json:"format"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	Framed     bool   `json:"framed"` // Binary messages start with an audio frame header
+}
+
+// MicInfo tells the client how to send its microphone audio
+type MicInfo struct {
+	Format     string `json:"format"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+// opusAvailable reports whether the server can send Opus
+func opusAvailable(config AudioConfig) bool {
+	return config.TtsOggOpus || (config.Transcode && newOpusEncoder != nil)
+}
+
+// negotiateAudio picks the first format the client accepts that the server
+// can send. accepted is the comma separated audio parameter of the WebSocket
+// URL; clients that send it get framed audio, others plain WAV as before.
+func (cs *ClientState) negotiateAudio(accepted string, config AudioConfig) {
+	if accepted == "" {
+		return
+	}
+	cs.audio.Framed = true
+
+	for _, format := range strings.Split(accepted, ",") {
+		format = strings.TrimSpace(format)
+		if format == AudioFormatWav {
+			break
+		}
+		if (format == AudioFormatOggOpus || format == AudioFormatWebmOpus) && opusAvailable(config) {
+			cs.audio.Format = format
+			break
+		}
+	}
+	logf(LogDebug, "Session %s receives %s audio", cs.sessionID, cs.audio.Format)
+}
+
+// negotiateMic picks the first microphone format the client offers that the
+// server accepts. offered is the comma separated mic parameter of the
+// WebSocket URL; clients without it send PCM. Opus is only accepted from
+// builds with the opus tag, as browsers encode CELT and hybrid packets that
+// only libopus decodes.
+func (cs *ClientState) negotiateMic(offered string, config AudioConfig) {
+	for _, format := range strings.Split(offered, ",") {
+		format = strings.TrimSpace(format)
+		if format == MicFormatPCM {
+			break
+		}
+		if format == MicFormatOpus && config.MicOpus && newOpusDecoder != nil {
+			decoder, err := newMicDecoder()
+			if err != nil {
+				log.Printf("Warning: Failed to create Opus decoder: %v\n", err)
+				break
+			}
+			cs.mic.Format = format
+			cs.micDecoder = decoder
+			break
+		}
+	}
+	logf(LogDebug, "Session %s sends %s microphone audio", cs.sessionID, cs.mic.Format)
+}
+
+// ttsFormat returns the format to request from TTS for the session
+func (cs *ClientState) ttsFormat(config AudioConfig) string {
+	switch cs.audio.Format {
+	case AudioFormatOggOpus, AudioFormatWebmOpus, AudioFormatRtpOpus:
+		if config.TtsOggOpus {
+			return AudioFormatOggOpus
+		}
+	}
+	return AudioFormatWav
+}
+
+// speechTrack is a transport that plays speech on a media track rather than
+// sending it in binary messages
+type speechTrack interface {
+	writeSpeech(audio []byte) error
+	flushSpeech()
+}
+
+// sendAudio sends synthesized WAV or Ogg Opus audio to the client in the
+// format of the session. Nothing is sent once ctx is done; speech is written
+// under writeMutex so a cancelled turn can flush all of it.
+func (cs *ClientState) sendAudio(ctx context.Context, audio []byte) error {
+	// Played speech is recognized in the microphone by its playback position,
+	// so every sent audio goes into the reference, in the order it is sent
+	reference := echoReference(audio)
+
+	if track, ok := cs.conn.(speechTrack); ok {
+		cs.writeMutex.Lock()
+		defer cs.writeMutex.Unlock()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := track.writeSpeech(audio); err != nil {
+			return err
+		}
+		cs.playback.sent(audioDuration(audio))
+		cs.echo.addSpeech(reference)
+		return nil
+	}
+
+	frame := audio
+	if cs.audio.Framed {
+		encoded, format, sampleRate, err := encodeAudio(audio, cs.audio.Format, cs.app.currentConfig().Audio)
+		if err != nil {
+			return fmt.Errorf("failed to encode audio: %w", err)
+		}
+		frame = append(audioFrameHeader(format, sampleRate, 0), encoded...)
+	}
+
+	// The id is taken just before writing so frames are numbered in order
+	cs.writeMutex.Lock()
+	if err := ctx.Err(); err != nil {
+		cs.writeMutex.Unlock()
+		return err
+	}
+	id := cs.playback.sent(audioDuration(audio))
+	if cs.audio.Framed {
+		binary.LittleEndian.PutUint32(frame[8:], id)
+	}
+	err := cs.conn.WriteMessage(websocket.BinaryMessage, frame)
+	if err == nil {
+		cs.echo.addSpeech(reference)
+	}
+	cs.writeMutex.Unlock()
+	return err
+}
+
+// audioFrameHeader returns the header of a mono audio frame
+func audioFrameHeader(format string, sampleRate int, id uint32) []byte {
+	header := []byte{audioFrameHeaderSize, audioFrameVersion, audioCodecIDs[format], 1}
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	return binary.LittleEndian.AppendUint32(header, id)
+}
+
+// audioDuration returns the playing time of WAV or Ogg Opus audio, 0 when it
+// cannot be read
+func audioDuration(audio []byte) time.Duration {
+	if isOgg(audio) {
+		head, packets, err := oggOpusPackets(audio)
+		if err != nil {
+			return 0
+		}
+		_, preSkip, _, _ := parseOpusHead(head)
+		samples := -preSkip
+		for _, packet := range packets {
+			samples += opusPacketDuration(packet)
+		}
+		return time.Duration(max(samples, 0)) * time.Second / opusClockRate
+	}
+
+	pcm, sampleRate, err := wavPCM(audio)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(len(pcm)/2) * time.Second / time.Duration(sampleRate)
+}
+
+// encodeAudio converts WAV or Ogg Opus audio to format where the server can.
+// It returns the audio, the format it ended up in and its sample rate; WAV
+// stays WAV when the server cannot encode Opus.
+func encodeAudio(audio []byte, format string, config AudioConfig) ([]byte, string, int, error) {
+	if isOgg(audio) {
+		head, packets, err := oggOpusPackets(audio)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		_, _, sampleRate, _ := parseOpusHead(head)
+		switch format {
+		case AudioFormatOggOpus:
+			return audio, format, sampleRate, nil
+		case AudioFormatWebmOpus:
+			return writeWebmOpus(head, packets), format, sampleRate, nil
+		}
+		return nil, "", 0, errors.New("Opus audio cannot be sent as " + format)
+	}
+
+	// Browsers decode any WAV; the sample rate is 0 unless it is 16-bit mono
+	pcm, sampleRate, err := wavPCM(audio)
+	if err != nil || format == AudioFormatWav || !config.Transcode || newOpusEncoder == nil {
+		return audio, AudioFormatWav, sampleRate, nil
+	}
+	packets, err := encodeOpus(pcm, sampleRate, config.OpusBitrate)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if format == AudioFormatWebmOpus {
+		return writeWebmOpus(opusHead(sampleRate), packets), format, sampleRate, nil
+	}
+	return writeOggOpus(packets, sampleRate, len(pcm)/2), format, sampleRate, nil
+}
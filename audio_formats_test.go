@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// testOpusPacket returns a packet of size bytes with the TOC byte of a single
+// frame in configuration config
+func testOpusPacket(config byte, size int) []byte {
+	packet := make([]byte, size)
+	packet[0] = config << 3
+	return packet
+}
+
+// testOggOpus returns an Ogg Opus file of n 20ms CELT packets at 24kHz
+func testOggOpus(n int) []byte {
+	var packets [][]byte
+	for i := 0; i < n; i++ {
+		packets = append(packets, testOpusPacket(31, 40))
+	}
+	return writeOggOpus(packets, 24000, n*480)
+}
+
+func TestNegotiateAudio(t *testing.T) {
+	app := newApp(DefaultConfig())
+
+	// Transcoding offers Opus only with an encoder
+	transcoded := AudioFormatWav
+	if newOpusEncoder != nil {
+		transcoded = AudioFormatOggOpus
+	}
+	tests := []struct {
+		accepted string
+		config   AudioConfig
+		format   string
+		framed   bool
+	}{
+		{"", AudioConfig{TtsOggOpus: true}, AudioFormatWav, false},
+		{"wav", AudioConfig{TtsOggOpus: true}, AudioFormatWav, true},
+		{"ogg_opus,wav", AudioConfig{TtsOggOpus: true}, AudioFormatOggOpus, true},
+		{" webm_opus , ogg_opus", AudioConfig{TtsOggOpus: true}, AudioFormatWebmOpus, true},
+		{"wav,ogg_opus", AudioConfig{TtsOggOpus: true}, AudioFormatWav, true},
+		{"mp3,ogg_opus", AudioConfig{TtsOggOpus: true}, AudioFormatOggOpus, true},
+		{"ogg_opus,webm_opus", AudioConfig{}, AudioFormatWav, true},
+		{"ogg_opus", AudioConfig{Transcode: true}, transcoded, true},
+	}
+	for _, test := range tests {
+		cs := NewClientState(&frameTransport{}, app, "alice")
+		cs.negotiateAudio(test.accepted, test.config)
+		if cs.audio.Format != test.format || cs.audio.Framed != test.framed {
+			t.Errorf("negotiateAudio(%q, %+v) = %s framed %v, want %s framed %v", test.accepted, test.config, cs.audio.Format, cs.audio.Framed, test.format, test.framed)
+		}
+	}
+}
+
+func TestNegotiateMic(t *testing.T) {
+	app := newApp(DefaultConfig())
+	config := AudioConfig{MicOpus: true}
+
+	// Without libopus every client sends PCM
+	if newOpusDecoder == nil {
+		cs := NewClientState(&frameTransport{}, app, "alice")
+		cs.negotiateMic("opus,pcm", config)
+		if cs.mic.Format != MicFormatPCM || cs.micDecoder != nil {
+			t.Errorf("mic format = %s without a decoder, want pcm", cs.mic.Format)
+		}
+	}
+
+	withStubOpusDecoder(t)
+	tests := []struct {
+		offered string
+		config  AudioConfig
+		format  string
+	}{
+		{"", config, MicFormatPCM},
+		{"opus,pcm", config, MicFormatOpus},
+		{"flac, opus", config, MicFormatOpus},
+		{"pcm,opus", config, MicFormatPCM},
+		{"opus", AudioConfig{}, MicFormatPCM},
+	}
+	for _, test := range tests {
+		cs := NewClientState(&frameTransport{}, app, "alice")
+		cs.negotiateMic(test.offered, test.config)
+		if cs.mic.Format != test.format || (cs.micDecoder != nil) != (test.format == MicFormatOpus) {
+			t.Errorf("negotiateMic(%q, %+v) = %s, want %s", test.offered, test.config, cs.mic.Format, test.format)
+		}
+	}
+}
+
+func TestAudioFrameHeader(t *testing.T) {
+	header := audioFrameHeader(AudioFormatWebmOpus, 24000, 7)
+	want := []byte{12, 2, 3, 1, 0xc0, 0x5d, 0, 0, 7, 0, 0, 0}
+	if !bytes.Equal(header, want) || len(header) != audioFrameHeaderSize {
+		t.Errorf("header = %v, want %v", header, want)
+	}
+}
+
+func TestSendAudioFrames(t *testing.T) {
+	app := newApp(DefaultConfig())
+	conn := &frameTransport{}
+	cs := NewClientState(conn, app, "alice")
+	cs.negotiateAudio("webm_opus,wav", AudioConfig{TtsOggOpus: true})
+
+	// Frames carry their codec, so WAV can follow Opus
+	wav := wrapWav(make([]byte, pcmBytes(100*time.Millisecond)), inputSampleRate)
+	for _, audio := range [][]byte{testOggOpus(5), wav} {
+		if err := cs.sendAudio(context.Background(), audio); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(conn.frames) != 2 {
+		t.Fatalf("sent %d frames, want 2", len(conn.frames))
+	}
+	for i, codec := range []byte{3, 1} {
+		frame := conn.frames[i]
+		if frame[0] != audioFrameHeaderSize || frame[2] != codec {
+			t.Errorf("frame %d header = %v, want codec %d", i, frame[:audioFrameHeaderSize], codec)
+		}
+		if id := binary.LittleEndian.Uint32(frame[8:]); id != uint32(i+1) {
+			t.Errorf("frame %d id = %d, want %d", i, id, i+1)
+		}
+	}
+	if !bytes.HasPrefix(conn.frames[0][audioFrameHeaderSize:], []byte{0x1a, 0x45, 0xdf, 0xa3}) {
+		t.Error("Opus frame is not WebM")
+	}
+	if rate := binary.LittleEndian.Uint32(conn.frames[1][4:]); rate != inputSampleRate {
+		t.Errorf("WAV frame rate = %d", rate)
+	}
+
+	// Clients that don't negotiate get the audio as it is
+	plain := &frameTransport{}
+	cs = NewClientState(plain, app, "bob")
+	cs.sendAudio(context.Background(), wav)
+	if len(plain.frames) != 1 || !bytes.Equal(plain.frames[0], wav) {
+		t.Error("unframed audio changed")
+	}
+
+	// Nothing is sent for a cancelled turn
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cs.sendAudio(ctx, wav); err == nil || len(plain.frames) != 1 {
+		t.Errorf("cancelled send: err %v, %d frames", err, len(plain.frames))
+	}
+}
+
+func TestEncodeAudio(t *testing.T) {
+	pcm := make([]byte, pcmBytes(200*time.Millisecond))
+	wav := wrapWav(pcm, inputSampleRate)
+
+	// Without the opus tag speech stays WAV
+	audio, format, rate, err := encodeAudio(wav, AudioFormatOggOpus, AudioConfig{Transcode: true, OpusBitrate: 32000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newOpusEncoder == nil && (format != AudioFormatWav || !bytes.Equal(audio, wav)) {
+		t.Errorf("format = %s, want WAV without an encoder", format)
+	}
+	if newOpusEncoder != nil && (format != AudioFormatOggOpus || !isOgg(audio)) {
+		t.Errorf("format = %s, want Ogg Opus", format)
+	}
+	if rate != inputSampleRate {
+		t.Errorf("sample rate = %d", rate)
+	}
+	if _, format, _, _ := encodeAudio(wav, AudioFormatOggOpus, AudioConfig{}); format != AudioFormatWav {
+		t.Errorf("format without transcode = %s, want WAV", format)
+	}
+
+	// Opus from TTS is passed through or remuxed, never turned into WAV
+	ogg := testOggOpus(3)
+	if audio, format, rate, _ := encodeAudio(ogg, AudioFormatOggOpus, AudioConfig{}); !bytes.Equal(audio, ogg) || format != AudioFormatOggOpus || rate != 24000 {
+		t.Errorf("Ogg passthrough = %s at %d", format, rate)
+	}
+	if _, _, _, err := encodeAudio(ogg, AudioFormatWav, AudioConfig{}); err == nil {
+		t.Error("Opus encoded as WAV")
+	}
+}
+
+func TestAudioDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		audio []byte
+		want  time.Duration
+	}{
+		{"wav", wrapWav(make([]byte, pcmBytes(1500*time.Millisecond)), inputSampleRate), 1500 * time.Millisecond},
+		{"ogg", testOggOpus(50), time.Second - opusPreSkip*time.Second/opusClockRate},
+		{"garbage", []byte("not audio"), 0},
+	}
+	for _, test := range tests {
+		if got := audioDuration(test.audio); got != test.want {
+			t.Errorf("%s duration = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
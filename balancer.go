@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	BalancingRoundRobin       = "round_robin"       // Take the replicas in turn
+	BalancingLeastOutstanding = "least_outstanding" // Take the replica with the fewest calls in flight
+)
+
+// affinityIdle is how long a session keeps its replica without calls
+const affinityIdle = 30 * time.Minute
+
+// sessionKey is the context key of the session a backend call belongs to
+type sessionKey struct{}
+
+// withSession marks ctx as belonging to session, so calls made with it can
+// stick to one replica
+func withSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// sessionFromContext returns the session ctx belongs to, if any
+func sessionFromContext(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// splitAddresses returns the replicas of a comma separated address list
+func splitAddresses(addresses string) []string {
+	var list []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			list = append(list, address)
+		}
+	}
+	return list
+}
+
+// endpoint is the address of a replica and the host name it was found
+// under, which TLS verifies the replica against
+type endpoint struct {
+	addr string
+	host string
+}
+
+// replica is one endpoint of a backend
+type replica[T any] struct {
+	addr         string
+	client       T
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+}
+
+// affinity is the replica a session is assigned to
+type affinity struct {
+	addr     string
+	lastUsed time.Time
+}
+
+// endpointPool spreads the calls of a backend over its replicas. Replicas
+// failing EjectAfter times in a row are skipped for EjectFor, and calls of a
+// session stick to one replica while it is healthy.
+type endpointPool[T any] struct {
+	name      string
+	config    BalancingConfig
+	targets   []string
+	dial      func(ep endpoint) (T, error)
+	close     func(client T)
+	healthy   func(client T) bool // Optional connectivity check
+	replicas  []*replica[T]
+	next      int
+	sessions  map[string]affinity
+	lastPrune time.Time
+	mutex     sync.Mutex
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newEndpointPool connects to the replicas listed in addresses
+func newEndpointPool[T any](name, addresses string, config BalancingConfig, dial func(ep endpoint) (T, error), close func(client T)) (*endpointPool[T], error) {
+	pool := &endpointPool[T]{
+		name:     name,
+		config:   config,
+		targets:  splitAddresses(addresses),
+		dial:     dial,
+		close:    close,
+		sessions: make(map[string]affinity),
+		stop:     make(chan struct{}),
+	}
+	if len(pool.targets) == 0 {
+		return nil, fmt.Errorf("no %s address", name)
+	}
+
+	pool.update(pool.resolve())
+	if len(pool.replicas) == 0 {
+		return nil, fmt.Errorf("failed to connect to any %s replica", name)
+	}
+
+	// Follow DNS changes
+	if config.Resolve && config.ResolveInterval > 0 {
+		go pool.refresh()
+	}
+	return pool, nil
+}
+
+// resolve returns the replica endpoints, one per IP of each host name when
+// resolving is enabled
+func (p *endpointPool[T]) resolve() []endpoint {
+	var endpoints []endpoint
+	for _, target := range p.targets {
+		host, withHost := splitTarget(target)
+		if !p.config.Resolve {
+			endpoints = append(endpoints, endpoint{addr: target, host: host})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to resolve %s replicas of %s: %v", p.name, target, err)
+			endpoints = append(endpoints, endpoint{addr: target, host: host})
+			continue
+		}
+		for _, ip := range ips {
+			endpoints = append(endpoints, endpoint{addr: withHost(ip), host: host})
+		}
+	}
+	return endpoints
+}
+
+// splitTarget returns the host of a host:port address or URL and a function
+// building the same target for another host
+func splitTarget(target string) (string, func(host string) string) {
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err == nil {
+			port := u.Port()
+			return u.Hostname(), func(host string) string {
+				rebuilt := *u
+				rebuilt.Host = host
+				if port != "" {
+					rebuilt.Host = net.JoinHostPort(host, port)
+				} else if strings.Contains(host, ":") {
+					rebuilt.Host = "[" + host + "]"
+				}
+				return rebuilt.String()
+			}
+		}
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, func(host string) string { return host }
+	}
+	return host, func(host string) string { return net.JoinHostPort(host, port) }
+}
+
+// update connects to new replica endpoints and drops the vanished ones
+func (p *endpointPool[T]) update(endpoints []endpoint) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, ep := range endpoints {
+		wanted[ep.addr] = true
+	}
+
+	kept := p.replicas[:0:0]
+	known := make(map[string]bool)
+	for _, r := range p.replicas {
+		if wanted[r.addr] {
+			kept = append(kept, r)
+			known[r.addr] = true
+			continue
+		}
+		// Give calls in flight time to finish
+		log.Printf("Removing %s replica %s", p.name, r.addr)
+		client := r.client
+		time.AfterFunc(time.Minute, func() { p.close(client) })
+	}
+	for _, ep := range endpoints {
+		if known[ep.addr] {
+			continue
+		}
+		known[ep.addr] = true
+		client, err := p.dial(ep)
+		if err != nil {
+			log.Printf("Failed to connect to %s replica %s: %v", p.name, ep.addr, err)
+			continue
+		}
+		if len(p.replicas) > 0 {
+			log.Printf("Adding %s replica %s", p.name, ep.addr)
+		}
+		kept = append(kept, &replica[T]{addr: ep.addr, client: client})
+	}
+	p.replicas = kept
+}
+
+// refresh re-resolves the replicas every ResolveInterval until the pool closes
+func (p *endpointPool[T]) refresh() {
+	ticker := time.NewTicker(p.config.ResolveInterval.Std())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.update(p.resolve())
+		}
+	}
+}
+
+// pick chooses the replica for a call and returns a function reporting its
+// outcome, which the caller must call once the call is over
+func (p *endpointPool[T]) pick(ctx context.Context) (T, func(err error), error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var zero T
+	if len(p.replicas) == 0 {
+		return zero, nil, fmt.Errorf("no %s replica available", p.name)
+	}
+
+	// Skip ejected and disconnected replicas unless none is left
+	now := time.Now()
+	var candidates []*replica[T]
+	for _, r := range p.replicas {
+		if now.Before(r.ejectedUntil) || (p.healthy != nil && !p.healthy(r.client)) {
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+	if len(candidates) == 0 {
+		candidates = p.replicas
+	}
+
+	chosen := p.choose(candidates)
+	if session := sessionFromContext(ctx); p.config.Sticky && session != "" {
+		chosen = p.stick(session, chosen, candidates, now)
+	}
+
+	chosen.outstanding++
+	var once sync.Once
+	done := func(err error) {
+		once.Do(func() { p.done(chosen, err) })
+	}
+	return chosen.client, done, nil
+}
+
+// choose applies the balancing policy to the candidates
+func (p *endpointPool[T]) choose(candidates []*replica[T]) *replica[T] {
+	start := p.next % len(candidates)
+	p.next++
+	if p.config.Policy != BalancingLeastOutstanding {
+		return candidates[start]
+	}
+
+	// Ties go to the next replica in turn
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		r := candidates[(start+i)%len(candidates)]
+		if r.outstanding < best.outstanding {
+			best = r
+		}
+	}
+	return best
+}
+
+// stick returns the replica assigned to session if it is a candidate, or
+// assigns chosen to it
+func (p *endpointPool[T]) stick(session string, chosen *replica[T], candidates []*replica[T], now time.Time) *replica[T] {
+	if assigned, ok := p.sessions[session]; ok {
+		for _, r := range candidates {
+			if r.addr == assigned.addr {
+				chosen = r
+				break
+			}
+		}
+	}
+	p.sessions[session] = affinity{addr: chosen.addr, lastUsed: now}
+
+	// Forget idle sessions now and then
+	if now.Sub(p.lastPrune) > time.Minute {
+		p.lastPrune = now
+		for key, entry := range p.sessions {
+			if now.Sub(entry.lastUsed) > affinityIdle {
+				delete(p.sessions, key)
+			}
+		}
+	}
+	return chosen
+}
+
+// done records the outcome of a call, ejecting replicas that keep failing
+func (p *endpointPool[T]) done(r *replica[T], err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	r.outstanding--
+	if !isReplicaFailure(err) {
+		r.failures = 0
+		return
+	}
+
+	r.failures++
+	if p.config.EjectAfter > 0 && r.failures >= p.config.EjectAfter && len(p.replicas) > 1 {
+		log.Printf("Ejecting %s replica %s for %s after %d failure(s): %v", p.name, r.addr, p.config.EjectFor.Std(), r.failures, err)
+		r.failures = 0
+		r.ejectedUntil = time.Now().Add(p.config.EjectFor.Std())
+	}
+}
+
+// isReplicaFailure reports whether err says the replica is unhealthy, as
+// opposed to a cancelled call or a request it rejected
+func isReplicaFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition,
+		codes.Unimplemented, codes.PermissionDenied, codes.Unauthenticated:
+		return false
+	}
+	return true
+}
+
+// each calls fn for every replica
+func (p *endpointPool[T]) each(fn func(client T)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, r := range p.replicas {
+		fn(r.client)
+	}
+}
+
+// Close stops following DNS and closes every replica
+func (p *endpointPool[T]) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.each(p.close)
+}
+
+// newGrpcPool connects to the replicas of a gRPC backend, over TLS unless
+// tlsConfig is nil
+func newGrpcPool(name, addresses string, config BalancingConfig, tlsConfig *tls.Config) (*endpointPool[*grpc.ClientConn], error) {
+	dial := func(ep endpoint) (*grpc.ClientConn, error) {
+		creds := insecure.NewCredentials()
+		if tlsConfig != nil {
+			creds = credentials.NewTLS(forHost(tlsConfig, ep.host))
+		}
+		conn, err := grpc.Dial(ep.addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		// Connect now so unreachable replicas are known before the first call
+		conn.Connect()
+		return conn, nil
+	}
+	closeConn := func(conn *grpc.ClientConn) { conn.Close() }
+
+	pool, err := newEndpointPool(name, addresses, config, dial, closeConn)
+	if err != nil {
+		return nil, err
+	}
+	pool.healthy = func(conn *grpc.ClientConn) bool {
+		return conn.GetState() != connectivity.TransientFailure
+	}
+	return pool, nil
+}
+
+// balancedLlmClient spreads LLM requests over the replicas of the LLM service
+type balancedLlmClient struct {
+	pool *endpointPool[LlmClient]
+}
+
+// NewBalancedLlmClient creates a LLM client for a comma separated list of
+// service URLs. A single URL gets a plain client. tlsConfig, if set, is used
+// for https URLs.
+func NewBalancedLlmClient(addresses string, config LlmConfig, balancing BalancingConfig, tlsConfig *tls.Config) (LlmClient, error) {
+	dial := func(ep endpoint) (LlmClient, error) {
+		client, err := NewLlmClient(ep.addr, config)
+		if err != nil || tlsConfig == nil {
+			return client, err
+		}
+		useTLS(client, forHost(tlsConfig, ep.host))
+		return client, nil
+	}
+	if targets := splitAddresses(addresses); len(targets) == 1 && !balancing.Resolve {
+		host, _ := splitTarget(targets[0])
+		return dial(endpoint{addr: targets[0], host: host})
+	}
+
+	pool, err := newEndpointPool("llm", addresses, balancing, dial, func(LlmClient) {})
+	if err != nil {
+		return nil, err
+	}
+	return &balancedLlmClient{pool: pool}, nil
+}
+
+// GetResponse sends the request to a replica, which counts as busy until
+// the response stream ends
+func (c *balancedLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	client, done, err := c.pool.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := client.GetResponse(ctx, messages, tools, options)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+
+	responseChan := make(chan LlmChunk)
+	go func() {
+		defer close(responseChan)
+		defer func() { done(ctx.Err()) }()
+
+		for chunk := range stream {
+			select {
+			case <-ctx.Done():
+				return
+			case responseChan <- chunk:
+			}
+		}
+	}()
+	return responseChan, nil
+}
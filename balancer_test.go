@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pb "assistant-app/grpc_modules"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testPool returns a pool whose clients are the replica addresses
+func testPool(t *testing.T, addresses string, config BalancingConfig) *endpointPool[string] {
+	t.Helper()
+	dial := func(ep endpoint) (string, error) {
+		if strings.HasPrefix(ep.addr, "down") {
+			return "", errors.New("connection refused")
+		}
+		return ep.addr, nil
+	}
+	pool, err := newEndpointPool("stt", addresses, config, dial, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// pickAll picks n replicas of pool with ctx, reporting err for each call
+func pickAll(t *testing.T, pool *endpointPool[string], ctx context.Context, n int, err error) []string {
+	t.Helper()
+	var picked []string
+	for i := 0; i < n; i++ {
+		client, done, pickErr := pool.pick(ctx)
+		if pickErr != nil {
+			t.Fatal(pickErr)
+		}
+		done(err)
+		picked = append(picked, client)
+	}
+	return picked
+}
+
+func TestSplitAddresses(t *testing.T) {
+	got := splitAddresses(" stt-1:50051, ,stt-2:50051,")
+	if len(got) != 2 || got[0] != "stt-1:50051" || got[1] != "stt-2:50051" {
+		t.Errorf("splitAddresses = %q", got)
+	}
+}
+
+func TestSplitTarget(t *testing.T) {
+	tests := []struct {
+		target, host, rebuilt string
+	}{
+		{"stt:50051", "stt", "10.0.0.1:50051"},
+		{"http://llm:8000/v1", "llm", "http://10.0.0.1:8000/v1"},
+		{"https://llm.example.com/v1", "llm.example.com", "https://10.0.0.1/v1"},
+		{"stt", "stt", "10.0.0.1"},
+	}
+	for _, test := range tests {
+		host, withHost := splitTarget(test.target)
+		if host != test.host || withHost("10.0.0.1") != test.rebuilt {
+			t.Errorf("splitTarget(%q) = %q, %q, want %q, %q", test.target, host, withHost("10.0.0.1"), test.host, test.rebuilt)
+		}
+	}
+	if _, withHost := splitTarget("http://llm/v1"); withHost("::1") != "http://[::1]/v1" {
+		t.Errorf("IPv6 rebuilt = %q", withHost("::1"))
+	}
+}
+
+func TestEndpointPoolRoundRobin(t *testing.T) {
+	pool := testPool(t, "a:1,down:1,b:1", BalancingConfig{Policy: BalancingRoundRobin})
+	if got := strings.Join(pickAll(t, pool, context.Background(), 4, nil), " "); got != "a:1 b:1 a:1 b:1" {
+		t.Errorf("picked %s, want the reachable replicas in turn", got)
+	}
+
+	if _, err := newEndpointPool("stt", "down:1", BalancingConfig{}, pool.dial, pool.close); err == nil {
+		t.Error("pool without any reachable replica")
+	}
+	if _, err := newEndpointPool("stt", " , ", BalancingConfig{}, pool.dial, pool.close); err == nil {
+		t.Error("pool without addresses")
+	}
+}
+
+func TestEndpointPoolLeastOutstanding(t *testing.T) {
+	pool := testPool(t, "a:1,b:1,c:1", BalancingConfig{Policy: BalancingLeastOutstanding})
+
+	// Calls in flight keep their replica busy
+	first, doneFirst, _ := pool.pick(context.Background())
+	second, doneSecond, _ := pool.pick(context.Background())
+	third, doneThird, _ := pool.pick(context.Background())
+	if first == second || second == third || first == third {
+		t.Fatalf("picked %s, %s and %s, want all three", first, second, third)
+	}
+	doneSecond(nil)
+	doneSecond(nil) // Reporting twice counts once
+	if next := pickAll(t, pool, context.Background(), 1, nil)[0]; next != second {
+		t.Errorf("picked %s, want the idle %s", next, second)
+	}
+	doneFirst(nil)
+	doneThird(nil)
+}
+
+func TestEndpointPoolEjection(t *testing.T) {
+	pool := testPool(t, "a:1,b:1", BalancingConfig{EjectAfter: 2, EjectFor: Duration(50 * time.Millisecond)})
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+
+	// Requests the replica rejected don't count
+	for i := 0; i < 4; i++ {
+		client, done, _ := pool.pick(context.Background())
+		if client == "a:1" {
+			done(status.Error(codes.InvalidArgument, "bad audio"))
+		} else {
+			done(nil)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		client, done, _ := pool.pick(context.Background())
+		if client == "a:1" {
+			done(unavailable)
+		} else {
+			done(nil)
+		}
+	}
+	if got := strings.Join(pickAll(t, pool, context.Background(), 3, nil), " "); got != "b:1 b:1 b:1" {
+		t.Errorf("picked %s, want a:1 ejected", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := pickAll(t, pool, context.Background(), 2, nil); got[0] == got[1] {
+		t.Errorf("picked %q, want a:1 back after eject_for", got)
+	}
+}
+
+func TestEndpointPoolKeepsLastReplica(t *testing.T) {
+	pool := testPool(t, "a:1", BalancingConfig{EjectAfter: 1, EjectFor: Duration(time.Minute)})
+	pickAll(t, pool, context.Background(), 3, errors.New("timeout"))
+	if got := pickAll(t, pool, context.Background(), 1, nil); got[0] != "a:1" {
+		t.Errorf("picked %q, want the only replica", got)
+	}
+}
+
+func TestEndpointPoolSticky(t *testing.T) {
+	pool := testPool(t, "a:1,b:1,c:1", BalancingConfig{Sticky: true, EjectAfter: 1, EjectFor: Duration(time.Minute)})
+	alice := withSession(context.Background(), "alice")
+	picked := pickAll(t, pool, alice, 5, nil)
+	for _, client := range picked {
+		if client != picked[0] {
+			t.Fatalf("picked %q, want one replica for the session", picked)
+		}
+	}
+
+	// Sessions move once their replica fails
+	pickAll(t, pool, alice, 1, errors.New("timeout"))
+	moved := pickAll(t, pool, alice, 3, nil)
+	if moved[0] == picked[0] || moved[1] != moved[0] || moved[2] != moved[0] {
+		t.Errorf("picked %q after %s failed, want another replica for the session", moved, picked[0])
+	}
+}
+
+func TestEndpointPoolUpdate(t *testing.T) {
+	pool := testPool(t, "a:1,b:1", BalancingConfig{})
+	pool.update([]endpoint{{addr: "b:1"}, {addr: "c:1"}, {addr: "down:1"}})
+
+	seen := make(map[string]bool)
+	for _, client := range pickAll(t, pool, context.Background(), 4, nil) {
+		seen[client] = true
+	}
+	if len(seen) != 2 || !seen["b:1"] || !seen["c:1"] {
+		t.Errorf("picked %v, want b:1 and c:1", seen)
+	}
+}
+
+func TestIsReplicaFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{fmt.Errorf("stream: %w", context.Canceled), false},
+		{status.Error(codes.Unauthenticated, "bad token"), false},
+		{status.Error(codes.Unavailable, "down"), true},
+		{status.Error(codes.DeadlineExceeded, "slow"), true},
+		{errors.New("connection refused"), true},
+	}
+	for _, test := range tests {
+		if got := isReplicaFailure(test.err); got != test.want {
+			t.Errorf("isReplicaFailure(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestBalancedLlmClient(t *testing.T) {
+	busy := make(map[string]int)
+	replica := func(name string) LlmClient {
+		return llmClientFunc(func(ctx context.Context, options LlmOptions) (chan LlmChunk, error) {
+			busy[name]++
+			return streamAfter(ctx, 0, name), nil
+		})
+	}
+	clients := map[string]LlmClient{"http://a": replica("a"), "http://b": replica("b")}
+	dial := func(ep endpoint) (LlmClient, error) { return clients[ep.addr], nil }
+	pool, err := newEndpointPool("llm", "http://a,http://b", BalancingConfig{Policy: BalancingLeastOutstanding}, dial, func(LlmClient) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client := &balancedLlmClient{pool: pool}
+
+	// The first replica is busy until its stream is read
+	first, _ := client.GetResponse(context.Background(), nil, nil, LlmOptions{})
+	second, _ := client.GetResponse(context.Background(), nil, nil, LlmOptions{})
+	if a, b := readStream(first), readStream(second); a == b {
+		t.Errorf("answers %q and %q, want both replicas", a, b)
+	}
+	if busy["a"] != 1 || busy["b"] != 1 {
+		t.Errorf("calls = %v", busy)
+	}
+}
+
+// failingVadStream is a VAD audio stream whose responses fail with err
+type failingVadStream struct {
+	pb.VADService_ProcessAudioClient
+	err error
+}
+
+func (s *failingVadStream) Recv() (*pb.VADResponse, error) { return nil, s.err }
+
+func TestVadStreamFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failed := &failingVadStream{err: errors.New("replica gone")}
+	c := &VadClientImpl{ctx: ctx, stream: failed, eventChan: make(chan VadEvent, 1)}
+
+	// A failed stream is dropped so the next chunk reconnects
+	var reported error
+	c.receiveResponses(failed, func(err error) { reported = err })
+	if c.stream != nil || reported == nil {
+		t.Errorf("stream %v, reported %v after the failure", c.stream, reported)
+	}
+
+	// A stream opened on another replica in the meantime is kept
+	replacement := &failingVadStream{}
+	c.stream = replacement
+	c.receiveResponses(failed, func(error) {})
+	if c.stream != replacement {
+		t.Error("replacement stream dropped for the failure of the old one")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls go through
+	BreakerOpen     BreakerState = "open"      // Calls fail fast
+	BreakerHalfOpen BreakerState = "half-open" // Probe calls test the backend
+)
+
+// ErrBreakerOpen is returned for calls refused by an open circuit breaker
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops calling a failing backend. After FailureThreshold
+// consecutive failures it opens and refuses calls for OpenFor, then lets
+// HalfOpenProbes calls through: a success closes it, a failure opens it again.
+type CircuitBreaker struct {
+	name      string
+	config    BreakerConfig
+	state     BreakerState
+	failures  int
+	probes    int
+	openedAt  time.Time
+	lastError string
+	mutex     sync.Mutex
+}
+
+// BreakerStatus describes a circuit breaker on the health endpoint
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	LastError string       `json:"last_error,omitempty"`
+	OpenedAt  *time.Time   `json:"opened_at,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:   name,
+		config: config,
+		state:  BreakerClosed,
+	}
+}
+
+// Do runs call with the configured timeout unless the breaker refuses it,
+// and records the outcome
+func (b *CircuitBreaker) Do(ctx context.Context, call func(ctx context.Context) error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	callCtx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	err := call(callCtx)
+	b.record(ctx, err)
+	return err
+}
+
+// withTimeout derives the context of a call
+func (b *CircuitBreaker) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, b.config.Timeout.Std())
+}
+
+// allow reserves a call, moving an open breaker to half-open once OpenFor
+// has passed
+func (b *CircuitBreaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.config.FailureThreshold == 0 {
+		return nil
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.OpenFor.Std() {
+			return fmt.Errorf("%s: %w", b.name, ErrBreakerOpen)
+		}
+		log.Printf("Circuit breaker %s half-open, probing", b.name)
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenProbes {
+			return fmt.Errorf("%s: %w", b.name, ErrBreakerOpen)
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// record counts the outcome of an allowed call. Calls the caller cancelled
+// say nothing about the backend and are not counted.
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.config.FailureThreshold == 0 {
+		return
+	}
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+	case err == nil:
+		if b.state != BreakerClosed {
+			log.Printf("Circuit breaker %s closed", b.name)
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		b.lastError = ""
+	default:
+		b.failures++
+		b.lastError = err.Error()
+		if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.config.FailureThreshold) {
+			log.Printf("Circuit breaker %s opened after %d failure(s): %v", b.name, b.failures, err)
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+}
+
+// State returns the current state, reporting an open breaker whose OpenFor
+// has passed as half-open
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.stateLocked()
+}
+
+func (b *CircuitBreaker) stateLocked() BreakerState {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.config.OpenFor.Std() {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Status describes the breaker for the health endpoint
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := BreakerStatus{
+		State:     b.stateLocked(),
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
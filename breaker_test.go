@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errBackend = errors.New("backend down")
+
+// breakerCall runs a call through b that returns err
+func breakerCall(b *CircuitBreaker, err error) error {
+	return b.Do(context.Background(), func(ctx context.Context) error { return err })
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	b := NewCircuitBreaker("stt", BreakerConfig{FailureThreshold: 2, OpenFor: Duration(50 * time.Millisecond), HalfOpenProbes: 1})
+
+	// Successes reset the count of consecutive failures
+	breakerCall(b, errBackend)
+	breakerCall(b, nil)
+	breakerCall(b, errBackend)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s, want closed below the threshold", b.State())
+	}
+	breakerCall(b, errBackend)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open", b.State())
+	}
+	status := b.Status()
+	if status.Failures != 2 || status.LastError != "backend down" || status.OpenedAt == nil {
+		t.Errorf("status = %+v", status)
+	}
+
+	// Open breakers fail fast
+	called := false
+	err := b.Do(context.Background(), func(ctx context.Context) error { called = true; return nil })
+	if !errors.Is(err, ErrBreakerOpen) || called {
+		t.Fatalf("call while open: err %v, called %v", err, called)
+	}
+
+	// A failed probe opens it again, a successful one closes it
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half-open after open_for", b.State())
+	}
+	breakerCall(b, errBackend)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open after a failed probe", b.State())
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := breakerCall(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if status := b.Status(); status.State != BreakerClosed || status.Failures != 0 || status.OpenedAt != nil {
+		t.Errorf("status after recovery = %+v", status)
+	}
+}
+
+func TestCircuitBreakerLimitsProbes(t *testing.T) {
+	b := NewCircuitBreaker("llm", BreakerConfig{FailureThreshold: 1, OpenFor: Duration(time.Millisecond), HalfOpenProbes: 1})
+	breakerCall(b, errBackend)
+	time.Sleep(5 * time.Millisecond)
+
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	go b.Do(context.Background(), func(ctx context.Context) error {
+		close(probing)
+		<-release
+		return nil
+	})
+	<-probing
+	if err := breakerCall(b, nil); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("second probe err = %v, want refused", err)
+	}
+	close(release)
+}
+
+func TestCircuitBreakerIgnoresCancelledCalls(t *testing.T) {
+	b := NewCircuitBreaker("tts", BreakerConfig{FailureThreshold: 1, OpenFor: Duration(time.Minute), HalfOpenProbes: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Do(ctx, func(ctx context.Context) error { return ctx.Err() })
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s, want closed after a cancelled call", b.State())
+	}
+}
+
+func TestCircuitBreakerTimeout(t *testing.T) {
+	b := NewCircuitBreaker("stt", BreakerConfig{Timeout: Duration(20 * time.Millisecond), FailureThreshold: 1, OpenFor: Duration(time.Minute), HalfOpenProbes: 1})
+	err := b.Do(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || b.State() != BreakerOpen {
+		t.Errorf("err %v, state %s, want a timeout opening the breaker", err, b.State())
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker("trigger", BreakerConfig{})
+	for i := 0; i < 10; i++ {
+		breakerCall(b, errBackend)
+	}
+	if err := breakerCall(b, nil); err != nil || b.State() != BreakerClosed {
+		t.Errorf("err %v, state %s, want a disabled breaker to stay closed", err, b.State())
+	}
+}
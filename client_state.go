@@ -1,31 +1,76 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Transport carries the messages of a session: a WebSocket, or the data
+// channel and audio tracks of a WebRTC peer connection. Message types are the
+// WebSocket ones.
+type Transport interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	RemoteAddr() net.Addr
+	Close() error
+}
+
 // ClientState represents the state of a client connection
 type ClientState struct {
-	conn             *websocket.Conn
+	conn             Transport
 	app              *App
+	userID           string
+	sessionID        string
+	connectedAt      time.Time
+	turns            int               // Turns started in this session, guarded by stateMutex
+	currentTurn      *LiveTurn         // Turn in progress, guarded by stateMutex
+	lastLatency      *LatencyBreakdown // Latency of the last finished turn, guarded by stateMutex
+	lastError        string            // Error of the last finished turn, guarded by stateMutex
 	state            State
 	stateMutex       sync.Mutex
-	cancelFuncs      map[string]context.CancelFunc
-	cancelMutex      sync.Mutex
+	turn             *turnContext // Root context of the turn in progress, guarded by stateMutex
 	transcript       string
-	vadActive        bool
+	vadActive        atomic.Bool // Written by the VAD event loop, read by wake word detection
 	triggered        bool
 	audioBuffer      [][]byte
+	utterance        *utteranceStream // Streams audioBuffer to STT, guarded by audioBufferMutex
 	audioBufferMutex sync.Mutex
+	speculation      *speculation // LLM response requested before the user finished speaking
+	speculationMutex sync.Mutex
 	closed           bool
+	timers           map[*time.Timer]bool // Pending tool timers, guarded by closeMutex
 	closeMutex       sync.Mutex
+	writeMutex       sync.Mutex
+	wakeWord         WakeWordConfig // Wake word of the current turn, guarded by stateMutex
+	wakeWindow       []byte         // Recent idle audio for wake word detection
+	wakeUnchecked    int            // Bytes added to wakeWindow since the last check
+	wakeDetecting    bool
+	wakeMutex        sync.Mutex
+	events           []RecordedEvent // Pipeline events for the next recording
+	eventsMutex      sync.Mutex
+	preferences      SessionPreferences
+	language         string // Language of the last turn, guarded by preferencesMutex
+	preferencesMutex sync.Mutex
+	feed             eventFeed   // Live events for admin watchers
+	audio            AudioInfo   // Format of the speech sent to the client, set on connect
+	mic              MicInfo     // Format of the microphone audio, set on connect
+	micDecoder       *micDecoder // Decodes Opus microphone audio, nil for PCM
+	micFailures      int         // Microphone packets that failed to decode, used by the read loop only
+	micSwitch        string      // Microphone format the client was told to switch to, until it acknowledges
+	echo             echoDetector
+	playback         playbackTracker
 }
 
 // State represents the possible states of the client
@@ -38,6 +83,7 @@ const (
 	StateProcessing   State = "PROCESSING"
 	StateSpeaking     State = "SPEAKING"
 	StateError        State = "ERROR"
+	StateDraining     State = "DRAINING"
 	StateDisconnected State = "DISCONNECTED"
 )
 
@@ -50,9 +96,14 @@ type StatusMessage struct {
 
 // TranscriptMessage represents a transcript update to send to the client
 type TranscriptMessage struct {
-	Type    string `json:"type"`
-	Text    string `json:"text"`
-	IsFinal bool   `json:"isFinal"`
+	Type       string     `json:"type"`
+	Text       string     `json:"text"`
+	IsFinal    bool       `json:"isFinal"`
+	Language   string     `json:"language,omitempty"`
+	Confidence float64    `json:"confidence,omitempty"` // With stt.words
+	Words      []WordInfo `json:"words,omitempty"`      // With stt.words
+
+	Alternatives []Alternative `json:"alternatives,omitempty"` // Less likely transcripts, with confirmation enabled
 }
 
 // ResponseMessage represents an LLM response to send to the client
@@ -61,21 +112,35 @@ type ResponseMessage struct {
 	Text string `json:"text"`
 }
 
+// ConfigMessage reports the effective session settings, or why a configure
+// command was rejected, to the client
+type ConfigMessage struct {
+	Type     string           `json:"type"`
+	User     string           `json:"user"`
+	Session  string           `json:"session"`
+	Settings *SessionSettings `json:"settings,omitempty"`
+	Personas []PersonaInfo    `json:"personas,omitempty"`
+	Audio    *AudioInfo       `json:"audio,omitempty"`
+	Mic      *MicInfo         `json:"mic,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
 // NewClientState creates a new client state
-func NewClientState(conn *websocket.Conn, app *App) *ClientState {
+func NewClientState(conn Transport, app *App, userID string) *ClientState {
 	return &ClientState{
 		conn:        conn,
 		app:         app,
+		userID:      userID,
+		sessionID:   newSessionID(),
+		connectedAt: time.Now(),
 		state:       StateIdle,
-		cancelFuncs: make(map[string]context.CancelFunc),
 		audioBuffer: make([][]byte, 0),
 		closed:      false,
+		audio:       AudioInfo{Format: AudioFormatWav, SampleRate: ttsSampleRate, Channels: 1},
+		mic:         MicInfo{Format: MicFormatPCM, SampleRate: inputSampleRate},
 	}
 }
 
-// handleClient handles the WebSocket connection for a client
-// Update in handleClient in client_state.go
-
 // handleClient handles the WebSocket connection for a client
 func (cs *ClientState) handleClient() {
 	defer func() {
@@ -83,8 +148,9 @@ func (cs *ClientState) handleClient() {
 		log.Println("Client disconnected")
 	}()
 
-	// Send initial status
+	// Send initial status and session settings
 	cs.sendStatus(StateIdle, "Ready")
+	cs.sendConfig("")
 
 	// Start processing VAD events
 	cs.startProcessingVadEvents()
@@ -111,17 +177,78 @@ func (cs *ClientState) handleClient() {
 	}
 }
 
+// micDecodeFailed reports a microphone packet that failed to decode. The
+// first failure is logged and sent to the client; WebSocket clients are also
+// told to switch back to PCM, while on WebRTC the track stays Opus. Failing
+// packets are dropped, on WebSocket until the client acknowledges the switch.
+func (cs *ClientState) micDecodeFailed(err error) {
+	cs.micFailures++
+	if cs.micFailures > 1 {
+		logf(LogDebug, "Dropping microphone packet of session %s: %v", cs.sessionID, err)
+		return
+	}
+
+	logf(LogWarn, "Failed to decode microphone audio of session %s: %v", cs.sessionID, err)
+	cs.recordEvent("mic", "error", err.Error())
+	if _, ok := cs.conn.(speechTrack); !ok {
+		cs.micSwitch = MicFormatPCM
+	}
+	cs.sendConfig("microphone audio could not be decoded: " + err.Error())
+}
+
+// handleMic switches the microphone format once the client acknowledges the
+// format it was told to send. Packets sent before the acknowledgement are
+// still decoded in the old format.
+func (cs *ClientState) handleMic(command string) {
+	var ack struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal([]byte(command), &ack); err != nil || cs.micSwitch == "" || ack.Format != cs.micSwitch {
+		return
+	}
+
+	cs.mic.Format = cs.micSwitch
+	cs.micSwitch = ""
+	if cs.mic.Format == MicFormatPCM {
+		cs.micDecoder = nil
+	}
+	logf(LogDebug, "Session %s switched to %s microphone audio", cs.sessionID, cs.mic.Format)
+}
+
 // handleAudioData processes incoming audio data
 func (cs *ClientState) handleAudioData(audioData []byte) {
+	// Decode Opus packets to PCM
+	if cs.micDecoder != nil {
+		pcm, err := cs.micDecoder.decode(audioData)
+		if err != nil {
+			cs.micDecodeFailed(err)
+			return
+		}
+		audioData = pcm
+	}
+
 	// Make a copy of the audio data
 	dataCopy := make([]byte, len(audioData))
 	copy(dataCopy, audioData)
 
-	// Store audio in buffer for STT if needed
+	// Keep the assistant's own voice away from the VAD and wake words
+	if config := cs.app.currentConfig(); cs.echoSuppression(config) {
+		cs.suppressEcho(dataCopy, config.Echo)
+	}
+
+	// Store audio in buffer for STT if needed, up to the utterance limit
 	if cs.getState() == StateTriggered {
+		maxChunks := cs.app.currentConfig().Limits.MaxUtteranceChunks
 		cs.audioBufferMutex.Lock()
-		cs.audioBuffer = append(cs.audioBuffer, dataCopy)
+		if maxChunks == 0 || len(cs.audioBuffer) < maxChunks {
+			cs.audioBuffer = append(cs.audioBuffer, dataCopy)
+			if cs.utterance != nil {
+				cs.utterance.send(dataCopy)
+			}
+		}
 		cs.audioBufferMutex.Unlock()
+	} else if cs.getState() == StateIdle {
+		cs.feedWakeWordDetector(dataCopy, false)
 	}
 
 	// Always send audio to VAD
@@ -135,23 +262,73 @@ func (cs *ClientState) handleAudioData(audioData []byte) {
 
 // handleTextCommand processes text commands from the client
 func (cs *ClientState) handleTextCommand(command string) {
-	var cmd map[string]string
+	var cmd struct {
+		Action string `json:"action"`
+	}
 	err := json.Unmarshal([]byte(command), &cmd)
 	if err != nil {
 		log.Printf("Invalid command format: %v", err)
 		return
 	}
 
-	switch cmd["action"] {
+	switch cmd.Action {
 	case "reset":
 		cs.resetState()
 	case "stop":
-		cs.cancelAllOperations()
-		cs.resetState()
+		cs.stopTurn()
+	case "configure":
+		cs.handleConfigure(command)
+	case "playback":
+		cs.handlePlayback(command)
+	case "mic":
+		cs.handleMic(command)
 	}
 }
 
-// Add to client_state.go
+// handleConfigure validates and stores the session preferences sent with a
+// configure command. Preferences not mentioned in the command are reset.
+func (cs *ClientState) handleConfigure(command string) {
+	var prefs SessionPreferences
+	if err := json.Unmarshal([]byte(command), &prefs); err != nil {
+		cs.sendConfig(fmt.Sprintf("invalid configure command: %v", err))
+		return
+	}
+
+	if err := cs.app.currentConfig().validatePreferences(prefs); err != nil {
+		cs.sendConfig(err.Error())
+		return
+	}
+
+	cs.preferencesMutex.Lock()
+	cs.preferences = prefs
+	cs.language = ""
+	cs.preferencesMutex.Unlock()
+
+	cs.sendConfig("")
+}
+
+// sessionSettings resolves the session preferences against config
+func (cs *ClientState) sessionSettings(config AppConfig) SessionSettings {
+	cs.preferencesMutex.Lock()
+	prefs := cs.preferences
+	cs.preferencesMutex.Unlock()
+
+	return config.resolveSession(prefs)
+}
+
+// setLanguage records the language of the current turn
+func (cs *ClientState) setLanguage(language string) {
+	cs.preferencesMutex.Lock()
+	defer cs.preferencesMutex.Unlock()
+	cs.language = language
+}
+
+// getLanguage returns the language of the last turn
+func (cs *ClientState) getLanguage() string {
+	cs.preferencesMutex.Lock()
+	defer cs.preferencesMutex.Unlock()
+	return cs.language
+}
 
 // startProcessingVadEvents starts processing VAD events
 func (cs *ClientState) startProcessingVadEvents() {
@@ -177,202 +354,347 @@ func (cs *ClientState) startProcessingVadEvents() {
 				// Process the VAD event
 				switch event.Type {
 				case "start":
-					cs.vadActive = true
+					cs.vadActive.Store(true)
 					log.Printf("VAD event: Speech started - %s", event.Message)
-
-					// If we're in IDLE state, check for trigger
-					if cs.getState() == StateIdle {
-						// This is where we would trigger wake word detection
-						// For now, let's just simulate a trigger with a probability
-						if cs.app.triggerClient != nil && cs.app.triggerClient.IsTriggered(nil) {
-							cs.triggered = true
-							cs.setState(StateTriggered)
-							cs.sendStatus(StateTriggered, "Listening to you...")
-
-							// Clear the audio buffer to start fresh
-							cs.audioBufferMutex.Lock()
-							cs.audioBuffer = make([][]byte, 0)
-							cs.audioBufferMutex.Unlock()
-						}
-					}
+					cs.recordEvent("vad", "start", event.Message)
+					// Wake word checks start in handleAudioData while the user speaks
 
 				case "end":
-					cs.vadActive = false
+					cs.vadActive.Store(false)
 					log.Printf("VAD event: Speech ended - %s", event.Message)
-
-					// If we're in TRIGGERED state, process the collected audio
-					if cs.getState() == StateTriggered {
+					cs.recordEvent("vad", "end", event.Message)
+
+					switch cs.getState() {
+					case StateIdle:
+						// Check the whole utterance for a wake word said on its own
+						cs.feedWakeWordDetector(nil, true)
+					case StateTriggered:
+						// A wake word followed by a pause keeps listening for the request
+						if cs.bufferedAudioBytes() < pcmBytes(cs.app.currentConfig().Trigger.MinCommand.Std()) {
+							logf(LogDebug, "Utterance after wake word too short, still listening")
+							break
+						}
+						// Process the collected audio
 						go cs.processAudio()
 					}
 
 				case "continue":
 					// Just log for debugging
-					log.Printf("VAD event: Speech continuing - %s", event.Message)
+					logf(LogDebug, "VAD event: Speech continuing - %s", event.Message)
 				}
 			}
 		}
 	}()
 }
 
-// startVadTriggerDetection starts the parallel VAD/Trigger detection process
-func (cs *ClientState) startVadTriggerDetection() {
-	ctx, cancel := context.WithCancel(context.Background())
-	cs.addCancelFunc("vadTrigger", cancel)
-
-	go func() {
-		defer cs.removeCancelFunc("vadTrigger")
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				// Check if we have audio data to process
-				cs.audioBufferMutex.Lock()
-				if len(cs.audioBuffer) == 0 {
-					cs.audioBufferMutex.Unlock()
-					time.Sleep(10 * time.Millisecond)
-					continue
-				}
-
-				// Get the oldest audio chunk
-				audioChunk := cs.audioBuffer[0]
-				cs.audioBuffer = cs.audioBuffer[1:]
-				cs.audioBufferMutex.Unlock()
+// bufferedAudioBytes returns the size of the audio collected for STT
+func (cs *ClientState) bufferedAudioBytes() int {
+	cs.audioBufferMutex.Lock()
+	defer cs.audioBufferMutex.Unlock()
 
-				// Process the audio chunk
-				cs.processVadTrigger(audioChunk)
-			}
-		}
-	}()
+	size := 0
+	for _, chunk := range cs.audioBuffer {
+		size += len(chunk)
+	}
+	return size
 }
 
-// processVadTrigger processes audio for VAD and trigger detection
-func (cs *ClientState) processVadTrigger(audioData []byte) {
-	currentState := cs.getState()
-
-	// Skip trigger detection if we're already in a triggered state or beyond
-	if currentState == StateTriggered || currentState == StateProcessing || currentState == StateSpeaking {
+// processAudio processes the collected audio with STT and LLM
+func (cs *ClientState) processAudio() {
+	// The turn may have been stopped while the user was speaking
+	turn := cs.activeTurn()
+	if turn == nil {
 		return
 	}
 
-	// Check voice activity
-	if cs.app.vadClient != nil {
-		isActive := cs.app.vadClient.IsActive(audioData)
-
-		// Update VAD status if changed
-		if isActive != cs.vadActive {
-			cs.vadActive = isActive
-
-			if isActive {
-				// Voice activity detected, now check for trigger
-				if cs.app.triggerClient != nil {
-					isTriggered := cs.app.triggerClient.IsTriggered(audioData)
-					if isTriggered {
-						// Wake word detected
-						cs.triggered = true
-						cs.setState(StateTriggered)
-						cs.sendStatus(StateTriggered, "Listening to you...")
-
-						// Clear the audio buffer to start fresh
-						cs.audioBufferMutex.Lock()
-						cs.audioBuffer = make([][]byte, 0)
-						cs.audioBufferMutex.Unlock()
-					}
-				}
-			}
+	// Settings are read once so a reload never changes a turn halfway
+	config := cs.app.currentConfig()
+	settings := cs.turnSettings(config)
+
+	// Record the turn for the conversation history
+	record := cs.startTurn(settings)
+	defer cs.finishTurn(config, settings, record)
+
+	// However the turn ends, the session is left idle unless it listens for
+	// the answer to a question. A turn cancelled by anything but a stop is
+	// cancelled here.
+	listening := false
+	defer func() {
+		if !listening {
+			cs.cancelTurn(turn)
 		}
-	}
-}
+		if stage, ok := turn.cancelledIn(); ok {
+			record.Error = "cancelled during " + stage
+		}
+	}()
 
-// processAudio processes the collected audio with STT and LLM
-func (cs *ClientState) processAudio() {
 	// Change state to processing
-	cs.setState(StateProcessing)
-	cs.sendStatus(StateProcessing, "Processing your request...")
-
-	// Create context for the operation
-	ctx, cancel := context.WithCancel(context.Background())
-	cs.addCancelFunc("processing", cancel)
-	defer cs.removeCancelFunc("processing")
+	if !cs.advanceTurn(turn, StateProcessing, "Processing your request...") {
+		return
+	}
 
-	// Get the audio buffer
+	// Get the audio buffer and its stream
 	cs.audioBufferMutex.Lock()
 	audioBuffer := cs.audioBuffer
+	utterance := cs.utterance
 	cs.audioBuffer = make([][]byte, 0) // Clear the buffer
+	cs.utterance = nil
 	cs.audioBufferMutex.Unlock()
+	if record.recording {
+		record.utterance = bytes.Join(audioBuffer, nil)
+	}
 
 	// Transcribe the audio
 	if cs.app.sttClient == nil {
-		cs.sendStatus(StateError, "STT service unavailable")
-		cs.setState(StateIdle)
+		if utterance != nil {
+			utterance.cancel()
+		}
+		record.Error = "STT service unavailable"
+		cs.failTurn(turn, config, settings.Voice, "STT service unavailable")
 		return
 	}
 
-	transcript, err := cs.app.sttClient.Transcribe(ctx, audioBuffer)
+	sttCtx, endStt := turn.enter(StageStt)
+	sttStart := time.Now()
+	transcription, err := cs.transcribe(sttCtx, config, audioBuffer, utterance, settings.sttLanguageHint())
+	record.Latency.SttMs = msSince(sttStart)
+	endStt()
+	if turn.ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		log.Printf("STT error: %v", err)
-		cs.sendStatus(StateError, "Failed to transcribe audio")
-		cs.setState(StateIdle)
+		record.Error = err.Error()
+		cs.recordEvent("stt", "error", err.Error())
+		cs.failTurn(turn, config, settings.Voice, "Failed to transcribe audio")
 		return
 	}
 
+	// An answer to a question about the last transcript stands for the
+	// request it settles
+	heard := transcription
+	pending, asked := turn.takeQuestion()
+	transcription, answer, question := config.Confirmation.settle(pending, heard, asked)
+
+	// Answer in the language the user spoke
+	language := config.applyLanguage(&settings, transcription.LanguageCode)
+	cs.setLanguage(language)
+
 	// Send the transcript to the client
+	transcript := transcription.Text
 	cs.transcript = transcript
-	cs.sendTranscript(transcript, true)
+	cs.sendTranscript(heard, language, true, config.Stt)
+	record.Transcript = transcript
+	record.Language = language
+	record.Confidence = heard.Confidence
+	record.Answer = answer
+	detail := fmt.Sprintf("%q (%s)", heard.Text, heard.LanguageCode)
+	if heard.Confidence > 0 {
+		detail += fmt.Sprintf(" confidence %.2f", heard.Confidence)
+	}
+	cs.recordEvent("stt", "final", detail)
+	if pending != nil {
+		cs.recordEvent("confirmation", answer, fmt.Sprintf("%q", heard.Text))
+		cs.app.metrics.inc(metricAnswers, answer)
+	}
+
+	// Check an uncertain request before answering it
+	if question != nil {
+		listening = cs.askQuestion(turn, config, settings.Voice, record, question)
+		return
+	}
+	if answer == AnswerRejected {
+		cs.completeTurn(turn)
+		return
+	}
 
 	// Send the transcript to the LLM service
 	if cs.app.llmClient == nil {
-		cs.sendStatus(StateError, "LLM service unavailable")
-		cs.setState(StateIdle)
+		record.Error = "LLM service unavailable"
+		cs.failTurn(turn, config, settings.Voice, "LLM service unavailable")
 		return
 	}
 
-	responseStream, err := cs.app.llmClient.GetResponse(ctx, transcript)
-	if err != nil {
-		log.Printf("LLM error: %v", err)
-		cs.sendStatus(StateError, "Failed to get AI response")
-		cs.setState(StateIdle)
+	messages, tools := cs.turnRequest(config, settings, transcript)
+
+	// The LLM call gets its own context so it can be cut off at the response limit
+	llmCtx, endLlm := turn.enter(StageLlm)
+	defer endLlm()
+	llmCtx, cancelLlm := context.WithCancel(llmCtx)
+	defer cancelLlm()
+	record.llmStart = time.Now()
+
+	// A response requested for the same transcript while the user spoke
+	// takes the place of the first request
+	spec := cs.takeSpeculation(llmCtx, transcript, language)
+	if spec != nil {
+		context.AfterFunc(llmCtx, spec.cancel)
+		record.llmStart = spec.startedAt
+	}
+
+	// Each round streams one LLM response. Rounds that end in tool calls run
+	// the tools and continue the conversation with their results.
+	for round := 0; ; round++ {
+		// Stop offering tools once the round limit is reached so the model has to answer
+		offeredTools := tools
+		if round >= config.Tools.MaxRounds {
+			offeredTools = nil
+		}
+
+		var responseStream chan LlmChunk
+		if round == 0 && spec != nil {
+			responseStream = spec.stream
+		} else {
+			responseStream, err = cs.app.llmClient.GetResponse(llmCtx, messages, offeredTools, settings.Llm)
+		}
+		if turn.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("LLM error: %v", err)
+			record.Error = err.Error()
+			cs.recordEvent("llm", "error", err.Error())
+			cs.failTurn(turn, config, settings.Voice, "Failed to get AI response")
+			return
+		}
+
+		// Change state to speaking, in text only while TTS is down
+		if cs.getState() != StateSpeaking {
+			detail := "Speaking..."
+			if cs.app.ttsUnavailable() {
+				detail = "Speech unavailable, replying in text"
+			}
+			if !cs.advanceTurn(turn, StateSpeaking, detail) {
+				return
+			}
+		}
+
+		reply, toolCalls, ok := cs.streamReply(turn, cancelLlm, config, settings.Voice, responseStream, record)
+		record.addResponse(reply)
+		record.Latency.LlmMs = msSince(record.llmStart)
+		if !ok {
+			return
+		}
+		if len(toolCalls) == 0 || offeredTools == nil {
+			break
+		}
+
+		messages = append(messages, ChatMessage{Role: "assistant", Content: reply, ToolCalls: toolCalls})
+		toolsCtx, endTools := turn.enter(StageTools)
+		messages = append(messages, cs.runTools(toolsCtx, config, settings, toolCalls)...)
+		endTools()
+		if turn.ctx.Err() != nil {
+			return
+		}
+	}
+	endLlm()
+
+	// Stay speaking until the client has played the reply
+	playbackCtx, endPlayback := turn.enter(StagePlayback)
+	cs.waitForPlayback(playbackCtx, config)
+	endPlayback()
+	if turn.ctx.Err() != nil {
 		return
 	}
 
-	// Change state to speaking
-	cs.setState(StateSpeaking)
-	cs.sendStatus(StateSpeaking, "Speaking...")
+	// Reset state to idle
+	cs.completeTurn(turn)
+}
+
+// turnRequest returns the messages and tools of the first LLM request of a
+// turn for transcript
+func (cs *ClientState) turnRequest(config AppConfig, settings SessionSettings, transcript string) ([]ChatMessage, []ToolDefinition) {
+	messages := []ChatMessage{{Role: "user", Content: transcript}}
+	if settings.SystemPrompt != "" {
+		messages = append([]ChatMessage{{Role: "system", Content: settings.SystemPrompt}}, messages...)
+	}
+
+	var tools []ToolDefinition
+	if config.Tools.Enabled {
+		tools = cs.app.tools.Definitions(settings.Tools)
+	}
+	return messages, tools
+}
+
+// streamReply reads one streamed LLM response, sending the text to the client
+// and each complete sentence to TTS. It returns the text and the tool calls
+// of the response, and false if the turn was cancelled.
+func (cs *ClientState) streamReply(turn *turnContext, cancelLlm context.CancelFunc, config AppConfig, voice VoiceConfig, responseStream chan LlmChunk, record *TurnRecord) (string, []ToolCall, bool) {
+	// speak synthesizes a sentence and notes when the first audio went out
+	speak := func(sentence string) {
+		ttsCtx, endTts := turn.enter(StageTts)
+		defer endTts()
+		audio := cs.synthesizeAndSend(ttsCtx, sentence, voice)
+		if audio == nil {
+			return
+		}
+		if record.Latency.FirstAudioMs == 0 {
+			record.Latency.FirstAudioMs = msSince(record.StartedAt)
+		}
+		record.addReplyAudio(audio)
+	}
 
-	// Process the streaming response
 	var fullResponse string
 	var currentSentence string
+	var toolCalls []ToolCall
+	truncated := false
 	for {
+		// A chunk may be ready when the turn is cancelled
+		if turn.ctx.Err() != nil {
+			return fullResponse, nil, false
+		}
 		select {
-		case <-ctx.Done():
-			return
-		case resp, ok := <-responseStream:
+		case <-turn.ctx.Done():
+			return fullResponse, nil, false
+		case chunk, ok := <-responseStream:
 			if !ok {
 				// End of stream, synthesize last sentence if any
 				if currentSentence != "" {
-					cs.synthesizeAndSend(ctx, currentSentence)
+					speak(currentSentence)
 				}
-				// Reset state to idle
-				cs.setState(StateIdle)
-				cs.sendStatus(StateIdle, "Ready")
-				return
+				if truncated {
+					toolCalls = nil
+				}
+				return fullResponse, toolCalls, turn.ctx.Err() == nil
+			}
+
+			if record.Latency.LlmFirstMs == 0 {
+				record.Latency.LlmFirstMs = msSince(record.llmStart)
+			}
+
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+			resp := chunk.Text
+			if resp == "" {
+				continue
+			}
+
+			// Stop the LLM once the response limit is reached and drop
+			// whatever it still delivers before the stream closes
+			if truncated {
+				continue
+			}
+			if config.Limits.MaxResponseChars > 0 && len(fullResponse)+len(resp) > config.Limits.MaxResponseChars {
+				log.Printf("LLM response exceeded %d characters, truncating", config.Limits.MaxResponseChars)
+				truncated = true
+				cancelLlm()
+				continue
 			}
 
 			fullResponse += resp
 			currentSentence += resp
 
 			// Check if we have a complete sentence
-			if strings.Contains(currentSentence, ".") || strings.Contains(currentSentence, "!") || strings.Contains(currentSentence, "?") {
+			terminators := config.Sentences.Terminators
+			if strings.ContainsAny(currentSentence, terminators) {
 				// Find the end of the sentence
-				endIdx := strings.LastIndexAny(currentSentence, ".!?") + 1
+				endIdx := strings.LastIndexAny(currentSentence, terminators) + 1
 
-				if endIdx > 0 && endIdx < len(currentSentence) {
+				if endIdx >= config.Sentences.MinLength && endIdx < len(currentSentence) {
 					sentence := currentSentence[:endIdx]
 					currentSentence = currentSentence[endIdx:]
 
 					// Synthesize and send the sentence
-					cs.synthesizeAndSend(ctx, sentence)
+					speak(sentence)
 				}
 			}
 
@@ -382,28 +704,106 @@ func (cs *ClientState) processAudio() {
 	}
 }
 
-// synthesizeAndSend synthesizes a text sentence and sends it to the client
-func (cs *ClientState) synthesizeAndSend(ctx context.Context, text string) {
+// runTools executes the tool calls concurrently while speaking the filler
+// phrase and returns the tool messages to send back to the LLM
+func (cs *ClientState) runTools(ctx context.Context, config AppConfig, settings SessionSettings, toolCalls []ToolCall) []ChatMessage {
+	results := make([]ChatMessage, len(toolCalls))
+
+	var wg sync.WaitGroup
+	for i, call := range toolCalls {
+		log.Printf("Calling tool %s with %s", call.Function.Name, call.Function.Arguments)
+		wg.Add(1)
+		go func(i int, call ToolCall) {
+			defer wg.Done()
+			results[i] = ChatMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    cs.app.tools.Execute(ctx, cs, call, settings.Tools, config.Tools.Timeout.Std()),
+			}
+		}(i, call)
+	}
+
+	// Let the user know we are working on it
+	if config.Tools.Filler != "" {
+		cs.synthesizeAndSend(ctx, config.Tools.Filler, settings.Voice)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// synthesizeAndSend synthesizes a text sentence and sends it to the client.
+// It returns the synthesized audio, or nil if nothing was sent.
+func (cs *ClientState) synthesizeAndSend(ctx context.Context, text string, voice VoiceConfig) []byte {
 	if cs.app.ttsClient == nil {
-		return
+		return nil
 	}
 
 	// Synthesize the text
-	audioData, err := cs.app.ttsClient.Synthesize(ctx, text)
+	format := cs.ttsFormat(cs.app.currentConfig().Audio)
+	audioData, err := cs.app.ttsClient.Synthesize(ctx, text, voice, format)
+	if ctx.Err() != nil {
+		// The turn was cancelled while the sentence was synthesized
+		return nil
+	}
+	if errors.Is(err, ErrBreakerOpen) {
+		logf(LogDebug, "Skipping TTS: %v", err)
+		return nil
+	}
 	if err != nil {
 		log.Printf("TTS error: %v", err)
-		return
+		return nil
 	}
 
 	// Send the audio to the client
-	err = cs.conn.WriteMessage(websocket.BinaryMessage, audioData)
+	err = cs.sendAudio(ctx, audioData)
 	if err != nil {
 		log.Printf("WebSocket write error: %v", err)
+		return nil
+	}
+	return audioData
+}
+
+// announce speaks a message outside of a turn, such as a finished timer
+func (cs *ClientState) announce(text string) {
+	if cs.isClosed() {
+		return
+	}
+
+	config := cs.app.currentConfig()
+	settings := cs.sessionSettings(config)
+	config.applyLanguage(&settings, cs.getLanguage())
+	cs.sendResponse(text)
+	cs.synthesizeAndSend(withSession(context.Background(), cs.sessionID), text, settings.Voice)
+}
+
+// afterFunc calls f after duration unless the session closes first. It
+// returns false if the session is already closed.
+func (cs *ClientState) afterFunc(duration time.Duration, f func()) bool {
+	cs.closeMutex.Lock()
+	defer cs.closeMutex.Unlock()
+
+	if cs.closed {
+		return false
 	}
+	if cs.timers == nil {
+		cs.timers = make(map[*time.Timer]bool)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		cs.closeMutex.Lock()
+		delete(cs.timers, timer)
+		cs.closeMutex.Unlock()
+		f()
+	})
+	cs.timers[timer] = true
+	return true
 }
 
 // sendStatus sends a status update to the client
 func (cs *ClientState) sendStatus(status State, detail string) {
+	cs.feed.publish(RecordedEvent{Time: time.Now(), Source: "status", Type: string(status), Detail: detail})
+
 	message := StatusMessage{
 		Type:   "status",
 		Status: string(status),
@@ -416,18 +816,27 @@ func (cs *ClientState) sendStatus(status State, detail string) {
 		return
 	}
 
-	err = cs.conn.WriteMessage(websocket.TextMessage, jsonMsg)
+	err = cs.writeMessage(websocket.TextMessage, jsonMsg)
 	if err != nil {
 		log.Printf("WebSocket write error: %v", err)
 	}
 }
 
-// sendTranscript sends a transcript update to the client
-func (cs *ClientState) sendTranscript(text string, isFinal bool) {
+// sendTranscript sends a transcript update to the client, with the word
+// timings and confidence when stt.words is set
+func (cs *ClientState) sendTranscript(transcription Transcription, language string, isFinal bool, config SttConfig) {
 	message := TranscriptMessage{
-		Type:    "transcript",
-		Text:    text,
-		IsFinal: isFinal,
+		Type:     "transcript",
+		Text:     transcription.Text,
+		IsFinal:  isFinal,
+		Language: language,
+	}
+	if config.Words {
+		message.Confidence = transcription.Confidence
+		message.Words = transcription.Words
+	}
+	if isFinal {
+		message.Alternatives = transcription.Alternatives
 	}
 
 	jsonMsg, err := json.Marshal(message)
@@ -436,7 +845,41 @@ func (cs *ClientState) sendTranscript(text string, isFinal bool) {
 		return
 	}
 
-	err = cs.conn.WriteMessage(websocket.TextMessage, jsonMsg)
+	err = cs.writeMessage(websocket.TextMessage, jsonMsg)
+	if err != nil {
+		log.Printf("WebSocket write error: %v", err)
+	}
+}
+
+// sendConfig sends the effective session settings, or errMsg if a configure
+// command was rejected, to the client
+func (cs *ClientState) sendConfig(errMsg string) {
+	config := cs.app.currentConfig()
+	mic := cs.mic
+	if cs.micSwitch != "" {
+		mic.Format = cs.micSwitch
+	}
+	message := ConfigMessage{
+		Type:     "config",
+		User:     cs.userID,
+		Session:  cs.sessionID,
+		Personas: config.Personas.personaList(),
+		Audio:    &cs.audio,
+		Mic:      &mic,
+		Error:    errMsg,
+	}
+	if errMsg == "" {
+		settings := cs.sessionSettings(config)
+		message.Settings = &settings
+	}
+
+	jsonMsg, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling config message: %v", err)
+		return
+	}
+
+	err = cs.writeMessage(websocket.TextMessage, jsonMsg)
 	if err != nil {
 		log.Printf("WebSocket write error: %v", err)
 	}
@@ -455,12 +898,19 @@ func (cs *ClientState) sendResponse(text string) {
 		return
 	}
 
-	err = cs.conn.WriteMessage(websocket.TextMessage, jsonMsg)
+	err = cs.writeMessage(websocket.TextMessage, jsonMsg)
 	if err != nil {
 		log.Printf("WebSocket write error: %v", err)
 	}
 }
 
+// writeMessage writes a message to the WebSocket, serializing concurrent writers
+func (cs *ClientState) writeMessage(messageType int, data []byte) error {
+	cs.writeMutex.Lock()
+	defer cs.writeMutex.Unlock()
+	return cs.conn.WriteMessage(messageType, data)
+}
+
 // getState gets the current state thread-safely
 func (cs *ClientState) getState() State {
 	cs.stateMutex.Lock()
@@ -477,42 +927,30 @@ func (cs *ClientState) setState(state State) {
 
 // resetState resets the client state
 func (cs *ClientState) resetState() {
-	cs.setState(StateIdle)
+	cs.stateMutex.Lock()
+	cs.state = StateIdle
+	cs.wakeWord = WakeWordConfig{}
+	cs.stateMutex.Unlock()
 	cs.transcript = ""
-	cs.vadActive = false
+	cs.vadActive.Store(false)
 	cs.triggered = false
+	cs.clearWakeWindow()
 
 	cs.audioBufferMutex.Lock()
 	cs.audioBuffer = make([][]byte, 0)
 	cs.audioBufferMutex.Unlock()
+	cs.dropTurn()
+	cs.dropUtterance()
+	cs.dropSpeculation()
 
 	cs.sendStatus(StateIdle, "Ready")
 }
 
-// addCancelFunc adds a cancel function thread-safely
-func (cs *ClientState) addCancelFunc(key string, cancel context.CancelFunc) {
-	cs.cancelMutex.Lock()
-	defer cs.cancelMutex.Unlock()
-	cs.cancelFuncs[key] = cancel
-}
-
-// removeCancelFunc removes a cancel function thread-safely
-func (cs *ClientState) removeCancelFunc(key string) {
-	cs.cancelMutex.Lock()
-	defer cs.cancelMutex.Unlock()
-	delete(cs.cancelFuncs, key)
-}
-
-// cancelAllOperations cancels all ongoing operations
-func (cs *ClientState) cancelAllOperations() {
-	cs.cancelMutex.Lock()
-	defer cs.cancelMutex.Unlock()
-
-	for _, cancel := range cs.cancelFuncs {
-		cancel()
+// stopTurn cancels the turn in progress and returns to idle
+func (cs *ClientState) stopTurn() {
+	if turn := cs.activeTurn(); turn == nil || !cs.cancelTurn(turn) {
+		cs.resetState()
 	}
-
-	cs.cancelFuncs = make(map[string]context.CancelFunc)
 }
 
 // close closes the client state and all resources
@@ -524,11 +962,39 @@ func (cs *ClientState) close() {
 		return
 	}
 
-	// Cancel all operations
-	cs.cancelAllOperations()
+	// Cancel the turn in progress and the pending timers
+	cs.dropTurn()
+	cs.dropUtterance()
+	cs.dropSpeculation()
+	for timer := range cs.timers {
+		timer.Stop()
+	}
+	cs.timers = nil
 
 	// Close the connection
 	cs.conn.Close()
 
+	// End the admin event streams
+	cs.feed.close()
+
 	cs.closed = true
 }
+
+// isClosed reports whether the client has been closed
+func (cs *ClientState) isClosed() bool {
+	cs.closeMutex.Lock()
+	defer cs.closeMutex.Unlock()
+	return cs.closed
+}
+
+// closeWithReason sends a close frame with the given code and reason before
+// closing the client
+func (cs *ClientState) closeWithReason(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	err := cs.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	if err != nil && err != websocket.ErrCloseSent {
+		log.Printf("WebSocket close error: %v", err)
+	}
+
+	cs.close()
+}
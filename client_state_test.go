@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// trackTransport is a Transport playing speech on a media track, like WebRTC
+type trackTransport struct {
+	frameTransport
+}
+
+func (t *trackTransport) writeSpeech(audio []byte) error { return nil }
+func (t *trackTransport) flushSpeech()                   {}
+
+// configMessages returns the config messages written to conn
+func configMessages(t *testing.T, conn *frameTransport) []ConfigMessage {
+	t.Helper()
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	var messages []ConfigMessage
+	for _, frame := range conn.frames {
+		var message ConfigMessage
+		if json.Unmarshal(frame, &message) == nil && message.Type == "config" {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+func TestMicDecodeFailure(t *testing.T) {
+	withStubOpusDecoder(t)
+	app := newApp(DefaultConfig())
+
+	// WebSocket clients are told to switch back to PCM
+	conn := &frameTransport{}
+	cs := NewClientState(conn, app, "alice")
+	cs.negotiateMic("opus,pcm", AudioConfig{MicOpus: true})
+	cs.handleAudioData(testOpusPacket(31, 40))
+	if len(configMessages(t, conn)) != 0 {
+		t.Fatal("config sent for a decoded packet")
+	}
+	cs.handleAudioData([]byte{0xff, 0})
+	messages := configMessages(t, conn)
+	if len(messages) != 1 || !strings.Contains(messages[0].Error, "corrupted packet") || messages[0].Mic.Format != MicFormatPCM {
+		t.Fatalf("config messages = %+v, want the error and PCM", messages)
+	}
+
+	// Packets in flight are still Opus, and dropped if they fail, until the
+	// client acknowledges the switch
+	cs.handleAudioData([]byte{0xff, 0})
+	cs.handleTextCommand(`{"action":"mic","format":"opus"}`)
+	if cs.mic.Format != MicFormatOpus || cs.micDecoder == nil || len(configMessages(t, conn)) != 1 {
+		t.Fatalf("switched to %s before the acknowledgement", cs.mic.Format)
+	}
+	cs.handleTextCommand(`{"action":"mic","format":"pcm"}`)
+	if cs.mic.Format != MicFormatPCM || cs.micDecoder != nil {
+		t.Error("decoder kept after the switch to PCM was acknowledged")
+	}
+	cs.sendConfig("")
+	if messages = configMessages(t, conn); messages[len(messages)-1].Mic.Format != MicFormatPCM {
+		t.Errorf("mic = %+v after the switch", messages[len(messages)-1].Mic)
+	}
+
+	// WebRTC tracks stay Opus and report only the first failure
+	track := &trackTransport{}
+	cs = NewClientState(track, app, "bob")
+	cs.negotiateMic("opus", AudioConfig{MicOpus: true})
+	for range 3 {
+		cs.handleAudioData([]byte{0xff, 0})
+	}
+	messages = configMessages(t, &track.frameTransport)
+	if len(messages) != 1 || messages[0].Mic.Format != MicFormatOpus || cs.micDecoder == nil {
+		t.Errorf("config messages = %+v, want one error keeping Opus", messages)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// inputSampleRate is the sample rate of the microphone audio the app sends
+const inputSampleRate = 16000
+
+// rms returns the RMS level of 16-bit PCM as a fraction of full scale
+func rms(pcm []byte) float64 {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < samples; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(samples))
+}
+
+// pcmDuration returns the duration of 16-bit mono PCM at sampleRate
+func pcmDuration(pcm []byte, sampleRate int) time.Duration {
+	return time.Duration(len(pcm)/2) * time.Second / time.Duration(sampleRate)
+}
+
+// speechDuration returns how much of the 16 kHz audio is louder than threshold,
+// measured in 20ms frames
+func speechDuration(pcm []byte, threshold float64) time.Duration {
+	const frameBytes = inputSampleRate / 50 * 2
+	var speech time.Duration
+	for offset := 0; offset+frameBytes <= len(pcm); offset += frameBytes {
+		if rms(pcm[offset:offset+frameBytes]) >= threshold {
+			speech += 20 * time.Millisecond
+		}
+	}
+	return speech
+}
+
+// sineWave returns 16-bit mono PCM of a tone with short fades to avoid clicks
+func sineWave(frequency, amplitude float64, duration time.Duration, sampleRate int) []byte {
+	samples := int(duration.Seconds() * float64(sampleRate))
+	fade := sampleRate / 100
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		gain := amplitude
+		if i < fade {
+			gain *= float64(i) / float64(fade)
+		} else if samples-i < fade {
+			gain *= float64(samples-i) / float64(fade)
+		}
+		value := gain * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(value*32767)))
+	}
+	return pcm
+}
+
+// opusSilence is a 20ms Opus packet of silence
+var opusSilence = []byte{0xf8, 0xff, 0xfe}
+
+// oggOpusSilence returns an Ogg Opus file of silence. Without an encoder the
+// mock cannot speak the tone in Opus, but clients can still decode the file.
+func oggOpusSilence(duration time.Duration, sampleRate int) []byte {
+	const preSkip = 312
+	var pages []byte
+	sequence := uint32(0)
+	page := func(flags byte, granule int64, packets ...[]byte) {
+		header := []byte("OggS\x00")
+		header = append(header, flags)
+		header = binary.LittleEndian.AppendUint64(header, uint64(granule))
+		header = binary.LittleEndian.AppendUint32(header, 1) // Serial
+		header = binary.LittleEndian.AppendUint32(header, sequence)
+		header = binary.LittleEndian.AppendUint32(header, 0) // Checksum
+		header = append(header, byte(len(packets)))
+		var body []byte
+		for _, packet := range packets {
+			header = append(header, byte(len(packet))) // Packets are shorter than 255 bytes
+			body = append(body, packet...)
+		}
+		start := len(pages)
+		pages = append(append(pages, header...), body...)
+		binary.LittleEndian.PutUint32(pages[start+22:], oggCRC(pages[start:]))
+		sequence++
+	}
+
+	head := []byte("OpusHead\x01\x01")
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = binary.LittleEndian.AppendUint32(head, uint32(sampleRate))
+	head = append(head, 0, 0, 0) // Output gain and channel mapping family
+	page(0x02, 0, head)
+	page(0, 0, []byte("OpusTags\x04\x00\x00\x00mock\x00\x00\x00\x00"))
+
+	// Pages of up to a second of 20ms packets
+	frames := max(1, int((duration+19*time.Millisecond)/(20*time.Millisecond)))
+	end := preSkip + int64(duration.Seconds()*48000)
+	for written := 0; written < frames; {
+		packets := make([][]byte, min(50, frames-written))
+		for i := range packets {
+			packets[i] = opusSilence
+		}
+		written += len(packets)
+		flags := byte(0)
+		if written == frames {
+			flags = 0x04
+		}
+		page(flags, min(preSkip+int64(written)*960, end), packets...)
+	}
+	return pages
+}
+
+// oggCRC returns the Ogg checksum of a page whose checksum field is zero
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"assistant-app/internal/duration"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// Config scripts the behaviour of the fake services
+type Config struct {
+	Behavior `yaml:",inline"`
+	Version  string        `yaml:"version"`
+	Vad      VadConfig     `yaml:"vad"`
+	Trigger  TriggerConfig `yaml:"trigger"`
+	Stt      SttConfig     `yaml:"stt"`
+	Tts      TtsConfig     `yaml:"tts"`
+	Llm      LlmConfig     `yaml:"llm"`
+}
+
+// Behavior holds the latency and failure injection of a service. Zero
+// values in a service section fall back to the top level values.
+type Behavior struct {
+	Latency     duration.Duration `yaml:"latency"`
+	FailureRate float64           `yaml:"failure_rate"`
+}
+
+// VadConfig configures the energy based VAD
+type VadConfig struct {
+	Behavior  `yaml:",inline"`
+	Threshold float64           `yaml:"threshold"` // RMS as a fraction of full scale
+	MinSpeech duration.Duration `yaml:"min_speech"`
+	Hangover  duration.Duration `yaml:"hangover"`
+}
+
+// TriggerConfig configures the keyword trigger
+type TriggerConfig struct {
+	Behavior  `yaml:",inline"`
+	Keywords  []KeywordConfig   `yaml:"keywords"`
+	Threshold float64           `yaml:"threshold"`  // RMS as a fraction of full scale
+	MinSpeech duration.Duration `yaml:"min_speech"` // Speech in the window needed to trigger
+}
+
+// KeywordConfig is a wake word the trigger reports
+type KeywordConfig struct {
+	Phrase     string  `yaml:"phrase"`
+	Confidence float64 `yaml:"confidence"`
+}
+
+// SttConfig configures the canned transcripts
+type SttConfig struct {
+	Behavior    `yaml:",inline"`
+	Transcripts []string `yaml:"transcripts"`
+	Language    string   `yaml:"language"`
+	Confidence  float64  `yaml:"confidence"`
+}
+
+// TtsConfig configures the sine wave TTS
+type TtsConfig struct {
+	Behavior     `yaml:",inline"`
+	Frequency    float64           `yaml:"frequency"`
+	Amplitude    float64           `yaml:"amplitude"`
+	CharDuration duration.Duration `yaml:"char_duration"`
+}
+
+// LlmConfig configures the OpenAI-compatible LLM
+type LlmConfig struct {
+	Behavior   `yaml:",inline"`
+	Model      string            `yaml:"model"`
+	Responses  []string          `yaml:"responses"`
+	Rules      []LlmRule         `yaml:"rules"`
+	TokenDelay duration.Duration `yaml:"token_delay"`
+}
+
+// LlmRule answers prompts containing a phrase with a reply or a tool call
+type LlmRule struct {
+	Contains  string `yaml:"contains"`
+	Reply     string `yaml:"reply"`
+	Tool      string `yaml:"tool"`
+	Arguments string `yaml:"arguments"`
+}
+
+// DefaultConfig returns the built-in behaviour
+func DefaultConfig() Config {
+	return Config{
+		Version: "mockservices/1",
+		Vad: VadConfig{
+			Threshold: 0.02,
+			MinSpeech: duration.Duration(100 * time.Millisecond),
+			Hangover:  duration.Duration(500 * time.Millisecond),
+		},
+		Trigger: TriggerConfig{
+			Keywords:  []KeywordConfig{{Phrase: "hey assistant", Confidence: 0.9}},
+			Threshold: 0.02,
+			MinSpeech: duration.Duration(400 * time.Millisecond),
+		},
+		Stt: SttConfig{
+			Transcripts: []string{
+				"What's the weather like today?",
+				"Tell me a joke.",
+				"What time is it?",
+			},
+			Language:   "en-US",
+			Confidence: 0.92,
+		},
+		Tts: TtsConfig{
+			Frequency:    440,
+			Amplitude:    0.2,
+			CharDuration: duration.Duration(65 * time.Millisecond),
+		},
+		Llm: LlmConfig{
+			Model: "mock",
+			Responses: []string{
+				"This is a mock response from the development LLM. Everything is working.",
+			},
+			Rules: []LlmRule{
+				{Contains: "time", Tool: "get_current_time", Arguments: "{}"},
+				{Contains: "joke", Reply: "Why did the developer go broke? Because he used up all his cache."},
+			},
+			TokenDelay: duration.Duration(30 * time.Millisecond),
+		},
+	}
+}
+
+// LoadConfig reads the behaviour from path over the defaults
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return config, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// or returns b with its zero values taken from fallback
+func (b Behavior) or(fallback Behavior) Behavior {
+	if b.Latency == 0 {
+		b.Latency = fallback.Latency
+	}
+	if b.FailureRate == 0 {
+		b.FailureRate = fallback.FailureRate
+	}
+	return b
+}
+
+// inject waits for the configured latency and fails a share of the calls
+func (b Behavior) inject(ctx context.Context) error {
+	if b.Latency > 0 {
+		timer := time.NewTimer(b.Latency.Std())
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	if b.FailureRate > 0 && rand.Float64() < b.FailureRate {
+		return status.Error(codes.Unavailable, "injected failure")
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// llmServer is an OpenAI-compatible chat completions endpoint answering from
+// rules and canned responses
+type llmServer struct {
+	config   LlmConfig
+	behavior Behavior
+	next     int
+	mutex    sync.Mutex
+}
+
+// chatRequest is the part of a chat completions request the fake reads
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+	Stream bool `json:"stream"`
+}
+
+// chatMessage is a message of a chat completions request or response
+type chatMessage struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+// toolCall is a tool call in a chat completions response
+type toolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatChoice is a choice of a response or a streamed chunk
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// chatResponse is a chat completion or a streamed chunk of one
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+}
+
+// routes registers the OpenAI-compatible endpoints
+func (s *llmServer) routes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// reply picks the answer to a conversation: a rule matching the last user
+// message, a summary of tool results, or the next canned response
+func (s *llmServer) reply(req chatRequest) (string, *toolCall) {
+	if len(req.Messages) == 0 {
+		return "", nil
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == "tool" {
+		return "Here is what I found: " + last.Content, nil
+	}
+
+	offered := make(map[string]bool)
+	for _, tool := range req.Tools {
+		offered[tool.Function.Name] = true
+	}
+	prompt := strings.ToLower(last.Content)
+	for _, rule := range s.config.Rules {
+		if !strings.Contains(prompt, strings.ToLower(rule.Contains)) {
+			continue
+		}
+		if rule.Tool == "" {
+			return rule.Reply, nil
+		}
+		if !offered[rule.Tool] {
+			continue
+		}
+		call := &toolCall{ID: fmt.Sprintf("call_%d", time.Now().UnixNano()), Type: "function"}
+		call.Function.Name = rule.Tool
+		call.Function.Arguments = rule.Arguments
+		return "", call
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.config.Responses) == 0 {
+		return "", nil
+	}
+	text := s.config.Responses[s.next%len(s.config.Responses)]
+	s.next++
+	return text, nil
+}
+
+// handleChat answers a chat completions request, streamed as server-sent
+// events word by word when asked to
+func (s *llmServer) handleChat(w http.ResponseWriter, r *http.Request) {
+	if err := s.behavior.inject(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, status.Convert(err).Message())
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = s.config.Model
+	}
+
+	text, call := s.reply(req)
+	finish := "stop"
+	if call != nil {
+		finish = "tool_calls"
+		logf("LLM tool call %s(%s)", call.Function.Name, call.Function.Arguments)
+	} else {
+		logf("LLM %q", text)
+	}
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+
+	if !req.Stream {
+		message := &chatMessage{Role: "assistant", Content: text}
+		if call != nil {
+			message.ToolCalls = []toolCall{*call}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chatResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   model,
+			Choices: []chatChoice{{Message: message, FinishReason: &finish}},
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(delta *chatMessage, finishReason *string) bool {
+		data, _ := json.Marshal(chatResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   model,
+			Choices: []chatChoice{{Delta: delta, FinishReason: finishReason}},
+		})
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// Stream the words with their trailing space, then the finish reason
+	if !send(&chatMessage{Role: "assistant"}, nil) {
+		return
+	}
+	for _, token := range strings.SplitAfter(text, " ") {
+		if token == "" {
+			continue
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(s.config.TokenDelay.Std()):
+		}
+		if !send(&chatMessage{Content: token}, nil) {
+			return
+		}
+	}
+	if call != nil && !send(&chatMessage{ToolCalls: []toolCall{*call}}, nil) {
+		return
+	}
+	if !send(&chatMessage{}, &finish) {
+		return
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// handleModels lists the configured model
+func (s *llmServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{
+			{"id": s.config.Model, "object": "model", "owned_by": "mockservices"},
+		},
+	})
+}
+
+// writeError writes an error in the OpenAI format
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "mock_error"},
+	}); err != nil {
+		log.Printf("Failed to write error: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Command mockservices runs fake VAD, trigger, STT, TTS and LLM backends for
+// local development. Their behaviour is scripted with a YAML file, see
+// mockservices.example.yaml.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	vad "assistant-app/grpc_modules"
+	"assistant-app/grpc_modules/stt"
+	"assistant-app/grpc_modules/trigger"
+	"assistant-app/grpc_modules/tts"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// verbose logs every event of the fakes
+var verbose bool
+
+func main() {
+	configPath := flag.String("config", "", "Path to a YAML file scripting the fakes")
+	services := flag.String("services", "vad,trigger,stt,tts,llm", "Comma separated services to run")
+	vadAddr := flag.String("vad", ":50051", "VAD gRPC listen address")
+	triggerAddr := flag.String("trigger", ":50052", "Trigger gRPC listen address")
+	sttAddr := flag.String("stt", ":50053", "STT gRPC listen address")
+	ttsAddr := flag.String("tts", ":50054", "TTS gRPC listen address")
+	llmAddr := flag.String("llm", ":8000", "LLM HTTP listen address")
+	tlsCert := flag.String("tls-cert", "", "Serve TLS with this certificate file")
+	tlsKey := flag.String("tls-key", "", "Private key of the TLS certificate")
+	tlsClientCA := flag.String("tls-client-ca", "", "Require client certificates issued by this CA (mutual TLS)")
+	flag.BoolVar(&verbose, "v", false, "Log every event")
+	flag.Parse()
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	tlsConfig, err := serverTLS(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(*services, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+
+	// Register each gRPC service on its own server so it gets its own port
+	var grpcServers []*grpc.Server
+	serve := func(name, addr string, register func(*grpc.Server)) {
+		if !enabled[name] {
+			return
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s for %s: %v", addr, name, err)
+		}
+		var options []grpc.ServerOption
+		if tlsConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		server := grpc.NewServer(options...)
+		register(server)
+		grpcServers = append(grpcServers, server)
+		log.Printf("Mock %s service listening on %s", name, addr)
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				log.Fatalf("%s server failed: %v", name, err)
+			}
+		}()
+	}
+
+	serve("vad", *vadAddr, func(s *grpc.Server) {
+		vad.RegisterVADServiceServer(s, &vadServer{config: config.Vad, behavior: config.Vad.Behavior.or(config.Behavior)})
+	})
+	serve("trigger", *triggerAddr, func(s *grpc.Server) {
+		trigger.RegisterTriggerServiceServer(s, &triggerServer{config: config.Trigger, behavior: config.Trigger.Behavior.or(config.Behavior), version: config.Version})
+	})
+	serve("stt", *sttAddr, func(s *grpc.Server) {
+		stt.RegisterSttServiceServer(s, &sttServer{config: config.Stt, behavior: config.Stt.Behavior.or(config.Behavior), version: config.Version})
+	})
+	serve("tts", *ttsAddr, func(s *grpc.Server) {
+		tts.RegisterTtsServiceServer(s, &ttsServer{config: config.Tts, behavior: config.Tts.Behavior.or(config.Behavior), version: config.Version})
+	})
+
+	var httpServer *http.Server
+	if enabled["llm"] {
+		mux := http.NewServeMux()
+		llm := &llmServer{config: config.Llm, behavior: config.Llm.Behavior.or(config.Behavior)}
+		llm.routes(mux)
+		httpServer = &http.Server{Addr: *llmAddr, Handler: mux, TLSConfig: tlsConfig}
+		log.Printf("Mock llm service listening on %s", *llmAddr)
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				err = httpServer.ListenAndServeTLS("", "")
+			} else {
+				err = httpServer.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("llm server failed: %v", err)
+			}
+		}()
+	}
+
+	if len(grpcServers) == 0 && httpServer == nil {
+		log.Fatalf("No services enabled in %q", *services)
+	}
+
+	// Wait for a shutdown signal
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	log.Println("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if httpServer != nil {
+		httpServer.Shutdown(ctx)
+	}
+	for _, server := range grpcServers {
+		server.Stop()
+	}
+}
+
+// serverTLS returns the TLS settings of the fakes, or nil to serve plain text
+func serverTLS(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// setVersion reports the version in the x-service-version response header
+func setVersion(ctx context.Context, version string) {
+	if version == "" {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-service-version", version)); err != nil {
+		logf("Failed to set version header: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"assistant-app/grpc_modules/stt"
+)
+
+// sttServer is a SttService answering with canned transcripts in turn
+type sttServer struct {
+	stt.UnimplementedSttServiceServer
+	config   SttConfig
+	behavior Behavior
+	version  string
+	next     int
+	mutex    sync.Mutex
+}
+
+// transcript returns the next canned transcript
+func (s *sttServer) transcript() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.config.Transcripts) == 0 {
+		return ""
+	}
+	text := s.config.Transcripts[s.next%len(s.config.Transcripts)]
+	s.next++
+	return text
+}
+
+// response builds a result for text spread over the audio
+func (s *sttServer) response(text string, req *stt.TranscribeRequest, audio []byte, final bool) *stt.TranscribeResponse {
+	language := req.LanguageCode
+	if language == "" {
+		language = s.config.Language
+	}
+	confidence := float32(s.config.Confidence)
+
+	response := &stt.TranscribeResponse{
+		Transcript:   text,
+		IsFinal:      final,
+		Confidence:   confidence,
+		LanguageCode: language,
+	}
+
+	// Spread the words evenly over the audio
+	if req.Config != nil && req.Config.EnableWordTimestamps {
+		words := strings.Fields(text)
+		duration := pcmDuration(audio, inputSampleRate).Seconds()
+		for i, word := range words {
+			response.Words = append(response.Words, &stt.WordInfo{
+				Word:       word,
+				StartTime:  duration * float64(i) / float64(len(words)),
+				EndTime:    duration * float64(i+1) / float64(len(words)),
+				Confidence: confidence,
+			})
+		}
+	}
+
+	// Offer the other canned transcripts as less likely alternatives
+	if final && req.Config != nil {
+		for _, alternative := range s.config.Transcripts {
+			if len(response.Alternatives)+1 >= int(req.Config.MaxAlternatives) {
+				break
+			}
+			if alternative != text {
+				response.Alternatives = append(response.Alternatives, &stt.TranscriptAlternative{
+					Transcript: alternative,
+					Confidence: confidence / 2,
+				})
+			}
+		}
+	}
+	return response
+}
+
+// Transcribe answers with the next canned transcript
+func (s *sttServer) Transcribe(ctx context.Context, req *stt.TranscribeRequest) (*stt.TranscribeResponse, error) {
+	if err := s.behavior.inject(ctx); err != nil {
+		return nil, err
+	}
+	setVersion(ctx, s.version)
+
+	text := s.transcript()
+	logf("STT %q", text)
+	return s.response(text, req, req.AudioData, true), nil
+}
+
+// TranscribeStream sends growing interim results of the next canned
+// transcript for every chunk and the final result when the client closes the
+// stream
+func (s *sttServer) TranscribeStream(stream stt.SttService_TranscribeStreamServer) error {
+	if err := s.behavior.inject(stream.Context()); err != nil {
+		return err
+	}
+
+	text := s.transcript()
+	words := strings.Fields(text)
+	first := &stt.TranscribeRequest{}
+	var audio []byte
+	for chunks := 1; ; chunks++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			logf("STT %q", text)
+			return stream.Send(s.response(text, first, audio, true))
+		}
+		if err != nil {
+			return err
+		}
+		if chunks == 1 {
+			first = req
+		}
+		audio = append(audio, req.AudioData...)
+
+		if first.Config == nil || !first.Config.EnableInterimResults {
+			continue
+		}
+		// Reveal one more word per chunk
+		partial := strings.Join(words[:min(chunks, len(words))], " ")
+		if err := stream.Send(s.response(partial, first, audio, false)); err != nil {
+			return err
+		}
+	}
+}
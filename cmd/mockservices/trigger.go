@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"assistant-app/grpc_modules/trigger"
+)
+
+// triggerServer is a keyword TriggerService: any window with enough speech is
+// reported as the requested keyword
+type triggerServer struct {
+	trigger.UnimplementedTriggerServiceServer
+	config   TriggerConfig
+	behavior Behavior
+	version  string
+}
+
+// detect decides whether audio holds the requested wake word
+func (s *triggerServer) detect(req *trigger.DetectRequest) *trigger.DetectResponse {
+	keyword, ok := s.keyword(req.WakeWord)
+	if !ok || speechDuration(req.AudioData, s.config.Threshold) < s.config.MinSpeech.Std() {
+		return &trigger.DetectResponse{}
+	}
+	return &trigger.DetectResponse{
+		IsTriggered:      true,
+		Confidence:       float32(keyword.Confidence),
+		DetectedWakeWord: keyword.Phrase,
+	}
+}
+
+// keyword returns the configured keyword matching phrase, or the first
+// keyword for an empty phrase
+func (s *triggerServer) keyword(phrase string) (KeywordConfig, bool) {
+	for _, keyword := range s.config.Keywords {
+		if phrase == "" || strings.EqualFold(keyword.Phrase, phrase) {
+			return keyword, true
+		}
+	}
+	return KeywordConfig{}, false
+}
+
+// Detect checks one window of audio
+func (s *triggerServer) Detect(ctx context.Context, req *trigger.DetectRequest) (*trigger.DetectResponse, error) {
+	if err := s.behavior.inject(ctx); err != nil {
+		return nil, err
+	}
+	setVersion(ctx, s.version)
+
+	response := s.detect(req)
+	if response.IsTriggered {
+		logf("Trigger %q (%.2f)", response.DetectedWakeWord, response.Confidence)
+	}
+	return response, nil
+}
+
+// DetectStream checks the audio received so far after every chunk
+func (s *triggerServer) DetectStream(stream trigger.TriggerService_DetectStreamServer) error {
+	if err := s.behavior.inject(stream.Context()); err != nil {
+		return err
+	}
+
+	var audio []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		audio = append(audio, req.AudioData...)
+		response := s.detect(&trigger.DetectRequest{AudioData: audio, WakeWord: req.WakeWord})
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+		// Start a new window after a detection
+		if response.IsTriggered {
+			audio = nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"assistant-app/grpc_modules/tts"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ttsServer is a TtsService producing a tone as long as the text would take
+// to speak
+type ttsServer struct {
+	tts.UnimplementedTtsServiceServer
+	config   TtsConfig
+	behavior Behavior
+	version  string
+}
+
+// synthesize renders the tone for a request
+func (s *ttsServer) synthesize(req *tts.SynthesizeRequest) (*tts.SynthesizeResponse, error) {
+	sampleRate := 24000
+	rate := 1.0
+	opus := false
+	if audioConfig := req.AudioConfig; audioConfig != nil {
+		switch audioConfig.AudioEncoding {
+		case tts.AudioEncoding_AUDIO_ENCODING_UNSPECIFIED, tts.AudioEncoding_LINEAR16:
+		case tts.AudioEncoding_OGG_OPUS:
+			opus = true
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported audio encoding %s", audioConfig.AudioEncoding)
+		}
+		if audioConfig.SampleRateHertz > 0 {
+			sampleRate = int(audioConfig.SampleRateHertz)
+		}
+		if audioConfig.SpeakingRate > 0 {
+			rate = float64(audioConfig.SpeakingRate)
+		}
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, status.Error(codes.InvalidArgument, "text is empty")
+	}
+
+	duration := time.Duration(float64(s.config.CharDuration) * float64(len(req.Text)) / rate)
+	audio := sineWave(s.config.Frequency, s.config.Amplitude, duration, sampleRate)
+	if opus {
+		audio = oggOpusSilence(duration, sampleRate)
+	}
+
+	// Spread the words evenly over the audio
+	timing := &tts.TimingInfo{TotalDurationSeconds: duration.Seconds()}
+	words := strings.Fields(req.Text)
+	for i, word := range words {
+		timing.WordTimings = append(timing.WordTimings, &tts.WordTiming{
+			Word:      word,
+			StartTime: duration.Seconds() * float64(i) / float64(len(words)),
+			EndTime:   duration.Seconds() * float64(i+1) / float64(len(words)),
+		})
+	}
+	return &tts.SynthesizeResponse{AudioContent: audio, TimingInfo: timing}, nil
+}
+
+// Synthesize answers with the whole tone
+func (s *ttsServer) Synthesize(ctx context.Context, req *tts.SynthesizeRequest) (*tts.SynthesizeResponse, error) {
+	if err := s.behavior.inject(ctx); err != nil {
+		return nil, err
+	}
+	setVersion(ctx, s.version)
+
+	response, err := s.synthesize(req)
+	if err != nil {
+		return nil, err
+	}
+	logf("TTS %q (%.1fs)", req.Text, response.TimingInfo.TotalDurationSeconds)
+	return response, nil
+}
+
+// SynthesizeStream sends the tone in chunks of 200ms, the timing info with the
+// first chunk
+func (s *ttsServer) SynthesizeStream(req *tts.SynthesizeRequest, stream tts.TtsService_SynthesizeStreamServer) error {
+	if err := s.behavior.inject(stream.Context()); err != nil {
+		return err
+	}
+
+	response, err := s.synthesize(req)
+	if err != nil {
+		return err
+	}
+	sampleRate := 24000
+	if req.AudioConfig != nil && req.AudioConfig.SampleRateHertz > 0 {
+		sampleRate = int(req.AudioConfig.SampleRateHertz)
+	}
+	chunkBytes := sampleRate / 5 * 2
+
+	audio := response.AudioContent
+	timing := response.TimingInfo
+	for len(audio) > 0 {
+		n := min(chunkBytes, len(audio))
+		if err := stream.Send(&tts.SynthesizeResponse{AudioContent: audio[:n], TimingInfo: timing}); err != nil {
+			return fmt.Errorf("failed to send audio: %w", err)
+		}
+		audio = audio[n:]
+		timing = nil
+	}
+	return nil
+}
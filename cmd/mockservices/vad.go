@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+
+	vad "assistant-app/grpc_modules"
+)
+
+// vadServer is an energy based VADService
+type vadServer struct {
+	vad.UnimplementedVADServiceServer
+	config   VadConfig
+	behavior Behavior
+	resets   atomic.Int64 // Bumped by ResetVAD, streams restart their segment when it changes
+}
+
+// vadSegmenter tracks speech on one audio stream
+type vadSegmenter struct {
+	config   VadConfig
+	speaking bool
+	above    time.Duration // Loud audio while silent
+	below    time.Duration // Quiet audio while speaking
+}
+
+// process classifies a chunk and returns the event to send, if any
+func (s *vadSegmenter) process(chunk []byte) string {
+	level := rms(chunk)
+	duration := pcmDuration(chunk, inputSampleRate)
+	loud := level >= s.config.Threshold
+
+	if !s.speaking {
+		if !loud {
+			s.above = 0
+			return ""
+		}
+		s.above += duration
+		if s.above < s.config.MinSpeech.Std() {
+			return ""
+		}
+		s.speaking = true
+		s.below = 0
+		return "start"
+	}
+
+	if loud {
+		s.below = 0
+		return "continue"
+	}
+	s.below += duration
+	if s.below < s.config.Hangover.Std() {
+		return "continue"
+	}
+	s.speaking = false
+	s.above = 0
+	return "end"
+}
+
+// ProcessAudio answers audio chunks with start, continue and end events
+func (s *vadServer) ProcessAudio(stream vad.VADService_ProcessAudioServer) error {
+	if err := s.behavior.inject(stream.Context()); err != nil {
+		return err
+	}
+
+	segmenter := &vadSegmenter{config: s.config}
+	generation := s.resets.Load()
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Start over after a reset
+		if current := s.resets.Load(); current != generation {
+			generation = current
+			segmenter = &vadSegmenter{config: s.config}
+		}
+
+		event := segmenter.process(chunk.AudioData)
+		if event == "" {
+			continue
+		}
+		if event != "continue" {
+			logf("VAD %s", event)
+		}
+		response := &vad.VADResponse{
+			Event:   event,
+			Message: fmt.Sprintf("rms %.3f", rms(chunk.AudioData)),
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+// ResetVAD resets the speech state of every stream
+func (s *vadServer) ResetVAD(ctx context.Context, req *vad.ResetRequest) (*vad.ResetResponse, error) {
+	if err := s.behavior.inject(ctx); err != nil {
+		return nil, err
+	}
+	s.resets.Add(1)
+	return &vad.ResetResponse{Success: true}, nil
+}
+
+// logf logs when verbose logging is enabled
+func logf(format string, args ...interface{}) {
+	if verbose {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,895 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"assistant-app/internal/duration"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppConfig holds the application configuration
+//
+// Values are layered: built-in defaults, then the YAML config file, then
+// environment variables and finally command line flags that were set
+// explicitly.
+type AppConfig struct {
+	Server       ServerConfig       `yaml:"server"`
+	Services     ServicesConfig     `yaml:"services"`
+	Balancing    BalancingConfig    `yaml:"balancing"`
+	TLS          TLSConfig          `yaml:"tls"`
+	Vad          VadConfig          `yaml:"vad"`
+	Trigger      TriggerConfig      `yaml:"trigger"`
+	Llm          LlmConfig          `yaml:"llm"`
+	Tts          VoiceConfig        `yaml:"tts"`
+	Audio        AudioConfig        `yaml:"audio"`
+	Rtc          RtcConfig          `yaml:"rtc"`
+	Echo         EchoConfig         `yaml:"echo"`
+	Stt          SttConfig          `yaml:"stt"`
+	Speculation  SpeculationConfig  `yaml:"speculation"`
+	Confirmation ConfirmationConfig `yaml:"confirmation"`
+	Sentences    SentenceConfig     `yaml:"sentences"`
+	Limits       LimitsConfig       `yaml:"limits"`
+	Tools        ToolsConfig        `yaml:"tools"`
+	Personas     PersonasConfig     `yaml:"personas"`
+	Languages    LanguagesConfig    `yaml:"languages"`
+	History      HistoryConfig      `yaml:"history"`
+	Recording    RecordingConfig    `yaml:"recording"`
+	Resilience   ResilienceConfig   `yaml:"resilience"`
+	Admin        AdminConfig        `yaml:"admin"`
+	LogLevel     string             `yaml:"log_level"`
+}
+
+// ServerConfig holds the HTTP and WebSocket server settings
+type ServerConfig struct {
+	Port            string   `yaml:"port"`
+	DrainTimeout    Duration `yaml:"drain_timeout"`
+	ReadBufferSize  int      `yaml:"read_buffer_size"`
+	WriteBufferSize int      `yaml:"write_buffer_size"`
+}
+
+// ServicesConfig holds the addresses of the backend services. Each one may
+// be a comma separated list of replicas.
+type ServicesConfig struct {
+	Vad     string `yaml:"vad"`
+	Trigger string `yaml:"trigger"`
+	Stt     string `yaml:"stt"`
+	Tts     string `yaml:"tts"`
+	Llm     string `yaml:"llm"`
+}
+
+// BalancingConfig holds how backend calls are spread over the replicas of a
+// service
+type BalancingConfig struct {
+	Policy          string   `yaml:"policy"`           // round_robin or least_outstanding
+	Resolve         bool     `yaml:"resolve"`          // Use every address a host name resolves to as a replica
+	ResolveInterval Duration `yaml:"resolve_interval"` // How often host names are resolved again
+	EjectAfter      int      `yaml:"eject_after"`      // Consecutive failures that eject a replica; 0 disables ejection
+	EjectFor        Duration `yaml:"eject_for"`
+	Sticky          bool     `yaml:"sticky"` // Keep the calls of a session on one replica
+}
+
+// TLSConfig holds the certificates of the web server and the TLS settings of
+// each backend. Certificate files are checked for changes every
+// ReloadInterval and reloaded without a restart.
+type TLSConfig struct {
+	ReloadInterval Duration        `yaml:"reload_interval"`
+	Server         ServerTLSConfig `yaml:"server"`
+	Vad            ClientTLSConfig `yaml:"vad"`
+	Trigger        ClientTLSConfig `yaml:"trigger"`
+	Stt            ClientTLSConfig `yaml:"stt"`
+	Tts            ClientTLSConfig `yaml:"tts"`
+	Llm            ClientTLSConfig `yaml:"llm"`
+	FallbackLlm    ClientTLSConfig `yaml:"fallback_llm"`
+}
+
+// ServerTLSConfig holds the certificate the web server serves HTTPS and wss
+// with. Without one it serves plain HTTP.
+type ServerTLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// ClientTLSConfig holds how a backend is reached over TLS. For the LLM,
+// https URLs always use TLS; enabling it applies these settings.
+type ClientTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`   // CA verifying the backend instead of the system roots
+	CertFile           string `yaml:"cert_file"` // Client certificate for mutual TLS
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"` // Name to verify instead of the host of the address
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// VadConfig holds the VAD client settings
+type VadConfig struct {
+	Mode            string         `yaml:"mode"`
+	ChunkSizeBytes  int            `yaml:"chunk_size_bytes"`
+	EventBufferSize int            `yaml:"event_buffer_size"`
+	Local           LocalVadConfig `yaml:"local"`
+}
+
+// LocalVadConfig holds the settings of the built-in energy based VAD. Levels
+// are RMS values as a fraction of full scale.
+type LocalVadConfig struct {
+	Frame            Duration `yaml:"frame"`
+	MinLevel         float64  `yaml:"min_level"`
+	Threshold        float64  `yaml:"threshold"`          // Speech level as a multiple of the noise floor
+	NoiseAdaptation  float64  `yaml:"noise_adaptation"`   // Share of a quiet frame mixed into the noise floor
+	MaxZeroCrossings float64  `yaml:"max_zero_crossings"` // Zero crossing rate above which quiet frames count as noise
+	MinSpeech        Duration `yaml:"min_speech"`
+	Hangover         Duration `yaml:"hangover"`
+}
+
+// TriggerConfig holds the wake word detection settings
+type TriggerConfig struct {
+	Threshold     float64          `yaml:"threshold"`
+	Window        Duration         `yaml:"window"`
+	CheckInterval Duration         `yaml:"check_interval"`
+	Timeout       Duration         `yaml:"timeout"`
+	MinCommand    Duration         `yaml:"min_command"`
+	WakeWords     []WakeWordConfig `yaml:"wake_words"`
+}
+
+// WakeWordConfig is a wake word and the assistant it reaches. Empty fields
+// fall back to the session settings.
+type WakeWordConfig struct {
+	Phrase       string   `yaml:"phrase"`
+	Threshold    float64  `yaml:"threshold"`
+	Persona      string   `yaml:"persona"`
+	SystemPrompt string   `yaml:"system_prompt"`
+	Tools        []string `yaml:"tools"`
+}
+
+// LlmConfig holds the LLM client settings
+type LlmConfig struct {
+	Provider     string         `yaml:"provider"`
+	Model        string         `yaml:"model"`
+	APIKey       string         `yaml:"api_key"`
+	AuthHeader   string         `yaml:"auth_header"`
+	Timeout      Duration       `yaml:"timeout"`
+	SystemPrompt string         `yaml:"system_prompt"`
+	Sampling     SamplingConfig `yaml:"sampling"`
+}
+
+// SamplingConfig holds the LLM sampling parameters
+type SamplingConfig struct {
+	Temperature float64 `yaml:"temperature" json:"temperature"`
+	TopP        float64 `yaml:"top_p" json:"top_p"`
+	MaxTokens   int     `yaml:"max_tokens" json:"max_tokens"`
+}
+
+// VoiceConfig holds the voice settings used for synthesis
+type VoiceConfig struct {
+	VoiceName    string  `yaml:"voice_name" json:"voice_name"`
+	LanguageCode string  `yaml:"language_code" json:"language_code"`
+	SpeakingRate float64 `yaml:"speaking_rate" json:"speaking_rate"`
+	Pitch        float64 `yaml:"pitch" json:"pitch"`
+}
+
+// AudioConfig holds the audio codecs used with clients. Speech is sent as
+// Opus to clients that accept it when it comes from the TTS service as Ogg
+// Opus, or is encoded on the server in builds with the opus tag.
+type AudioConfig struct {
+	TtsOggOpus      bool     `yaml:"tts_ogg_opus"`     // The TTS service can synthesize Ogg Opus
+	Transcode       bool     `yaml:"transcode"`        // Encode synthesized PCM to Opus on the server
+	OpusBitrate     int      `yaml:"opus_bitrate"`     // Bits per second of Opus encoded on the server
+	MicOpus         bool     `yaml:"mic_opus"`         // Accept Opus microphone audio from clients that offer it
+	PlaybackTimeout Duration `yaml:"playback_timeout"` // How long past the expected end of speech a finish ack is waited for
+}
+
+// SentenceConfig holds the rules used to split LLM output into sentences for TTS
+type SentenceConfig struct {
+	Terminators string `yaml:"terminators"`
+	MinLength   int    `yaml:"min_length"`
+}
+
+// LimitsConfig holds per-turn resource limits
+type LimitsConfig struct {
+	MaxUtteranceChunks int `yaml:"max_utterance_chunks"`
+	MaxResponseChars   int `yaml:"max_response_chars"`
+}
+
+// ToolsConfig holds the LLM tool calling settings
+type ToolsConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Timeout   Duration `yaml:"timeout"`
+	MaxRounds int      `yaml:"max_rounds"`
+	Filler    string   `yaml:"filler"`
+}
+
+// PersonasConfig holds the persona presets and what clients may change per session
+type PersonasConfig struct {
+	Default           string                   `yaml:"default"`
+	AllowCustomPrompt bool                     `yaml:"allow_custom_prompt"`
+	AllowedVoices     []string                 `yaml:"allowed_voices"`
+	AllowedLanguages  []string                 `yaml:"allowed_languages"`
+	AllowedModels     []string                 `yaml:"allowed_models"`
+	Presets           map[string]PersonaConfig `yaml:"presets"`
+}
+
+// PersonaConfig is a persona preset. Empty fields fall back to the llm and
+// tts settings.
+type PersonaConfig struct {
+	Description  string         `yaml:"description"`
+	SystemPrompt string         `yaml:"system_prompt"`
+	Model        string         `yaml:"model"`
+	Sampling     SamplingConfig `yaml:"sampling"`
+	Voice        VoiceConfig    `yaml:"voice"`
+}
+
+// LanguagesConfig holds the languages sessions can speak and how a session's
+// language is chosen
+type LanguagesConfig struct {
+	Policy    string                    `yaml:"policy"`
+	Fallback  string                    `yaml:"fallback"`
+	Supported map[string]LanguageConfig `yaml:"supported"`
+}
+
+// LanguageConfig describes a supported language and the voice used for it
+type LanguageConfig struct {
+	Name      string `yaml:"name"`
+	VoiceName string `yaml:"voice_name"`
+}
+
+// HistoryConfig selects where conversation turns are persisted
+type HistoryConfig struct {
+	Backend string   `yaml:"backend"`
+	Path    string   `yaml:"path"`
+	Timeout Duration `yaml:"timeout"`
+}
+
+// RecordingConfig holds the settings for recording utterances for debugging
+type RecordingConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Default        bool     `yaml:"default"`
+	Dir            string   `yaml:"dir"`
+	IncludeReplies bool     `yaml:"include_replies"`
+	MaxAge         Duration `yaml:"max_age"`
+	MaxRecordings  int      `yaml:"max_recordings"`
+}
+
+// ResilienceConfig holds the circuit breakers of the backends and the
+// fallbacks used while they fail
+type ResilienceConfig struct {
+	Trigger        BreakerConfig     `yaml:"trigger"`
+	Stt            BreakerConfig     `yaml:"stt"`
+	Llm            BreakerConfig     `yaml:"llm"`
+	Tts            BreakerConfig     `yaml:"tts"`
+	FallbackLlm    FallbackLlmConfig `yaml:"fallback_llm"`
+	TroubleMessage string            `yaml:"trouble_message"`
+	TroubleAudio   string            `yaml:"trouble_audio"`
+}
+
+// BreakerConfig holds the settings of a backend's circuit breaker
+type BreakerConfig struct {
+	Timeout          Duration `yaml:"timeout"`           // Per call, time to the first chunk for the LLM; 0 for none
+	FailureThreshold int      `yaml:"failure_threshold"` // Consecutive failures that open the breaker; 0 disables it
+	OpenFor          Duration `yaml:"open_for"`
+	HalfOpenProbes   int      `yaml:"half_open_probes"`
+}
+
+// FallbackLlmConfig is a secondary LLM asked when the primary one fails.
+// Empty fields other than the service fall back to the llm settings.
+type FallbackLlmConfig struct {
+	Service  string `yaml:"service"`
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+	APIKey   string `yaml:"api_key"`
+}
+
+// RtcConfig holds the settings of the WebRTC transport
+type RtcConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	IceServers string `yaml:"ice_servers"` // Comma separated STUN URLs used by the server and offered to browsers
+	PublicIPs  string `yaml:"public_ips"`  // Comma separated addresses announced instead of the host's behind 1:1 NAT
+	PortMin    int    `yaml:"port_min"`    // UDP port range for media; 0 lets the OS pick
+	PortMax    int    `yaml:"port_max"`
+}
+
+// EchoConfig holds the settings of echo suppression, which keeps the
+// assistant's own speech picked up by the microphone away from the VAD and
+// wake word detection
+type EchoConfig struct {
+	Default     bool     `yaml:"default"`     // Suppress echo in sessions that did not choose
+	Mode        string   `yaml:"mode"`        // suppress silences echo, attenuate lowers it
+	Threshold   float64  `yaml:"threshold"`   // Correlation with the speech above which microphone audio is echo
+	Attenuation float64  `yaml:"attenuation"` // Decibels removed from echo in attenuate mode
+	MaxDelay    Duration `yaml:"max_delay"`   // Longest delay from playback to the microphone
+	Tail        Duration `yaml:"tail"`        // How long echo is looked for after playback stops
+}
+
+// Echo suppression modes
+const (
+	EchoModeSuppress  = "suppress"
+	EchoModeAttenuate = "attenuate"
+)
+
+// SttConfig holds the settings of the transcripts sent while the user speaks
+type SttConfig struct {
+	Interim         bool     `yaml:"interim"`          // Send interim transcripts while the user speaks
+	InterimInterval Duration `yaml:"interim_interval"` // Least time between interim transcripts
+	Words           bool     `yaml:"words"`            // Send word timings and confidence with transcripts
+}
+
+// SpeculationConfig holds the settings of speculative LLM requests, started
+// on a stable interim transcript before the user finished speaking
+type SpeculationConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Stable  Duration `yaml:"stable"` // How long an interim transcript must stay unchanged
+}
+
+// ConfirmationConfig holds when the assistant checks what it heard before
+// answering. Transcripts without a confidence are never checked.
+type ConfirmationConfig struct {
+	Threshold       float64 `yaml:"threshold"`        // Confidence below which the assistant asks whether it heard right; 0 never asks
+	RepeatThreshold float64 `yaml:"repeat_threshold"` // Confidence below which it asks the user to repeat instead
+	Alternatives    int     `yaml:"alternatives"`     // Alternative transcripts offered with the question
+	MaxQuestions    int     `yaml:"max_questions"`    // Questions asked in one turn before answering what was heard
+	Prompt          string  `yaml:"prompt"`           // Question about the transcripts, which replace %s
+	RepeatPrompt    string  `yaml:"repeat_prompt"`
+}
+
+// AdminConfig holds the settings of the admin dashboard and API for live
+// sessions
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"` // Bearer token, or basic auth password, required by every admin request
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config files
+type Duration = duration.Duration
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() AppConfig {
+	return AppConfig{
+		Server: ServerConfig{
+			Port:            "8080",
+			DrainTimeout:    Duration(30 * time.Second),
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+		Services: ServicesConfig{
+			Vad:     "localhost:50051",
+			Trigger: "localhost:50052",
+			Stt:     "localhost:50053",
+			Tts:     "localhost:50054",
+			Llm:     "http://localhost:8000",
+		},
+		Balancing: BalancingConfig{
+			Policy:          BalancingRoundRobin,
+			ResolveInterval: Duration(30 * time.Second),
+			EjectAfter:      3,
+			EjectFor:        Duration(30 * time.Second),
+			Sticky:          true,
+		},
+		TLS: TLSConfig{
+			ReloadInterval: Duration(time.Minute),
+		},
+		Vad: VadConfig{
+			Mode:            VadModeAuto,
+			ChunkSizeBytes:  512 * 2, // 512 samples * 2 bytes per sample (16-bit)
+			EventBufferSize: 100,
+			Local: LocalVadConfig{
+				Frame:            Duration(20 * time.Millisecond),
+				MinLevel:         0.01,
+				Threshold:        3,
+				NoiseAdaptation:  0.05,
+				MaxZeroCrossings: 0.35,
+				MinSpeech:        Duration(100 * time.Millisecond),
+				Hangover:         Duration(500 * time.Millisecond),
+			},
+		},
+		Trigger: TriggerConfig{
+			Threshold:     0.5,
+			Window:        Duration(2 * time.Second),
+			CheckInterval: Duration(250 * time.Millisecond),
+			Timeout:       Duration(time.Second),
+			MinCommand:    Duration(600 * time.Millisecond),
+		},
+		Llm: LlmConfig{
+			Provider:     "openai",
+			Model:        "mistralai/Mistral-7B-Instruct-v0.2",
+			Timeout:      Duration(30 * time.Second),
+			SystemPrompt: "You are a helpful voice assistant. Answer briefly in plain spoken language.",
+			Sampling: SamplingConfig{
+				Temperature: 0.7,
+				TopP:        1.0,
+				MaxTokens:   512,
+			},
+		},
+		Tts: VoiceConfig{
+			LanguageCode: "en-US",
+			SpeakingRate: 1.0,
+		},
+		Audio: AudioConfig{
+			OpusBitrate:     32000,
+			MicOpus:         true,
+			PlaybackTimeout: Duration(2 * time.Second),
+		},
+		Echo: EchoConfig{
+			Default:     false,
+			Mode:        EchoModeSuppress,
+			Threshold:   0.7,
+			Attenuation: 20,
+			MaxDelay:    Duration(300 * time.Millisecond),
+			Tail:        Duration(300 * time.Millisecond),
+		},
+		Stt: SttConfig{
+			Interim:         false,
+			InterimInterval: Duration(250 * time.Millisecond),
+		},
+		Speculation: SpeculationConfig{
+			Stable: Duration(300 * time.Millisecond),
+		},
+		Confirmation: ConfirmationConfig{
+			Threshold:       0,
+			RepeatThreshold: 0.2,
+			Alternatives:    2,
+			MaxQuestions:    2,
+			Prompt:          "Did you say %s?",
+			RepeatPrompt:    "Sorry, I didn't catch that. Could you say it again?",
+		},
+		Sentences: SentenceConfig{
+			Terminators: ".!?",
+			MinLength:   1,
+		},
+		Limits: LimitsConfig{
+			MaxUtteranceChunks: 2000,
+			MaxResponseChars:   4000,
+		},
+		Tools: ToolsConfig{
+			Enabled:   true,
+			Timeout:   Duration(5 * time.Second),
+			MaxRounds: 3,
+			Filler:    "Let me check.",
+		},
+		Personas: PersonasConfig{
+			Default: "assistant",
+			Presets: map[string]PersonaConfig{
+				"assistant": {Description: "General purpose voice assistant"},
+			},
+		},
+		Languages: LanguagesConfig{
+			Policy:   LanguagePolicyFixed,
+			Fallback: "en-US",
+			Supported: map[string]LanguageConfig{
+				"en-US": {Name: "English"},
+			},
+		},
+		History: HistoryConfig{
+			Backend: "none",
+			Path:    "data/history.db",
+			Timeout: Duration(2 * time.Second),
+		},
+		Recording: RecordingConfig{
+			Dir:           "data/recordings",
+			MaxAge:        Duration(7 * 24 * time.Hour),
+			MaxRecordings: 1000,
+		},
+		Resilience: ResilienceConfig{
+			Trigger:        BreakerConfig{FailureThreshold: 5, OpenFor: Duration(30 * time.Second), HalfOpenProbes: 1},
+			Stt:            BreakerConfig{Timeout: Duration(10 * time.Second), FailureThreshold: 3, OpenFor: Duration(30 * time.Second), HalfOpenProbes: 1},
+			Llm:            BreakerConfig{Timeout: Duration(15 * time.Second), FailureThreshold: 3, OpenFor: Duration(30 * time.Second), HalfOpenProbes: 1},
+			Tts:            BreakerConfig{Timeout: Duration(10 * time.Second), FailureThreshold: 3, OpenFor: Duration(30 * time.Second), HalfOpenProbes: 1},
+			TroubleMessage: "Sorry, I'm having trouble right now. Please try again in a moment.",
+		},
+		LogLevel: "info",
+	}
+}
+
+// ConfigError lists every problem found while loading a configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// LoadConfig builds the configuration from the defaults, the config file at
+// path (if any), the environment and the flags explicitly set on fs
+func LoadConfig(path string, fs *flag.FlagSet) (AppConfig, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return config, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	var problems []string
+	problems = append(problems, config.applyEnv()...)
+	if fs != nil {
+		problems = append(problems, config.applyFlags(fs)...)
+	}
+	problems = append(problems, config.validate()...)
+
+	if len(problems) > 0 {
+		return config, &ConfigError{Problems: problems}
+	}
+	return config, nil
+}
+
+// applyEnv overrides the configuration with environment variables
+func (c *AppConfig) applyEnv() []string {
+	var problems []string
+
+	envStrings := map[string]*string{
+		"PORT":            &c.Server.Port,
+		"VAD_SERVICE":     &c.Services.Vad,
+		"VAD_MODE":        &c.Vad.Mode,
+		"TRIGGER_SERVICE": &c.Services.Trigger,
+		"STT_SERVICE":     &c.Services.Stt,
+		"TTS_SERVICE":     &c.Services.Tts,
+		"LLM_SERVICE":     &c.Services.Llm,
+		"LLM_PROVIDER":    &c.Llm.Provider,
+		"LLM_MODEL":       &c.Llm.Model,
+		"LLM_API_KEY":     &c.Llm.APIKey,
+		"ADMIN_TOKEN":     &c.Admin.Token,
+		"TLS_CERT_FILE":   &c.TLS.Server.CertFile,
+		"TLS_KEY_FILE":    &c.TLS.Server.KeyFile,
+		"LOG_LEVEL":       &c.LogLevel,
+	}
+	for key, field := range envStrings {
+		if value, exists := os.LookupEnv(key); exists {
+			*field = value
+		}
+	}
+
+	if value, exists := os.LookupEnv("DRAIN_TIMEOUT"); exists {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("DRAIN_TIMEOUT: invalid duration %q", value))
+		} else {
+			c.Server.DrainTimeout = Duration(duration)
+		}
+	}
+
+	return problems
+}
+
+// applyFlags overrides the configuration with the flags set on the command line
+func (c *AppConfig) applyFlags(fs *flag.FlagSet) []string {
+	var problems []string
+
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "port":
+			c.Server.Port = value
+		case "vad":
+			c.Services.Vad = value
+		case "trigger":
+			c.Services.Trigger = value
+		case "stt":
+			c.Services.Stt = value
+		case "tts":
+			c.Services.Tts = value
+		case "llm":
+			c.Services.Llm = value
+		case "llm-provider":
+			c.Llm.Provider = value
+		case "llm-model":
+			c.Llm.Model = value
+		case "tls-cert":
+			c.TLS.Server.CertFile = value
+		case "tls-key":
+			c.TLS.Server.KeyFile = value
+		case "log-level":
+			c.LogLevel = value
+		case "drain-timeout":
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("-drain-timeout: invalid duration %q", value))
+				return
+			}
+			c.Server.DrainTimeout = Duration(duration)
+		}
+	})
+
+	return problems
+}
+
+// validate checks the configuration and returns a description of every problem
+func (c *AppConfig) validate() []string {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port: %q is not a valid port", c.Server.Port)
+	check(c.Server.DrainTimeout >= 0, "server.drain_timeout: must not be negative")
+	check(c.Server.ReadBufferSize > 0, "server.read_buffer_size: must be positive")
+	check(c.Server.WriteBufferSize > 0, "server.write_buffer_size: must be positive")
+
+	check(len(splitAddresses(c.Services.Vad)) > 0, "services.vad: address is required")
+	check(len(splitAddresses(c.Services.Trigger)) > 0, "services.trigger: address is required")
+	check(len(splitAddresses(c.Services.Stt)) > 0, "services.stt: address is required")
+	check(len(splitAddresses(c.Services.Tts)) > 0, "services.tts: address is required")
+	llmReplicas := splitAddresses(c.Services.Llm)
+	check(len(llmReplicas) > 0, "services.llm: address is required")
+	for _, replica := range llmReplicas {
+		llmURL, err := url.Parse(replica)
+		check(err == nil && (llmURL.Scheme == "http" || llmURL.Scheme == "https") && llmURL.Host != "",
+			"services.llm: %q must be an http(s) URL", replica)
+	}
+
+	check(c.Balancing.Policy == BalancingRoundRobin || c.Balancing.Policy == BalancingLeastOutstanding,
+		"balancing.policy: %q is not one of %s, %s", c.Balancing.Policy, BalancingRoundRobin, BalancingLeastOutstanding)
+	check(!c.Balancing.Resolve || c.Balancing.ResolveInterval >= 0, "balancing.resolve_interval: must not be negative")
+	check(c.Balancing.EjectAfter >= 0, "balancing.eject_after: must not be negative")
+	check(c.Balancing.EjectAfter == 0 || c.Balancing.EjectFor > 0, "balancing.eject_for: must be positive")
+
+	check(c.TLS.ReloadInterval >= 0, "tls.reload_interval: must not be negative")
+	check((c.TLS.Server.CertFile == "") == (c.TLS.Server.KeyFile == ""), "tls.server: cert_file and key_file must be set together")
+	backendsTLS := map[string]ClientTLSConfig{
+		"vad":          c.TLS.Vad,
+		"trigger":      c.TLS.Trigger,
+		"stt":          c.TLS.Stt,
+		"tts":          c.TLS.Tts,
+		"llm":          c.TLS.Llm,
+		"fallback_llm": c.TLS.FallbackLlm,
+	}
+	for name, backend := range backendsTLS {
+		check((backend.CertFile == "") == (backend.KeyFile == ""), "tls.%s: cert_file and key_file must be set together", name)
+	}
+
+	check(c.Vad.ChunkSizeBytes > 0 && c.Vad.ChunkSizeBytes%2 == 0, "vad.chunk_size_bytes: must be a positive even number")
+	check(c.Vad.EventBufferSize > 0, "vad.event_buffer_size: must be positive")
+	check(c.Vad.Mode == VadModeAuto || c.Vad.Mode == VadModeService || c.Vad.Mode == VadModeLocal,
+		"vad.mode: %q is not one of %s, %s, %s", c.Vad.Mode, VadModeAuto, VadModeService, VadModeLocal)
+	local := c.Vad.Local
+	check(local.Frame >= Duration(5*time.Millisecond) && local.Frame <= Duration(100*time.Millisecond),
+		"vad.local.frame: must be between 5ms and 100ms")
+	check(local.MinLevel > 0 && local.MinLevel < 1, "vad.local.min_level: must be between 0 and 1")
+	check(local.Threshold >= 1, "vad.local.threshold: must be at least 1")
+	check(local.NoiseAdaptation > 0 && local.NoiseAdaptation <= 1, "vad.local.noise_adaptation: must be greater than 0 and at most 1")
+	check(local.MaxZeroCrossings > 0 && local.MaxZeroCrossings <= 1, "vad.local.max_zero_crossings: must be greater than 0 and at most 1")
+	check(local.MinSpeech >= 0, "vad.local.min_speech: must not be negative")
+	check(local.Hangover >= 0, "vad.local.hangover: must not be negative")
+
+	check(c.Trigger.Threshold > 0 && c.Trigger.Threshold <= 1, "trigger.threshold: must be greater than 0 and at most 1")
+	check(c.Trigger.Window > 0, "trigger.window: must be positive")
+	check(c.Trigger.CheckInterval > 0, "trigger.check_interval: must be positive")
+	check(c.Trigger.Timeout > 0, "trigger.timeout: must be positive")
+	check(c.Trigger.MinCommand >= 0, "trigger.min_command: must not be negative")
+	phrases := make(map[string]bool)
+	for i, wakeWord := range c.Trigger.WakeWords {
+		phrase := strings.ToLower(strings.TrimSpace(wakeWord.Phrase))
+		check(phrase != "", "trigger.wake_words[%d].phrase: is required", i)
+		check(phrase == "" || !phrases[phrase], "trigger.wake_words[%d].phrase: %q is used twice", i, wakeWord.Phrase)
+		phrases[phrase] = true
+		check(wakeWord.Threshold >= 0 && wakeWord.Threshold <= 1, "trigger.wake_words[%d].threshold: must be between 0 and 1", i)
+		_, known := c.Personas.Presets[wakeWord.Persona]
+		check(wakeWord.Persona == "" || known, "trigger.wake_words[%d].persona: %q is not a preset", i, wakeWord.Persona)
+	}
+
+	provider, known := llmProviders[c.Llm.Provider]
+	check(known, "llm.provider: %q is not one of %s", c.Llm.Provider, strings.Join(LlmProviderNames(), ", "))
+	check(!known || !provider.RequiresModel || c.Llm.Model != "", "llm.model: required by the %s provider", c.Llm.Provider)
+	check(c.Llm.Timeout > 0, "llm.timeout: must be positive")
+	check(c.Llm.Sampling.Temperature >= 0 && c.Llm.Sampling.Temperature <= 2, "llm.sampling.temperature: must be between 0 and 2")
+	check(c.Llm.Sampling.TopP > 0 && c.Llm.Sampling.TopP <= 1, "llm.sampling.top_p: must be greater than 0 and at most 1")
+	check(c.Llm.Sampling.MaxTokens >= 0, "llm.sampling.max_tokens: must not be negative")
+
+	check(c.Tts.SpeakingRate >= minSpeakingRate && c.Tts.SpeakingRate <= maxSpeakingRate,
+		"tts.speaking_rate: must be between %g and %g", minSpeakingRate, maxSpeakingRate)
+	check(c.Tts.Pitch >= minPitch && c.Tts.Pitch <= maxPitch, "tts.pitch: must be between %g and %g", minPitch, maxPitch)
+
+	check(!c.Audio.Transcode || newOpusEncoder != nil, "audio.transcode: requires a build with the opus tag")
+	check(c.Audio.OpusBitrate >= 6000 && c.Audio.OpusBitrate <= 510000, "audio.opus_bitrate: must be between 6000 and 510000")
+	check(c.Audio.PlaybackTimeout >= 0, "audio.playback_timeout: must not be negative")
+
+	check(c.Echo.Mode == EchoModeSuppress || c.Echo.Mode == EchoModeAttenuate,
+		"echo.mode: %q must be %s or %s", c.Echo.Mode, EchoModeSuppress, EchoModeAttenuate)
+	check(c.Echo.Threshold > 0 && c.Echo.Threshold <= 1, "echo.threshold: must be greater than 0 and at most 1")
+	check(c.Echo.Attenuation > 0, "echo.attenuation: must be positive")
+	check(c.Echo.MaxDelay > 0 && c.Echo.MaxDelay.Std() <= 2*time.Second, "echo.max_delay: must be positive and at most 2s")
+	check(c.Echo.Tail >= 0, "echo.tail: must not be negative")
+	check(c.Stt.InterimInterval >= 0, "stt.interim_interval: must not be negative")
+	check(!c.Speculation.Enabled || c.Stt.Interim, "speculation.enabled: requires stt.interim")
+	check(c.Speculation.Stable > 0, "speculation.stable: must be positive")
+
+	confirmation := c.Confirmation
+	check(confirmation.Threshold >= 0 && confirmation.Threshold <= 1, "confirmation.threshold: must be between 0 and 1")
+	check(confirmation.RepeatThreshold >= 0 && (confirmation.Threshold == 0 || confirmation.RepeatThreshold <= confirmation.Threshold), "confirmation.repeat_threshold: must be between 0 and confirmation.threshold")
+	check(confirmation.Alternatives >= 0, "confirmation.alternatives: must not be negative")
+	check(confirmation.MaxQuestions >= 0, "confirmation.max_questions: must not be negative")
+	check(confirmation.Threshold == 0 || strings.Count(confirmation.Prompt, "%s") == 1, "confirmation.prompt: must contain %%s once")
+	check(confirmation.Threshold == 0 || confirmation.RepeatPrompt != "", "confirmation.repeat_prompt: is required")
+
+	check(c.Sentences.Terminators != "", "sentences.terminators: must not be empty")
+	check(c.Sentences.MinLength >= 0, "sentences.min_length: must not be negative")
+
+	check(c.Limits.MaxUtteranceChunks >= 0, "limits.max_utterance_chunks: must not be negative")
+	check(c.Limits.MaxResponseChars >= 0, "limits.max_response_chars: must not be negative")
+
+	_, known = c.Personas.Presets[c.Personas.Default]
+	check(known, "personas.default: %q is not a preset", c.Personas.Default)
+	for name, persona := range c.Personas.Presets {
+		rate := persona.Voice.SpeakingRate
+		check(rate == 0 || (rate >= minSpeakingRate && rate <= maxSpeakingRate),
+			"personas.presets.%s.voice.speaking_rate: must be between %g and %g", name, minSpeakingRate, maxSpeakingRate)
+		check(persona.Voice.Pitch >= minPitch && persona.Voice.Pitch <= maxPitch,
+			"personas.presets.%s.voice.pitch: must be between %g and %g", name, minPitch, maxPitch)
+		sampling := persona.Sampling
+		check(sampling == SamplingConfig{} || (sampling.TopP > 0 && sampling.TopP <= 1 && sampling.Temperature >= 0 && sampling.Temperature <= 2),
+			"personas.presets.%s.sampling: temperature must be between 0 and 2 and top_p greater than 0 and at most 1", name)
+	}
+
+	check(c.Languages.Policy == LanguagePolicyFixed || c.Languages.Policy == LanguagePolicyAuto,
+		"languages.policy: %q must be %s or %s", c.Languages.Policy, LanguagePolicyFixed, LanguagePolicyAuto)
+	_, known = c.Languages.Supported[c.Languages.Fallback]
+	check(known, "languages.fallback: %q is not a supported language", c.Languages.Fallback)
+
+	switch c.History.Backend {
+	case "none":
+	case "jsonl", "sqlite":
+		check(c.History.Path != "", "history.path: required by the %s backend", c.History.Backend)
+	default:
+		problems = append(problems, fmt.Sprintf("history.backend: %q is not one of none, jsonl, sqlite", c.History.Backend))
+	}
+	check(c.History.Timeout > 0, "history.timeout: must be positive")
+
+	check(!c.Recording.Enabled || c.Recording.Dir != "", "recording.dir: required when recording is enabled")
+	check(c.Recording.MaxAge >= 0, "recording.max_age: must not be negative")
+	check(c.Recording.MaxRecordings >= 0, "recording.max_recordings: must not be negative")
+
+	breakers := map[string]BreakerConfig{
+		"trigger": c.Resilience.Trigger,
+		"stt":     c.Resilience.Stt,
+		"llm":     c.Resilience.Llm,
+		"tts":     c.Resilience.Tts,
+	}
+	for name, breaker := range breakers {
+		check(breaker.Timeout >= 0, "resilience.%s.timeout: must not be negative", name)
+		check(breaker.FailureThreshold >= 0, "resilience.%s.failure_threshold: must not be negative", name)
+		check(breaker.FailureThreshold == 0 || breaker.OpenFor > 0, "resilience.%s.open_for: must be positive", name)
+		check(breaker.FailureThreshold == 0 || breaker.HalfOpenProbes > 0, "resilience.%s.half_open_probes: must be positive", name)
+	}
+	if fallback := c.Resilience.FallbackLlm; fallback.Service != "" {
+		for _, replica := range splitAddresses(fallback.Service) {
+			fallbackURL, err := url.Parse(replica)
+			check(err == nil && (fallbackURL.Scheme == "http" || fallbackURL.Scheme == "https") && fallbackURL.Host != "",
+				"resilience.fallback_llm.service: %q must be an http(s) URL", replica)
+		}
+		llm := c.fallbackLlmConfig()
+		provider, known := llmProviders[llm.Provider]
+		check(known, "resilience.fallback_llm.provider: %q is not one of %s", llm.Provider, strings.Join(LlmProviderNames(), ", "))
+		check(!known || !provider.RequiresModel || llm.Model != "", "resilience.fallback_llm.model: required by the %s provider", llm.Provider)
+	}
+
+	for _, server := range splitAddresses(c.Rtc.IceServers) {
+		check(strings.HasPrefix(server, "stun:") || strings.HasPrefix(server, "stuns:"),
+			"rtc.ice_servers: %q must be a stun: or stuns: URL", server)
+	}
+	for _, ip := range splitAddresses(c.Rtc.PublicIPs) {
+		check(net.ParseIP(ip) != nil, "rtc.public_ips: %q is not an IP address", ip)
+	}
+	check(c.Rtc.PortMin >= 0 && c.Rtc.PortMax <= 65535 && c.Rtc.PortMin <= c.Rtc.PortMax,
+		"rtc.port_min, rtc.port_max: must be a range of UDP ports")
+	check(!c.Rtc.Enabled || newOpusDecoder != nil, "rtc.enabled: requires a build with the opus tag")
+
+	check(!c.Admin.Enabled || c.Admin.Token != "", "admin.token: required when the admin API is enabled")
+
+	check(c.Tools.Timeout > 0, "tools.timeout: must be positive")
+	check(c.Tools.MaxRounds >= 0, "tools.max_rounds: must not be negative")
+
+	_, err = ParseLogLevel(c.LogLevel)
+	check(err == nil, "log_level: %v", err)
+
+	return problems
+}
+
+// fallbackLlmConfig returns the llm settings of the fallback LLM
+func (c AppConfig) fallbackLlmConfig() LlmConfig {
+	llm := c.Llm
+	fallback := c.Resilience.FallbackLlm
+	if fallback.Provider != "" {
+		llm.Provider = fallback.Provider
+	}
+	if fallback.Model != "" {
+		llm.Model = fallback.Model
+	}
+	if fallback.APIKey != "" {
+		llm.APIKey = fallback.APIKey
+	}
+	return llm
+}
+
+// restartRequiredChanges lists the settings that differ between c and other
+// but can only be applied by restarting the server
+func (c AppConfig) restartRequiredChanges(other AppConfig) []string {
+	var changed []string
+	if c.Server != other.Server {
+		changed = append(changed, "server")
+	}
+	if c.Services != other.Services {
+		changed = append(changed, "services")
+	}
+	if c.Balancing != other.Balancing {
+		changed = append(changed, "balancing")
+	}
+	if c.TLS != other.TLS {
+		changed = append(changed, "tls")
+	}
+	if c.Vad != other.Vad {
+		changed = append(changed, "vad")
+	}
+	if c.History != other.History {
+		changed = append(changed, "history")
+	}
+	if c.Recording != other.Recording {
+		changed = append(changed, "recording")
+	}
+	if c.Resilience != other.Resilience {
+		changed = append(changed, "resilience")
+	}
+	if c.Rtc != other.Rtc {
+		changed = append(changed, "rtc")
+	}
+	if c.Admin.Enabled != other.Admin.Enabled {
+		changed = append(changed, "admin.enabled")
+	}
+	llm, otherLlm := c.Llm, other.Llm
+	llm.SystemPrompt, otherLlm.SystemPrompt = "", ""
+	if llm != otherLlm {
+		changed = append(changed, "llm")
+	}
+	return changed
+}
+
+// withReloadable returns a copy of c with the settings that can safely change
+// at runtime taken from other
+func (c AppConfig) withReloadable(other AppConfig) AppConfig {
+	c.Trigger = other.Trigger
+	c.Llm.SystemPrompt = other.Llm.SystemPrompt
+	c.Tts = other.Tts
+	c.Audio = other.Audio
+	c.Echo = other.Echo
+	c.Stt = other.Stt
+	c.Speculation = other.Speculation
+	c.Confirmation = other.Confirmation
+	c.Sentences = other.Sentences
+	c.Limits = other.Limits
+	c.Tools = other.Tools
+	c.Personas = other.Personas
+	c.Languages = other.Languages
+	c.Admin.Token = other.Admin.Token
+	c.LogLevel = other.LogLevel
+	return c
+}
+
+// Redacted returns a copy of c with secrets masked, suitable for printing
+func (c AppConfig) Redacted() AppConfig {
+	if c.Llm.APIKey != "" {
+		c.Llm.APIKey = "********"
+	}
+	if c.Resilience.FallbackLlm.APIKey != "" {
+		c.Resilience.FallbackLlm.APIKey = "********"
+	}
+	if c.Admin.Token != "" {
+		c.Admin.Token = "********"
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes data to a config file in a temporary directory
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	config := DefaultConfig()
+	if problems := config.validate(); len(problems) > 0 {
+		t.Fatalf("default config problems: %v", problems)
+	}
+}
+
+func TestLoadConfigLayering(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: "9000"
+  drain_timeout: 10s
+services:
+  stt: file-stt:50053
+llm:
+  model: file-model
+`)
+	t.Setenv("STT_SERVICE", "env-stt:50053")
+	t.Setenv("LLM_MODEL", "env-model")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("llm-model", "", "")
+	fs.Duration("drain-timeout", 0, "")
+	if err := fs.Parse([]string{"-llm-model", "flag-model"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path, fs)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.Server.Port != "9000" {
+		t.Errorf("port = %q, want the file's 9000", config.Server.Port)
+	}
+	if config.Server.DrainTimeout != Duration(10*time.Second) {
+		t.Errorf("drain timeout = %s, want the file's 10s (flag not set)", config.Server.DrainTimeout.Std())
+	}
+	if config.Services.Stt != "env-stt:50053" {
+		t.Errorf("stt = %q, want the environment's", config.Services.Stt)
+	}
+	if config.Llm.Model != "flag-model" {
+		t.Errorf("model = %q, want the flag's", config.Llm.Model)
+	}
+	if config.Services.Tts != DefaultConfig().Services.Tts {
+		t.Errorf("tts = %q, want the default", config.Services.Tts)
+	}
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, "server:\n  prot: \"9000\"\n")
+	if _, err := LoadConfig(path, nil); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Fatalf("err = %v, want unknown field prot", err)
+	}
+}
+
+func TestLoadConfigInvalidDuration(t *testing.T) {
+	path := writeConfig(t, "server:\n  drain_timeout: soon\n")
+	if _, err := LoadConfig(path, nil); err == nil || !strings.Contains(err.Error(), `invalid duration "soon"`) {
+		t.Fatalf("err = %v, want invalid duration", err)
+	}
+
+	t.Setenv("DRAIN_TIMEOUT", "later")
+	var configErr *ConfigError
+	if _, err := LoadConfig("", nil); !errors.As(err, &configErr) {
+		t.Fatalf("err = %v, want ConfigError", err)
+	}
+	if len(configErr.Problems) != 1 || !strings.HasPrefix(configErr.Problems[0], "DRAIN_TIMEOUT") {
+		t.Errorf("problems = %v, want DRAIN_TIMEOUT", configErr.Problems)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.Port = "http"
+	config.Services.Llm = "llm-service:8000"
+	config.Trigger.Threshold = 2
+	config.TLS.Server.CertFile = "server.pem"
+
+	problems := config.validate()
+	for _, want := range []string{"server.port", "services.llm", "trigger.threshold", "tls.server"} {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, want+":")
+		}
+		if !found {
+			t.Errorf("problems %v do not mention %s", problems, want)
+		}
+	}
+}
+
+func TestWithReloadable(t *testing.T) {
+	running := DefaultConfig()
+	reloaded := DefaultConfig()
+	reloaded.Server.Port = "9000"
+	reloaded.Llm.Model = "other-model"
+	reloaded.Llm.SystemPrompt = "Be brief."
+	reloaded.Trigger.Threshold = 0.8
+	reloaded.LogLevel = "debug"
+
+	changes := running.restartRequiredChanges(reloaded)
+	if strings.Join(changes, ",") != "server,llm" {
+		t.Errorf("restart required changes = %v, want server and llm", changes)
+	}
+
+	applied := running.withReloadable(reloaded)
+	if applied.Server.Port != running.Server.Port || applied.Llm.Model != running.Llm.Model {
+		t.Errorf("restart-only settings changed: port %q, model %q", applied.Server.Port, applied.Llm.Model)
+	}
+	if applied.Llm.SystemPrompt != "Be brief." || applied.Trigger.Threshold != 0.8 || applied.LogLevel != "debug" {
+		t.Errorf("reloadable settings not applied: %+v", applied)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	config := DefaultConfig()
+	config.Llm.APIKey = "secret-key"
+	config.Admin.Token = "secret-token"
+
+	redacted := config.Redacted()
+	if redacted.Llm.APIKey == "secret-key" || redacted.Admin.Token == "secret-token" {
+		t.Errorf("secrets not masked: %q, %q", redacted.Llm.APIKey, redacted.Admin.Token)
+	}
+	if config.Llm.APIKey != "secret-key" {
+		t.Error("Redacted modified the original config")
+	}
+}
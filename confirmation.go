@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Questions asked about an uncertain transcript
+const (
+	QuestionConfirm = "confirm" // Did you say ...?
+	QuestionRepeat  = "repeat"  // Could you say it again?
+)
+
+// How the answer to a question was taken
+const (
+	AnswerConfirmed   = "confirmed"   // The user said yes or repeated the transcript
+	AnswerAlternative = "alternative" // The user picked an alternative transcript
+	AnswerRejected    = "rejected"    // The user said no
+	AnswerRestated    = "restated"    // The user said something else, taken as the request
+)
+
+// Answers meaning yes or no to a confirmation, as normalized transcripts
+var (
+	yesAnswers = map[string]bool{
+		"yes": true, "yeah": true, "yep": true, "yup": true, "sure": true, "correct": true,
+		"right": true, "that's right": true, "exactly": true, "yes please": true, "yes i did": true,
+	}
+	noAnswers = map[string]bool{
+		"no": true, "nope": true, "wrong": true, "that's wrong": true, "no i didn't": true, "not really": true,
+	}
+)
+
+// question is a question asked about an uncertain transcript. The next
+// utterance of the turn answers it.
+type question struct {
+	kind    string
+	heard   Transcription
+	choices []string // Transcripts offered, the most likely first
+}
+
+// sttAlternatives returns how many alternative transcripts to ask STT for
+func (c ConfirmationConfig) sttAlternatives() int {
+	if c.Threshold == 0 {
+		return 0
+	}
+	return c.Alternatives
+}
+
+// settle returns the request heard stands for after the question it
+// answers, if any, and how the answer was taken. When the request still
+// needs checking it also returns the question to ask, as long as fewer than
+// confirmation.max_questions were asked in the turn. A rejected transcript
+// without a question left returns an empty request.
+func (c ConfirmationConfig) settle(pending *question, heard Transcription, asked int) (Transcription, string, *question) {
+	request, answer := heard, ""
+	if pending != nil {
+		request, answer = pending.answer(heard)
+	}
+
+	switch {
+	case answer == AnswerConfirmed || answer == AnswerAlternative:
+		return request, answer, nil
+	case answer == AnswerRejected && asked < c.MaxQuestions:
+		return request, answer, &question{kind: QuestionRepeat, heard: pending.heard}
+	case answer == AnswerRejected:
+		return Transcription{}, answer, nil
+	}
+	return request, answer, c.questionFor(request, asked)
+}
+
+// questionFor returns the question to ask about transcription, or nil if it
+// is certain enough or the turn has no question left
+func (c ConfirmationConfig) questionFor(transcription Transcription, asked int) *question {
+	confidence := transcription.Confidence
+	if confidence == 0 || confidence >= c.Threshold || asked >= c.MaxQuestions {
+		return nil
+	}
+	if confidence < c.RepeatThreshold || normalizeTranscript(transcription.Text) == "" {
+		return &question{kind: QuestionRepeat, heard: transcription}
+	}
+
+	// Offer the alternatives that say something different
+	choices := []string{transcription.Text}
+	seen := map[string]bool{normalizeTranscript(transcription.Text): true}
+	for _, alternative := range transcription.Alternatives {
+		normalized := normalizeTranscript(alternative.Text)
+		if len(choices) > c.Alternatives || normalized == "" || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		choices = append(choices, alternative.Text)
+	}
+	return &question{kind: QuestionConfirm, heard: transcription, choices: choices}
+}
+
+// prompt returns what the assistant says to ask q
+func (q *question) prompt(config ConfirmationConfig) string {
+	if q.kind == QuestionRepeat {
+		return config.RepeatPrompt
+	}
+
+	quoted := make([]string, len(q.choices))
+	for i, choice := range q.choices {
+		quoted[i] = fmt.Sprintf("%q", strings.TrimRight(strings.TrimSpace(choice), ".!?"))
+	}
+	choices := quoted[0]
+	if last := len(quoted) - 1; last > 0 {
+		choices = strings.Join(quoted[:last], ", ") + " or " + quoted[last]
+	}
+	return fmt.Sprintf(config.Prompt, choices)
+}
+
+// answer returns the request the reply to q stands for and how the reply was
+// taken. A confirmed request keeps the language it was heard in.
+func (q *question) answer(reply Transcription) (Transcription, string) {
+	if q.kind == QuestionRepeat {
+		return reply, AnswerRestated
+	}
+
+	normalized := normalizeTranscript(reply.Text)
+	for i, choice := range q.choices {
+		if normalized != normalizeTranscript(choice) {
+			continue
+		}
+		if i == 0 {
+			return q.heard, AnswerConfirmed
+		}
+		chosen := q.heard
+		chosen.Text = choice
+		return chosen, AnswerAlternative
+	}
+	switch {
+	case yesAnswers[normalized]:
+		return q.heard, AnswerConfirmed
+	case noAnswers[normalized]:
+		return reply, AnswerRejected
+	}
+	return reply, AnswerRestated
+}
+
+// askQuestion speaks q and listens for the answer within the same turn. It
+// returns false if the turn ended.
+func (cs *ClientState) askQuestion(turn *turnContext, config AppConfig, voice VoiceConfig, record *TurnRecord, q *question) bool {
+	text := q.prompt(config.Confirmation)
+	log.Printf("Transcript %q has confidence %.2f, asking the user to %s", q.heard.Text, q.heard.Confidence, q.kind)
+	cs.recordEvent("confirmation", q.kind, fmt.Sprintf("%q confidence %.2f", q.heard.Text, q.heard.Confidence))
+	cs.app.metrics.inc(metricQuestions, q.kind)
+	record.Question = q.kind
+	record.Alternatives = q.heard.Alternatives
+
+	// The answer is a new utterance, so a response started on this one is not needed
+	cs.dropSpeculation()
+
+	if !cs.advanceTurn(turn, StateSpeaking, "Checking what I heard...") {
+		return false
+	}
+	cs.sendResponse(text)
+	record.addResponse(text)
+	ttsCtx, endTts := turn.enter(StageTts)
+	if audio := cs.synthesizeAndSend(ttsCtx, text, voice); audio != nil {
+		record.addReplyAudio(audio)
+	}
+	endTts()
+	if turn.ctx.Err() != nil {
+		return false
+	}
+
+	// Listen once the question was played, so it is not heard as the answer
+	playbackCtx, endPlayback := turn.enter(StagePlayback)
+	cs.waitForPlayback(playbackCtx, config)
+	endPlayback()
+	if turn.ctx.Err() != nil {
+		return false
+	}
+
+	turn.ask(q)
+	if !cs.advanceTurn(turn, StateTriggered, "Listening for your answer...") {
+		return false
+	}
+	cs.openUtterance(config)
+	return true
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// confirmationConfig returns the default confirmation settings, turned on
+func confirmationConfig() ConfirmationConfig {
+	config := DefaultConfig().Confirmation
+	config.Threshold = 0.5
+	return config
+}
+
+// heardJazz is an uncertain transcript with alternatives
+var heardJazz = Transcription{
+	Text:         "Play some jazz.",
+	LanguageCode: "en-US",
+	Confidence:   0.4,
+	Alternatives: []Alternative{{Text: "play some jazz"}, {Text: "Play some chess.", Confidence: 0.3}, {Text: "Play sunjazz."}, {Text: "Plays um jazz"}},
+}
+
+func TestSttAlternatives(t *testing.T) {
+	if got := DefaultConfig().Confirmation.sttAlternatives(); got != 0 {
+		t.Errorf("alternatives with confirmation off = %d, want 0", got)
+	}
+	if got := confirmationConfig().sttAlternatives(); got != 2 {
+		t.Errorf("alternatives = %d, want 2", got)
+	}
+}
+
+func TestQuestionFor(t *testing.T) {
+	config := confirmationConfig()
+	tests := []struct {
+		name          string
+		transcription Transcription
+		asked         int
+		kind          string
+		choices       []string
+	}{
+		{"no confidence", Transcription{Text: "play some jazz"}, 0, "", nil},
+		{"certain", Transcription{Text: "play some jazz", Confidence: 0.8}, 0, "", nil},
+		{"unsure", heardJazz, 0, QuestionConfirm, []string{"Play some jazz.", "Play some chess.", "Play sunjazz."}},
+		{"no question left", heardJazz, 2, "", nil},
+		{"barely heard", Transcription{Text: "play some jazz", Confidence: 0.1}, 0, QuestionRepeat, nil},
+		{"empty", Transcription{Text: "...", Confidence: 0.4}, 0, QuestionRepeat, nil},
+	}
+	for _, test := range tests {
+		q := config.questionFor(test.transcription, test.asked)
+		switch {
+		case test.kind == "" && q != nil:
+			t.Errorf("%s: asked %s", test.name, q.kind)
+		case test.kind != "" && (q == nil || q.kind != test.kind):
+			t.Errorf("%s: question %+v, want %s", test.name, q, test.kind)
+		case q != nil && !slices.Equal(q.choices, test.choices):
+			t.Errorf("%s: choices %q, want %q", test.name, q.choices, test.choices)
+		}
+	}
+
+	// Confirmation is off by default
+	if q := DefaultConfig().Confirmation.questionFor(heardJazz, 0); q != nil {
+		t.Errorf("asked %s with the default config", q.kind)
+	}
+}
+
+func TestQuestionPrompt(t *testing.T) {
+	config := confirmationConfig()
+	tests := []struct {
+		q    question
+		want string
+	}{
+		{question{kind: QuestionConfirm, choices: []string{"Play some jazz."}}, `Did you say "Play some jazz"?`},
+		{question{kind: QuestionConfirm, choices: []string{"Play some jazz.", "Play some chess!"}}, `Did you say "Play some jazz" or "Play some chess"?`},
+		{question{kind: QuestionConfirm, choices: []string{"a", "b", "c"}}, `Did you say "a", "b" or "c"?`},
+		{question{kind: QuestionRepeat}, config.RepeatPrompt},
+	}
+	for _, test := range tests {
+		if got := test.q.prompt(config); got != test.want {
+			t.Errorf("prompt = %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestQuestionAnswer(t *testing.T) {
+	confirm := &question{kind: QuestionConfirm, heard: heardJazz, choices: []string{"Play some jazz.", "Play some chess."}}
+	tests := []struct {
+		q      *question
+		reply  string
+		text   string
+		answer string
+	}{
+		{confirm, "Yes.", "Play some jazz.", AnswerConfirmed},
+		{confirm, "play some jazz", "Play some jazz.", AnswerConfirmed},
+		{confirm, "Play some chess!", "Play some chess.", AnswerAlternative},
+		{confirm, "No.", "No.", AnswerRejected},
+		{confirm, "Play some blues.", "Play some blues.", AnswerRestated},
+		{&question{kind: QuestionRepeat, heard: heardJazz}, "Yes.", "Yes.", AnswerRestated},
+	}
+	for _, test := range tests {
+		request, answer := test.q.answer(Transcription{Text: test.reply, LanguageCode: "de-DE"})
+		if request.Text != test.text || answer != test.answer {
+			t.Errorf("answer to %q = %q %s, want %q %s", test.reply, request.Text, answer, test.text, test.answer)
+		}
+		if (answer == AnswerConfirmed || answer == AnswerAlternative) && request.LanguageCode != heardJazz.LanguageCode {
+			t.Errorf("%q answered in %s, want the language it was heard in", test.reply, request.LanguageCode)
+		}
+	}
+}
+
+func TestSettle(t *testing.T) {
+	config := confirmationConfig()
+	confirm := config.questionFor(heardJazz, 0)
+
+	// Rejected transcripts are asked again while questions are left
+	_, answer, q := config.settle(confirm, Transcription{Text: "no"}, 1)
+	if answer != AnswerRejected || q == nil || q.kind != QuestionRepeat {
+		t.Errorf("rejection = %s, question %+v, want a repeat", answer, q)
+	}
+	request, answer, q := config.settle(confirm, Transcription{Text: "no"}, 2)
+	if answer != AnswerRejected || q != nil || request.Text != "" {
+		t.Errorf("last rejection = %q %s, question %+v, want nothing to answer", request.Text, answer, q)
+	}
+
+	// Accepted alternatives are answered at once
+	request, answer, q = config.settle(confirm, Transcription{Text: "play some chess", Confidence: 0.3}, 1)
+	if answer != AnswerAlternative || q != nil || request.Text != "Play some chess." {
+		t.Errorf("alternative = %q %s, question %+v", request.Text, answer, q)
+	}
+
+	// A restated request is checked like any other
+	if _, answer, q = config.settle(confirm, Transcription{Text: "play the blues", Confidence: 0.3}, 1); answer != AnswerRestated || q == nil {
+		t.Errorf("uncertain restatement = %s, question %+v, want another question", answer, q)
+	}
+	if _, answer, q = config.settle(nil, heardJazz, 0); answer != "" || q == nil {
+		t.Errorf("first transcript = %q, question %+v", answer, q)
+	}
+}
+
+func TestConfirmationFlow(t *testing.T) {
+	config := DefaultConfig()
+	config.Confirmation = confirmationConfig()
+	config.Audio.PlaybackTimeout = Duration(100 * time.Millisecond)
+	tests := []struct {
+		name      string
+		answers   []Transcription
+		responses []string
+		metric    counter
+		value     string
+	}{
+		{
+			name:      "alternative accepted",
+			answers:   []Transcription{{Text: "Play some chess.", Confidence: 0.9}},
+			responses: []string{`Did you say "Play some jazz", "Play some chess" or "Play sunjazz"?`, "Answering play some chess"},
+			metric:    metricAnswers,
+			value:     AnswerAlternative,
+		},
+		{
+			name:      "rejected and restated",
+			answers:   []Transcription{{Text: "No.", Confidence: 0.9}, {Text: "Play the blues.", Confidence: 0.9}},
+			responses: []string{`Did you say "Play some jazz", "Play some chess" or "Play sunjazz"?`, config.Confirmation.RepeatPrompt, "Answering play the blues"},
+			metric:    metricAnswers,
+			value:     AnswerRejected,
+		},
+	}
+	for _, test := range tests {
+		stt := &scriptedStt{results: append([]Transcription{heardJazz}, test.answers...)}
+		cs, conn := triggeredSession(t, config, stt)
+		cs.app.llmClient = echoingLlm{}
+
+		// Each utterance answers the question asked about the one before
+		for range len(stt.results) {
+			cs.processAudio()
+		}
+		if got := responses(conn); !slices.Equal(got, test.responses) {
+			t.Errorf("%s: responses %q, want %q", test.name, got, test.responses)
+		}
+		if cs.activeTurn() != nil || cs.getState() != StateIdle {
+			t.Errorf("%s: state %s after the turn", test.name, cs.getState())
+		}
+		if got := cs.app.metrics.count(test.metric, test.value); got != 1 {
+			t.Errorf("%s: %s %s = %d, want 1", test.name, test.metric.name, test.value, got)
+		}
+		if got := cs.app.metrics.count(metricQuestions, QuestionConfirm); got != 1 {
+			t.Errorf("%s: %d confirm questions, want 1", test.name, got)
+		}
+	}
+}
+
+// echoingLlm answers with the request it was asked, so tests see which
+// transcript a turn settled on
+type echoingLlm struct{}
+
+func (echoingLlm) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	request := strings.ToLower(strings.TrimRight(messages[len(messages)-1].Content, "."))
+	return streamAfter(ctx, 0, "Answering "+request), nil
+}
+
+func TestRejectedWakeWordsCounted(t *testing.T) {
+	config := DefaultConfig()
+	config.Trigger.WakeWords = []WakeWordConfig{{Phrase: "hey chef", Threshold: 0.8}}
+	app := newApp(config)
+	app.triggerClient = stubTriggerClient{"hey chef": {Triggered: true, Confidence: 0.6}}
+	cs := NewClientState(&frameTransport{}, app, "alice")
+
+	cs.checkWakeWord(config, make([]byte, pcmBytes(100*time.Millisecond)))
+	if cs.getState() == StateTriggered {
+		t.Error("session triggered below the threshold")
+	}
+	if got := app.metrics.count(metricWakeWordsRejected, "hey chef"); got != 1 {
+		t.Errorf("rejected hey chef = %d, want 1", got)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// echoFrameSamples is the size of the envelope frames, 10ms of
+	// microphone audio
+	echoFrameSamples = inputSampleRate / 100
+
+	// echoWindow is how many recent microphone frames are compared with the
+	// speech. Shorter windows hold too few syllables to tell the speech from
+	// another voice.
+	echoWindow = 150
+
+	// echoMinFrames is how many microphone frames during playback are needed
+	// before audio can be recognized as echo
+	echoMinFrames = 10
+
+	// echoLead is how many frames the microphone may run ahead of the
+	// acknowledged playback position, which arrives with some delay
+	echoLead = 5
+
+	// echoMaxReference bounds the speech kept for clients that never
+	// acknowledge playback, a minute
+	echoMaxReference = 6000
+
+	// echoMaxExtrapolation bounds how far the playback position is assumed to
+	// advance without an acknowledgement
+	echoMaxExtrapolation = time.Second
+)
+
+// echoDetector recognizes the assistant's own speech in the microphone
+// audio. It keeps the energy envelope of the speech sent to the client and
+// correlates the envelope of the microphone with it around the playback
+// position the client acknowledges.
+type echoDetector struct {
+	mutex      sync.Mutex
+	reference  []float64 // Envelope of the speech, from frame base on
+	base       int       // Frame of the speech that reference starts at
+	refPending []int16   // Speech samples short of a frame
+	position   float64   // Acknowledged playback position in frames
+	playing    bool
+	ackedAt    time.Time // When the position was acknowledged, zero before the first ack
+	mic        []float64 // Envelope of the recent microphone frames
+	micAt      []float64 // Playback position when each microphone frame arrived, -1 outside playback
+	micPending []int16   // Microphone samples short of a frame
+	echo       bool      // Whether the last microphone audio was echo
+}
+
+// addSpeech appends speech sent to the client, 16-bit mono PCM at the
+// microphone rate, to the reference. Clients play the speech back to back,
+// so the reference is one continuous stream.
+func (d *echoDetector) addSpeech(samples []int16) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var frames []float64
+	frames, d.refPending = envelope(append(d.refPending, samples...))
+	d.reference = append(d.reference, frames...)
+	if excess := len(d.reference) - echoMaxReference; excess > 0 {
+		d.reference = d.reference[excess:]
+		d.base += excess
+	}
+}
+
+// acknowledge records the playback position of the client
+func (d *echoDetector) acknowledge(ack PlaybackAck) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.position = float64(ack.PositionMs) / 10
+	d.playing = ack.Playing
+	d.ackedAt = time.Now()
+}
+
+// detect adds microphone audio and reports whether it is echo, whether that
+// changed since the last audio, and the correlation it was judged by
+func (d *echoDetector) detect(pcm []byte, config EchoConfig) (echo, changed bool, correlation float64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var frames []float64
+	frames, d.micPending = envelope(append(d.micPending, pcmSamples(pcm)...))
+
+	// Playback position of the frames, advanced since the last ack
+	at := -1.0
+	if !d.ackedAt.IsZero() {
+		elapsed := time.Since(d.ackedAt)
+		if d.playing || elapsed < config.Tail.Std() {
+			at = d.position + float64(min(elapsed, echoMaxExtrapolation)/(10*time.Millisecond))
+		}
+	}
+	d.mic = append(d.mic, frames...)
+	for range frames {
+		d.micAt = append(d.micAt, at)
+	}
+	if excess := len(d.mic) - echoWindow; excess > 0 {
+		d.mic = d.mic[excess:]
+		d.micAt = d.micAt[excess:]
+	}
+	d.trimReference(config)
+
+	if len(frames) == 0 {
+		return d.echo, false, 0
+	}
+	correlation = d.correlate(config)
+	echo = correlation >= config.Threshold
+	changed, d.echo = echo != d.echo, echo
+	return echo, changed, correlation
+}
+
+// correlate returns the highest correlation of the microphone envelope with
+// the speech over the delays playback may take to reach the microphone
+func (d *echoDetector) correlate(config EchoConfig) float64 {
+	during := 0
+	for _, at := range d.micAt {
+		if at >= 0 {
+			during++
+		}
+	}
+	if during < echoMinFrames {
+		return 0
+	}
+
+	best := 0.0
+	ref := make([]float64, len(d.mic))
+	maxDelay := int(config.MaxDelay.Std() / (10 * time.Millisecond))
+	for delay := -echoLead; delay <= maxDelay; delay++ {
+		for i, at := range d.micAt {
+			ref[i] = 0
+			if at < 0 {
+				continue
+			}
+			if frame := int(math.Round(at)) - delay - d.base; frame >= 0 && frame < len(d.reference) {
+				ref[i] = d.reference[frame]
+			}
+		}
+		best = math.Max(best, pearson(d.mic, ref))
+	}
+	return best
+}
+
+// trimReference drops the speech that can no longer be heard
+func (d *echoDetector) trimReference(config EchoConfig) {
+	oldest := math.Inf(1)
+	for _, at := range d.micAt {
+		if at >= 0 {
+			oldest = math.Min(oldest, at)
+		}
+	}
+	if math.IsInf(oldest, 1) {
+		oldest = d.position
+	}
+	keep := int(oldest) - int(config.MaxDelay.Std()/(10*time.Millisecond)) - d.base
+	if keep > 0 {
+		keep = min(keep, len(d.reference))
+		d.reference = d.reference[keep:]
+		d.base += keep
+	}
+}
+
+// envelope returns the log energy of each whole frame of samples and the
+// samples left over
+func envelope(samples []int16) ([]float64, []int16) {
+	var frames []float64
+	for len(samples) >= echoFrameSamples {
+		energy := 0.0
+		for _, sample := range samples[:echoFrameSamples] {
+			energy += float64(sample) * float64(sample)
+		}
+		frames = append(frames, math.Log1p(energy/echoFrameSamples))
+		samples = samples[echoFrameSamples:]
+	}
+	return frames, append([]int16(nil), samples...)
+}
+
+// pearson returns the correlation coefficient of x and y, 0 when either is
+// constant
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// echoSuppression reports whether echo is suppressed in this session
+func (cs *ClientState) echoSuppression(config AppConfig) bool {
+	cs.preferencesMutex.Lock()
+	defer cs.preferencesMutex.Unlock()
+	if cs.preferences.EchoSuppression != nil {
+		return *cs.preferences.EchoSuppression
+	}
+	return config.Echo.Default
+}
+
+// echoReference returns the speech to recognize in the microphone, taken
+// from the synthesized audio before it is encoded for the transport. Audio
+// that cannot be decoded is replaced by silence of its length, so the
+// reference stays aligned with the playback position.
+func echoReference(audio []byte) []int16 {
+	samples, err := speechPCM(audio)
+	if err != nil {
+		logf(LogDebug, "No echo reference for speech: %v", err)
+		return make([]int16, audioDuration(audio)*inputSampleRate/time.Second)
+	}
+	return samples
+}
+
+// suppressEcho silences or attenuates microphone audio that is the
+// assistant's own speech, in place
+func (cs *ClientState) suppressEcho(pcm []byte, config EchoConfig) {
+	echo, changed, correlation := cs.echo.detect(pcm, config)
+	if changed {
+		detail := fmt.Sprintf("correlation %.2f", correlation)
+		if echo {
+			logf(LogDebug, "Echo detected, %s", detail)
+			cs.recordEvent("echo", "start", detail)
+		} else {
+			logf(LogDebug, "Echo ended, %s", detail)
+			cs.recordEvent("echo", "end", detail)
+		}
+	}
+	if !echo {
+		return
+	}
+
+	gain := 0.0
+	if config.Mode == EchoModeAttenuate {
+		gain = math.Pow(10, -config.Attenuation/20)
+	}
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(sample*gain)))
+	}
+}
+
+// speechPCM decodes synthesized WAV or Ogg Opus audio to 16-bit mono PCM at
+// the microphone rate
+func speechPCM(audio []byte) ([]int16, error) {
+	if isOgg(audio) {
+		head, packets, err := oggOpusPackets(audio)
+		if err != nil {
+			return nil, err
+		}
+		decoder, err := newMicDecoder()
+		if err != nil {
+			return nil, err
+		}
+		var samples []int16
+		for _, packet := range packets {
+			pcm, err := decoder.decode(packet)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, pcmSamples(pcm)...)
+		}
+
+		// Players skip the encoder delay
+		_, preSkip, _, _ := parseOpusHead(head)
+		return samples[min(preSkip*inputSampleRate/opusClockRate, len(samples)):], nil
+	}
+
+	pcm, sampleRate, err := wavPCM(audio)
+	if err != nil {
+		return nil, err
+	}
+	return resamplePCM(pcm, sampleRate, inputSampleRate), nil
+}
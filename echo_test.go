@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// speechLike returns duration of 16 kHz audio with the envelope of speech:
+// syllables of random length and loudness between short pauses
+func speechLike(seed int64, duration time.Duration) []int16 {
+	random := rand.New(rand.NewSource(seed))
+	samples := make([]int16, int(duration*inputSampleRate/time.Second))
+	for start := 0; start < len(samples); {
+		syllable := inputSampleRate * (80 + random.Intn(170)) / 1000
+		pause := inputSampleRate * (30 + random.Intn(120)) / 1000
+		amplitude := 0.1 + 0.5*random.Float64()
+		for i := start; i < min(start+syllable, len(samples)); i++ {
+			samples[i] = int16(amplitude * 32767 * math.Sin(2*math.Pi*220*float64(i)/inputSampleRate))
+		}
+		start += syllable + pause
+	}
+	return samples
+}
+
+// samplesPCM returns samples as little endian bytes, scaled by gain
+func samplesPCM(samples []int16, gain float64) []byte {
+	pcm := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(float64(sample)*gain)))
+	}
+	return pcm
+}
+
+// playAndListen plays speech and feeds mic to the detector in 10ms chunks,
+// acknowledging the playback position before each, and returns how many
+// chunks were echo after the first second
+func playAndListen(d *echoDetector, speech, mic []int16, config EchoConfig) int {
+	d.addSpeech(speech)
+	echoChunks := 0
+	for i := 0; i*echoFrameSamples < len(mic); i++ {
+		d.acknowledge(PlaybackAck{Event: PlaybackPosition, PositionMs: i * 10, Playing: true})
+		chunk := mic[i*echoFrameSamples : min((i+1)*echoFrameSamples, len(mic))]
+		if echo, _, _ := d.detect(samplesPCM(chunk, 1), config); echo && i >= 100 {
+			echoChunks++
+		}
+	}
+	return echoChunks
+}
+
+func TestEnvelope(t *testing.T) {
+	samples := make([]int16, echoFrameSamples*2+80)
+	for i := range samples {
+		samples[i] = 100
+	}
+	frames, rest := envelope(samples)
+	if len(frames) != 2 || len(rest) != 80 {
+		t.Fatalf("%d frames and %d samples left, want 2 and 80", len(frames), len(rest))
+	}
+	if want := math.Log1p(100 * 100); math.Abs(frames[0]-want) > 1e-9 {
+		t.Errorf("frame energy = %v, want %v", frames[0], want)
+	}
+}
+
+func TestPearson(t *testing.T) {
+	x := []float64{1, 2, 3, 5, 8}
+	tests := []struct {
+		name string
+		y    []float64
+		want float64
+	}{
+		{"same", []float64{1, 2, 3, 5, 8}, 1},
+		{"scaled", []float64{12, 14, 16, 20, 26}, 1},
+		{"inverted", []float64{-1, -2, -3, -5, -8}, -1},
+		{"constant", []float64{4, 4, 4, 4, 4}, 0},
+	}
+	for _, test := range tests {
+		if got := pearson(x, test.y); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: pearson = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEchoDetector(t *testing.T) {
+	config := DefaultConfig().Echo
+	speech := speechLike(1, 4*time.Second)
+
+	// The speech reaching the microphone quieter and 120ms late is echo
+	delay := make([]int16, inputSampleRate*120/1000)
+	echo := append(delay, speech...)[:len(speech)]
+	for i := range echo {
+		echo[i] /= 4
+	}
+	if chunks := playAndListen(&echoDetector{}, speech, echo, config); chunks < 250 {
+		t.Errorf("echo detected in %d of 300 chunks", chunks)
+	}
+
+	// Another voice during playback is not
+	other := speechLike(2, 4*time.Second)
+	if chunks := playAndListen(&echoDetector{}, speech, other, config); chunks > 0 {
+		t.Errorf("another voice taken for echo in %d chunks", chunks)
+	}
+}
+
+func TestEchoDetectorWithoutAcks(t *testing.T) {
+	var d echoDetector
+	speech := speechLike(1, 2*time.Second)
+	d.addSpeech(speech)
+	if echo, _, _ := d.detect(samplesPCM(speech, 0.5), DefaultConfig().Echo); echo {
+		t.Error("echo detected without playback acknowledgements")
+	}
+}
+
+func TestSuppressEcho(t *testing.T) {
+	app := newApp(DefaultConfig())
+	speech := speechLike(1, 3*time.Second)
+	for _, mode := range []string{EchoModeSuppress, EchoModeAttenuate} {
+		config := app.currentConfig().Echo
+		config.Mode = mode
+		cs := NewClientState(&frameTransport{}, app, "alice")
+		cs.echo.addSpeech(speech)
+
+		var last []byte
+		for i := 0; i*echoFrameSamples < len(speech); i++ {
+			cs.echo.acknowledge(PlaybackAck{PositionMs: i * 10, Playing: true})
+			last = samplesPCM(speech[i*echoFrameSamples:(i+1)*echoFrameSamples], 1)
+			cs.suppressEcho(last, config)
+		}
+		level, _ := audioLevel(last)
+		original, _ := audioLevel(samplesPCM(speech[len(speech)-echoFrameSamples:], 1))
+		want := 0.0
+		if mode == EchoModeAttenuate {
+			want = original / 10 // 20 dB
+		}
+		if math.Abs(level-want) > 0.01 {
+			t.Errorf("%s: level = %.3f, want %.3f", mode, level, want)
+		}
+	}
+}
+
+func TestEchoReference(t *testing.T) {
+	// WAV is resampled to the microphone rate
+	wav := wrapWav(samplesPCM(speechLike(1, 1500*time.Millisecond), 1), 24000)
+	if samples := echoReference(wav); len(samples) != inputSampleRate {
+		t.Errorf("WAV reference has %d samples, want %d", len(samples), inputSampleRate)
+	}
+
+	// Ogg Opus is decoded with libopus, without the encoder delay
+	ogg := testOggOpus(50)
+	if newOpusDecoder == nil {
+		if samples := echoReference(ogg); len(samples) != int(audioDuration(ogg)*inputSampleRate/time.Second) {
+			t.Errorf("undecodable reference has %d samples, want silence as long as the speech", len(samples))
+		}
+	}
+	withStubOpusDecoder(t)
+	if samples := echoReference(ogg); len(samples) != 50*inputSampleRate/50-opusPreSkip/3 {
+		t.Errorf("Ogg reference has %d samples", len(samples))
+	}
+}
+
+func TestSendAudioKeepsEchoReference(t *testing.T) {
+	app := newApp(DefaultConfig())
+	wav := wrapWav(make([]byte, pcmBytes(100*time.Millisecond)), inputSampleRate)
+
+	// Speech played on a WebRTC track is kept like speech in binary messages
+	for _, conn := range []Transport{&frameTransport{}, &trackTransport{}} {
+		cs := NewClientState(conn, app, "alice")
+		cs.negotiateAudio("ogg_opus,wav", AudioConfig{})
+		for range 2 {
+			if err := cs.sendAudio(context.Background(), wav); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if frames := len(cs.echo.reference); frames != 20 {
+			t.Errorf("%T: reference has %d frames, want 20", conn, frames)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.30.2
+// source: proto/trigger.proto
+
+package trigger
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TriggerService_Detect_FullMethodName       = "/trigger.TriggerService/Detect"
+	TriggerService_DetectStream_FullMethodName = "/trigger.TriggerService/DetectStream"
+)
+
+// TriggerServiceClient is the client API for TriggerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TriggerService provides wake word detection
+type TriggerServiceClient interface {
+	// Detect determines if the wake word is present in the audio.
+	Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectResponse, error)
+	// DetectStream processes a stream of audio chunks and detects the wake word.
+	DetectStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DetectRequest, DetectResponse], error)
+}
+
+type triggerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTriggerServiceClient(cc grpc.ClientConnInterface) TriggerServiceClient {
+	return &triggerServiceClient{cc}
+}
+
+func (c *triggerServiceClient) Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DetectResponse)
+	err := c.cc.Invoke(ctx, TriggerService_Detect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *triggerServiceClient) DetectStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DetectRequest, DetectResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TriggerService_ServiceDesc.Streams[0], TriggerService_DetectStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DetectRequest, DetectResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TriggerService_DetectStreamClient = grpc.BidiStreamingClient[DetectRequest, DetectResponse]
+
+// TriggerServiceServer is the server API for TriggerService service.
+// All implementations must embed UnimplementedTriggerServiceServer
+// for forward compatibility.
+//
+// TriggerService provides wake word detection
+type TriggerServiceServer interface {
+	// Detect determines if the wake word is present in the audio.
+	Detect(context.Context, *DetectRequest) (*DetectResponse, error)
+	// DetectStream processes a stream of audio chunks and detects the wake word.
+	DetectStream(grpc.BidiStreamingServer[DetectRequest, DetectResponse]) error
+	mustEmbedUnimplementedTriggerServiceServer()
+}
+
+// UnimplementedTriggerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTriggerServiceServer struct{}
+
+func (UnimplementedTriggerServiceServer) Detect(context.Context, *DetectRequest) (*DetectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Detect not implemented")
+}
+func (UnimplementedTriggerServiceServer) DetectStream(grpc.BidiStreamingServer[DetectRequest, DetectResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method DetectStream not implemented")
+}
+func (UnimplementedTriggerServiceServer) mustEmbedUnimplementedTriggerServiceServer() {}
+func (UnimplementedTriggerServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeTriggerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TriggerServiceServer will
+// result in compilation errors.
+type UnsafeTriggerServiceServer interface {
+	mustEmbedUnimplementedTriggerServiceServer()
+}
+
+func RegisterTriggerServiceServer(s grpc.ServiceRegistrar, srv TriggerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTriggerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TriggerService_ServiceDesc, srv)
+}
+
+func _TriggerService_Detect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TriggerServiceServer).Detect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TriggerService_Detect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TriggerServiceServer).Detect(ctx, req.(*DetectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TriggerService_DetectStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TriggerServiceServer).DetectStream(&grpc.GenericServerStream[DetectRequest, DetectResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TriggerService_DetectStreamServer = grpc.BidiStreamingServer[DetectRequest, DetectResponse]
+
+// TriggerService_ServiceDesc is the grpc.ServiceDesc for TriggerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TriggerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "trigger.TriggerService",
+	HandlerType: (*TriggerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Detect",
+			Handler:    _TriggerService_Detect_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DetectStream",
+			Handler:       _TriggerService_DetectStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/trigger.proto",
+}
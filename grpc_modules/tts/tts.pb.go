@@ -0,0 +1,548 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        v6.30.2
+// source: proto/tts.proto
+
+package tts
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AudioEncoding defines the audio encoding format
+type AudioEncoding int32
+
+const (
+	// Not specified
+	AudioEncoding_AUDIO_ENCODING_UNSPECIFIED AudioEncoding = 0
+	// Linear PCM (16-bit signed little-endian)
+	AudioEncoding_LINEAR16 AudioEncoding = 1
+	// MP3
+	AudioEncoding_MP3 AudioEncoding = 2
+	// Opus encoded audio in Ogg container
+	AudioEncoding_OGG_OPUS AudioEncoding = 3
+	// FLAC
+	AudioEncoding_FLAC AudioEncoding = 4
+	// MULAW
+	AudioEncoding_MULAW AudioEncoding = 5
+)
+
+// Enum value maps for AudioEncoding.
+var (
+	AudioEncoding_name = map[int32]string{
+		0: "AUDIO_ENCODING_UNSPECIFIED",
+		1: "LINEAR16",
+		2: "MP3",
+		3: "OGG_OPUS",
+		4: "FLAC",
+		5: "MULAW",
+	}
+	AudioEncoding_value = map[string]int32{
+		"AUDIO_ENCODING_UNSPECIFIED": 0,
+		"LINEAR16":                   1,
+		"MP3":                        2,
+		"OGG_OPUS":                   3,
+		"FLAC":                       4,
+		"MULAW":                      5,
+	}
+)
+
+func (x AudioEncoding) Enum() *AudioEncoding {
+	p := new(AudioEncoding)
+	*p = x
+	return p
+}
+
+func (x AudioEncoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AudioEncoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_tts_proto_enumTypes[0].Descriptor()
+}
+
+func (AudioEncoding) Type() protoreflect.EnumType {
+	return &file_proto_tts_proto_enumTypes[0]
+}
+
+func (x AudioEncoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AudioEncoding.Descriptor instead.
+func (AudioEncoding) EnumDescriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{0}
+}
+
+// SynthesizeRequest contains text to synthesize
+type SynthesizeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Text to be synthesized
+	Text string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// Language code (e.g., "en-US")
+	LanguageCode string `protobuf:"bytes,2,opt,name=language_code,json=languageCode,proto3" json:"language_code,omitempty"`
+	// Voice name
+	VoiceName string `protobuf:"bytes,3,opt,name=voice_name,json=voiceName,proto3" json:"voice_name,omitempty"`
+	// Audio configuration
+	AudioConfig   *AudioConfig `protobuf:"bytes,4,opt,name=audio_config,json=audioConfig,proto3" json:"audio_config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SynthesizeRequest) Reset() {
+	*x = SynthesizeRequest{}
+	mi := &file_proto_tts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesizeRequest) ProtoMessage() {}
+
+func (x *SynthesizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_tts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesizeRequest.ProtoReflect.Descriptor instead.
+func (*SynthesizeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SynthesizeRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetLanguageCode() string {
+	if x != nil {
+		return x.LanguageCode
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetVoiceName() string {
+	if x != nil {
+		return x.VoiceName
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetAudioConfig() *AudioConfig {
+	if x != nil {
+		return x.AudioConfig
+	}
+	return nil
+}
+
+// AudioConfig contains configuration for the synthesized audio
+type AudioConfig struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Audio encoding format
+	AudioEncoding AudioEncoding `protobuf:"varint,1,opt,name=audio_encoding,json=audioEncoding,proto3,enum=tts.AudioEncoding" json:"audio_encoding,omitempty"`
+	// Speaking rate (1.0 is normal speed, 0.5 is half speed, 2.0 is double speed)
+	SpeakingRate float32 `protobuf:"fixed32,2,opt,name=speaking_rate,json=speakingRate,proto3" json:"speaking_rate,omitempty"`
+	// Pitch (0.0 is normal pitch, -10.0 to 10.0)
+	Pitch float32 `protobuf:"fixed32,3,opt,name=pitch,proto3" json:"pitch,omitempty"`
+	// Volume gain in dB (-96.0 to 16.0)
+	VolumeGainDb float32 `protobuf:"fixed32,4,opt,name=volume_gain_db,json=volumeGainDb,proto3" json:"volume_gain_db,omitempty"`
+	// Sample rate in Hz
+	SampleRateHertz int32 `protobuf:"varint,5,opt,name=sample_rate_hertz,json=sampleRateHertz,proto3" json:"sample_rate_hertz,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AudioConfig) Reset() {
+	*x = AudioConfig{}
+	mi := &file_proto_tts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioConfig) ProtoMessage() {}
+
+func (x *AudioConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_tts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioConfig.ProtoReflect.Descriptor instead.
+func (*AudioConfig) Descriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AudioConfig) GetAudioEncoding() AudioEncoding {
+	if x != nil {
+		return x.AudioEncoding
+	}
+	return AudioEncoding_AUDIO_ENCODING_UNSPECIFIED
+}
+
+func (x *AudioConfig) GetSpeakingRate() float32 {
+	if x != nil {
+		return x.SpeakingRate
+	}
+	return 0
+}
+
+func (x *AudioConfig) GetPitch() float32 {
+	if x != nil {
+		return x.Pitch
+	}
+	return 0
+}
+
+func (x *AudioConfig) GetVolumeGainDb() float32 {
+	if x != nil {
+		return x.VolumeGainDb
+	}
+	return 0
+}
+
+func (x *AudioConfig) GetSampleRateHertz() int32 {
+	if x != nil {
+		return x.SampleRateHertz
+	}
+	return 0
+}
+
+// SynthesizeResponse contains the synthesized audio
+type SynthesizeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Audio data in the format specified in the request
+	AudioContent []byte `protobuf:"bytes,1,opt,name=audio_content,json=audioContent,proto3" json:"audio_content,omitempty"`
+	// Timing information for the synthesized audio
+	TimingInfo    *TimingInfo `protobuf:"bytes,2,opt,name=timing_info,json=timingInfo,proto3" json:"timing_info,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SynthesizeResponse) Reset() {
+	*x = SynthesizeResponse{}
+	mi := &file_proto_tts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesizeResponse) ProtoMessage() {}
+
+func (x *SynthesizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_tts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesizeResponse.ProtoReflect.Descriptor instead.
+func (*SynthesizeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SynthesizeResponse) GetAudioContent() []byte {
+	if x != nil {
+		return x.AudioContent
+	}
+	return nil
+}
+
+func (x *SynthesizeResponse) GetTimingInfo() *TimingInfo {
+	if x != nil {
+		return x.TimingInfo
+	}
+	return nil
+}
+
+// TimingInfo contains timing information for the synthesized audio
+type TimingInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Total audio duration in seconds
+	TotalDurationSeconds float64 `protobuf:"fixed64,1,opt,name=total_duration_seconds,json=totalDurationSeconds,proto3" json:"total_duration_seconds,omitempty"`
+	// Word-level timing information
+	WordTimings   []*WordTiming `protobuf:"bytes,2,rep,name=word_timings,json=wordTimings,proto3" json:"word_timings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimingInfo) Reset() {
+	*x = TimingInfo{}
+	mi := &file_proto_tts_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimingInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimingInfo) ProtoMessage() {}
+
+func (x *TimingInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_tts_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimingInfo.ProtoReflect.Descriptor instead.
+func (*TimingInfo) Descriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TimingInfo) GetTotalDurationSeconds() float64 {
+	if x != nil {
+		return x.TotalDurationSeconds
+	}
+	return 0
+}
+
+func (x *TimingInfo) GetWordTimings() []*WordTiming {
+	if x != nil {
+		return x.WordTimings
+	}
+	return nil
+}
+
+// WordTiming contains timing information for a word
+type WordTiming struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The word
+	Word string `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	// Start time in seconds
+	StartTime float64 `protobuf:"fixed64,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	// End time in seconds
+	EndTime       float64 `protobuf:"fixed64,3,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WordTiming) Reset() {
+	*x = WordTiming{}
+	mi := &file_proto_tts_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WordTiming) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WordTiming) ProtoMessage() {}
+
+func (x *WordTiming) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_tts_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WordTiming.ProtoReflect.Descriptor instead.
+func (*WordTiming) Descriptor() ([]byte, []int) {
+	return file_proto_tts_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WordTiming) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+func (x *WordTiming) GetStartTime() float64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *WordTiming) GetEndTime() float64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+var File_proto_tts_proto protoreflect.FileDescriptor
+
+var file_proto_tts_proto_rawDesc = string([]byte{
+	0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x74, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x03, 0x74, 0x74, 0x73, 0x22, 0xa0, 0x01, 0x0a, 0x11, 0x53, 0x79, 0x6e, 0x74, 0x68,
+	0x65, 0x73, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x74, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74,
+	0x12, 0x23, 0x0a, 0x0d, 0x6c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67, 0x65, 0x5f, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6c, 0x61, 0x6e, 0x67, 0x75, 0x61, 0x67,
+	0x65, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x76, 0x6f, 0x69, 0x63, 0x65, 0x5f, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x76, 0x6f, 0x69, 0x63, 0x65,
+	0x4e, 0x61, 0x6d, 0x65, 0x12, 0x33, 0x0a, 0x0c, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x5f, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x74, 0x74, 0x73,
+	0x2e, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x0b, 0x61, 0x75,
+	0x64, 0x69, 0x6f, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0xd5, 0x01, 0x0a, 0x0b, 0x41, 0x75,
+	0x64, 0x69, 0x6f, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x39, 0x0a, 0x0e, 0x61, 0x75, 0x64,
+	0x69, 0x6f, 0x5f, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x12, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x45, 0x6e, 0x63,
+	0x6f, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x0d, 0x61, 0x75, 0x64, 0x69, 0x6f, 0x45, 0x6e, 0x63, 0x6f,
+	0x64, 0x69, 0x6e, 0x67, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x70, 0x65, 0x61, 0x6b, 0x69, 0x6e, 0x67,
+	0x5f, 0x72, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x0c, 0x73, 0x70, 0x65,
+	0x61, 0x6b, 0x69, 0x6e, 0x67, 0x52, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x69, 0x74,
+	0x63, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x70, 0x69, 0x74, 0x63, 0x68, 0x12,
+	0x24, 0x0a, 0x0e, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x5f, 0x67, 0x61, 0x69, 0x6e, 0x5f, 0x64,
+	0x62, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x52, 0x0c, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x47,
+	0x61, 0x69, 0x6e, 0x44, 0x62, 0x12, 0x2a, 0x0a, 0x11, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f,
+	0x72, 0x61, 0x74, 0x65, 0x5f, 0x68, 0x65, 0x72, 0x74, 0x7a, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x61, 0x74, 0x65, 0x48, 0x65, 0x72, 0x74,
+	0x7a, 0x22, 0x6b, 0x0a, 0x12, 0x53, 0x79, 0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x75, 0x64, 0x69, 0x6f,
+	0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c,
+	0x61, 0x75, 0x64, 0x69, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x30, 0x0a, 0x0b,
+	0x74, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0f, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x54, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x49, 0x6e,
+	0x66, 0x6f, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0x76,
+	0x0a, 0x0a, 0x54, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x34, 0x0a, 0x16,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x14, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x12, 0x32, 0x0a, 0x0c, 0x77, 0x6f, 0x72, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x69, 0x6e,
+	0x67, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x57,
+	0x6f, 0x72, 0x64, 0x54, 0x69, 0x6d, 0x69, 0x6e, 0x67, 0x52, 0x0b, 0x77, 0x6f, 0x72, 0x64, 0x54,
+	0x69, 0x6d, 0x69, 0x6e, 0x67, 0x73, 0x22, 0x5a, 0x0a, 0x0a, 0x57, 0x6f, 0x72, 0x64, 0x54, 0x69,
+	0x6d, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x77, 0x6f, 0x72, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72,
+	0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x5f, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x65, 0x6e, 0x64, 0x54, 0x69,
+	0x6d, 0x65, 0x2a, 0x69, 0x0a, 0x0d, 0x41, 0x75, 0x64, 0x69, 0x6f, 0x45, 0x6e, 0x63, 0x6f, 0x64,
+	0x69, 0x6e, 0x67, 0x12, 0x1e, 0x0a, 0x1a, 0x41, 0x55, 0x44, 0x49, 0x4f, 0x5f, 0x45, 0x4e, 0x43,
+	0x4f, 0x44, 0x49, 0x4e, 0x47, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x4c, 0x49, 0x4e, 0x45, 0x41, 0x52, 0x31, 0x36, 0x10,
+	0x01, 0x12, 0x07, 0x0a, 0x03, 0x4d, 0x50, 0x33, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x47,
+	0x47, 0x5f, 0x4f, 0x50, 0x55, 0x53, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x4c, 0x41, 0x43,
+	0x10, 0x04, 0x12, 0x09, 0x0a, 0x05, 0x4d, 0x55, 0x4c, 0x41, 0x57, 0x10, 0x05, 0x32, 0x92, 0x01,
+	0x0a, 0x0a, 0x54, 0x74, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3d, 0x0a, 0x0a,
+	0x53, 0x79, 0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x2e, 0x74, 0x74, 0x73,
+	0x2e, 0x53, 0x79, 0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x17, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x53, 0x79, 0x6e, 0x74, 0x68, 0x65, 0x73,
+	0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x45, 0x0a, 0x10, 0x53,
+	0x79, 0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12,
+	0x16, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x53, 0x79, 0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x74, 0x74, 0x73, 0x2e, 0x53, 0x79,
+	0x6e, 0x74, 0x68, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x30, 0x01, 0x42, 0x20, 0x5a, 0x1e, 0x61, 0x73, 0x73, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x74, 0x2d,
+	0x61, 0x70, 0x70, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73,
+	0x2f, 0x74, 0x74, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+})
+
+var (
+	file_proto_tts_proto_rawDescOnce sync.Once
+	file_proto_tts_proto_rawDescData []byte
+)
+
+func file_proto_tts_proto_rawDescGZIP() []byte {
+	file_proto_tts_proto_rawDescOnce.Do(func() {
+		file_proto_tts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_tts_proto_rawDesc), len(file_proto_tts_proto_rawDesc)))
+	})
+	return file_proto_tts_proto_rawDescData
+}
+
+var file_proto_tts_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_tts_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_tts_proto_goTypes = []any{
+	(AudioEncoding)(0),         // 0: tts.AudioEncoding
+	(*SynthesizeRequest)(nil),  // 1: tts.SynthesizeRequest
+	(*AudioConfig)(nil),        // 2: tts.AudioConfig
+	(*SynthesizeResponse)(nil), // 3: tts.SynthesizeResponse
+	(*TimingInfo)(nil),         // 4: tts.TimingInfo
+	(*WordTiming)(nil),         // 5: tts.WordTiming
+}
+var file_proto_tts_proto_depIdxs = []int32{
+	2, // 0: tts.SynthesizeRequest.audio_config:type_name -> tts.AudioConfig
+	0, // 1: tts.AudioConfig.audio_encoding:type_name -> tts.AudioEncoding
+	4, // 2: tts.SynthesizeResponse.timing_info:type_name -> tts.TimingInfo
+	5, // 3: tts.TimingInfo.word_timings:type_name -> tts.WordTiming
+	1, // 4: tts.TtsService.Synthesize:input_type -> tts.SynthesizeRequest
+	1, // 5: tts.TtsService.SynthesizeStream:input_type -> tts.SynthesizeRequest
+	3, // 6: tts.TtsService.Synthesize:output_type -> tts.SynthesizeResponse
+	3, // 7: tts.TtsService.SynthesizeStream:output_type -> tts.SynthesizeResponse
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_proto_tts_proto_init() }
+func file_proto_tts_proto_init() {
+	if File_proto_tts_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_tts_proto_rawDesc), len(file_proto_tts_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_tts_proto_goTypes,
+		DependencyIndexes: file_proto_tts_proto_depIdxs,
+		EnumInfos:         file_proto_tts_proto_enumTypes,
+		MessageInfos:      file_proto_tts_proto_msgTypes,
+	}.Build()
+	File_proto_tts_proto = out.File
+	file_proto_tts_proto_goTypes = nil
+	file_proto_tts_proto_depIdxs = nil
+}
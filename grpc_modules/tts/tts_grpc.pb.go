@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.30.2
+// source: proto/tts.proto
+
+package tts
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TtsService_Synthesize_FullMethodName       = "/tts.TtsService/Synthesize"
+	TtsService_SynthesizeStream_FullMethodName = "/tts.TtsService/SynthesizeStream"
+)
+
+// TtsServiceClient is the client API for TtsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TtsService provides text-to-speech synthesis
+type TtsServiceClient interface {
+	// Synthesize converts text to speech.
+	Synthesize(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (*SynthesizeResponse, error)
+	// SynthesizeStream converts text to a stream of speech audio chunks.
+	SynthesizeStream(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SynthesizeResponse], error)
+}
+
+type ttsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTtsServiceClient(cc grpc.ClientConnInterface) TtsServiceClient {
+	return &ttsServiceClient{cc}
+}
+
+func (c *ttsServiceClient) Synthesize(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (*SynthesizeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SynthesizeResponse)
+	err := c.cc.Invoke(ctx, TtsService_Synthesize_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ttsServiceClient) SynthesizeStream(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SynthesizeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TtsService_ServiceDesc.Streams[0], TtsService_SynthesizeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SynthesizeRequest, SynthesizeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TtsService_SynthesizeStreamClient = grpc.ServerStreamingClient[SynthesizeResponse]
+
+// TtsServiceServer is the server API for TtsService service.
+// All implementations must embed UnimplementedTtsServiceServer
+// for forward compatibility.
+//
+// TtsService provides text-to-speech synthesis
+type TtsServiceServer interface {
+	// Synthesize converts text to speech.
+	Synthesize(context.Context, *SynthesizeRequest) (*SynthesizeResponse, error)
+	// SynthesizeStream converts text to a stream of speech audio chunks.
+	SynthesizeStream(*SynthesizeRequest, grpc.ServerStreamingServer[SynthesizeResponse]) error
+	mustEmbedUnimplementedTtsServiceServer()
+}
+
+// UnimplementedTtsServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTtsServiceServer struct{}
+
+func (UnimplementedTtsServiceServer) Synthesize(context.Context, *SynthesizeRequest) (*SynthesizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Synthesize not implemented")
+}
+func (UnimplementedTtsServiceServer) SynthesizeStream(*SynthesizeRequest, grpc.ServerStreamingServer[SynthesizeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SynthesizeStream not implemented")
+}
+func (UnimplementedTtsServiceServer) mustEmbedUnimplementedTtsServiceServer() {}
+func (UnimplementedTtsServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeTtsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TtsServiceServer will
+// result in compilation errors.
+type UnsafeTtsServiceServer interface {
+	mustEmbedUnimplementedTtsServiceServer()
+}
+
+func RegisterTtsServiceServer(s grpc.ServiceRegistrar, srv TtsServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTtsServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TtsService_ServiceDesc, srv)
+}
+
+func _TtsService_Synthesize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SynthesizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TtsServiceServer).Synthesize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TtsService_Synthesize_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TtsServiceServer).Synthesize(ctx, req.(*SynthesizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TtsService_SynthesizeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SynthesizeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TtsServiceServer).SynthesizeStream(m, &grpc.GenericServerStream[SynthesizeRequest, SynthesizeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TtsService_SynthesizeStreamServer = grpc.ServerStreamingServer[SynthesizeResponse]
+
+// TtsService_ServiceDesc is the grpc.ServiceDesc for TtsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TtsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tts.TtsService",
+	HandlerType: (*TtsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Synthesize",
+			Handler:    _TtsService_Synthesize_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SynthesizeStream",
+			Handler:       _TtsService_SynthesizeStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/tts.proto",
+}
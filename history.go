@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrSessionNotFound is returned by a TranscriptStore for an unknown session
+var ErrSessionNotFound = errors.New("session not found")
+
+// TranscriptStore persists conversation turns keyed by user and session
+type TranscriptStore interface {
+	SaveTurn(ctx context.Context, turn TurnRecord) error
+	// ListSessions lists the sessions of user, or of every user if user is empty
+	ListSessions(ctx context.Context, user string) ([]SessionSummary, error)
+	GetConversation(ctx context.Context, session string) (Conversation, error)
+	DeleteSession(ctx context.Context, session string) error
+	Close() error
+}
+
+// TurnRecord is one persisted turn of a conversation
+type TurnRecord struct {
+	UserID     string            `json:"user_id"`
+	SessionID  string            `json:"session_id"`
+	Turn       int               `json:"turn"`
+	StartedAt  time.Time         `json:"started_at"`
+	EndedAt    time.Time         `json:"ended_at"`
+	WakeWord   string            `json:"wake_word,omitempty"`
+	Persona    string            `json:"persona,omitempty"`
+	Language   string            `json:"language,omitempty"`
+	Transcript string            `json:"transcript"`
+	Response   string            `json:"response"`
+	Latency    LatencyBreakdown  `json:"latency"`
+	Backends   map[string]string `json:"backends,omitempty"`
+	Error      string            `json:"error,omitempty"`
+
+	Confidence   float64       `json:"confidence,omitempty"`   // Of the transcript, 0 when STT reports none
+	Alternatives []Alternative `json:"alternatives,omitempty"` // Reported with an uncertain transcript
+	Question     string        `json:"question,omitempty"`     // Asked about the transcript instead of answering it
+	Answer       string        `json:"answer,omitempty"`       // How the transcript answered the last question
+
+	llmStart   time.Time // When the first LLM request was sent
+	recording  bool      // Whether the turn is recorded
+	utterance  []byte    // Audio sent to STT, kept for recording
+	replyAudio []byte    // Reply audio as PCM, kept for recording
+}
+
+// LatencyBreakdown holds the time spent in each stage of a turn in milliseconds
+type LatencyBreakdown struct {
+	SttMs        int64 `json:"stt_ms"`
+	LlmFirstMs   int64 `json:"llm_first_token_ms"`
+	LlmMs        int64 `json:"llm_ms"`
+	FirstAudioMs int64 `json:"first_audio_ms"`
+	TotalMs      int64 `json:"total_ms"`
+}
+
+// SessionSummary describes a stored session
+type SessionSummary struct {
+	UserID       string    `json:"user_id"`
+	SessionID    string    `json:"session_id"`
+	StartedAt    time.Time `json:"started_at"`
+	LastActivity time.Time `json:"last_activity"`
+	Turns        int       `json:"turns"`
+}
+
+// Conversation is every stored turn of a session in order
+type Conversation struct {
+	UserID    string       `json:"user_id"`
+	SessionID string       `json:"session_id"`
+	Turns     []TurnRecord `json:"turns"`
+}
+
+// NewTranscriptStore opens the store selected by config, or returns nil if
+// history is disabled
+func NewTranscriptStore(config HistoryConfig) (TranscriptStore, error) {
+	switch config.Backend {
+	case "", "none":
+		return nil, nil
+	case "jsonl":
+		return NewJsonlTranscriptStore(config.Path)
+	case "sqlite":
+		return NewSqliteTranscriptStore(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown history backend %q", config.Backend)
+	}
+}
+
+// summarizeSessions groups turns into session summaries, most recent first
+func summarizeSessions(turns []TurnRecord) []SessionSummary {
+	bySession := make(map[string]*SessionSummary)
+	for _, turn := range turns {
+		summary, ok := bySession[turn.SessionID]
+		if !ok {
+			summary = &SessionSummary{UserID: turn.UserID, SessionID: turn.SessionID, StartedAt: turn.StartedAt}
+			bySession[turn.SessionID] = summary
+		}
+		if turn.StartedAt.Before(summary.StartedAt) {
+			summary.StartedAt = turn.StartedAt
+		}
+		if turn.EndedAt.After(summary.LastActivity) {
+			summary.LastActivity = turn.EndedAt
+		}
+		summary.Turns++
+	}
+
+	sessions := make([]SessionSummary, 0, len(bySession))
+	for _, summary := range bySession {
+		sessions = append(sessions, *summary)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+	return sessions
+}
+
+// Markdown renders the conversation for export
+func (c Conversation) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation %s\n\n", c.SessionID)
+	fmt.Fprintf(&b, "- User: %s\n", c.UserID)
+	for _, turn := range c.Turns {
+		fmt.Fprintf(&b, "\n## Turn %d - %s\n\n", turn.Turn, turn.StartedAt.Format(time.RFC3339))
+		if turn.Transcript != "" {
+			fmt.Fprintf(&b, "**User:** %s\n\n", turn.Transcript)
+		}
+		if turn.Response != "" {
+			fmt.Fprintf(&b, "**Assistant:** %s\n\n", turn.Response)
+		}
+		if turn.Error != "" {
+			fmt.Fprintf(&b, "*Error: %s*\n\n", turn.Error)
+		}
+		fmt.Fprintf(&b, "_STT %d ms, first token %d ms, LLM %d ms, first audio %d ms, total %d ms_\n",
+			turn.Latency.SttMs, turn.Latency.LlmFirstMs, turn.Latency.LlmMs, turn.Latency.FirstAudioMs, turn.Latency.TotalMs)
+	}
+	return b.String()
+}
+
+// versionHeader is the response metadata key gRPC backends use to report their version
+const versionHeader = "x-service-version"
+
+// serviceVersion remembers the version a gRPC backend last reported
+type serviceVersion struct {
+	mutex   sync.Mutex
+	version string
+}
+
+// record stores the version found in the response header, if any
+func (v *serviceVersion) record(header metadata.MD) {
+	if values := header.Get(versionHeader); len(values) > 0 {
+		v.mutex.Lock()
+		v.version = values[0]
+		v.mutex.Unlock()
+	}
+}
+
+// Version returns the last version the backend reported
+func (v *serviceVersion) Version() string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.version
+}
+
+// backendVersions describes the backends that served a turn
+func (app *App) backendVersions(settings SessionSettings) map[string]string {
+	config := app.currentConfig()
+	versions := map[string]string{
+		"llm": config.Llm.Provider + "/" + settings.Llm.Model,
+	}
+	services := map[string]interface{}{
+		"trigger": app.triggerClient,
+		"stt":     app.sttClient,
+		"tts":     app.ttsClient,
+	}
+	for name, client := range services {
+		if versioned, ok := client.(interface{ Version() string }); ok && versioned.Version() != "" {
+			versions[name] = versioned.Version()
+		}
+	}
+	return versions
+}
+
+// startTurn begins the history record of a new turn
+func (cs *ClientState) startTurn(settings SessionSettings) *TurnRecord {
+	cs.stateMutex.Lock()
+	cs.turns++
+	turn := cs.turns
+	wakeWord := cs.wakeWord.Phrase
+	cs.currentTurn = &LiveTurn{Turn: turn, StartedAt: time.Now(), WakeWord: wakeWord, Persona: settings.Persona}
+	cs.stateMutex.Unlock()
+
+	return &TurnRecord{
+		UserID:    cs.userID,
+		SessionID: cs.sessionID,
+		Turn:      turn,
+		StartedAt: time.Now(),
+		WakeWord:  wakeWord,
+		Persona:   settings.Persona,
+		recording: cs.recordingEnabled(cs.app.currentConfig()),
+	}
+}
+
+// finishTurn completes a turn record and saves it to the history store
+func (cs *ClientState) finishTurn(config AppConfig, settings SessionSettings, record *TurnRecord) {
+	record.EndedAt = time.Now()
+	record.Latency.TotalMs = record.EndedAt.Sub(record.StartedAt).Milliseconds()
+	record.Backends = cs.app.backendVersions(settings)
+
+	cs.stateMutex.Lock()
+	cs.currentTurn = nil
+	latency := record.Latency
+	cs.lastLatency = &latency
+	cs.lastError = record.Error
+	cs.stateMutex.Unlock()
+
+	if record.recording {
+		cs.saveRecording(record)
+	} else {
+		cs.takeEvents()
+	}
+
+	if cs.app.history == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.History.Timeout.Std())
+	defer cancel()
+	if err := cs.app.history.SaveTurn(ctx, *record); err != nil {
+		log.Printf("Failed to save turn %d of session %s: %v", record.Turn, record.SessionID, err)
+	}
+}
+
+// addReplyAudio keeps the PCM of a reply audio frame for recording
+func (t *TurnRecord) addReplyAudio(audio []byte) {
+	if !t.recording {
+		return
+	}
+	pcm, sampleRate, err := wavPCM(audio)
+	if err != nil || sampleRate != ttsSampleRate {
+		return
+	}
+	t.replyAudio = append(t.replyAudio, pcm...)
+}
+
+// addResponse appends the text of one LLM response to the turn
+func (t *TurnRecord) addResponse(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if t.Response != "" {
+		t.Response += " "
+	}
+	t.Response += text
+}
+
+// msSince returns the milliseconds elapsed since start
+func msSince(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
+
+// newSessionID returns a random session identifier
+func newSessionID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// userFromRequest returns the user named by the user query parameter of a
+// WebSocket request, or "anonymous"
+func userFromRequest(r *http.Request) string {
+	user := strings.TrimSpace(r.URL.Query().Get("user"))
+	if user == "" {
+		return "anonymous"
+	}
+	if len(user) > 64 {
+		user = user[:64]
+	}
+	return user
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// historyRoutes registers the conversation history API
+func (app *App) historyRoutes(r *mux.Router) {
+	api := r.PathPrefix("/api/sessions").Subrouter()
+	api.HandleFunc("", app.handleListSessions).Methods(http.MethodGet)
+	api.HandleFunc("/{session}", app.handleGetConversation).Methods(http.MethodGet)
+	api.HandleFunc("/{session}", app.handleDeleteSession).Methods(http.MethodDelete)
+	api.HandleFunc("/{session}/export", app.handleExportConversation).Methods(http.MethodGet)
+}
+
+// handleListSessions lists the stored sessions, optionally of one user
+func (app *App) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	if !app.requireHistory(w) {
+		return
+	}
+
+	sessions, err := app.history.ListSessions(r.Context(), r.URL.Query().Get("user"))
+	if err != nil {
+		app.historyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// handleGetConversation returns every turn of a session
+func (app *App) handleGetConversation(w http.ResponseWriter, r *http.Request) {
+	if !app.requireHistory(w) {
+		return
+	}
+
+	conversation, err := app.history.GetConversation(r.Context(), mux.Vars(r)["session"])
+	if err != nil {
+		app.historyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+// handleExportConversation downloads a session as JSON or Markdown
+func (app *App) handleExportConversation(w http.ResponseWriter, r *http.Request) {
+	if !app.requireHistory(w) {
+		return
+	}
+
+	session := mux.Vars(r)["session"]
+	conversation, err := app.history.GetConversation(r.Context(), session)
+	if err != nil {
+		app.historyError(w, err)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", session+".json"))
+		writeJSON(w, http.StatusOK, conversation)
+	case "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", session+".md"))
+		w.Write([]byte(conversation.Markdown()))
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q, use json or markdown", format), http.StatusBadRequest)
+	}
+}
+
+// handleDeleteSession deletes every turn of a session
+func (app *App) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if !app.requireHistory(w) {
+		return
+	}
+
+	if err := app.history.DeleteSession(r.Context(), mux.Vars(r)["session"]); err != nil {
+		app.historyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireHistory answers 404 if history is disabled
+func (app *App) requireHistory(w http.ResponseWriter) bool {
+	if app.history == nil {
+		http.Error(w, "history is disabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// historyError maps a store error to an HTTP response
+func (app *App) historyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrSessionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("History store error: %v", err)
+	http.Error(w, "history store error", http.StatusInternalServerError)
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
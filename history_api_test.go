@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// historyServer serves the API of an app storing history in a JSONL file
+// with turns saved
+func historyServer(t *testing.T, turns ...TurnRecord) *httptest.Server {
+	t.Helper()
+	store, err := NewJsonlTranscriptStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, turn := range turns {
+		if err := store.SaveTurn(context.Background(), turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := newApp(DefaultConfig())
+	app.history = store
+	server := httptest.NewServer(app.Routes())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// request sends a request to server and returns the status and body
+func request(t *testing.T, method, url string) (int, http.Header, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, string(body)
+}
+
+func TestHistoryAPI(t *testing.T) {
+	server := historyServer(t, testTurn("alice", "s1", 1, 0), testTurn("bob", "s2", 1, 1))
+
+	status, _, body := request(t, http.MethodGet, server.URL+"/api/sessions?user=alice")
+	var sessions []SessionSummary
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &sessions) != nil || len(sessions) != 1 || sessions[0].SessionID != "s1" {
+		t.Fatalf("list: %d %s", status, body)
+	}
+
+	status, _, body = request(t, http.MethodGet, server.URL+"/api/sessions/s2")
+	var conversation Conversation
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &conversation) != nil || conversation.UserID != "bob" || len(conversation.Turns) != 1 {
+		t.Fatalf("get: %d %s", status, body)
+	}
+
+	status, header, body := request(t, http.MethodGet, server.URL+"/api/sessions/s2/export?format=markdown")
+	if status != http.StatusOK || !strings.HasPrefix(body, "# Conversation s2") || header.Get("Content-Disposition") != `attachment; filename="s2.md"` {
+		t.Errorf("markdown export: %d %v %s", status, header, body)
+	}
+	status, header, _ = request(t, http.MethodGet, server.URL+"/api/sessions/s2/export")
+	if status != http.StatusOK || header.Get("Content-Disposition") != `attachment; filename="s2.json"` {
+		t.Errorf("json export: %d %v", status, header)
+	}
+	if status, _, _ := request(t, http.MethodGet, server.URL+"/api/sessions/s2/export?format=pdf"); status != http.StatusBadRequest {
+		t.Errorf("pdf export status = %d, want 400", status)
+	}
+
+	if status, _, _ := request(t, http.MethodDelete, server.URL+"/api/sessions/s2"); status != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", status)
+	}
+	if status, _, _ := request(t, http.MethodGet, server.URL+"/api/sessions/s2"); status != http.StatusNotFound {
+		t.Errorf("deleted session status = %d, want 404", status)
+	}
+	if status, _, _ := request(t, http.MethodDelete, server.URL+"/api/sessions/s2"); status != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want 404", status)
+	}
+}
+
+func TestHistoryAPIDisabled(t *testing.T) {
+	server := httptest.NewServer(newApp(DefaultConfig()).Routes())
+	defer server.Close()
+
+	if status, _, body := request(t, http.MethodGet, server.URL+"/api/sessions"); status != http.StatusNotFound || !strings.Contains(body, "history is disabled") {
+		t.Errorf("list without history: %d %s", status, body)
+	}
+}
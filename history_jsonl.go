@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jsonlTranscriptStore appends one JSON line per turn to a file
+type jsonlTranscriptStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewJsonlTranscriptStore opens the JSONL store at path, creating the file if needed
+func NewJsonlTranscriptStore(path string) (TranscriptStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	file.Close()
+
+	return &jsonlTranscriptStore{path: path}, nil
+}
+
+// SaveTurn appends a turn to the file
+func (s *jsonlTranscriptStore) SaveTurn(ctx context.Context, turn TurnRecord) error {
+	line, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// ListSessions lists the stored sessions
+func (s *jsonlTranscriptStore) ListSessions(ctx context.Context, user string) ([]SessionSummary, error) {
+	turns, err := s.readTurns(func(turn TurnRecord) bool {
+		return user == "" || turn.UserID == user
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summarizeSessions(turns), nil
+}
+
+// GetConversation returns the turns of a session
+func (s *jsonlTranscriptStore) GetConversation(ctx context.Context, session string) (Conversation, error) {
+	turns, err := s.readTurns(func(turn TurnRecord) bool {
+		return turn.SessionID == session
+	})
+	if err != nil {
+		return Conversation{}, err
+	}
+	if len(turns) == 0 {
+		return Conversation{}, ErrSessionNotFound
+	}
+	return Conversation{UserID: turns[0].UserID, SessionID: session, Turns: turns}, nil
+}
+
+// DeleteSession rewrites the file without the turns of a session
+func (s *jsonlTranscriptStore) DeleteSession(ctx context.Context, session string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	turns, err := s.readTurnsLocked(func(TurnRecord) bool { return true })
+	if err != nil {
+		return err
+	}
+	kept := turns[:0]
+	for _, turn := range turns {
+		if turn.SessionID != session {
+			kept = append(kept, turn)
+		}
+	}
+	if len(kept) == len(turns) {
+		return ErrSessionNotFound
+	}
+
+	// Write a new file and swap it in so a crash never leaves a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, turn := range kept {
+		if err := encoder.Encode(turn); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Close closes the store
+func (s *jsonlTranscriptStore) Close() error {
+	return nil
+}
+
+// readTurns returns the turns matching keep in file order
+func (s *jsonlTranscriptStore) readTurns(keep func(TurnRecord) bool) ([]TurnRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.readTurnsLocked(keep)
+}
+
+func (s *jsonlTranscriptStore) readTurnsLocked(keep func(TurnRecord) bool) ([]TurnRecord, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var turns []TurnRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var turn TurnRecord
+		if err := json.Unmarshal(scanner.Bytes(), &turn); err != nil {
+			// Skip lines cut short by a crash
+			logf(LogWarn, "Skipping invalid history line: %v", err)
+			continue
+		}
+		if keep(turn) {
+			turns = append(turns, turn)
+		}
+	}
+	return turns, scanner.Err()
+}
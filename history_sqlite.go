@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTranscriptStore keeps turns in a SQLite database file
+type sqliteTranscriptStore struct {
+	db *sql.DB
+}
+
+const sqliteHistorySchema = `
+CREATE TABLE IF NOT EXISTS turns (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	turn       INTEGER NOT NULL,
+	started_at TEXT NOT NULL,
+	ended_at   TEXT NOT NULL,
+	record     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS turns_session ON turns (session_id, turn);
+CREATE INDEX IF NOT EXISTS turns_user ON turns (user_id);
+`
+
+// NewSqliteTranscriptStore opens the SQLite store at path, creating the schema if needed
+func NewSqliteTranscriptStore(path string) (TranscriptStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteHistorySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+
+	return &sqliteTranscriptStore{db: db}, nil
+}
+
+// SaveTurn inserts a turn
+func (s *sqliteTranscriptStore) SaveTurn(ctx context.Context, turn TurnRecord) error {
+	record, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO turns (user_id, session_id, turn, started_at, ended_at, record) VALUES (?, ?, ?, ?, ?, ?)`,
+		turn.UserID, turn.SessionID, turn.Turn,
+		turn.StartedAt.UTC().Format(time.RFC3339Nano), turn.EndedAt.UTC().Format(time.RFC3339Nano), string(record))
+	return err
+}
+
+// ListSessions lists the stored sessions
+func (s *sqliteTranscriptStore) ListSessions(ctx context.Context, user string) ([]SessionSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, session_id, MIN(started_at), MAX(ended_at), COUNT(*)
+		FROM turns
+		WHERE ? = '' OR user_id = ?
+		GROUP BY user_id, session_id
+		ORDER BY MAX(ended_at) DESC`, user, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var summary SessionSummary
+		var started, last string
+		if err := rows.Scan(&summary.UserID, &summary.SessionID, &started, &last, &summary.Turns); err != nil {
+			return nil, err
+		}
+		summary.StartedAt, _ = time.Parse(time.RFC3339Nano, started)
+		summary.LastActivity, _ = time.Parse(time.RFC3339Nano, last)
+		sessions = append(sessions, summary)
+	}
+	return sessions, rows.Err()
+}
+
+// GetConversation returns the turns of a session
+func (s *sqliteTranscriptStore) GetConversation(ctx context.Context, session string) (Conversation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT record FROM turns WHERE session_id = ? ORDER BY turn, id`, session)
+	if err != nil {
+		return Conversation{}, err
+	}
+	defer rows.Close()
+
+	conversation := Conversation{SessionID: session}
+	for rows.Next() {
+		var record string
+		if err := rows.Scan(&record); err != nil {
+			return Conversation{}, err
+		}
+		var turn TurnRecord
+		if err := json.Unmarshal([]byte(record), &turn); err != nil {
+			return Conversation{}, err
+		}
+		conversation.UserID = turn.UserID
+		conversation.Turns = append(conversation.Turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return Conversation{}, err
+	}
+	if len(conversation.Turns) == 0 {
+		return Conversation{}, ErrSessionNotFound
+	}
+	return conversation, nil
+}
+
+// DeleteSession deletes the turns of a session
+func (s *sqliteTranscriptStore) DeleteSession(ctx context.Context, session string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM turns WHERE session_id = ?`, session)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *sqliteTranscriptStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTurn returns a stored turn of session started minutes after a fixed time
+func testTurn(user, session string, turn int, minutes int) TurnRecord {
+	started := time.Date(2025, 3, 1, 12, minutes, 0, 0, time.UTC)
+	return TurnRecord{
+		UserID:     user,
+		SessionID:  session,
+		Turn:       turn,
+		StartedAt:  started,
+		EndedAt:    started.Add(5 * time.Second),
+		Transcript: "What time is it?",
+		Response:   "It is noon.",
+		Latency:    LatencyBreakdown{SttMs: 120, TotalMs: 900},
+		Confidence: 0.8,
+	}
+}
+
+// testTranscriptStore checks the behavior every TranscriptStore shares
+func testTranscriptStore(t *testing.T, store TranscriptStore) {
+	ctx := context.Background()
+	defer store.Close()
+
+	if sessions, err := store.ListSessions(ctx, ""); err != nil || len(sessions) != 0 {
+		t.Fatalf("empty store sessions = %v, %v", sessions, err)
+	}
+
+	turns := []TurnRecord{
+		testTurn("alice", "s1", 1, 0),
+		testTurn("alice", "s1", 2, 1),
+		testTurn("bob", "s2", 1, 2),
+		testTurn("alice", "s3", 1, 3),
+	}
+	for _, turn := range turns {
+		if err := store.SaveTurn(ctx, turn); err != nil {
+			t.Fatalf("SaveTurn: %v", err)
+		}
+	}
+
+	// Most recent first
+	sessions, err := store.ListSessions(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, session := range sessions {
+		ids = append(ids, session.SessionID)
+	}
+	if strings.Join(ids, ",") != "s3,s2,s1" {
+		t.Errorf("sessions = %v, want s3,s2,s1", ids)
+	}
+	s1 := sessions[2]
+	if s1.UserID != "alice" || s1.Turns != 2 || !s1.StartedAt.Equal(turns[0].StartedAt) || !s1.LastActivity.Equal(turns[1].EndedAt) {
+		t.Errorf("s1 summary = %+v", s1)
+	}
+
+	sessions, err = store.ListSessions(ctx, "bob")
+	if err != nil || len(sessions) != 1 || sessions[0].SessionID != "s2" {
+		t.Errorf("bob's sessions = %+v, %v", sessions, err)
+	}
+
+	conversation, err := store.GetConversation(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.UserID != "alice" || len(conversation.Turns) != 2 || conversation.Turns[1].Turn != 2 {
+		t.Errorf("conversation = %+v", conversation)
+	}
+	if turn := conversation.Turns[0]; turn.Transcript != "What time is it?" || turn.Latency.SttMs != 120 || turn.Confidence != 0.8 || !turn.StartedAt.Equal(turns[0].StartedAt) {
+		t.Errorf("stored turn = %+v", turn)
+	}
+
+	if _, err := store.GetConversation(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("missing conversation err = %v", err)
+	}
+
+	if err := store.DeleteSession(ctx, "s1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, err := store.GetConversation(ctx, "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("deleted conversation err = %v", err)
+	}
+	if err := store.DeleteSession(ctx, "s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second delete err = %v", err)
+	}
+	if sessions, _ := store.ListSessions(ctx, ""); len(sessions) != 2 {
+		t.Errorf("sessions after delete = %+v", sessions)
+	}
+}
+
+func TestJsonlTranscriptStore(t *testing.T) {
+	store, err := NewTranscriptStore(HistoryConfig{Backend: "jsonl", Path: filepath.Join(t.TempDir(), "history", "turns.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTranscriptStore(t, store)
+}
+
+func TestSqliteTranscriptStore(t *testing.T) {
+	store, err := NewTranscriptStore(HistoryConfig{Backend: "sqlite", Path: filepath.Join(t.TempDir(), "history", "history.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTranscriptStore(t, store)
+}
+
+func TestNewTranscriptStore(t *testing.T) {
+	if store, err := NewTranscriptStore(HistoryConfig{Backend: "none"}); store != nil || err != nil {
+		t.Errorf("none = %v, %v, want no store", store, err)
+	}
+	if _, err := NewTranscriptStore(HistoryConfig{Backend: "postgres"}); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
+
+func TestConversationMarkdown(t *testing.T) {
+	turn := testTurn("alice", "s1", 1, 0)
+	failed := testTurn("alice", "s1", 2, 1)
+	failed.Response = ""
+	failed.Error = "LLM unavailable"
+
+	markdown := Conversation{UserID: "alice", SessionID: "s1", Turns: []TurnRecord{turn, failed}}.Markdown()
+	for _, want := range []string{
+		"# Conversation s1",
+		"- User: alice",
+		"## Turn 1 - 2025-03-01T12:00:00Z",
+		"**User:** What time is it?",
+		"**Assistant:** It is noon.",
+		"*Error: LLM unavailable*",
+		"_STT 120 ms, first token 0 ms, LLM 0 ms, first audio 0 ms, total 900 ms_",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, markdown)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language policies a session can use
+const (
+	LanguagePolicyFixed = "fixed" // Always use the session language
+	LanguagePolicyAuto  = "auto"  // Follow the language detected by STT
+)
+
+// lookup finds the supported language matching code. Codes are compared
+// case-insensitively and a code such as "de" or "de-AT" falls back to the
+// first supported entry with the same primary subtag.
+func (c LanguagesConfig) lookup(code string) (string, LanguageConfig, bool) {
+	if code == "" {
+		return "", LanguageConfig{}, false
+	}
+	for supported, language := range c.Supported {
+		if strings.EqualFold(supported, code) {
+			return supported, language, true
+		}
+	}
+
+	primary := primarySubtag(code)
+	best := ""
+	for supported := range c.Supported {
+		// Pick the smallest match so the result does not depend on map order
+		if strings.EqualFold(primarySubtag(supported), primary) && (best == "" || supported < best) {
+			best = supported
+		}
+	}
+	if best == "" {
+		return "", LanguageConfig{}, false
+	}
+	return best, c.Supported[best], true
+}
+
+// primarySubtag returns the language part of a code such as "en-US"
+func primarySubtag(code string) string {
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		return code[:i]
+	}
+	return code
+}
+
+// sttLanguageHint returns the language code to send with an STT request.
+// Auto sessions send none so the service detects the language.
+func (s SessionSettings) sttLanguageHint() string {
+	if s.LanguagePolicy == LanguagePolicyAuto {
+		return ""
+	}
+	return s.Voice.LanguageCode
+}
+
+// applyLanguage routes a turn to the language the user spoke. Fixed sessions
+// keep their language. Auto sessions switch the TTS language and voice to the
+// detected language, or to the fallback when it is not supported. The system
+// prompt is told which language to answer in. It returns the language used.
+func (c AppConfig) applyLanguage(settings *SessionSettings, detected string) string {
+	language := settings.Voice.LanguageCode
+	if settings.LanguagePolicy == LanguagePolicyAuto && detected != "" {
+		code, entry, ok := c.Languages.lookup(detected)
+		if !ok {
+			logf(LogDebug, "Detected language %s is not supported, using %s", detected, c.Languages.Fallback)
+			code, entry, _ = c.Languages.lookup(c.Languages.Fallback)
+		}
+		if !strings.EqualFold(code, settings.Voice.LanguageCode) {
+			settings.Voice.LanguageCode = code
+			settings.Voice.VoiceName = entry.VoiceName
+		}
+		language = code
+	}
+
+	if _, entry, ok := c.Languages.lookup(language); ok && entry.Name != "" && settings.SystemPrompt != "" {
+		settings.SystemPrompt += fmt.Sprintf("\n\nThe user speaks %s. Always answer in %s.", entry.Name, entry.Name)
+	}
+	return language
+}
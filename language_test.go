@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// languageConfig returns a configuration supporting English, German and
+// Austrian German
+func languageConfig() AppConfig {
+	config := DefaultConfig()
+	config.Languages.Supported = map[string]LanguageConfig{
+		"en-US": {Name: "English", VoiceName: "en-voice"},
+		"de-DE": {Name: "German", VoiceName: "de-voice"},
+		"de-AT": {Name: "German", VoiceName: "at-voice"},
+	}
+	return config
+}
+
+func TestLanguageLookup(t *testing.T) {
+	languages := languageConfig().Languages
+	tests := map[string]string{
+		"en-US": "en-US",
+		"EN-us": "en-US",
+		"en":    "en-US",
+		"en-GB": "en-US",
+		"en_AU": "en-US",
+		"de-AT": "de-AT",
+		"de":    "de-AT", // The smallest code with the same primary subtag
+		"de-CH": "de-AT",
+	}
+	for code, want := range tests {
+		if got, _, ok := languages.lookup(code); !ok || got != want {
+			t.Errorf("lookup(%q) = %q, %v, want %q", code, got, ok, want)
+		}
+	}
+	for _, code := range []string{"", "fr-FR", "e"} {
+		if got, _, ok := languages.lookup(code); ok {
+			t.Errorf("lookup(%q) = %q, want no match", code, got)
+		}
+	}
+}
+
+func TestSttLanguageHint(t *testing.T) {
+	settings := SessionSettings{LanguagePolicy: LanguagePolicyFixed, Voice: VoiceConfig{LanguageCode: "de-DE"}}
+	if hint := settings.sttLanguageHint(); hint != "de-DE" {
+		t.Errorf("fixed hint = %q", hint)
+	}
+	settings.LanguagePolicy = LanguagePolicyAuto
+	if hint := settings.sttLanguageHint(); hint != "" {
+		t.Errorf("auto hint = %q, want none", hint)
+	}
+}
+
+func TestApplyLanguage(t *testing.T) {
+	config := languageConfig()
+	session := func(policy string) SessionSettings {
+		return SessionSettings{
+			SystemPrompt:   "Be brief.",
+			LanguagePolicy: policy,
+			Voice:          VoiceConfig{VoiceName: "chosen-voice", LanguageCode: "en-US"},
+		}
+	}
+
+	// Fixed sessions keep their language and voice
+	settings := session(LanguagePolicyFixed)
+	if language := config.applyLanguage(&settings, "de-DE"); language != "en-US" || settings.Voice.VoiceName != "chosen-voice" {
+		t.Errorf("fixed: language %s, voice %s", language, settings.Voice.VoiceName)
+	}
+	if !strings.HasSuffix(settings.SystemPrompt, "Always answer in English.") {
+		t.Errorf("fixed prompt = %q", settings.SystemPrompt)
+	}
+
+	// Auto sessions follow the detected language
+	settings = session(LanguagePolicyAuto)
+	if language := config.applyLanguage(&settings, "de"); language != "de-AT" || settings.Voice.VoiceName != "at-voice" || settings.Voice.LanguageCode != "de-AT" {
+		t.Errorf("auto: language %s, voice %+v", language, settings.Voice)
+	}
+	if !strings.HasSuffix(settings.SystemPrompt, "Always answer in German.") {
+		t.Errorf("auto prompt = %q", settings.SystemPrompt)
+	}
+
+	// The session's own voice stays when the detected language is its own
+	settings = session(LanguagePolicyAuto)
+	if language := config.applyLanguage(&settings, "en-us"); language != "en-US" || settings.Voice.VoiceName != "chosen-voice" {
+		t.Errorf("same language: language %s, voice %s", language, settings.Voice.VoiceName)
+	}
+
+	// Unsupported languages use the fallback
+	settings = session(LanguagePolicyAuto)
+	settings.Voice.LanguageCode = "de-DE"
+	if language := config.applyLanguage(&settings, "fr-FR"); language != "en-US" || settings.Voice.VoiceName != "en-voice" {
+		t.Errorf("unsupported: language %s, voice %s", language, settings.Voice.VoiceName)
+	}
+
+	// Nothing detected keeps the session language
+	settings = session(LanguagePolicyAuto)
+	if language := config.applyLanguage(&settings, ""); language != "en-US" || settings.Voice.VoiceName != "chosen-voice" {
+		t.Errorf("undetected: language %s, voice %s", language, settings.Voice.VoiceName)
+	}
+}
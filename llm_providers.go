@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LlmProvider describes an LLM backend that can be selected with llm.provider
+type LlmProvider struct {
+	// New creates a client talking to the service at baseURL
+	New func(baseURL string, config LlmConfig) LlmClient
+	// RequiresModel reports whether llm.model must be set for this provider
+	RequiresModel bool
+}
+
+var llmProviders = map[string]LlmProvider{}
+
+func init() {
+	RegisterLlmProvider("openai", LlmProvider{New: newOpenAILlmClient, RequiresModel: true})
+	RegisterLlmProvider("ollama", LlmProvider{New: newOllamaLlmClient, RequiresModel: true})
+	RegisterLlmProvider("llamacpp", LlmProvider{New: newLlamaCppLlmClient})
+	RegisterLlmProvider("mock", LlmProvider{New: newMockLlmClient})
+}
+
+// RegisterLlmProvider makes an LLM provider available under name
+func RegisterLlmProvider(name string, provider LlmProvider) {
+	llmProviders[name] = provider
+}
+
+// LlmProviderNames returns the names of the registered providers
+func LlmProviderNames() []string {
+	names := make([]string, 0, len(llmProviders))
+	for name := range llmProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewLlmClient creates a client for the provider selected in config
+func NewLlmClient(baseURL string, config LlmConfig) (LlmClient, error) {
+	provider, ok := llmProviders[config.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q", config.Provider)
+	}
+	return provider.New(strings.TrimRight(baseURL, "/"), config), nil
+}
+
+// httpLlmClient holds what every HTTP based provider needs
+type httpLlmClient struct {
+	baseURL   string
+	config    LlmConfig
+	transport *http.Transport
+	client    *http.Client
+}
+
+// newHTTPLlmClient creates the shared client. llm.timeout bounds the time to
+// the response headers only, as a streamed body lasts as long as the answer.
+func newHTTPLlmClient(baseURL string, config LlmConfig) httpLlmClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = config.Timeout.Std()
+	return httpLlmClient{
+		baseURL:   baseURL,
+		config:    config,
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+	}
+}
+
+// setTLS makes the client verify and authenticate https connections with
+// tlsConfig
+func (c *httpLlmClient) setTLS(tlsConfig *tls.Config) {
+	c.transport.TLSClientConfig = tlsConfig
+}
+
+// useTLS applies tlsConfig to clients of HTTP based providers
+func useTLS(client LlmClient, tlsConfig *tls.Config) {
+	if httpClient, ok := client.(interface{ setTLS(*tls.Config) }); ok {
+		httpClient.setTLS(tlsConfig)
+	}
+}
+
+// resolve fills the zero values of options from the client settings
+func (c *httpLlmClient) resolve(options LlmOptions) LlmOptions {
+	if options.Model == "" {
+		options.Model = c.config.Model
+	}
+	if options.Sampling == (SamplingConfig{}) {
+		options.Sampling = c.config.Sampling
+	}
+	return options
+}
+
+// post sends body as JSON to path and returns the response if it succeeded
+func (c *httpLlmClient) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal LLM request: %w", err)
+	}
+
+	// The request has until llm.timeout to connect and get the response
+	// headers. The body then streams until ctx ends.
+	reqCtx, cancel := context.WithCancel(ctx)
+	deadline := time.AfterFunc(c.config.Timeout.Std(), cancel)
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", c.baseURL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create LLM request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Authenticate with a bearer token unless a custom header is configured
+	if c.config.APIKey != "" {
+		if c.config.AuthHeader == "" || strings.EqualFold(c.config.AuthHeader, "Authorization") {
+			req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+		} else {
+			req.Header.Set(c.config.AuthHeader, c.config.APIKey)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	inTime := deadline.Stop()
+	if err != nil {
+		cancel()
+		if !inTime && ctx.Err() == nil {
+			return nil, fmt.Errorf("LLM service did not respond within %s", c.config.Timeout.Std())
+		}
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("LLM service returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the request context when the response body is
+// closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// stream reads body line by line and sends the chunk that parse extracts
+// from each line to the returned channel until parse reports the end of the
+// response, the body ends or ctx is cancelled
+func (c *httpLlmClient) stream(ctx context.Context, body io.ReadCloser, parse func(line []byte) (chunk LlmChunk, done bool, err error)) chan LlmChunk {
+	responseChan := make(chan LlmChunk)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			chunk, done, err := parse(line)
+			if err != nil {
+				log.Printf("LLM stream error: %v", err)
+				return
+			}
+			if chunk.Text != "" || len(chunk.ToolCalls) > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case responseChan <- chunk:
+				}
+			}
+			if done {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("LLM stream read error: %v", err)
+		}
+	}()
+
+	return responseChan
+}
+
+// sseData returns the payload of a server-sent events "data:" line
+func sseData(line []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil, false
+	}
+	return bytes.TrimSpace(line[len("data:"):]), true
+}
+
+// OpenAI-compatible chat completions (vLLM, OpenAI, LiteLLM, ...)
+
+type openAILlmClient struct {
+	httpLlmClient
+}
+
+func newOpenAILlmClient(baseURL string, config LlmConfig) LlmClient {
+	return &openAILlmClient{newHTTPLlmClient(baseURL, config)}
+}
+
+// openAIChatRequest is the body of a /v1/chat/completions request
+type openAIChatRequest struct {
+	Model       string           `json:"model"`
+	Messages    []ChatMessage    `json:"messages"`
+	Tools       []ToolDefinition `json:"tools,omitempty"`
+	Stream      bool             `json:"stream"`
+	Temperature float64          `json:"temperature"`
+	TopP        float64          `json:"top_p"`
+	MaxTokens   int              `json:"max_tokens,omitempty"`
+}
+
+// openAIChatChunk is a single streamed chat completion chunk
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string                `json:"content"`
+			ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// openAIToolCallDelta is a fragment of a streamed tool call. The id and name
+// arrive in the first fragment, the arguments are spread over all of them.
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// GetResponse streams a chat completion
+func (c *openAILlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	options = c.resolve(options)
+
+	resp, err := c.post(ctx, "/v1/chat/completions", openAIChatRequest{
+		Model:       options.Model,
+		Messages:    messages,
+		Tools:       tools,
+		Stream:      true,
+		Temperature: options.Sampling.Temperature,
+		TopP:        options.Sampling.TopP,
+		MaxTokens:   options.Sampling.MaxTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tool calls are assembled from their deltas and emitted as one chunk
+	// once the model finishes
+	var pending []ToolCall
+	flush := func() LlmChunk {
+		chunk := LlmChunk{ToolCalls: pending}
+		pending = nil
+		return chunk
+	}
+
+	return c.stream(ctx, resp.Body, func(line []byte) (LlmChunk, bool, error) {
+		data, ok := sseData(line)
+		if !ok {
+			return LlmChunk{}, false, nil
+		}
+		if string(data) == "[DONE]" {
+			return flush(), true, nil
+		}
+
+		var chunk openAIChatChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return LlmChunk{}, false, fmt.Errorf("invalid chat completion chunk: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			return LlmChunk{}, false, nil
+		}
+
+		choice := chunk.Choices[0]
+		for _, delta := range choice.Delta.ToolCalls {
+			for len(pending) <= delta.Index {
+				pending = append(pending, ToolCall{Type: "function"})
+			}
+			call := &pending[delta.Index]
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			call.Function.Name += delta.Function.Name
+			call.Function.Arguments += delta.Function.Arguments
+		}
+
+		result := LlmChunk{Text: choice.Delta.Content}
+		if choice.FinishReason != nil {
+			result.ToolCalls = flush().ToolCalls
+		}
+		return result, false, nil
+	}), nil
+}
+
+// Ollama /api/chat with NDJSON streaming
+
+type ollamaLlmClient struct {
+	httpLlmClient
+}
+
+func newOllamaLlmClient(baseURL string, config LlmConfig) LlmClient {
+	return &ollamaLlmClient{newHTTPLlmClient(baseURL, config)}
+}
+
+// ollamaChatRequest is the body of an /api/chat request
+type ollamaChatRequest struct {
+	Model    string           `json:"model"`
+	Messages []ollamaMessage  `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+	Stream   bool             `json:"stream"`
+	Options  ollamaOptions    `json:"options"`
+}
+
+// ollamaMessage is a chat message in the Ollama format, which sends tool
+// call arguments as a JSON object rather than a string
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaToolCall is a tool call in the Ollama format
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaOptions holds the Ollama sampling parameters
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	TopP        float64 `json:"top_p"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// ollamaChatChunk is a single line of an /api/chat stream
+type ollamaChatChunk struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+// GetResponse streams a chat response
+func (c *ollamaLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	options = c.resolve(options)
+
+	ollamaMessages := make([]ollamaMessage, 0, len(messages))
+	for _, message := range messages {
+		converted := ollamaMessage{Role: message.Role, Content: message.Content}
+		for _, call := range message.ToolCalls {
+			var ollamaCall ollamaToolCall
+			ollamaCall.Function.Name = call.Function.Name
+			ollamaCall.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if call.Function.Arguments == "" {
+				// Calls to tools without parameters may come without arguments
+				ollamaCall.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, ollamaCall)
+		}
+		ollamaMessages = append(ollamaMessages, converted)
+	}
+
+	resp, err := c.post(ctx, "/api/chat", ollamaChatRequest{
+		Model:    options.Model,
+		Messages: ollamaMessages,
+		Tools:    tools,
+		Stream:   true,
+		Options: ollamaOptions{
+			Temperature: options.Sampling.Temperature,
+			TopP:        options.Sampling.TopP,
+			NumPredict:  options.Sampling.MaxTokens,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ollama has no call ids, so they are numbered per response
+	callCount := 0
+
+	return c.stream(ctx, resp.Body, func(line []byte) (LlmChunk, bool, error) {
+		var chunk ollamaChatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return LlmChunk{}, false, fmt.Errorf("invalid chat chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return LlmChunk{}, true, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+
+		result := LlmChunk{Text: chunk.Message.Content}
+		for _, call := range chunk.Message.ToolCalls {
+			callCount++
+			toolCall := ToolCall{ID: fmt.Sprintf("call_%d", callCount), Type: "function"}
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = string(call.Function.Arguments)
+			result.ToolCalls = append(result.ToolCalls, toolCall)
+		}
+		return result, chunk.Done, nil
+	}), nil
+}
+
+// llama.cpp server /completion with SSE streaming
+
+type llamaCppLlmClient struct {
+	httpLlmClient
+}
+
+func newLlamaCppLlmClient(baseURL string, config LlmConfig) LlmClient {
+	return &llamaCppLlmClient{newHTTPLlmClient(baseURL, config)}
+}
+
+// llamaCppCompletionRequest is the body of a /completion request
+type llamaCppCompletionRequest struct {
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream"`
+	Temperature float64  `json:"temperature"`
+	TopP        float64  `json:"top_p"`
+	NPredict    int      `json:"n_predict,omitempty"`
+	Stop        []string `json:"stop"`
+}
+
+// llamaCppCompletionChunk is a single streamed completion chunk
+type llamaCppCompletionChunk struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+}
+
+// GetResponse streams a completion for the flattened conversation.
+// The /completion endpoint has no tool support, so tools are ignored.
+func (c *llamaCppLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	options = c.resolve(options)
+
+	resp, err := c.post(ctx, "/completion", llamaCppCompletionRequest{
+		Prompt:      formatPrompt(messages),
+		Stream:      true,
+		Temperature: options.Sampling.Temperature,
+		TopP:        options.Sampling.TopP,
+		NPredict:    options.Sampling.MaxTokens,
+		Stop:        []string{"\nUser:"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.stream(ctx, resp.Body, func(line []byte) (LlmChunk, bool, error) {
+		data, ok := sseData(line)
+		if !ok {
+			return LlmChunk{}, false, nil
+		}
+
+		var chunk llamaCppCompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return LlmChunk{}, false, fmt.Errorf("invalid completion chunk: %w", err)
+		}
+		return LlmChunk{Text: chunk.Content}, chunk.Stop, nil
+	}), nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// llmRequest is a request received by a fake LLM service
+type llmRequest struct {
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// fakeLlmService serves lines as a streamed response to every request and
+// sends the requests it got to the returned channel. After the last line
+// the response stays open until the client goes away, so clients must stop
+// on the end of stream marker.
+func fakeLlmService(t *testing.T, lines ...string) (*httptest.Server, chan llmRequest) {
+	t.Helper()
+	requests := make(chan llmRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		requests <- llmRequest{path: r.URL.Path, header: r.Header, body: body}
+
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// collectResponse reads stream to the end and returns its text and tool
+// calls
+func collectResponse(t *testing.T, stream chan LlmChunk) (string, []ToolCall) {
+	t.Helper()
+	var text strings.Builder
+	var calls []ToolCall
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				return text.String(), calls
+			}
+			text.WriteString(chunk.Text)
+			calls = append(calls, chunk.ToolCalls...)
+		case <-timeout:
+			t.Fatal("stream did not end")
+		}
+	}
+}
+
+func testLlmConfig(provider string) LlmConfig {
+	return LlmConfig{
+		Provider: provider,
+		Model:    "test-model",
+		APIKey:   "secret",
+		Timeout:  Duration(2 * time.Second),
+		Sampling: SamplingConfig{Temperature: 0.2, TopP: 0.9, MaxTokens: 64},
+	}
+}
+
+var testMessages = []ChatMessage{
+	{Role: "system", Content: "Be brief."},
+	{Role: "user", Content: "What time is it?"},
+}
+
+func TestOpenAILlmClient(t *testing.T) {
+	server, requests := fakeLlmService(t,
+		`data: {"choices":[{"delta":{"content":"It is "}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_time","arguments":"{\"zone\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"UTC\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	)
+	client, err := NewLlmClient(server.URL+"/", testLlmConfig("openai"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, calls := collectResponse(t, stream)
+	if text != "It is " {
+		t.Errorf("text = %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "get_time" || calls[0].Function.Arguments != `{"zone":"UTC"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+
+	request := <-requests
+	if request.path != "/v1/chat/completions" {
+		t.Errorf("path = %s", request.path)
+	}
+	if auth := request.header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	body := request.body
+	if body["model"] != "test-model" || body["stream"] != true || body["temperature"] != 0.2 || body["top_p"] != 0.9 || body["max_tokens"] != 64.0 {
+		t.Errorf("request body = %v", body)
+	}
+	if messages := body["messages"].([]interface{}); len(messages) != 2 {
+		t.Errorf("messages = %v", messages)
+	}
+}
+
+func TestOpenAILlmClientCustomAuthHeader(t *testing.T) {
+	server, requests := fakeLlmService(t, `data: [DONE]`)
+	config := testLlmConfig("openai")
+	config.AuthHeader = "X-Api-Key"
+	client, _ := NewLlmClient(server.URL, config)
+
+	options := LlmOptions{Model: "persona-model", Sampling: SamplingConfig{Temperature: 1, TopP: 0.5}}
+	stream, err := client.GetResponse(context.Background(), testMessages, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectResponse(t, stream)
+
+	request := <-requests
+	if request.header.Get("X-Api-Key") != "secret" || request.header.Get("Authorization") != "" {
+		t.Errorf("headers = %v", request.header)
+	}
+	if request.body["model"] != "persona-model" || request.body["temperature"] != 1.0 || request.body["max_tokens"] != nil {
+		t.Errorf("request body = %v, want the options", request.body)
+	}
+}
+
+func TestOllamaLlmClient(t *testing.T) {
+	server, requests := fakeLlmService(t,
+		`{"message":{"role":"assistant","content":"Hello"},"done":false}`,
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_time","arguments":{"zone":"UTC"}}}]},"done":false}`,
+		`{"message":{"role":"assistant","content":" there"},"done":true}`,
+	)
+	client, _ := NewLlmClient(server.URL, testLlmConfig("ollama"))
+
+	messages := append(testMessages, ChatMessage{Role: "assistant", ToolCalls: []ToolCall{
+		{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "get_time", Arguments: `{"zone":"CET"}`}},
+	}})
+	stream, err := client.GetResponse(context.Background(), messages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, calls := collectResponse(t, stream)
+	if text != "Hello there" {
+		t.Errorf("text = %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Arguments != `{"zone":"UTC"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+
+	request := <-requests
+	if request.path != "/api/chat" || request.header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("path %s, headers %v", request.path, request.header)
+	}
+	options := request.body["options"].(map[string]interface{})
+	if request.body["model"] != "test-model" || options["temperature"] != 0.2 || options["top_p"] != 0.9 || options["num_predict"] != 64.0 {
+		t.Errorf("request body = %v", request.body)
+	}
+	// Tool call arguments are sent as objects
+	sent := request.body["messages"].([]interface{})[2].(map[string]interface{})
+	arguments := sent["tool_calls"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})["arguments"]
+	if arguments.(map[string]interface{})["zone"] != "CET" {
+		t.Errorf("tool call arguments = %v", arguments)
+	}
+}
+
+func TestOllamaLlmClientToolCallWithoutArguments(t *testing.T) {
+	server, requests := fakeLlmService(t, `{"message":{"role":"assistant","content":"Done"},"done":true}`)
+	client, _ := NewLlmClient(server.URL, testLlmConfig("ollama"))
+
+	messages := append(testMessages, ChatMessage{Role: "assistant", ToolCalls: []ToolCall{
+		{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "get_time"}},
+	}})
+	stream, err := client.GetResponse(context.Background(), messages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := collectResponse(t, stream); text != "Done" {
+		t.Errorf("text = %q", text)
+	}
+
+	request := <-requests
+	sent := request.body["messages"].([]interface{})[2].(map[string]interface{})
+	arguments := sent["tool_calls"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})["arguments"]
+	if arguments, ok := arguments.(map[string]interface{}); !ok || len(arguments) != 0 {
+		t.Errorf("tool call arguments = %v, want an empty object", arguments)
+	}
+}
+
+func TestOllamaLlmClientError(t *testing.T) {
+	server, _ := fakeLlmService(t,
+		`{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+		`{"error":"model unloaded"}`,
+		`{"message":{"role":"assistant","content":"lo"},"done":true}`,
+	)
+	client, _ := NewLlmClient(server.URL, testLlmConfig("ollama"))
+	stream, err := client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := collectResponse(t, stream); text != "Hel" {
+		t.Errorf("text = %q, want the stream to end at the error", text)
+	}
+}
+
+func TestLlamaCppLlmClient(t *testing.T) {
+	server, requests := fakeLlmService(t,
+		`data: {"content":"Twelve","stop":false}`,
+		``,
+		`data: {"content":" o'clock.","stop":true}`,
+	)
+	client, _ := NewLlmClient(server.URL, testLlmConfig("llamacpp"))
+	stream, err := client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := collectResponse(t, stream); text != "Twelve o'clock." {
+		t.Errorf("text = %q", text)
+	}
+
+	request := <-requests
+	if request.path != "/completion" || request.header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("path %s, headers %v", request.path, request.header)
+	}
+	body := request.body
+	if body["prompt"] != formatPrompt(testMessages) || body["temperature"] != 0.2 || body["top_p"] != 0.9 || body["n_predict"] != 64.0 {
+		t.Errorf("request body = %v", body)
+	}
+}
+
+func TestLlmClientErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, _ := NewLlmClient(server.URL, testLlmConfig("openai"))
+	_, err := client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "model not found") {
+		t.Fatalf("err = %v, want the status and detail", err)
+	}
+}
+
+func TestLlmClientTimeout(t *testing.T) {
+	// A response streaming for longer than the timeout is read to the end
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for _, word := range []string{"Slow", " but", " steady."} {
+			time.Sleep(150 * time.Millisecond)
+			fmt.Fprintf(w, "data: {\"content\":%q}\n", word)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintln(w, `data: {"content":"","stop":true}`)
+	}))
+	defer server.Close()
+
+	config := testLlmConfig("llamacpp")
+	config.Timeout = Duration(200 * time.Millisecond)
+	client, _ := NewLlmClient(server.URL, config)
+	stream, err := client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := collectResponse(t, stream); text != "Slow but steady." {
+		t.Errorf("text = %q, want the whole response", text)
+	}
+
+	// A service that never answers times out
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer stalled.Close()
+
+	client, _ = NewLlmClient(stalled.URL, config)
+	start := time.Now()
+	_, err = client.GetResponse(context.Background(), testMessages, nil, LlmOptions{})
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timed out after %s, want about 200ms", elapsed)
+	}
+}
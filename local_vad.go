@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	VadModeAuto    = "auto"    // Use the VAD service, or the built-in VAD if it is unavailable
+	VadModeService = "service" // Only use the VAD service
+	VadModeLocal   = "local"   // Only use the built-in VAD
+)
+
+// LocalVadClient is a VadClient detecting speech from the energy of the audio.
+// Speech has to stand out from an adaptive noise floor, so a steady background
+// such as a fan does not keep it triggered.
+type LocalVadClient struct {
+	config     LocalVadConfig
+	frameBytes int
+	eventChan  chan VadEvent
+	buffer     []byte
+	noiseFloor float64
+	speaking   bool
+	speechRun  time.Duration // Consecutive speech while silent
+	silenceRun time.Duration // Consecutive silence while speaking
+	closed     bool
+	mutex      sync.Mutex
+}
+
+// NewLocalVadClient creates a built-in VAD emitting the same events as the
+// VAD service
+func NewLocalVadClient(config VadConfig) *LocalVadClient {
+	local := config.Local
+	return &LocalVadClient{
+		config:     local,
+		frameBytes: int(local.Frame.Std()*inputSampleRate/time.Second) * 2,
+		eventChan:  make(chan VadEvent, config.EventBufferSize),
+		noiseFloor: local.MinLevel / local.Threshold,
+	}
+}
+
+// ProcessAudio analyses the audio frame by frame
+func (c *LocalVadClient) ProcessAudio(audioData []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.buffer = append(c.buffer, audioData...)
+	for len(c.buffer) >= c.frameBytes {
+		c.processFrame(c.buffer[:c.frameBytes])
+		c.buffer = c.buffer[c.frameBytes:]
+	}
+	return nil
+}
+
+// processFrame updates the speech state with one frame and emits the events
+func (c *LocalVadClient) processFrame(frame []byte) {
+	level, crossings := audioLevel(frame)
+	speech := c.isSpeech(level, crossings)
+	c.adaptNoiseFloor(level, speech)
+	message := fmt.Sprintf("local rms %.3f floor %.3f zcr %.2f", level, c.noiseFloor, crossings)
+
+	frameDuration := c.config.Frame.Std()
+	if !c.speaking {
+		if !speech {
+			c.speechRun = 0
+			return
+		}
+		c.speechRun += frameDuration
+		if c.speechRun < c.config.MinSpeech.Std() {
+			return
+		}
+		c.speaking = true
+		c.silenceRun = 0
+		c.emit("start", message)
+		return
+	}
+
+	// Keep speaking through short pauses
+	if speech {
+		c.silenceRun = 0
+	} else {
+		c.silenceRun += frameDuration
+		if c.silenceRun >= c.config.Hangover.Std() {
+			c.speaking = false
+			c.speechRun = 0
+			c.emit("end", message)
+			return
+		}
+	}
+	c.emit("continue", message)
+}
+
+// isSpeech reports whether a frame sounds like voice. Frames with many zero
+// crossings are hiss unless they are loud, which keeps fricatives.
+func (c *LocalVadClient) isSpeech(level, crossings float64) bool {
+	threshold := math.Max(c.config.MinLevel, c.noiseFloor*c.config.Threshold)
+	if level < threshold {
+		return false
+	}
+	return crossings <= c.config.MaxZeroCrossings || level >= 2*threshold
+}
+
+// adaptNoiseFloor follows the background level: quickly down, slowly up, and
+// much slower during speech so a new steady noise eventually ends an utterance
+func (c *LocalVadClient) adaptNoiseFloor(level float64, speech bool) {
+	rate := c.config.NoiseAdaptation
+	switch {
+	case level < c.noiseFloor:
+		rate = 0.5
+	case speech:
+		rate /= 20
+	}
+	c.noiseFloor += rate * (level - c.noiseFloor)
+}
+
+// emit sends an event without blocking the audio path
+func (c *LocalVadClient) emit(event, message string) {
+	logf(LogDebug, "Local VAD event: %s - %s", event, message)
+	select {
+	case c.eventChan <- VadEvent{Type: event, Message: message}:
+	default:
+		log.Printf("VAD event channel full, discarding: %s - %s", event, message)
+	}
+}
+
+// IsActive analyses the audio and returns whether speech is ongoing
+func (c *LocalVadClient) IsActive(audioData []byte) bool {
+	if audioData != nil {
+		_ = c.ProcessAudio(audioData)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.speaking
+}
+
+// ResetVAD forgets the current utterance but keeps the noise floor
+func (c *LocalVadClient) ResetVAD() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.buffer = nil
+	c.speaking = false
+	c.speechRun = 0
+	c.silenceRun = 0
+	return nil
+}
+
+// GetEventChannel returns the VAD event channel
+func (c *LocalVadClient) GetEventChannel() <-chan VadEvent {
+	return c.eventChan
+}
+
+// Close stops the VAD and closes the event channel
+func (c *LocalVadClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.eventChan)
+	}
+	return nil
+}
+
+// audioLevel returns the RMS level of 16-bit PCM as a fraction of full scale
+// and the share of samples where the signal crosses zero
+func audioLevel(pcm []byte) (float64, float64) {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	crossings := 0
+	previous := int16(0)
+	for i := 0; i < samples; i++ {
+		sample := int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+		value := float64(sample) / 32768
+		sum += value * value
+		if i > 0 && (sample >= 0) != (previous >= 0) {
+			crossings++
+		}
+		previous = sample
+	}
+	return math.Sqrt(sum / float64(samples)), float64(crossings) / float64(samples)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// sineWave returns duration of a 16 kHz sine at frequency with amplitude as
+// a fraction of full scale
+func sineWave(frequency, amplitude float64, duration time.Duration) []byte {
+	samples := int(duration * inputSampleRate / time.Second)
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		value := amplitude * math.Sin(2*math.Pi*frequency*float64(i)/inputSampleRate)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(value*32767)))
+	}
+	return pcm
+}
+
+// whiteNoise returns duration of 16 kHz noise with samples uniform within
+// amplitude
+func whiteNoise(amplitude float64, duration time.Duration) []byte {
+	random := rand.New(rand.NewSource(1))
+	samples := int(duration * inputSampleRate / time.Second)
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		value := amplitude * (2*random.Float64() - 1)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(value*32767)))
+	}
+	return pcm
+}
+
+// mixPCM adds the samples of b to a
+func mixPCM(a, b []byte) []byte {
+	mixed := make([]byte, len(a))
+	for i := 0; i+1 < len(a) && i+1 < len(b); i += 2 {
+		sum := int16(binary.LittleEndian.Uint16(a[i:])) + int16(binary.LittleEndian.Uint16(b[i:]))
+		binary.LittleEndian.PutUint16(mixed[i:], uint16(sum))
+	}
+	return mixed
+}
+
+// vadEvents feeds pcm to vad in 100ms chunks and returns the event types it
+// emitted, without the continue events
+func vadEvents(t *testing.T, vad *LocalVadClient, pcm []byte) []string {
+	t.Helper()
+	chunk := pcmBytes(100 * time.Millisecond)
+	for offset := 0; offset < len(pcm); offset += chunk {
+		vad.ProcessAudio(pcm[offset:min(offset+chunk, len(pcm))])
+	}
+
+	var events []string
+	for {
+		select {
+		case event := <-vad.GetEventChannel():
+			if event.Type != "continue" {
+				events = append(events, event.Type)
+			}
+		default:
+			return events
+		}
+	}
+}
+
+func testLocalVad() *LocalVadClient {
+	config := DefaultConfig().Vad
+	config.EventBufferSize = 1000
+	return NewLocalVadClient(config)
+}
+
+func TestLocalVadUtterance(t *testing.T) {
+	vad := testLocalVad()
+	if events := vadEvents(t, vad, make([]byte, pcmBytes(time.Second))); len(events) != 0 {
+		t.Fatalf("silence events = %v", events)
+	}
+
+	// Speech with a pause shorter than the hangover is one utterance
+	var utterance []byte
+	utterance = append(utterance, sineWave(220, 0.3, 400*time.Millisecond)...)
+	utterance = append(utterance, make([]byte, pcmBytes(200*time.Millisecond))...)
+	utterance = append(utterance, sineWave(220, 0.3, 400*time.Millisecond)...)
+	if events := vadEvents(t, vad, utterance); len(events) != 1 || events[0] != "start" {
+		t.Fatalf("speech events = %v, want start", events)
+	}
+	if !vad.IsActive(nil) {
+		t.Error("not active during speech")
+	}
+
+	if events := vadEvents(t, vad, make([]byte, pcmBytes(600*time.Millisecond))); len(events) != 1 || events[0] != "end" {
+		t.Fatalf("events after the hangover = %v, want end", events)
+	}
+	if vad.IsActive(nil) {
+		t.Error("active after the hangover")
+	}
+}
+
+func TestLocalVadIgnoresBlipsAndHiss(t *testing.T) {
+	vad := testLocalVad()
+	if events := vadEvents(t, vad, sineWave(220, 0.3, 60*time.Millisecond)); len(events) != 0 {
+		t.Errorf("blip events = %v, want none below min_speech", events)
+	}
+	vadEvents(t, vad, make([]byte, pcmBytes(200*time.Millisecond)))
+
+	// Quiet noise crosses zero too often to be voice
+	if events := vadEvents(t, vad, whiteNoise(0.03, time.Second)); len(events) != 0 {
+		t.Errorf("hiss events = %v, want none", events)
+	}
+}
+
+func TestLocalVadFollowsNoiseFloor(t *testing.T) {
+	vad := testLocalVad()
+
+	// A steady hum raises the noise floor until it is no longer speech
+	hum := sineWave(100, 0.05, 20*time.Second)
+	events := vadEvents(t, vad, hum)
+	if len(events) != 2 || events[0] != "start" || events[1] != "end" {
+		t.Fatalf("hum events = %v, want start and end", events)
+	}
+
+	// Speech over the hum stands out
+	speech := mixPCM(sineWave(300, 0.4, 500*time.Millisecond), hum)
+	if events := vadEvents(t, vad, speech); len(events) != 1 || events[0] != "start" {
+		t.Errorf("speech over the hum events = %v, want start", events)
+	}
+}
+
+func TestLocalVadResetAndClose(t *testing.T) {
+	vad := testLocalVad()
+	vadEvents(t, vad, sineWave(220, 0.3, 300*time.Millisecond))
+	vad.ResetVAD()
+	if vad.IsActive(nil) {
+		t.Error("active after reset")
+	}
+
+	vad.Close()
+	vad.Close()
+	if err := vad.ProcessAudio(sineWave(220, 0.3, 300*time.Millisecond)); err != nil {
+		t.Errorf("ProcessAudio after close: %v", err)
+	}
+	if _, open := <-vad.GetEventChannel(); open {
+		t.Error("event channel open after close")
+	}
+}
+
+func TestAudioLevel(t *testing.T) {
+	if level, crossings := audioLevel(nil); level != 0 || crossings != 0 {
+		t.Errorf("empty = %v, %v", level, crossings)
+	}
+	level, crossings := audioLevel(sineWave(400, 0.5, time.Second))
+	if math.Abs(level-0.5/math.Sqrt2) > 0.001 {
+		t.Errorf("sine level = %v, want %v", level, 0.5/math.Sqrt2)
+	}
+	if math.Abs(crossings-800.0/inputSampleRate) > 0.001 {
+		t.Errorf("sine crossings = %v, want %v", crossings, 800.0/inputSampleRate)
+	}
+}
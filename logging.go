@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel controls which log messages are written
+type LogLevel int32
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var currentLogLevel atomic.Int32
+
+func init() {
+	currentLogLevel.Store(int32(LogInfo))
+}
+
+// ParseLogLevel parses a level name such as "debug" or "info"
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LogDebug, nil
+	case "info", "":
+		return LogInfo, nil
+	case "warn", "warning":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	}
+	return LogInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// SetLogLevel changes the minimum level of messages written by logf
+func SetLogLevel(level LogLevel) {
+	currentLogLevel.Store(int32(level))
+}
+
+// logf writes a log message if level is enabled
+func logf(level LogLevel, format string, args ...interface{}) {
+	if int32(level) < currentLogLevel.Load() {
+		return
+	}
+	log.Printf(format, args...)
+}
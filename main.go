@@ -7,53 +7,113 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
 	// Load configuration from .env file if present
 	_ = godotenv.Load()
 
-	// Command line flags
-	port := flag.String("port", getEnv("PORT", "8080"), "HTTP server port")
-	vadService := flag.String("vad", getEnv("VAD_SERVICE", "localhost:50051"), "VAD gRPC service address")
-	triggerService := flag.String("trigger", getEnv("TRIGGER_SERVICE", "localhost:50052"), "Trigger detection gRPC service address")
-	sttService := flag.String("stt", getEnv("STT_SERVICE", "localhost:50053"), "STT gRPC service address")
-	ttsService := flag.String("tts", getEnv("TTS_SERVICE", "localhost:50054"), "TTS gRPC service address")
-	llmService := flag.String("llm", getEnv("LLM_SERVICE", "http://localhost:8000"), "LLM HTTP service address")
+	// Command line flags override the config file and environment
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "Path to a YAML config file")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration and exit")
+	replay := flag.String("replay", "", "Replay recordings (comma separated ids or \"all\") through the pipeline and exit")
+	flag.String("port", "", "HTTP server port")
+	flag.String("vad", "", "VAD gRPC service address")
+	flag.String("trigger", "", "Trigger detection gRPC service address")
+	flag.String("stt", "", "STT gRPC service address")
+	flag.String("tts", "", "TTS gRPC service address")
+	flag.String("llm", "", "LLM HTTP service address")
+	flag.String("llm-provider", "", "LLM provider (openai, ollama, llamacpp, mock)")
+	flag.String("llm-model", "", "LLM model name")
+	flag.String("tls-cert", "", "TLS certificate file for serving HTTPS")
+	flag.String("tls-key", "", "TLS private key file for serving HTTPS")
+	flag.String("log-level", "", "Log level (debug, info, warn, error)")
+	flag.Duration("drain-timeout", 0, "Time to let in-flight turns finish on shutdown")
 
 	flag.Parse()
 
+	config, err := LoadConfig(*configPath, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if *printConfig {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config.Redacted()); err != nil {
+			log.Fatalf("Error printing configuration: %v\n", err)
+		}
+		return
+	}
+
+	level, _ := ParseLogLevel(config.LogLevel)
+	SetLogLevel(level)
+
 	// Initialize the application
-	app := NewApp(AppConfig{
-		VadServiceAddr:     *vadService,
-		TriggerServiceAddr: *triggerService,
-		SttServiceAddr:     *sttService,
-		TtsServiceAddr:     *ttsService,
-		LlmServiceAddr:     *llmService,
-	})
+	app := NewApp(config)
+
+	if *replay != "" {
+		os.Exit(runReplay(app, *replay))
+	}
+
+	// Serve HTTPS and wss when a certificate is configured
+	tlsConfig, err := serverTLSConfig(config.TLS)
+	if err != nil {
+		log.Fatalf("Error setting up TLS: %v\n", err)
+	}
 
 	// Create an HTTP server
 	server := &http.Server{
-		Addr:    ":" + *port,
-		Handler: app.Routes(),
+		Addr:      ":" + config.Server.Port,
+		Handler:   app.Routes(),
+		TLSConfig: tlsConfig,
 	}
 
 	// Start the server in a goroutine
 	go func() {
-		log.Printf("Starting server on port %s\n", *port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			log.Printf("Starting server on port %s with TLS\n", config.Server.Port)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting server on port %s\n", config.Server.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v\n", err)
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server
+	// Reload the runtime settings on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloaded, err := LoadConfig(*configPath, flag.CommandLine)
+			if err != nil {
+				log.Printf("Config reload failed, keeping current settings: %v\n", err)
+				continue
+			}
+			app.Reload(reloaded)
+		}
+	}()
+
+	// Wait for interrupt or termination signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
+	// Stop accepting new sessions and let in-flight turns finish
+	log.Println("Draining client sessions...")
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), app.currentConfig().Server.DrainTimeout.Std())
+	app.Drain(drainCtx)
+	cancelDrain()
+
 	log.Println("Shutting down server...")
 
 	// Create a deadline to wait for current operations to complete
@@ -71,11 +131,3 @@ func main() {
 
 	log.Println("Server exited properly")
 }
-
-// Helper function to get environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// counter is a family of counters told apart by the value of one label
+type counter struct {
+	name   string
+	help   string
+	label  string
+	values []string // Label values reported from the start, at 0 until counted
+}
+
+// Counters of the server, served by /metrics
+var (
+	metricWakeWordsRejected = counter{
+		name:  "assistant_wake_words_rejected_total",
+		help:  "Wake word detections ignored for a confidence below their threshold.",
+		label: "wake_word",
+	}
+	metricQuestions = counter{
+		name:   "assistant_confirmation_questions_total",
+		help:   "Questions asked about uncertain transcripts: confirm asks whether the transcript is right, repeat asks the user to say it again.",
+		label:  "kind",
+		values: []string{QuestionConfirm, QuestionRepeat},
+	}
+	metricAnswers = counter{
+		name:   "assistant_confirmation_answers_total",
+		help:   "Answers to confirmation questions by how they were taken: confirmed, an alternative accepted, rejected or restated.",
+		label:  "answer",
+		values: []string{AnswerConfirmed, AnswerAlternative, AnswerRejected, AnswerRestated},
+	}
+
+	counters = []counter{metricWakeWordsRejected, metricQuestions, metricAnswers}
+)
+
+// Metrics holds the counters of the server
+type Metrics struct {
+	mutex  sync.Mutex
+	counts map[string]map[string]int64 // Counter name to label value to count
+}
+
+// NewMetrics returns the counters with their known label values at 0
+func NewMetrics() *Metrics {
+	m := &Metrics{counts: make(map[string]map[string]int64)}
+	for _, c := range counters {
+		m.counts[c.name] = make(map[string]int64)
+		for _, value := range c.values {
+			m.counts[c.name][value] = 0
+		}
+	}
+	return m
+}
+
+// inc adds one to counter c for label value
+func (m *Metrics) inc(c counter, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counts[c.name][value]++
+}
+
+// count returns counter c for label value
+func (m *Metrics) count(c counter, value string) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counts[c.name][value]
+}
+
+// labelEscaper escapes label values for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// write writes the counters in the Prometheus text format
+func (m *Metrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		values := make([]string, 0, len(m.counts[c.name]))
+		for value := range m.counts[c.name] {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label, labelEscaper.Replace(value), m.counts[c.name][value])
+		}
+	}
+}
+
+// handleMetrics serves the counters for Prometheus
+func (app *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	app.metrics.write(w)
+}
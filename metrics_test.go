@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.inc(metricWakeWordsRejected, "hey chef")
+	m.inc(metricWakeWordsRejected, "hey chef")
+	m.inc(metricWakeWordsRejected, `say "hi"`)
+	m.inc(metricAnswers, AnswerAlternative)
+	if got := m.count(metricWakeWordsRejected, "hey chef"); got != 2 {
+		t.Errorf("rejected hey chef = %d, want 2", got)
+	}
+
+	var out strings.Builder
+	m.write(&out)
+	for _, want := range []string{
+		"# TYPE assistant_wake_words_rejected_total counter\n",
+		`assistant_wake_words_rejected_total{wake_word="hey chef"} 2` + "\n",
+		`assistant_wake_words_rejected_total{wake_word="say \"hi\""} 1` + "\n",
+		// Known label values are reported before they are counted
+		`assistant_confirmation_questions_total{kind="repeat"} 0` + "\n",
+		`assistant_confirmation_answers_total{answer="alternative"} 1` + "\n",
+		`assistant_confirmation_answers_total{answer="rejected"} 0` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	app := newApp(DefaultConfig())
+	app.metrics.inc(metricQuestions, QuestionConfirm)
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+
+	status, header, body := request(t, http.MethodGet, server.URL+"/metrics")
+	if status != http.StatusOK || !strings.HasPrefix(header.Get("Content-Type"), "text/plain") {
+		t.Errorf("status %d, content type %q", status, header.Get("Content-Type"))
+	}
+	if !strings.Contains(body, `assistant_confirmation_questions_total{kind="confirm"} 1`) {
+		t.Errorf("body = %s", body)
+	}
+}
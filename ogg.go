@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Ogg page header flags
+const (
+	oggContinued = 0x01
+	oggFirstPage = 0x02
+	oggLastPage  = 0x04
+)
+
+// oggMaxSegments is the number of lacing values a page can hold
+const oggMaxSegments = 255
+
+// oggCRCTable is the CRC-32 of Ogg pages: polynomial 0x04c11db7, not
+// reflected, no final inversion
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// oggCRC returns the checksum of a page whose checksum field is zero
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// isOgg reports whether data starts with an Ogg page
+func isOgg(data []byte) bool {
+	return len(data) >= 4 && bytes.Equal(data[0:4], []byte("OggS"))
+}
+
+// oggWriter writes the packets of one logical stream into Ogg pages. Packets
+// never span pages; a page is written once the next packet does not fit.
+type oggWriter struct {
+	buf      bytes.Buffer
+	serial   uint32
+	sequence uint32
+	segments []byte
+	body     []byte
+	granule  int64
+	flags    byte
+}
+
+// newOggWriter starts a stream with the given serial number
+func newOggWriter(serial uint32) *oggWriter {
+	return &oggWriter{serial: serial, flags: oggFirstPage}
+}
+
+// writePacket adds a packet that ends at granule
+func (w *oggWriter) writePacket(packet []byte, granule int64) {
+	lacing := len(packet)/255 + 1
+	if len(w.segments)+lacing > oggMaxSegments {
+		w.flush(0)
+	}
+	for range len(packet) / 255 {
+		w.segments = append(w.segments, 255)
+	}
+	w.segments = append(w.segments, byte(len(packet)%255))
+	w.body = append(w.body, packet...)
+	w.granule = granule
+}
+
+// flush writes the pending packets as a page
+func (w *oggWriter) flush(flags byte) {
+	if len(w.segments) == 0 && flags&oggLastPage == 0 {
+		return
+	}
+
+	start := w.buf.Len()
+	w.buf.WriteString("OggS")
+	w.buf.WriteByte(0) // Version
+	w.buf.WriteByte(w.flags | flags)
+	binary.Write(&w.buf, binary.LittleEndian, w.granule)
+	binary.Write(&w.buf, binary.LittleEndian, w.serial)
+	binary.Write(&w.buf, binary.LittleEndian, w.sequence)
+	binary.Write(&w.buf, binary.LittleEndian, uint32(0)) // Checksum, filled in below
+	w.buf.WriteByte(byte(len(w.segments)))
+	w.buf.Write(w.segments)
+	w.buf.Write(w.body)
+
+	page := w.buf.Bytes()[start:]
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+
+	w.sequence++
+	w.segments = w.segments[:0]
+	w.body = w.body[:0]
+	w.flags = 0
+}
+
+// close writes the last page and returns the stream
+func (w *oggWriter) close() []byte {
+	w.flush(oggLastPage)
+	return w.buf.Bytes()
+}
+
+// readOggPackets returns the packets of the first logical stream in data and
+// the granule position of its last page
+func readOggPackets(data []byte) ([][]byte, int64, error) {
+	var packets [][]byte
+	var partial []byte
+	var granule int64
+	serial, found := uint32(0), false
+
+	for offset := 0; offset < len(data); {
+		page := data[offset:]
+		if len(page) < 27 || !isOgg(page) {
+			return nil, 0, fmt.Errorf("invalid Ogg page at offset %d", offset)
+		}
+		segments := int(page[26])
+		headerSize := 27 + segments
+		if len(page) < headerSize {
+			return nil, 0, errors.New("truncated Ogg page")
+		}
+		bodySize := 0
+		for _, lacing := range page[27:headerSize] {
+			bodySize += int(lacing)
+		}
+		if len(page) < headerSize+bodySize {
+			return nil, 0, errors.New("truncated Ogg page")
+		}
+		page = page[:headerSize+bodySize]
+		offset += len(page)
+
+		// Check the page and skip other logical streams
+		checked := bytes.Clone(page)
+		binary.LittleEndian.PutUint32(checked[22:26], 0)
+		if oggCRC(checked) != binary.LittleEndian.Uint32(page[22:26]) {
+			return nil, 0, errors.New("Ogg page checksum mismatch")
+		}
+		pageSerial := binary.LittleEndian.Uint32(page[14:18])
+		if !found {
+			serial, found = pageSerial, true
+		}
+		if pageSerial != serial {
+			continue
+		}
+		if page[5]&oggContinued == 0 {
+			partial = nil
+		}
+
+		// Split the body into packets by their lacing values
+		body := page[headerSize:]
+		for _, lacing := range page[27:headerSize] {
+			partial = append(partial, body[:lacing]...)
+			body = body[lacing:]
+			if lacing < 255 {
+				packets = append(packets, partial)
+				partial = nil
+			}
+		}
+		if pageGranule := int64(binary.LittleEndian.Uint64(page[6:14])); pageGranule != -1 {
+			granule = pageGranule
+		}
+	}
+	if !found {
+		return nil, 0, errors.New("no Ogg pages")
+	}
+	return packets, granule, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestOggRoundTrip(t *testing.T) {
+	// Packets of 255 bytes and more need several lacing values, and enough of
+	// them spill onto further pages
+	sizes := []int{0, 1, 254, 255, 256, 600, 3000}
+	for range 200 {
+		sizes = append(sizes, 100)
+	}
+	w := newOggWriter(42)
+	var want [][]byte
+	for i, size := range sizes {
+		packet := bytes.Repeat([]byte{byte(i)}, size)
+		want = append(want, packet)
+		w.writePacket(packet, int64(i))
+	}
+	data := w.close()
+
+	packets, granule, err := readOggPackets(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != len(want) {
+		t.Fatalf("read %d packets, want %d", len(packets), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(packets[i], want[i]) {
+			t.Errorf("packet %d has %d bytes, want %d", i, len(packets[i]), len(want[i]))
+		}
+	}
+	if granule != int64(len(sizes)-1) {
+		t.Errorf("granule = %d, want %d", granule, len(sizes)-1)
+	}
+	if data[5]&oggFirstPage == 0 {
+		t.Error("first page not marked")
+	}
+}
+
+func TestReadOggPacketsErrors(t *testing.T) {
+	w := newOggWriter(1)
+	w.writePacket([]byte("packet"), 0)
+	data := w.close()
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-1] ^= 0xff
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not ogg":   []byte("RIFF0000WAVEfmt "),
+		"truncated": data[:len(data)-2],
+		"checksum":  corrupt,
+	} {
+		if _, _, err := readOggPackets(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestReadOggPacketsSkipsOtherStreams(t *testing.T) {
+	first := newOggWriter(1)
+	first.writePacket([]byte("mine"), 0)
+	other := newOggWriter(2)
+	other.writePacket([]byte("other"), 0)
+
+	data := append(first.close(), other.close()...)
+	packets, _, err := readOggPackets(data)
+	if err != nil || len(packets) != 1 || string(packets[0]) != "mine" {
+		t.Errorf("packets = %q, %v, want the first stream only", packets, err)
+	}
+}
+
+func TestOggOpusFile(t *testing.T) {
+	packets := [][]byte{testOpusPacket(31, 40), testOpusPacket(31, 40), testOpusPacket(31, 40)}
+	data := writeOggOpus(packets, 16000, 800) // The last frame is partly padding
+	head, read, err := oggOpusPackets(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels, preSkip, rate, _ := parseOpusHead(head)
+	if channels != 1 || preSkip != opusPreSkip || rate != 16000 || len(read) != 3 {
+		t.Errorf("head %d channels, pre-skip %d, %d Hz, %d packets", channels, preSkip, rate, len(read))
+	}
+
+	// The last granule position trims the padding
+	_, granule, _ := readOggPackets(data)
+	if want := int64(opusPreSkip + 800*3); granule != want {
+		t.Errorf("granule = %d, want %d", granule, want)
+	}
+
+	headers := newOggWriter(1)
+	headers.writePacket(opusHead(16000), 0)
+	if _, _, err := oggOpusPackets(headers.close()); err == nil {
+		t.Error("file without OpusTags accepted")
+	}
+	if binary.LittleEndian.Uint32(opusTags()[8:]) != uint32(len("assistant-app")) {
+		t.Error("OpusTags vendor length")
+	}
+}
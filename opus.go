@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Opus timestamps always count samples at 48kHz, whatever the input rate
+const opusClockRate = 48000
+
+// opusPreSkip is the number of samples at 48kHz the libopus encoder delays
+// its output by, which players skip at the start
+const opusPreSkip = 312
+
+// opusEncoder encodes 20ms frames of 16-bit mono PCM into Opus packets
+type opusEncoder interface {
+	Encode(pcm []int16, data []byte) (int, error)
+}
+
+// newOpusEncoder creates an encoder for sampleRate and bitrate. It is nil
+// unless the server is built with the opus tag, which links libopus.
+var newOpusEncoder func(sampleRate, bitrate int) (opusEncoder, error)
+
+// encodeOpus encodes 16-bit mono PCM into 20ms Opus packets, padding the last
+// frame with silence
+func encodeOpus(pcm []byte, sampleRate, bitrate int) ([][]byte, error) {
+	if newOpusEncoder == nil {
+		return nil, errors.New("Opus encoding requires a build with the opus tag")
+	}
+	encoder, err := newOpusEncoder(sampleRate, bitrate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opus encoder: %w", err)
+	}
+
+	frameSize := sampleRate / 50
+	samples := make([]int16, (len(pcm)/2+frameSize-1)/frameSize*frameSize)
+	for i := range len(pcm) / 2 {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+
+	var packets [][]byte
+	data := make([]byte, 4000) // Largest recommended packet
+	for start := 0; start < len(samples); start += frameSize {
+		n, err := encoder.Encode(samples[start:start+frameSize], data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode Opus frame: %w", err)
+		}
+		packets = append(packets, bytes.Clone(data[:n]))
+	}
+	return packets, nil
+}
+
+// opusDecoder decodes Opus packets into 16-bit PCM
+type opusDecoder interface {
+	Decode(data []byte, pcm []int16) (int, error)
+}
+
+// newOpusDecoder creates a decoder of every Opus mode, SILK, CELT and hybrid,
+// at sampleRate. Like newOpusEncoder it is nil unless the server is built
+// with the opus tag.
+var newOpusDecoder func(sampleRate, channels int) (opusDecoder, error)
+
+// micDecoder decodes the Opus packets of a microphone stream to 16-bit mono
+// PCM at the microphone rate. Decoding is stateful, so each stream needs its
+// own decoder.
+type micDecoder struct {
+	decoder opusDecoder
+	samples []int16
+}
+
+// newMicDecoder creates a decoder for a microphone stream
+func newMicDecoder() (*micDecoder, error) {
+	if newOpusDecoder == nil {
+		return nil, errors.New("Opus decoding requires a build with the opus tag")
+	}
+	decoder, err := newOpusDecoder(inputSampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+	// Packets hold at most 120ms
+	return &micDecoder{decoder: decoder, samples: make([]int16, inputSampleRate*120/1000)}, nil
+}
+
+// decode returns the PCM of an Opus packet
+func (d *micDecoder) decode(packet []byte) ([]byte, error) {
+	n, err := d.decoder.Decode(packet, d.samples)
+	if err != nil {
+		return nil, err
+	}
+	pcm := make([]byte, n*2)
+	for i, sample := range d.samples[:n] {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(sample))
+	}
+	return pcm, nil
+}
+
+// opusHead returns the identification header of a mono Opus stream
+func opusHead(sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusHead")
+	buf.WriteByte(1) // Version
+	buf.WriteByte(1) // Channels
+	binary.Write(&buf, binary.LittleEndian, uint16(opusPreSkip))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, int16(0)) // Output gain
+	buf.WriteByte(0)                                  // Channel mapping family
+	return buf.Bytes()
+}
+
+// parseOpusHead returns the channels, pre-skip and input sample rate of an
+// Opus identification header
+func parseOpusHead(head []byte) (channels, preSkip, sampleRate int, err error) {
+	if len(head) < 19 || !bytes.Equal(head[0:8], []byte("OpusHead")) {
+		return 0, 0, 0, errors.New("missing OpusHead")
+	}
+	channels = int(head[9])
+	preSkip = int(binary.LittleEndian.Uint16(head[10:12]))
+	sampleRate = int(binary.LittleEndian.Uint32(head[12:16]))
+	if sampleRate == 0 {
+		sampleRate = opusClockRate
+	}
+	return channels, preSkip, sampleRate, nil
+}
+
+// opusTags returns a comment header naming the encoder
+func opusTags() []byte {
+	const vendor = "assistant-app"
+	var buf bytes.Buffer
+	buf.WriteString("OpusTags")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(vendor)))
+	buf.WriteString(vendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // No comments
+	return buf.Bytes()
+}
+
+// opusPacketDuration returns the number of samples at 48kHz in an Opus
+// packet, from its table of contents byte
+func opusPacketDuration(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := int(toc >> 3)
+
+	var frameSize int
+	switch {
+	case config < 12: // SILK: 10, 20, 40 or 60ms
+		frameSize = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10 or 20ms
+		frameSize = []int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10 or 20ms
+		frameSize = []int{120, 240, 480, 960}[config%4]
+	}
+
+	frames := 1
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+	return frameSize * frames
+}
+
+// writeOggOpus wraps Opus packets of samples PCM samples at sampleRate in an
+// Ogg Opus file
+func writeOggOpus(packets [][]byte, sampleRate, samples int) []byte {
+	w := newOggWriter(1)
+	w.writePacket(opusHead(sampleRate), 0)
+	w.flush(0)
+	w.writePacket(opusTags(), 0)
+	w.flush(0)
+
+	// The last granule position trims the padding of the last frame
+	end := opusPreSkip + int64(samples)*opusClockRate/int64(sampleRate)
+	granule := int64(opusPreSkip)
+	for _, packet := range packets {
+		granule += int64(opusPacketDuration(packet))
+		w.writePacket(packet, min(granule, end))
+	}
+	return w.close()
+}
+
+// oggOpusPackets returns the identification header and the audio packets of
+// an Ogg Opus file
+func oggOpusPackets(data []byte) ([]byte, [][]byte, error) {
+	packets, _, err := readOggPackets(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(packets) < 2 {
+		return nil, nil, errors.New("Ogg Opus file without headers")
+	}
+	if _, _, _, err := parseOpusHead(packets[0]); err != nil {
+		return nil, nil, err
+	}
+	return packets[0], packets[2:], nil
+}
@@ -0,0 +1,26 @@
+//go:build opus
+
+package main
+
+import "gopkg.in/hraban/opus.v2"
+
+// Encode and decode Opus on the server with libopus
+func init() {
+	newOpusEncoder = func(sampleRate, bitrate int) (opusEncoder, error) {
+		encoder, err := opus.NewEncoder(sampleRate, 1, opus.AppVoIP)
+		if err != nil {
+			return nil, err
+		}
+		if err := encoder.SetBitrate(bitrate); err != nil {
+			return nil, err
+		}
+		return encoder, nil
+	}
+	newOpusDecoder = func(sampleRate, channels int) (opusDecoder, error) {
+		decoder, err := opus.NewDecoder(sampleRate, channels)
+		if err != nil {
+			return nil, err
+		}
+		return decoder, nil
+	}
+}
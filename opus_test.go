@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpusPacketDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   int
+	}{
+		{"empty", nil, 0},
+		{"SILK 10ms", testOpusPacket(0, 10), 480},
+		{"SILK 60ms", testOpusPacket(3, 10), 2880},
+		{"hybrid 20ms", testOpusPacket(13, 10), 960},
+		{"CELT 2.5ms", testOpusPacket(16, 10), 120},
+		{"CELT 20ms", testOpusPacket(31, 10), 960},
+		{"two frames", []byte{31<<3 | 1, 0}, 1920},
+		{"arbitrary frames", []byte{31<<3 | 3, 5}, 4800},
+		{"arbitrary without count", []byte{31<<3 | 3}, 0},
+	}
+	for _, test := range tests {
+		if got := opusPacketDuration(test.packet); got != test.want {
+			t.Errorf("%s: duration = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseOpusHead(t *testing.T) {
+	channels, preSkip, rate, err := parseOpusHead(opusHead(24000))
+	if err != nil || channels != 1 || preSkip != opusPreSkip || rate != 24000 {
+		t.Errorf("parseOpusHead = %d, %d, %d, %v", channels, preSkip, rate, err)
+	}
+
+	// A zero input rate means the stream was not resampled
+	head := opusHead(0)
+	if _, _, rate, _ := parseOpusHead(head); rate != opusClockRate {
+		t.Errorf("rate = %d, want %d", rate, opusClockRate)
+	}
+	if _, _, _, err := parseOpusHead([]byte("OpusTags")); err == nil {
+		t.Error("OpusTags parsed as OpusHead")
+	}
+}
+
+func TestEncodeOpus(t *testing.T) {
+	pcm := make([]byte, pcmBytes(50*time.Millisecond)) // Padded to three frames
+	packets, err := encodeOpus(pcm, inputSampleRate, 32000)
+	if newOpusEncoder == nil {
+		if err == nil {
+			t.Error("encoded without an encoder")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 3 {
+		t.Errorf("encoded %d packets, want 3", len(packets))
+	}
+	for _, packet := range packets {
+		if opusPacketDuration(packet) != 960 {
+			t.Errorf("packet duration = %d, want 20ms", opusPacketDuration(packet))
+		}
+	}
+}
+
+// stubOpusDecoder decodes every packet to 20ms of silence, and fails on
+// packets starting with 0xff
+type stubOpusDecoder struct{}
+
+func (stubOpusDecoder) Decode(data []byte, pcm []int16) (int, error) {
+	if len(data) > 0 && data[0] == 0xff {
+		return 0, errors.New("corrupted packet")
+	}
+	return inputSampleRate / 50, nil
+}
+
+// withStubOpusDecoder makes the stub the Opus decoder for the test
+func withStubOpusDecoder(t *testing.T) {
+	previous := newOpusDecoder
+	newOpusDecoder = func(sampleRate, channels int) (opusDecoder, error) { return stubOpusDecoder{}, nil }
+	t.Cleanup(func() { newOpusDecoder = previous })
+}
+
+func TestMicDecoder(t *testing.T) {
+	if newOpusDecoder == nil {
+		if _, err := newMicDecoder(); err == nil {
+			t.Error("mic decoder without libopus")
+		}
+	}
+
+	withStubOpusDecoder(t)
+	decoder, err := newMicDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pcm, err := decoder.decode(testOpusPacket(31, 40)); err != nil || len(pcm) != pcmBytes(20*time.Millisecond) {
+		t.Errorf("decoded %d bytes, %v, want 20ms", len(pcm), err)
+	}
+	if _, err := decoder.decode([]byte{0xff}); err == nil {
+		t.Error("corrupted packet decoded")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Limits for the voice settings a client or preset may choose
+const (
+	minSpeakingRate = 0.25
+	maxSpeakingRate = 4.0
+	minPitch        = -20.0
+	maxPitch        = 20.0
+)
+
+// SessionPreferences are the settings a client chose with the configure
+// command. They are stored on the ClientState and resolved against the
+// current configuration at the start of every turn, so presets changed by a
+// config reload apply to running sessions.
+type SessionPreferences struct {
+	Persona      string           `json:"persona,omitempty"`
+	SystemPrompt string           `json:"system_prompt,omitempty"`
+	Model        string           `json:"model,omitempty"`
+	Temperature  *float64         `json:"temperature,omitempty"`
+	TopP         *float64         `json:"top_p,omitempty"`
+	MaxTokens    *int             `json:"max_tokens,omitempty"`
+	Voice        VoicePreferences `json:"voice,omitempty"`
+	// LanguagePolicy is fixed or auto, empty uses languages.policy
+	LanguagePolicy string `json:"language_policy,omitempty"`
+	// Record turns this session's recording on or off, nil uses recording.default
+	Record *bool `json:"record,omitempty"`
+	// EchoSuppression turns echo suppression on or off, nil uses echo.default
+	EchoSuppression *bool `json:"echo_suppression,omitempty"`
+}
+
+// VoicePreferences are the TTS settings a client chose
+type VoicePreferences struct {
+	VoiceName    string   `json:"voice_name,omitempty"`
+	LanguageCode string   `json:"language_code,omitempty"`
+	SpeakingRate *float64 `json:"speaking_rate,omitempty"`
+	Pitch        *float64 `json:"pitch,omitempty"`
+}
+
+// SessionSettings are the effective settings of a session for one turn
+type SessionSettings struct {
+	Persona      string      `json:"persona"`
+	SystemPrompt string      `json:"-"`
+	Llm          LlmOptions  `json:"llm"`
+	Voice        VoiceConfig `json:"voice"`
+	// LanguagePolicy is fixed or auto
+	LanguagePolicy string `json:"language_policy"`
+	// Tools limits the tools offered to the LLM, nil offers every tool
+	Tools []string `json:"-"`
+}
+
+// PersonaInfo describes a preset to clients
+type PersonaInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// personaList returns the presets clients can choose from, sorted by name
+func (c PersonasConfig) personaList() []PersonaInfo {
+	personas := make([]PersonaInfo, 0, len(c.Presets))
+	for name, preset := range c.Presets {
+		personas = append(personas, PersonaInfo{Name: name, Description: preset.Description})
+	}
+	sort.Slice(personas, func(i, j int) bool {
+		return personas[i].Name < personas[j].Name
+	})
+	return personas
+}
+
+// validatePreferences checks preferences against the allow-lists of the configuration
+func (c AppConfig) validatePreferences(prefs SessionPreferences) error {
+	persona := c.Personas.Default
+	if prefs.Persona != "" {
+		persona = prefs.Persona
+	}
+	preset, ok := c.Personas.Presets[persona]
+	if !ok {
+		return fmt.Errorf("unknown persona %q", prefs.Persona)
+	}
+
+	if prefs.SystemPrompt != "" && !c.Personas.AllowCustomPrompt {
+		return fmt.Errorf("custom system prompts are not allowed")
+	}
+	if prefs.Model != "" && prefs.Model != preset.Model && !contains(c.Personas.AllowedModels, prefs.Model) {
+		return fmt.Errorf("model %q is not allowed", prefs.Model)
+	}
+	if prefs.Temperature != nil && (*prefs.Temperature < 0 || *prefs.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if prefs.TopP != nil && (*prefs.TopP <= 0 || *prefs.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	}
+	if prefs.MaxTokens != nil && (*prefs.MaxTokens <= 0 || *prefs.MaxTokens > c.Llm.Sampling.MaxTokens) {
+		return fmt.Errorf("max_tokens must be between 1 and %d", c.Llm.Sampling.MaxTokens)
+	}
+
+	voice := prefs.Voice
+	if voice.VoiceName != "" && voice.VoiceName != preset.Voice.VoiceName && !contains(c.Personas.AllowedVoices, voice.VoiceName) {
+		return fmt.Errorf("voice %q is not allowed", voice.VoiceName)
+	}
+	if voice.LanguageCode != "" && voice.LanguageCode != preset.Voice.LanguageCode &&
+		voice.LanguageCode != c.Tts.LanguageCode && !contains(c.Personas.AllowedLanguages, voice.LanguageCode) {
+		return fmt.Errorf("language %q is not allowed", voice.LanguageCode)
+	}
+	if voice.SpeakingRate != nil && (*voice.SpeakingRate < minSpeakingRate || *voice.SpeakingRate > maxSpeakingRate) {
+		return fmt.Errorf("speaking_rate must be between %g and %g", minSpeakingRate, maxSpeakingRate)
+	}
+	if voice.Pitch != nil && (*voice.Pitch < minPitch || *voice.Pitch > maxPitch) {
+		return fmt.Errorf("pitch must be between %g and %g", minPitch, maxPitch)
+	}
+	if prefs.Record != nil && *prefs.Record && !c.Recording.Enabled {
+		return fmt.Errorf("recording is disabled")
+	}
+	if prefs.LanguagePolicy != "" && prefs.LanguagePolicy != LanguagePolicyFixed && prefs.LanguagePolicy != LanguagePolicyAuto {
+		return fmt.Errorf("language_policy must be %s or %s", LanguagePolicyFixed, LanguagePolicyAuto)
+	}
+
+	return nil
+}
+
+// resolveSession layers the preferences over the chosen preset, which is
+// itself layered over the llm and tts settings. A persona removed by a
+// config reload falls back to the default persona.
+func (c AppConfig) resolveSession(prefs SessionPreferences) SessionSettings {
+	persona := prefs.Persona
+	preset, ok := c.Personas.Presets[persona]
+	if !ok {
+		persona = c.Personas.Default
+		preset = c.Personas.Presets[persona]
+	}
+
+	settings := SessionSettings{
+		Persona:      persona,
+		SystemPrompt: c.Llm.SystemPrompt,
+		Llm: LlmOptions{
+			Model:    c.Llm.Model,
+			Sampling: c.Llm.Sampling,
+		},
+		Voice:          c.Tts,
+		LanguagePolicy: c.Languages.Policy,
+	}
+
+	// Preset over the global settings
+	if preset.SystemPrompt != "" {
+		settings.SystemPrompt = preset.SystemPrompt
+	}
+	if preset.Model != "" {
+		settings.Llm.Model = preset.Model
+	}
+	if preset.Sampling != (SamplingConfig{}) {
+		settings.Llm.Sampling = preset.Sampling
+	}
+	if preset.Voice.VoiceName != "" {
+		settings.Voice.VoiceName = preset.Voice.VoiceName
+	}
+	if preset.Voice.LanguageCode != "" {
+		settings.Voice.LanguageCode = preset.Voice.LanguageCode
+	}
+	if preset.Voice.SpeakingRate != 0 {
+		settings.Voice.SpeakingRate = preset.Voice.SpeakingRate
+	}
+	if preset.Voice.Pitch != 0 {
+		settings.Voice.Pitch = preset.Voice.Pitch
+	}
+
+	// Client preferences over the preset
+	if prefs.SystemPrompt != "" {
+		settings.SystemPrompt = prefs.SystemPrompt
+	}
+	if prefs.Model != "" {
+		settings.Llm.Model = prefs.Model
+	}
+	if prefs.Temperature != nil {
+		settings.Llm.Sampling.Temperature = *prefs.Temperature
+	}
+	if prefs.TopP != nil {
+		settings.Llm.Sampling.TopP = *prefs.TopP
+	}
+	if prefs.MaxTokens != nil {
+		settings.Llm.Sampling.MaxTokens = *prefs.MaxTokens
+	}
+	if prefs.Voice.VoiceName != "" {
+		settings.Voice.VoiceName = prefs.Voice.VoiceName
+	}
+	if prefs.Voice.LanguageCode != "" {
+		settings.Voice.LanguageCode = prefs.Voice.LanguageCode
+	}
+	if prefs.Voice.SpeakingRate != nil {
+		settings.Voice.SpeakingRate = *prefs.Voice.SpeakingRate
+	}
+	if prefs.Voice.Pitch != nil {
+		settings.Voice.Pitch = *prefs.Voice.Pitch
+	}
+	if prefs.LanguagePolicy != "" {
+		settings.LanguagePolicy = prefs.LanguagePolicy
+	}
+
+	return settings
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Playback events clients acknowledge
+const (
+	PlaybackStart    = "start"
+	PlaybackFinish   = "finish"
+	PlaybackPosition = "position"
+)
+
+// PlaybackAck reports the playback of the speech sent to a client
+type PlaybackAck struct {
+	Event      string `json:"event"`       // start, finish or position, position when empty
+	ID         uint32 `json:"id"`          // Audio frame of a start or finish event
+	PositionMs int    `json:"position_ms"` // Milliseconds of speech played in the session
+	Playing    bool   `json:"playing"`
+}
+
+// playbackTracker follows the speech a client has yet to play. Frames are
+// played back to back, so the speech should have finished by the time each
+// frame would end after the previous one.
+type playbackTracker struct {
+	mutex    sync.Mutex
+	nextID   uint32
+	pending  map[uint32]time.Duration // Frames not finished yet and their durations
+	end      time.Time                // When the speech sent should have finished playing
+	acked    bool                     // Whether the client ever acknowledged playback
+	finished chan struct{}            // Closed when a frame finishes
+}
+
+// sent registers a frame of speech and returns its id
+func (p *playbackTracker) sent(duration time.Duration) uint32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.pending == nil {
+		p.pending = make(map[uint32]time.Duration)
+	}
+	p.nextID++
+	p.pending[p.nextID] = duration
+	p.end = later(p.end, time.Now()).Add(duration)
+	return p.nextID
+}
+
+// acknowledge records a start or finish event of the client
+func (p *playbackTracker) acknowledge(ack PlaybackAck) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.acked = true
+	switch ack.Event {
+	case PlaybackStart:
+		// The speech ends once this frame and the ones queued after it play
+		if _, ok := p.pending[ack.ID]; ok {
+			end := time.Now()
+			for id, duration := range p.pending {
+				if id >= ack.ID {
+					end = end.Add(duration)
+				}
+			}
+			p.end = end
+		}
+	case PlaybackFinish:
+		delete(p.pending, ack.ID)
+		if p.finished != nil {
+			close(p.finished)
+			p.finished = nil
+		}
+	}
+}
+
+// flush forgets the frames sent, for speech the client drops
+func (p *playbackTracker) flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	clear(p.pending)
+	p.end = time.Now()
+	if p.finished != nil {
+		close(p.finished)
+		p.finished = nil
+	}
+}
+
+// wait blocks until the client has played every frame or ctx is done. Clients
+// that acknowledge playback get timeout past the expected end of the speech
+// before their frames are given up on; others are assumed to finish on time.
+// It returns false if frames were given up on.
+func (p *playbackTracker) wait(ctx context.Context, timeout time.Duration) bool {
+	for {
+		p.mutex.Lock()
+		if len(p.pending) == 0 {
+			p.mutex.Unlock()
+			return true
+		}
+		deadline := p.end
+		if p.acked {
+			deadline = deadline.Add(timeout)
+		}
+		if !time.Now().Before(deadline) {
+			acked := p.acked
+			clear(p.pending)
+			p.mutex.Unlock()
+			return !acked
+		}
+		if p.finished == nil {
+			p.finished = make(chan struct{})
+		}
+		finished := p.finished
+		p.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return true
+		case <-finished:
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// handlePlayback records a playback acknowledgement from the client
+func (cs *ClientState) handlePlayback(command string) {
+	var ack PlaybackAck
+	if err := json.Unmarshal([]byte(command), &ack); err != nil {
+		log.Printf("Invalid playback command: %v", err)
+		return
+	}
+	switch ack.Event {
+	case "", PlaybackPosition:
+	case PlaybackStart, PlaybackFinish:
+		logf(LogDebug, "Playback %s of audio %d at %dms", ack.Event, ack.ID, ack.PositionMs)
+	default:
+		log.Printf("Invalid playback event: %q", ack.Event)
+		return
+	}
+
+	cs.echo.acknowledge(ack)
+	cs.playback.acknowledge(ack)
+}
+
+// waitForPlayback keeps the session speaking until the client has played the
+// speech sent to it
+func (cs *ClientState) waitForPlayback(ctx context.Context, config AppConfig) {
+	if !cs.playback.wait(ctx, config.Audio.PlaybackTimeout.Std()) {
+		log.Printf("Session %s did not acknowledge the end of playback within %s", cs.sessionID, config.Audio.PlaybackTimeout.Std())
+		cs.recordEvent("playback", "timeout", fmt.Sprintf("no finish within %s", config.Audio.PlaybackTimeout.Std()))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlaybackWithoutAcks(t *testing.T) {
+	// Clients that never acknowledge are assumed to play the speech on time
+	var p playbackTracker
+	p.sent(30 * time.Millisecond)
+	p.sent(30 * time.Millisecond)
+	start := time.Now()
+	if !p.wait(context.Background(), time.Minute) {
+		t.Error("wait gave up on a client without acks")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("waited %s, want the 60ms of speech", elapsed)
+	}
+}
+
+func TestPlaybackFinish(t *testing.T) {
+	var p playbackTracker
+	first := p.sent(time.Hour)
+	second := p.sent(time.Hour)
+	if first == second {
+		t.Fatalf("both frames have id %d", first)
+	}
+	p.acknowledge(PlaybackAck{Event: PlaybackStart, ID: first})
+	go func() {
+		p.acknowledge(PlaybackAck{Event: PlaybackFinish, ID: first})
+		time.Sleep(10 * time.Millisecond)
+		p.acknowledge(PlaybackAck{Event: PlaybackFinish, ID: second})
+	}()
+
+	done := make(chan bool)
+	go func() { done <- p.wait(context.Background(), time.Minute) }()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("wait gave up on finished frames")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait still blocked after both frames finished")
+	}
+}
+
+func TestPlaybackTimeout(t *testing.T) {
+	// Clients that acknowledge get the timeout past the end of the speech
+	var p playbackTracker
+	id := p.sent(20 * time.Millisecond)
+	p.acknowledge(PlaybackAck{Event: PlaybackStart, ID: id})
+	start := time.Now()
+	if p.wait(context.Background(), 50*time.Millisecond) {
+		t.Error("wait did not report the missing finish")
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("gave up after %s, want the speech and the timeout", elapsed)
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("%d frames still pending after giving up", len(p.pending))
+	}
+}
+
+func TestPlaybackStartMovesEnd(t *testing.T) {
+	// A client starting late pushes the end back by what is left to play
+	var p playbackTracker
+	first := p.sent(time.Second)
+	second := p.sent(time.Second)
+	p.end = time.Now()
+	p.acknowledge(PlaybackAck{Event: PlaybackStart, ID: second})
+	if left := time.Until(p.end); left < 900*time.Millisecond || left > time.Second {
+		t.Errorf("speech ends in %s after the last frame started, want 1s", left)
+	}
+	p.acknowledge(PlaybackAck{Event: PlaybackStart, ID: first})
+	if left := time.Until(p.end); left < 1900*time.Millisecond {
+		t.Errorf("speech ends in %s after the first frame started, want 2s", left)
+	}
+
+	// Unknown frames leave it alone
+	end := p.end
+	p.acknowledge(PlaybackAck{Event: PlaybackStart, ID: 99})
+	if !p.end.Equal(end) {
+		t.Error("start of an unknown frame moved the end")
+	}
+}
+
+func TestPlaybackFlushAndCancel(t *testing.T) {
+	var p playbackTracker
+	p.acknowledge(PlaybackAck{})
+	p.sent(time.Hour)
+	done := make(chan bool)
+	go func() { done <- p.wait(context.Background(), time.Hour) }()
+	time.Sleep(10 * time.Millisecond)
+	p.flush()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("flushed speech reported as a timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait still blocked after flush")
+	}
+
+	p.sent(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if !p.wait(ctx, time.Hour) {
+		t.Error("cancelled wait reported as a timeout")
+	}
+}
+
+func TestHandlePlayback(t *testing.T) {
+	app := newApp(DefaultConfig())
+	cs := NewClientState(&frameTransport{}, app, "alice")
+	id := cs.playback.sent(time.Hour)
+
+	cs.handleTextCommand(`{"action":"playback","event":"bogus","id":1}`)
+	cs.handleTextCommand(`{"action":"playback","event":"start",`)
+	if cs.playback.acked || !cs.echo.ackedAt.IsZero() {
+		t.Error("invalid playback commands acknowledged")
+	}
+
+	cs.handleTextCommand(`{"action":"playback","position_ms":1500,"playing":true}`)
+	if !cs.playback.acked || cs.echo.position != 150 || !cs.echo.playing {
+		t.Errorf("position not recorded: acked %v, echo position %v", cs.playback.acked, cs.echo.position)
+	}
+	cs.handleTextCommand(`{"action":"playback","event":"finish","id":1}`)
+	if _, ok := cs.playback.pending[id]; ok {
+		t.Error("finished frame still pending")
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRecordingNotFound is returned for an unknown recording id
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// maxSessionEvents caps the events kept for the next recording of a session
+const maxSessionEvents = 500
+
+// recordingIDPattern matches the ids the Recorder creates, so an id from a
+// request can never name a file outside the recording directory
+var recordingIDPattern = regexp.MustCompile(`^[0-9a-f]+-[0-9]+$`)
+
+// RecordedEvent is a pipeline event kept in the sidecar of a recording
+type RecordedEvent struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"` // vad, trigger, stt, llm; status and admin on the admin feed
+	Type   string    `json:"type"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Recording is the sidecar JSON describing a recorded utterance
+type Recording struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"user_id"`
+	SessionID  string          `json:"session_id"`
+	Turn       int             `json:"turn"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	SampleRate int             `json:"sample_rate"`
+	DurationMs int64           `json:"duration_ms"`
+	Persona    string          `json:"persona,omitempty"`
+	WakeWord   string          `json:"wake_word,omitempty"`
+	Language   string          `json:"language,omitempty"`
+	Transcript string          `json:"transcript"`
+	Response   string          `json:"response,omitempty"`
+	HasReply   bool            `json:"has_reply"`
+	Events     []RecordedEvent `json:"events"`
+}
+
+// Recorder writes utterances to WAV files with a JSON sidecar and enforces
+// the retention limits
+type Recorder struct {
+	config RecordingConfig
+	mutex  sync.Mutex
+}
+
+// NewRecorder creates the recording directory and returns a recorder, or nil
+// if recording is disabled
+func NewRecorder(config RecordingConfig) (*Recorder, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &Recorder{config: config}, nil
+}
+
+// Save writes the utterance, the optional reply and the sidecar of a recording
+func (r *Recorder) Save(recording Recording, utterance []byte, reply []byte) error {
+	recording.SampleRate = inputSampleRate
+	recording.DurationMs = int64(len(utterance)) * 1000 / (inputSampleRate * 2)
+	recording.HasReply = len(reply) > 0
+
+	sidecar, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	base := filepath.Join(r.config.Dir, recording.ID)
+	if err := os.WriteFile(base+".wav", wrapWav(utterance, inputSampleRate), 0o600); err != nil {
+		return err
+	}
+	if recording.HasReply {
+		if err := os.WriteFile(base+".reply.wav", wrapWav(reply, ttsSampleRate), 0o600); err != nil {
+			return err
+		}
+	}
+	// The sidecar goes last so a listed recording always has its audio
+	if err := os.WriteFile(base+".json", sidecar, 0o600); err != nil {
+		return err
+	}
+
+	r.pruneLocked()
+	return nil
+}
+
+// List returns the stored recordings, newest first
+func (r *Recorder) List() ([]Recording, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.listLocked()
+}
+
+// Get returns the sidecar of a recording
+func (r *Recorder) Get(id string) (Recording, error) {
+	if !recordingIDPattern.MatchString(id) {
+		return Recording{}, ErrRecordingNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(r.config.Dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Recording{}, ErrRecordingNotFound
+	}
+	if err != nil {
+		return Recording{}, err
+	}
+
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return Recording{}, err
+	}
+	return recording, nil
+}
+
+// AudioPath returns the WAV file of a recording, or of its reply
+func (r *Recorder) AudioPath(id string, reply bool) (string, error) {
+	if !recordingIDPattern.MatchString(id) {
+		return "", ErrRecordingNotFound
+	}
+	path := filepath.Join(r.config.Dir, id+".wav")
+	if reply {
+		path = filepath.Join(r.config.Dir, id+".reply.wav")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrRecordingNotFound
+	}
+	return path, nil
+}
+
+// Delete removes the files of a recording
+func (r *Recorder) Delete(id string) error {
+	if !recordingIDPattern.MatchString(id) {
+		return ErrRecordingNotFound
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	base := filepath.Join(r.config.Dir, id)
+	if err := os.Remove(base + ".json"); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrRecordingNotFound
+		}
+		return err
+	}
+	os.Remove(base + ".wav")
+	os.Remove(base + ".reply.wav")
+	return nil
+}
+
+func (r *Recorder) listLocked() ([]Recording, error) {
+	paths, err := filepath.Glob(filepath.Join(r.config.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := make([]Recording, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		recording, err := r.Get(id)
+		if err != nil {
+			logf(LogWarn, "Skipping recording %s: %v", id, err)
+			continue
+		}
+		recordings = append(recordings, recording)
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].RecordedAt.After(recordings[j].RecordedAt)
+	})
+	return recordings, nil
+}
+
+// pruneLocked deletes recordings older than max_age and the oldest
+// recordings beyond max_recordings
+func (r *Recorder) pruneLocked() {
+	recordings, err := r.listLocked()
+	if err != nil {
+		log.Printf("Failed to list recordings for retention: %v", err)
+		return
+	}
+
+	for i, recording := range recordings {
+		expired := r.config.MaxAge > 0 && time.Since(recording.RecordedAt) > r.config.MaxAge.Std()
+		excess := r.config.MaxRecordings > 0 && i >= r.config.MaxRecordings
+		if expired || excess {
+			base := filepath.Join(r.config.Dir, recording.ID)
+			os.Remove(base + ".json")
+			os.Remove(base + ".wav")
+			os.Remove(base + ".reply.wav")
+		}
+	}
+}
+
+// recordEvent adds a pipeline event to the log kept for the next recording
+// and shows it to admin watchers
+func (cs *ClientState) recordEvent(source, eventType, detail string) {
+	event := RecordedEvent{Time: time.Now(), Source: source, Type: eventType, Detail: detail}
+	cs.feed.publish(event)
+	if cs.app.recorder == nil {
+		return
+	}
+
+	cs.eventsMutex.Lock()
+	defer cs.eventsMutex.Unlock()
+	if len(cs.events) >= maxSessionEvents {
+		cs.events = cs.events[1:]
+	}
+	cs.events = append(cs.events, event)
+}
+
+// takeEvents returns and clears the events logged since the last recording
+func (cs *ClientState) takeEvents() []RecordedEvent {
+	cs.eventsMutex.Lock()
+	defer cs.eventsMutex.Unlock()
+	events := cs.events
+	cs.events = nil
+	return events
+}
+
+// recordingEnabled reports whether the turns of this session are recorded
+func (cs *ClientState) recordingEnabled(config AppConfig) bool {
+	if cs.app.recorder == nil {
+		return false
+	}
+	cs.preferencesMutex.Lock()
+	defer cs.preferencesMutex.Unlock()
+	if cs.preferences.Record != nil {
+		return *cs.preferences.Record
+	}
+	return config.Recording.Default
+}
+
+// saveRecording stores the utterance of a finished turn with its events
+func (cs *ClientState) saveRecording(record *TurnRecord) {
+	events := cs.takeEvents()
+	recording := Recording{
+		ID:         fmt.Sprintf("%s-%d", cs.sessionID, record.Turn),
+		UserID:     record.UserID,
+		SessionID:  record.SessionID,
+		Turn:       record.Turn,
+		RecordedAt: record.StartedAt,
+		Persona:    record.Persona,
+		WakeWord:   record.WakeWord,
+		Language:   record.Language,
+		Transcript: record.Transcript,
+		Response:   record.Response,
+		Events:     events,
+	}
+	reply := record.replyAudio
+	if !cs.app.recorder.config.IncludeReplies {
+		reply = nil
+	}
+	if err := cs.app.recorder.Save(recording, record.utterance, reply); err != nil {
+		log.Printf("Failed to save recording %s: %v", recording.ID, err)
+		return
+	}
+	logf(LogDebug, "Saved recording %s", recording.ID)
+}
+
+// ReplayResult is the outcome of running a recording through the current pipeline
+type ReplayResult struct {
+	ID                 string `json:"id"`
+	Transcript         string `json:"transcript"`
+	Language           string `json:"language,omitempty"`
+	Response           string `json:"response"`
+	OriginalTranscript string `json:"original_transcript"`
+	TranscriptMatches  bool   `json:"transcript_matches"`
+	SttMs              int64  `json:"stt_ms"`
+	LlmMs              int64  `json:"llm_ms"`
+}
+
+// Replay runs a stored utterance through the current STT and LLM settings,
+// with the persona it was recorded with. Tools are not offered because
+// their handlers need a live session.
+func (app *App) Replay(ctx context.Context, id string) (ReplayResult, error) {
+	if app.recorder == nil {
+		return ReplayResult{}, fmt.Errorf("recording is disabled")
+	}
+	recording, err := app.recorder.Get(id)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	path, err := app.recorder.AudioPath(id, false)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	pcm, _, err := wavPCM(data)
+	if err != nil {
+		return ReplayResult{}, fmt.Errorf("recording %s: %w", id, err)
+	}
+	if app.sttClient == nil || app.llmClient == nil {
+		return ReplayResult{}, fmt.Errorf("STT and LLM services are required for replay")
+	}
+
+	config := app.currentConfig()
+	settings := config.resolveSession(SessionPreferences{Persona: recording.Persona})
+	result := ReplayResult{ID: id, OriginalTranscript: recording.Transcript}
+
+	// Transcribe
+	sttStart := time.Now()
+	transcription, err := app.sttClient.Transcribe(ctx, [][]byte{pcm}, settings.sttLanguageHint(), 0)
+	result.SttMs = msSince(sttStart)
+	if err != nil {
+		return result, err
+	}
+	result.Transcript = transcription.Text
+	result.Language = config.applyLanguage(&settings, transcription.LanguageCode)
+	result.TranscriptMatches = normalizeTranscript(result.Transcript) == normalizeTranscript(recording.Transcript)
+
+	// Ask the LLM
+	messages := []ChatMessage{{Role: "user", Content: result.Transcript}}
+	if settings.SystemPrompt != "" {
+		messages = append([]ChatMessage{{Role: "system", Content: settings.SystemPrompt}}, messages...)
+	}
+	llmStart := time.Now()
+	stream, err := app.llmClient.GetResponse(ctx, messages, nil, settings.Llm)
+	if err != nil {
+		return result, err
+	}
+	var response strings.Builder
+	for chunk := range stream {
+		response.WriteString(chunk.Text)
+	}
+	result.LlmMs = msSince(llmStart)
+	result.Response = strings.TrimSpace(response.String())
+	return result, ctx.Err()
+}
+
+// normalizeTranscript lowercases a transcript and drops punctuation so
+// replays are compared by their words
+func normalizeTranscript(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r == '\'' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127)
+	})
+	return strings.Join(fields, " ")
+}
+
+// runReplay replays the recordings named by ids for the -replay flag, prints
+// the results as JSON lines and returns the process exit code: 0 if every
+// transcript matched, 2 if one differed and 1 on errors
+func runReplay(app *App, ids string) int {
+	defer app.Close()
+	if app.recorder == nil {
+		log.Println("Replay failed: recording is disabled")
+		return 1
+	}
+
+	var targets []string
+	if ids == "all" {
+		recordings, err := app.recorder.List()
+		if err != nil {
+			log.Printf("Replay failed: %v", err)
+			return 1
+		}
+		for _, recording := range recordings {
+			targets = append(targets, recording.ID)
+		}
+	} else {
+		targets = strings.Split(ids, ",")
+	}
+
+	code := 0
+	encoder := json.NewEncoder(os.Stdout)
+	for _, id := range targets {
+		ctx, cancel := context.WithTimeout(context.Background(), app.currentConfig().Llm.Timeout.Std()*2)
+		result, err := app.Replay(ctx, strings.TrimSpace(id))
+		cancel()
+		if err != nil {
+			log.Printf("Replay of %s failed: %v", id, err)
+			code = 1
+			continue
+		}
+		encoder.Encode(result)
+		if !result.TranscriptMatches && code == 0 {
+			code = 2
+		}
+	}
+	return code
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// recordingRoutes registers the utterance recording API
+func (app *App) recordingRoutes(r *mux.Router) {
+	api := r.PathPrefix("/api/recordings").Subrouter()
+	api.HandleFunc("", app.handleListRecordings).Methods(http.MethodGet)
+	api.HandleFunc("/{id}", app.handleGetRecording).Methods(http.MethodGet)
+	api.HandleFunc("/{id}", app.handleDeleteRecording).Methods(http.MethodDelete)
+	api.HandleFunc("/{id}/audio", app.handleRecordingAudio).Methods(http.MethodGet)
+	api.HandleFunc("/{id}/replay", app.handleReplayRecording).Methods(http.MethodPost)
+}
+
+// handleListRecordings lists the stored recordings, newest first
+func (app *App) handleListRecordings(w http.ResponseWriter, r *http.Request) {
+	if !app.requireRecorder(w) {
+		return
+	}
+
+	recordings, err := app.recorder.List()
+	if err != nil {
+		app.recordingError(w, err)
+		return
+	}
+	if session := r.URL.Query().Get("session"); session != "" {
+		filtered := recordings[:0]
+		for _, recording := range recordings {
+			if recording.SessionID == session {
+				filtered = append(filtered, recording)
+			}
+		}
+		recordings = filtered
+	}
+	writeJSON(w, http.StatusOK, recordings)
+}
+
+// handleGetRecording returns the sidecar of a recording
+func (app *App) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	if !app.requireRecorder(w) {
+		return
+	}
+
+	recording, err := app.recorder.Get(mux.Vars(r)["id"])
+	if err != nil {
+		app.recordingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, recording)
+}
+
+// handleRecordingAudio serves the WAV of a recording, or of its reply with ?reply=true
+func (app *App) handleRecordingAudio(w http.ResponseWriter, r *http.Request) {
+	if !app.requireRecorder(w) {
+		return
+	}
+
+	path, err := app.recorder.AudioPath(mux.Vars(r)["id"], r.URL.Query().Get("reply") == "true")
+	if err != nil {
+		app.recordingError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/wav")
+	http.ServeFile(w, r, path)
+}
+
+// handleDeleteRecording deletes a recording
+func (app *App) handleDeleteRecording(w http.ResponseWriter, r *http.Request) {
+	if !app.requireRecorder(w) {
+		return
+	}
+
+	if err := app.recorder.Delete(mux.Vars(r)["id"]); err != nil {
+		app.recordingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReplayRecording runs a recording through the current pipeline
+func (app *App) handleReplayRecording(w http.ResponseWriter, r *http.Request) {
+	if !app.requireRecorder(w) {
+		return
+	}
+
+	result, err := app.Replay(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, ErrRecordingNotFound) {
+			app.recordingError(w, err)
+			return
+		}
+		log.Printf("Replay failed: %v", err)
+		http.Error(w, "replay failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// requireRecorder answers 404 if recording is disabled
+func (app *App) requireRecorder(w http.ResponseWriter) bool {
+	if app.recorder == nil {
+		http.Error(w, "recording is disabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// recordingError maps a recorder error to an HTTP response
+func (app *App) recordingError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrRecordingNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("Recording error: %v", err)
+	http.Error(w, "recording error", http.StatusInternalServerError)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordingAPI(t *testing.T) {
+	app := newApp(DefaultConfig())
+	app.recorder = testRecorder(t, 0, 0)
+	app.recorder.Save(testRecording(1, 0), make([]byte, 320), nil)
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+
+	if status, _, body := request(t, http.MethodGet, server.URL+"/api/recordings?session=abc"); status != http.StatusOK || body == "[]\n" {
+		t.Errorf("list: %d %s", status, body)
+	}
+	if status, _, body := request(t, http.MethodGet, server.URL+"/api/recordings?session=other"); status != http.StatusOK || body != "[]\n" {
+		t.Errorf("list of another session: %d %s", status, body)
+	}
+	status, header, body := request(t, http.MethodGet, server.URL+"/api/recordings/abc-1/audio")
+	if status != http.StatusOK || len(body) != 44+320 || header.Get("Content-Type") != "audio/wav" {
+		t.Errorf("audio: %d %v, %d bytes", status, header, len(body))
+	}
+	if status, _, _ := request(t, http.MethodGet, server.URL+"/api/recordings/abc-1/audio?reply=true"); status != http.StatusNotFound {
+		t.Errorf("missing reply status = %d, want 404", status)
+	}
+	if status, _, _ := request(t, http.MethodDelete, server.URL+"/api/recordings/abc-1"); status != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", status)
+	}
+	if status, _, _ := request(t, http.MethodGet, server.URL+"/api/recordings/abc-1"); status != http.StatusNotFound {
+		t.Errorf("deleted recording status = %d, want 404", status)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRecorder returns a recorder writing to a temporary directory
+func testRecorder(t *testing.T, maxAge time.Duration, maxRecordings int) *Recorder {
+	t.Helper()
+	recorder, err := NewRecorder(RecordingConfig{
+		Enabled:       true,
+		Dir:           filepath.Join(t.TempDir(), "recordings"),
+		MaxAge:        Duration(maxAge),
+		MaxRecordings: maxRecordings,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder
+}
+
+// testRecording returns the sidecar of turn of session abc recorded ago
+func testRecording(turn int, ago time.Duration) Recording {
+	return Recording{
+		ID:         fmt.Sprintf("abc-%d", turn),
+		SessionID:  "abc",
+		Turn:       turn,
+		RecordedAt: time.Now().Add(-ago),
+		Transcript: "Play some jazz.",
+		Events:     []RecordedEvent{{Source: "vad", Type: "start"}},
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := testRecorder(t, 0, 0)
+	utterance := make([]byte, inputSampleRate) // Half a second
+	if err := recorder.Save(testRecording(1, time.Minute), utterance, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Save(testRecording(2, 0), utterance, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	recordings, err := recorder.List()
+	if err != nil || len(recordings) != 2 || recordings[0].ID != "abc-2" {
+		t.Fatalf("recordings = %+v, %v, want newest first", recordings, err)
+	}
+
+	recording, err := recorder.Get("abc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recording.DurationMs != 500 || recording.SampleRate != inputSampleRate || recording.HasReply || len(recording.Events) != 1 {
+		t.Errorf("recording = %+v", recording)
+	}
+
+	if _, err := recorder.AudioPath("abc-1", false); err != nil {
+		t.Errorf("utterance audio: %v", err)
+	}
+	if _, err := recorder.AudioPath("abc-1", true); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("missing reply err = %v", err)
+	}
+	if path, err := recorder.AudioPath("abc-2", true); err != nil || filepath.Base(path) != "abc-2.reply.wav" {
+		t.Errorf("reply audio = %s, %v", path, err)
+	}
+
+	if err := recorder.Delete("abc-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.Get("abc-2"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("deleted recording err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(recorder.config.Dir, "abc-2.reply.wav")); !os.IsNotExist(err) {
+		t.Errorf("reply audio left behind: %v", err)
+	}
+	if err := recorder.Delete("abc-2"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("second delete err = %v", err)
+	}
+}
+
+func TestRecorderRejectsForeignIDs(t *testing.T) {
+	recorder := testRecorder(t, 0, 0)
+	os.WriteFile(filepath.Join(filepath.Dir(recorder.config.Dir), "secret.json"), []byte(`{}`), 0o600)
+
+	for _, id := range []string{"../secret", "abc", "ABC-1", "abc-1/../x", ""} {
+		if _, err := recorder.Get(id); !errors.Is(err, ErrRecordingNotFound) {
+			t.Errorf("Get(%q) err = %v", id, err)
+		}
+		if _, err := recorder.AudioPath(id, false); !errors.Is(err, ErrRecordingNotFound) {
+			t.Errorf("AudioPath(%q) err = %v", id, err)
+		}
+		if err := recorder.Delete(id); !errors.Is(err, ErrRecordingNotFound) {
+			t.Errorf("Delete(%q) err = %v", id, err)
+		}
+	}
+}
+
+func TestRecorderRetention(t *testing.T) {
+	recorder := testRecorder(t, time.Hour, 2)
+	for turn, ago := range []time.Duration{2 * time.Hour, 3 * time.Minute, 2 * time.Minute, time.Minute} {
+		if err := recorder.Save(testRecording(turn, ago), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// abc-0 expired and abc-1 is beyond the newest two
+	recordings, _ := recorder.List()
+	if len(recordings) != 2 || recordings[0].ID != "abc-3" || recordings[1].ID != "abc-2" {
+		t.Errorf("recordings = %+v, want abc-3 and abc-2", recordings)
+	}
+	if _, err := os.Stat(filepath.Join(recorder.config.Dir, "abc-0.wav")); !os.IsNotExist(err) {
+		t.Errorf("expired audio left behind: %v", err)
+	}
+}
+
+func TestNormalizeTranscript(t *testing.T) {
+	tests := map[string]string{
+		"Play some jazz.":        "play some jazz",
+		"  What's the TIME?!  ":  "what's the time",
+		"Set a timer, 5 minutes": "set a timer 5 minutes",
+		"Grüße aus Köln":         "grüße aus köln",
+		"...":                    "",
+	}
+	for text, want := range tests {
+		if got := normalizeTranscript(text); got != want {
+			t.Errorf("normalizeTranscript(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// setupResilience guards the backend clients with circuit breakers, adds the
+// secondary LLM and loads the trouble audio
+func (app *App) setupResilience(config AppConfig) {
+	resilience := config.Resilience
+
+	if app.triggerClient != nil {
+		breaker := app.addBreaker("trigger", resilience.Trigger)
+		app.triggerClient = &breakerTriggerClient{app.triggerClient, guarded{breaker, app.triggerClient}}
+	}
+	if app.sttClient != nil {
+		breaker := app.addBreaker("stt", resilience.Stt)
+		app.sttClient = &breakerSttClient{app.sttClient, guarded{breaker, app.sttClient}}
+	}
+	if app.ttsClient != nil {
+		app.ttsBreaker = app.addBreaker("tts", resilience.Tts)
+		app.ttsClient = &breakerTtsClient{app.ttsClient, guarded{app.ttsBreaker, app.ttsClient}}
+	}
+
+	// LLMs are tried in order until one answers
+	var llms []LlmClient
+	if app.llmClient != nil {
+		llms = append(llms, &breakerLlmClient{app.llmClient, app.addBreaker("llm", resilience.Llm)})
+	}
+	if resilience.FallbackLlm.Service != "" {
+		tlsConfig, err := clientTLSConfig(config.TLS.FallbackLlm, config.TLS.ReloadInterval)
+		var client LlmClient
+		if err == nil {
+			client, err = NewBalancedLlmClient(resilience.FallbackLlm.Service, config.fallbackLlmConfig(), config.Balancing, tlsConfig)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to create fallback LLM client: %v\n", err)
+		} else {
+			llms = append(llms, &breakerLlmClient{client, app.addBreaker("llm_fallback", resilience.Llm)})
+		}
+	}
+	switch len(llms) {
+	case 0:
+		app.llmClient = nil
+	case 1:
+		app.llmClient = llms[0]
+	default:
+		app.llmClient = &fallbackLlmClient{clients: llms}
+	}
+
+	if resilience.TroubleAudio != "" {
+		audio, err := os.ReadFile(resilience.TroubleAudio)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Failed to read trouble audio: %v\n", err)
+		case !isWav(audio):
+			log.Printf("Warning: Trouble audio %s is not a WAV file\n", resilience.TroubleAudio)
+		default:
+			app.troubleAudio = audio
+		}
+	}
+}
+
+// addBreaker creates a circuit breaker reported on the health endpoint
+func (app *App) addBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	breaker := NewCircuitBreaker(name, config)
+	app.breakers = append(app.breakers, breaker)
+	return breaker
+}
+
+// ttsUnavailable reports whether replies can only be sent as text
+func (app *App) ttsUnavailable() bool {
+	return app.ttsClient == nil || (app.ttsBreaker != nil && app.ttsBreaker.State() == BreakerOpen)
+}
+
+// apologize tells the user that a backend is failing, with the trouble audio
+// or, without one, the trouble message spoken by TTS
+func (cs *ClientState) apologize(ctx context.Context, config AppConfig, voice VoiceConfig) {
+	message := config.Resilience.TroubleMessage
+	if message == "" {
+		return
+	}
+
+	cs.sendResponse(message)
+	if cs.app.troubleAudio == nil {
+		cs.synthesizeAndSend(ctx, message, voice)
+		return
+	}
+	if err := cs.sendAudio(ctx, cs.app.troubleAudio); err != nil {
+		log.Printf("WebSocket write error: %v", err)
+	}
+}
+
+// guarded holds the circuit breaker of a wrapped client and forwards the
+// version the client reports
+type guarded struct {
+	breaker *CircuitBreaker
+	inner   interface{}
+}
+
+// Version returns the version reported by the wrapped client
+func (g guarded) Version() string {
+	if versioned, ok := g.inner.(interface{ Version() string }); ok {
+		return versioned.Version()
+	}
+	return ""
+}
+
+// breakerTriggerClient is a TriggerClient guarded by a circuit breaker
+type breakerTriggerClient struct {
+	TriggerClient
+	guarded
+}
+
+func (c *breakerTriggerClient) Detect(ctx context.Context, audioData []byte, wakeWord string) (TriggerResult, error) {
+	var result TriggerResult
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = c.TriggerClient.Detect(ctx, audioData, wakeWord)
+		return err
+	})
+	return result, err
+}
+
+// breakerSttClient is a SttClient guarded by a circuit breaker
+type breakerSttClient struct {
+	SttClient
+	guarded
+}
+
+func (c *breakerSttClient) Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error) {
+	var result Transcription
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = c.SttClient.Transcribe(ctx, audioBuffer, languageCode, alternatives)
+		return err
+	})
+	return result, err
+}
+
+// Stream only starts while the breaker is closed. Streams do not count
+// towards the breaker; turns fall back to Transcribe when they fail.
+func (c *breakerSttClient) Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error) {
+	if c.breaker.State() != BreakerClosed {
+		return nil, fmt.Errorf("%s: %w", c.breaker.name, ErrBreakerOpen)
+	}
+	return c.SttClient.Stream(ctx, languageCode, options)
+}
+
+// breakerTtsClient is a TtsClient guarded by a circuit breaker
+type breakerTtsClient struct {
+	TtsClient
+	guarded
+}
+
+func (c *breakerTtsClient) Synthesize(ctx context.Context, text string, voice VoiceConfig, format string) ([]byte, error) {
+	var audio []byte
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		audio, err = c.TtsClient.Synthesize(ctx, text, voice, format)
+		return err
+	})
+	return audio, err
+}
+
+// breakerLlmClient is a LlmClient guarded by a circuit breaker. A call
+// succeeds once the first chunk arrives within the breaker timeout; the rest
+// of the stream is not limited.
+type breakerLlmClient struct {
+	LlmClient
+	breaker *CircuitBreaker
+}
+
+func (c *breakerLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	// The stream outlives this call, so it is only cancelled on failure
+	streamCtx, cancel := context.WithCancel(ctx)
+	fail := func(err error) (chan LlmChunk, error) {
+		cancel()
+		c.breaker.record(ctx, err)
+		return nil, err
+	}
+
+	stream, err := c.LlmClient.GetResponse(streamCtx, messages, tools, options)
+	if err != nil {
+		return fail(err)
+	}
+
+	var timeout <-chan time.Time
+	if c.breaker.config.Timeout > 0 {
+		timer := time.NewTimer(c.breaker.config.Timeout.Std())
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Wait for the first chunk
+	var first LlmChunk
+	select {
+	case <-ctx.Done():
+		return fail(ctx.Err())
+	case <-timeout:
+		return fail(fmt.Errorf("%s: no response within %s", c.breaker.name, c.breaker.config.Timeout.Std()))
+	case chunk, ok := <-stream:
+		if !ok {
+			return fail(errors.New("LLM returned an empty response"))
+		}
+		first = chunk
+	}
+	c.breaker.record(ctx, nil)
+
+	// Pass the first chunk and the rest of the stream on
+	responseChan := make(chan LlmChunk)
+	go func() {
+		defer close(responseChan)
+		defer cancel()
+
+		chunk, ok := first, true
+		for ok {
+			select {
+			case <-streamCtx.Done():
+				return
+			case responseChan <- chunk:
+			}
+			chunk, ok = <-stream
+		}
+	}()
+	return responseChan, nil
+}
+
+// fallbackLlmClient asks each LLM in turn until one answers. Fallback LLMs
+// use their own model.
+type fallbackLlmClient struct {
+	clients []LlmClient
+}
+
+func (c *fallbackLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	var errs []error
+	for i, client := range c.clients {
+		if i > 0 {
+			options.Model = ""
+		}
+		stream, err := client.GetResponse(ctx, messages, tools, options)
+		if err == nil {
+			if i > 0 {
+				log.Printf("Using fallback LLM %d", i)
+			}
+			return stream, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("LLM %d failed: %v", i, err)
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
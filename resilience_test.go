@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// llmClientFunc is a LlmClient answering with a function
+type llmClientFunc func(ctx context.Context, options LlmOptions) (chan LlmChunk, error)
+
+func (f llmClientFunc) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	return f(ctx, options)
+}
+
+// streamAfter returns a stream sending texts after delay
+func streamAfter(ctx context.Context, delay time.Duration, texts ...string) chan LlmChunk {
+	stream := make(chan LlmChunk)
+	go func() {
+		defer close(stream)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		for _, text := range texts {
+			select {
+			case <-ctx.Done():
+				return
+			case stream <- LlmChunk{Text: text}:
+			}
+		}
+	}()
+	return stream
+}
+
+// readStream returns the text of stream
+func readStream(stream chan LlmChunk) string {
+	var text strings.Builder
+	for chunk := range stream {
+		text.WriteString(chunk.Text)
+	}
+	return text.String()
+}
+
+func TestBreakerLlmClient(t *testing.T) {
+	breaker := NewCircuitBreaker("llm", BreakerConfig{Timeout: Duration(50 * time.Millisecond), FailureThreshold: 1, OpenFor: Duration(time.Minute), HalfOpenProbes: 1})
+	delay := time.Duration(0)
+	client := &breakerLlmClient{
+		LlmClient: llmClientFunc(func(ctx context.Context, options LlmOptions) (chan LlmChunk, error) {
+			// The stream may take longer than the timeout once it started
+			return streamAfter(ctx, delay, "Hello", " there", "."), nil
+		}),
+		breaker: breaker,
+	}
+
+	stream, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := readStream(stream); text != "Hello there." {
+		t.Errorf("text = %q", text)
+	}
+
+	// No first chunk within the timeout is a failure
+	delay = 200 * time.Millisecond
+	if _, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{}); err == nil || !strings.Contains(err.Error(), "no response within") {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if _, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{}); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("err = %v, want the breaker open", err)
+	}
+}
+
+func TestBreakerLlmClientEmptyResponse(t *testing.T) {
+	breaker := NewCircuitBreaker("llm", BreakerConfig{FailureThreshold: 1, OpenFor: Duration(time.Minute), HalfOpenProbes: 1})
+	client := &breakerLlmClient{
+		LlmClient: llmClientFunc(func(ctx context.Context, options LlmOptions) (chan LlmChunk, error) {
+			return streamAfter(ctx, 0), nil
+		}),
+		breaker: breaker,
+	}
+	if _, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{}); err == nil || breaker.State() != BreakerOpen {
+		t.Errorf("err %v, state %s, want an empty response to count as a failure", err, breaker.State())
+	}
+}
+
+func TestFallbackLlmClient(t *testing.T) {
+	var models []string
+	answer := func(fail bool) LlmClient {
+		return llmClientFunc(func(ctx context.Context, options LlmOptions) (chan LlmChunk, error) {
+			models = append(models, options.Model)
+			if fail {
+				return nil, errBackend
+			}
+			return streamAfter(ctx, 0, "ok"), nil
+		})
+	}
+
+	client := &fallbackLlmClient{clients: []LlmClient{answer(true), answer(false)}}
+	stream, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{Model: "persona-model"})
+	if err != nil || readStream(stream) != "ok" {
+		t.Fatalf("err = %v, want the fallback's answer", err)
+	}
+	if len(models) != 2 || models[0] != "persona-model" || models[1] != "" {
+		t.Errorf("models = %q, want the fallback to use its own model", models)
+	}
+
+	client = &fallbackLlmClient{clients: []LlmClient{answer(true), answer(true)}}
+	if _, err := client.GetResponse(context.Background(), nil, nil, LlmOptions{}); !errors.Is(err, errBackend) {
+		t.Errorf("err = %v, want every failure", err)
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/samplebuilder"
+)
+
+// Audio formats of the speech track of WebRTC sessions
+const (
+	AudioFormatRtpOpus = "rtp_opus"
+	AudioFormatRtpPCMU = "rtp_pcmu"
+)
+
+const (
+	// rtcGatherTimeout bounds how long an offer waits for ICE candidates
+	rtcGatherTimeout = 5 * time.Second
+
+	// rtcMicMaxLate is how many packets the jitter buffer of the microphone
+	// track waits for a missing one
+	rtcMicMaxLate = 5
+
+	// rtcSpeechQueue is how many 20ms samples of speech can be queued on the
+	// speech track, a bit over five minutes
+	rtcSpeechQueue = 16384
+
+	// pcmuSampleRate is the clock rate of G.711 audio
+	pcmuSampleRate = 8000
+)
+
+// RtcServers is the body of GET /rtc, the ICE servers browsers should use
+type RtcServers struct {
+	IceServers []webrtc.ICEServer `json:"ice_servers"`
+}
+
+// CloseNotice tells a WebRTC client the server closed the session, in place
+// of a WebSocket close frame
+type CloseNotice struct {
+	Type   string `json:"type"`
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// newRtcAPI creates the WebRTC stack for config, offering Opus and PCMU audio
+func newRtcAPI(config RtcConfig) (*webrtc.API, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	audioCodecs := []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: opusClockRate, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+			PayloadType:        111,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: pcmuSampleRate},
+			PayloadType:        0,
+		},
+	}
+	for _, codec := range audioCodecs {
+		if err := mediaEngine.RegisterCodec(codec, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, err
+		}
+	}
+
+	// RTCP reports and NACKs
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, registry); err != nil {
+		return nil, err
+	}
+
+	settings := webrtc.SettingEngine{}
+	if config.PortMin != 0 || config.PortMax != 0 {
+		if err := settings.SetEphemeralUDPPortRange(uint16(config.PortMin), uint16(config.PortMax)); err != nil {
+			return nil, err
+		}
+	}
+	if ips := splitAddresses(config.PublicIPs); len(ips) > 0 {
+		settings.SetNAT1To1IPs(ips, webrtc.ICECandidateTypeHost)
+	}
+
+	return webrtc.NewAPI(
+		webrtc.WithMediaEngine(mediaEngine),
+		webrtc.WithInterceptorRegistry(registry),
+		webrtc.WithSettingEngine(settings),
+	), nil
+}
+
+// iceServers returns the ICE servers of config
+func iceServers(config RtcConfig) []webrtc.ICEServer {
+	servers := []webrtc.ICEServer{}
+	if urls := splitAddresses(config.IceServers); len(urls) > 0 {
+		servers = append(servers, webrtc.ICEServer{URLs: urls})
+	}
+	return servers
+}
+
+// rtcRoutes registers the WebRTC signaling endpoint
+func (app *App) rtcRoutes(r *mux.Router) {
+	r.HandleFunc("/rtc", app.handleRtcServers).Methods(http.MethodGet)
+	r.HandleFunc("/rtc", app.handleRtcOffer).Methods(http.MethodPost)
+}
+
+// handleRtcServers returns the ICE servers browsers should use
+func (app *App) handleRtcServers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, RtcServers{IceServers: iceServers(app.currentConfig().Rtc)})
+}
+
+// handleRtcOffer answers the SDP offer of a browser. Candidates are gathered
+// before answering, so no trickle ICE is needed. The session starts once the
+// control data channel opens.
+func (app *App) handleRtcOffer(w http.ResponseWriter, r *http.Request) {
+	// Refuse new sessions once the server is draining
+	if app.isDraining() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	var offer webrtc.SessionDescription
+	if err := json.NewDecoder(r.Body).Decode(&offer); err != nil || offer.Type != webrtc.SDPTypeOffer {
+		http.Error(w, "Expected an SDP offer", http.StatusBadRequest)
+		return
+	}
+
+	answer, err := app.acceptRtcOffer(r, offer)
+	if err != nil {
+		log.Printf("Error answering WebRTC offer: %v\n", err)
+		http.Error(w, "Failed to answer offer", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+// acceptRtcOffer creates the peer connection and session of an offer and
+// returns the answer
+func (app *App) acceptRtcOffer(r *http.Request, offer webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	config := app.currentConfig()
+	pc, err := app.rtcAPI.NewPeerConnection(webrtc.Configuration{ICEServers: iceServers(config.Rtc)})
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newRtcTransport(pc, r.RemoteAddr, config.Audio)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	// The session gets its speech on the track and sends Opus from the
+	// microphone track, so the WebSocket pipeline runs unchanged
+	cs := NewClientState(transport, app, userFromRequest(r))
+	cs.audio = AudioInfo{Format: transport.format, SampleRate: transport.sampleRate, Channels: 1}
+	cs.mic.Format = MicFormatOpus
+	transport.onPlayback = cs.echo.acknowledge
+	if cs.micDecoder, err = newMicDecoder(); err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("failed to create Opus decoder: %w", err)
+	}
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		if !strings.EqualFold(track.Codec().MimeType, webrtc.MimeTypeOpus) {
+			log.Printf("Warning: Ignoring %s track of session %s\n", track.Codec().MimeType, cs.sessionID)
+			return
+		}
+		go transport.readMic(track)
+	})
+	pc.OnDataChannel(func(channel *webrtc.DataChannel) {
+		channel.OnOpen(func() {
+			if !transport.open(channel) {
+				return
+			}
+			app.addClient(cs)
+			go cs.handleClient()
+		})
+		channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+			if msg.IsString {
+				transport.deliver(websocket.TextMessage, msg.Data)
+			}
+		})
+		channel.OnClose(func() { transport.Close() })
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		logf(LogDebug, "Session %s peer connection %s", cs.sessionID, state)
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			transport.Close()
+		}
+	})
+
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	select {
+	case <-gathered:
+	case <-time.After(rtcGatherTimeout):
+		log.Println("Warning: ICE gathering timed out, answering with the candidates found")
+	case <-r.Context().Done():
+		transport.Close()
+		return nil, r.Context().Err()
+	}
+	go transport.paceSpeech()
+
+	return pc.LocalDescription(), nil
+}
+
+// rtcMessage is a message received over a peer connection
+type rtcMessage struct {
+	messageType int
+	data        []byte
+}
+
+// rtcAddr is the address a peer connection was signaled from
+type rtcAddr string
+
+func (a rtcAddr) Network() string { return "webrtc" }
+func (a rtcAddr) String() string  { return string(a) }
+
+// rtcTransport carries a session over a peer connection: text messages over
+// the control data channel, microphone audio over an incoming Opus track and
+// speech over an outgoing track
+type rtcTransport struct {
+	pc         *webrtc.PeerConnection
+	channel    *webrtc.DataChannel // Set once the control channel opens
+	track      *webrtc.TrackLocalStaticSample
+	format     string
+	sampleRate int
+	audio      AudioConfig
+	silence    media.Sample
+	speech     chan media.Sample
+	incoming   chan rtcMessage
+	remoteAddr rtcAddr
+	played     time.Duration     // Speech written to the track or flushed, guarded by mutex
+	onPlayback func(PlaybackAck) // Reports the playback position, set before pacing starts
+	mutex      sync.Mutex
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// newRtcTransport adds the speech track to pc. Speech is sent as Opus when
+// the server can encode any audio to Opus, as PCMU otherwise.
+func newRtcTransport(pc *webrtc.PeerConnection, remoteAddr string, audio AudioConfig) (*rtcTransport, error) {
+	t := &rtcTransport{
+		pc:         pc,
+		audio:      audio,
+		speech:     make(chan media.Sample, rtcSpeechQueue),
+		incoming:   make(chan rtcMessage, 64),
+		remoteAddr: rtcAddr(remoteAddr),
+		done:       make(chan struct{}),
+	}
+
+	codec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: pcmuSampleRate}
+	t.format, t.sampleRate = AudioFormatRtpPCMU, pcmuSampleRate
+	t.silence = media.Sample{Data: make([]byte, pcmuSampleRate/50), Duration: 20 * time.Millisecond}
+	for i := range t.silence.Data {
+		t.silence.Data[i] = mulaw(0)
+	}
+	if audio.Transcode && newOpusEncoder != nil {
+		codec = webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: opusClockRate, Channels: 2}
+		t.format, t.sampleRate = AudioFormatRtpOpus, opusClockRate
+		t.silence = media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond}
+	}
+
+	track, err := webrtc.NewTrackLocalStaticSample(codec, "speech", "assistant")
+	if err != nil {
+		return nil, err
+	}
+	sender, err := pc.AddTrack(track)
+	if err != nil {
+		return nil, err
+	}
+	t.track = track
+
+	// Read RTCP so the interceptors see the receiver reports
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return t, nil
+}
+
+// open makes channel the control channel and reports whether it is the first
+func (t *rtcTransport) open(channel *webrtc.DataChannel) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.channel != nil {
+		log.Printf("Warning: Ignoring extra data channel %q\n", channel.Label())
+		return false
+	}
+	t.channel = channel
+	return true
+}
+
+// deliver queues a received message for ReadMessage and reports whether the
+// transport is still open
+func (t *rtcTransport) deliver(messageType int, data []byte) bool {
+	if t.closed() {
+		return false
+	}
+	select {
+	case t.incoming <- rtcMessage{messageType: messageType, data: data}:
+		return true
+	case <-t.done:
+		return false
+	}
+}
+
+// readMic passes the Opus packets of the microphone track to the session in
+// order, as binary messages
+func (t *rtcTransport) readMic(track *webrtc.TrackRemote) {
+	builder := samplebuilder.New(rtcMicMaxLate, &codecs.OpusPacket{}, track.Codec().ClockRate)
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		builder.Push(packet)
+		for sample := builder.Pop(); sample != nil; sample = builder.Pop() {
+			if !t.deliver(websocket.BinaryMessage, sample.Data) {
+				return
+			}
+		}
+	}
+}
+
+// paceSpeech writes queued speech to the track in real time, and silence
+// when there is none, so the browser plays a continuous stream. The browser
+// plays the track as it arrives, so the speech written is the playback
+// position, which the page would otherwise acknowledge.
+func (t *rtcTransport) paceSpeech() {
+	next := time.Now()
+	for {
+		sample, speaking := t.silence, false
+		select {
+		case sample = <-t.speech:
+			speaking = true
+		default:
+		}
+		if err := t.track.WriteSample(sample); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			logf(LogDebug, "Speech track write error: %v", err)
+		}
+		t.mutex.Lock()
+		if speaking {
+			t.played += sample.Duration
+		}
+		played := t.played
+		t.mutex.Unlock()
+		if t.onPlayback != nil {
+			t.onPlayback(PlaybackAck{Event: PlaybackPosition, PositionMs: int(played / time.Millisecond), Playing: speaking})
+		}
+
+		// Start over after a stall instead of bursting to catch up
+		next = next.Add(sample.Duration)
+		if wait := time.Until(next); wait < -100*time.Millisecond {
+			next = time.Now()
+		}
+		select {
+		case <-t.done:
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// writeSpeech queues synthesized WAV or Ogg Opus audio on the speech track
+func (t *rtcTransport) writeSpeech(audio []byte) error {
+	samples, err := t.speechSamples(audio)
+	if err != nil {
+		return err
+	}
+	if t.closed() {
+		return io.ErrClosedPipe
+	}
+	for _, sample := range samples {
+		select {
+		case t.speech <- sample:
+		case <-t.done:
+			return io.ErrClosedPipe
+		default:
+			return errors.New("speech queue full")
+		}
+	}
+	return nil
+}
+
+// flushSpeech drops the speech queued on the speech track. The position
+// keeps counting the dropped speech, as the echo reference does.
+func (t *rtcTransport) flushSpeech() {
+	for {
+		select {
+		case sample := <-t.speech:
+			t.mutex.Lock()
+			t.played += sample.Duration
+			t.mutex.Unlock()
+		default:
+			return
+		}
+	}
+}
+
+// speechSamples splits audio into the samples of the speech track codec
+func (t *rtcTransport) speechSamples(audio []byte) ([]media.Sample, error) {
+	var samples []media.Sample
+	if isOgg(audio) {
+		if t.format != AudioFormatRtpOpus {
+			return nil, errors.New("Opus audio cannot be sent as " + t.format)
+		}
+		_, packets, err := oggOpusPackets(audio)
+		if err != nil {
+			return nil, err
+		}
+		for _, packet := range packets {
+			duration := time.Duration(opusPacketDuration(packet)) * time.Second / opusClockRate
+			samples = append(samples, media.Sample{Data: packet, Duration: duration})
+		}
+		return samples, nil
+	}
+
+	pcm, sampleRate, err := wavPCM(audio)
+	if err != nil {
+		return nil, err
+	}
+	if t.format == AudioFormatRtpOpus {
+		packets, err := encodeOpus(pcm, sampleRate, t.audio.OpusBitrate)
+		if err != nil {
+			return nil, err
+		}
+		for _, packet := range packets {
+			samples = append(samples, media.Sample{Data: packet, Duration: 20 * time.Millisecond})
+		}
+		return samples, nil
+	}
+
+	// G.711 in 20ms samples
+	encoded := encodePCMU(resamplePCM(pcm, sampleRate, pcmuSampleRate))
+	frameSize := pcmuSampleRate / 50
+	for start := 0; start < len(encoded); start += frameSize {
+		data := encoded[start:min(start+frameSize, len(encoded))]
+		duration := time.Duration(len(data)) * time.Second / pcmuSampleRate
+		samples = append(samples, media.Sample{Data: data, Duration: duration})
+	}
+	return samples, nil
+}
+
+// ReadMessage returns the next message from the data channel or the
+// microphone track
+func (t *rtcTransport) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-t.incoming:
+		return msg.messageType, msg.data, nil
+	case <-t.done:
+		return 0, nil, io.EOF
+	}
+}
+
+// WriteMessage sends a text message over the data channel. Binary messages
+// are not supported; speech goes out on the track.
+func (t *rtcTransport) WriteMessage(messageType int, data []byte) error {
+	if messageType != websocket.TextMessage {
+		return errors.New("only text messages are sent over the data channel")
+	}
+	t.mutex.Lock()
+	channel := t.channel
+	t.mutex.Unlock()
+	if channel == nil {
+		return errors.New("data channel not open")
+	}
+	return channel.SendText(string(data))
+}
+
+// WriteControl sends a close frame as a close notice over the data channel
+// and closes the connection. Other control messages are ignored.
+func (t *rtcTransport) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != websocket.CloseMessage {
+		return nil
+	}
+	notice := CloseNotice{Type: "close", Code: websocket.CloseNormalClosure}
+	if len(data) >= 2 {
+		notice.Code = int(binary.BigEndian.Uint16(data))
+		notice.Reason = string(data[2:])
+	}
+	message, _ := json.Marshal(notice)
+	err := t.WriteMessage(websocket.TextMessage, message)
+	t.Close()
+	return err
+}
+
+// RemoteAddr returns the address the connection was signaled from
+func (t *rtcTransport) RemoteAddr() net.Addr {
+	return t.remoteAddr
+}
+
+// closed reports whether the transport was closed
+func (t *rtcTransport) closed() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the peer connection
+func (t *rtcTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		// Closing waits for the callbacks of the connection, which may be
+		// the caller
+		go t.pc.Close()
+	})
+	return nil
+}
+
+// encodePCMU encodes samples with G.711 μ-law
+func encodePCMU(samples []int16) []byte {
+	encoded := make([]byte, len(samples))
+	for i, sample := range samples {
+		encoded[i] = mulaw(sample)
+	}
+	return encoded
+}
+
+// mulaw returns the G.711 μ-law code of a sample
+func mulaw(sample int16) byte {
+	const bias, clip = 0x84, 32635
+	value, sign := int(sample), 0
+	if value < 0 {
+		value, sign = -value, 0x80
+	}
+	value = min(value, clip) + bias
+
+	exponent := 7
+	for mask := 0x4000; value&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (value >> (exponent + 3)) & 0x0f
+	return ^byte(sign | exponent<<4 | mantissa)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+// testRtcTransport returns a transport on a peer connection that is never
+// connected
+func testRtcTransport(t *testing.T, audio AudioConfig) *rtcTransport {
+	t.Helper()
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, err := newRtcTransport(pc, "192.0.2.1:4000", audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+// unmulaw decodes a G.711 μ-law code
+func unmulaw(code byte) int {
+	code = ^code
+	magnitude := ((int(code&0x0f) << 3) + 0x84) << (code >> 4 & 0x07)
+	if code&0x80 != 0 {
+		return 0x84 - magnitude
+	}
+	return magnitude - 0x84
+}
+
+func TestMulaw(t *testing.T) {
+	tests := map[int16]byte{0: 0xff, -1: 0x7f, 32767: 0x80, -32768: 0x00}
+	for sample, want := range tests {
+		if got := mulaw(sample); got != want {
+			t.Errorf("mulaw(%d) = %#x, want %#x", sample, got, want)
+		}
+	}
+
+	// The logarithmic steps keep the error within a few percent
+	for sample := -32768; sample <= 32767; sample += 97 {
+		decoded := unmulaw(mulaw(int16(sample)))
+		if diff := math.Abs(float64(decoded - sample)); diff > 8 && diff > 0.04*math.Abs(float64(sample)) {
+			t.Fatalf("sample %d decodes to %d", sample, decoded)
+		}
+	}
+}
+
+func TestSpeechSamplesPCMU(t *testing.T) {
+	transport := testRtcTransport(t, AudioConfig{})
+	if transport.format != AudioFormatRtpPCMU || transport.sampleRate != pcmuSampleRate {
+		t.Fatalf("format = %s at %d, want PCMU without an encoder", transport.format, transport.sampleRate)
+	}
+
+	// 16 kHz speech is resampled into 20ms samples at 8 kHz
+	samples, err := transport.speechSamples(wrapWav(sineWave(440, 0.5, 50*time.Millisecond), inputSampleRate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || len(samples[0].Data) != 160 || samples[2].Duration != 10*time.Millisecond {
+		t.Errorf("%d samples, first %d bytes, last %s", len(samples), len(samples[0].Data), samples[len(samples)-1].Duration)
+	}
+	if _, err := transport.speechSamples(testOggOpus(2)); err == nil {
+		t.Error("Opus sent on a PCMU track")
+	}
+}
+
+func TestSpeechSamplesOpus(t *testing.T) {
+	transport := &rtcTransport{format: AudioFormatRtpOpus}
+	samples, err := transport.speechSamples(testOggOpus(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || samples[0].Duration != 20*time.Millisecond || len(samples[0].Data) != 40 {
+		t.Errorf("samples = %+v, want the Ogg packets", samples)
+	}
+}
+
+func TestRtcTransportSpeechQueue(t *testing.T) {
+	transport := testRtcTransport(t, AudioConfig{})
+	wav := wrapWav(make([]byte, pcmBytes(100*time.Millisecond)), inputSampleRate)
+	if err := transport.writeSpeech(wav); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.speech) != 5 {
+		t.Errorf("queued %d samples, want 5", len(transport.speech))
+	}
+	transport.flushSpeech()
+	if len(transport.speech) != 0 {
+		t.Errorf("%d samples left after flush", len(transport.speech))
+	}
+
+	transport.Close()
+	if err := transport.writeSpeech(wav); err == nil {
+		t.Error("speech queued after close")
+	}
+}
+
+func TestRtcTransportMessages(t *testing.T) {
+	transport := testRtcTransport(t, AudioConfig{})
+	if !transport.deliver(websocket.BinaryMessage, []byte{1, 2}) {
+		t.Fatal("deliver to an open transport failed")
+	}
+	if messageType, data, err := transport.ReadMessage(); err != nil || messageType != websocket.BinaryMessage || !bytes.Equal(data, []byte{1, 2}) {
+		t.Errorf("ReadMessage = %d %v %v", messageType, data, err)
+	}
+	if err := transport.WriteMessage(websocket.BinaryMessage, []byte{1}); err == nil {
+		t.Error("binary message sent over the data channel")
+	}
+	if err := transport.WriteMessage(websocket.TextMessage, []byte("{}")); err == nil {
+		t.Error("text message sent before the data channel opened")
+	}
+	if transport.RemoteAddr().String() != "192.0.2.1:4000" || transport.RemoteAddr().Network() != "webrtc" {
+		t.Errorf("remote address = %v", transport.RemoteAddr())
+	}
+
+	// Close frames end the session
+	transport.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye"), time.Now())
+	if _, _, err := transport.ReadMessage(); err == nil {
+		t.Error("ReadMessage after close succeeded")
+	}
+	if transport.deliver(websocket.TextMessage, []byte("{}")) {
+		t.Error("deliver after close succeeded")
+	}
+}
+
+func TestRtcSignaling(t *testing.T) {
+	withStubOpusDecoder(t)
+	config := DefaultConfig()
+	config.Rtc.Enabled = true
+	app := newApp(config)
+	var err error
+	if app.rtcAPI, err = newRtcAPI(config.Rtc); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+
+	status, _, body := request(t, http.MethodGet, server.URL+"/rtc")
+	var servers RtcServers
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &servers) != nil || servers.IceServers == nil {
+		t.Errorf("ICE servers: %d %s", status, body)
+	}
+	if servers := iceServers(RtcConfig{IceServers: "stun:a.example.com, stun:b.example.com"}); len(servers) != 1 || len(servers[0].URLs) != 2 {
+		t.Errorf("iceServers = %+v, want one server with both URLs", servers)
+	}
+
+	resp, err := http.Post(server.URL+"/rtc", "application/json", bytes.NewBufferString(`{"type":"answer","sdp":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("answer instead of offer status = %d, want 400", resp.StatusCode)
+	}
+
+	// A browser-like offer with a microphone track and a control channel
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateDataChannel("control", nil); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(client)
+	client.SetLocalDescription(offer)
+	<-gathered
+
+	data, _ := json.Marshal(client.LocalDescription())
+	resp, err = http.Post(server.URL+"/rtc", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var answer webrtc.SessionDescription
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&answer) != nil || answer.Type != webrtc.SDPTypeAnswer {
+		t.Fatalf("offer status = %d, answer %v", resp.StatusCode, answer.Type)
+	}
+	if err := client.SetRemoteDescription(answer); err != nil {
+		t.Errorf("answer rejected: %v", err)
+	}
+}
+
+func TestRtcTransportPlaybackPosition(t *testing.T) {
+	transport := testRtcTransport(t, AudioConfig{})
+	wav := wrapWav(make([]byte, pcmBytes(100*time.Millisecond)), inputSampleRate)
+
+	// Flushed speech counts like played speech, as it stays in the echo reference
+	transport.writeSpeech(wav)
+	transport.flushSpeech()
+	if transport.played != 100*time.Millisecond {
+		t.Errorf("played = %s after flush, want 100ms", transport.played)
+	}
+
+	acks := make(chan PlaybackAck, 100)
+	transport.onPlayback = func(ack PlaybackAck) { acks <- ack }
+	transport.writeSpeech(wav)
+	go transport.paceSpeech()
+	var positions []int
+	for ack := range acks {
+		if !ack.Playing {
+			if ack.PositionMs != 200 {
+				t.Errorf("position after speech = %dms, want 200ms", ack.PositionMs)
+			}
+			break
+		}
+		positions = append(positions, ack.PositionMs)
+	}
+	transport.Close()
+	if want := []int{120, 140, 160, 180, 200}; !slices.Equal(positions, want) {
+		t.Errorf("positions = %v, want %v", positions, want)
+	}
+}
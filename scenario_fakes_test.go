@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scenarioClock tracks how much scenario audio has been played, so the fake
+// backends can act at fixed points of the audio regardless of playback speed
+type scenarioClock struct {
+	mutex sync.Mutex
+	bytes int
+	speed float64
+}
+
+// advance adds played audio and returns the positions before and after it
+func (c *scenarioClock) advance(n int) (time.Duration, time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	before := pcmDuration(c.bytes)
+	c.bytes += n
+	return before, pcmDuration(c.bytes)
+}
+
+// position returns how much audio has been played
+func (c *scenarioClock) position() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return pcmDuration(c.bytes)
+}
+
+// sleep waits d scaled by the playback speed
+func (c *scenarioClock) sleep(ctx context.Context, d Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(time.Duration(float64(d) / c.speed))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pcmDuration returns the duration of n bytes of microphone audio
+func pcmDuration(n int) time.Duration {
+	return time.Duration(n) * time.Second / (inputSampleRate * 2)
+}
+
+// fakeVadClient emits the scripted speech segments as the audio plays
+type fakeVadClient struct {
+	clock     *scenarioClock
+	segments  []ScenarioSegment
+	eventChan chan VadEvent
+	closeOnce sync.Once
+}
+
+func newFakeVadClient(clock *scenarioClock, segments []ScenarioSegment) *fakeVadClient {
+	return &fakeVadClient{
+		clock:     clock,
+		segments:  segments,
+		eventChan: make(chan VadEvent, 100),
+	}
+}
+
+// ProcessAudio advances the clock and emits the segment edges it passed
+func (c *fakeVadClient) ProcessAudio(audioData []byte) error {
+	before, after := c.clock.advance(len(audioData))
+	for _, segment := range c.segments {
+		if before <= segment.Start.Std() && segment.Start.Std() < after {
+			c.eventChan <- VadEvent{Type: "start", Message: fmt.Sprintf("scripted at %s", segment.Start.Std())}
+		}
+		if before <= segment.End.Std() && segment.End.Std() < after {
+			c.eventChan <- VadEvent{Type: "end", Message: fmt.Sprintf("scripted at %s", segment.End.Std())}
+		}
+	}
+	return nil
+}
+
+// IsActive reports whether the clock is inside a speech segment
+func (c *fakeVadClient) IsActive(audioData []byte) bool {
+	position := c.clock.position()
+	for _, segment := range c.segments {
+		if position >= segment.Start.Std() && position < segment.End.Std() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fakeVadClient) ResetVAD() error                  { return nil }
+func (c *fakeVadClient) GetEventChannel() <-chan VadEvent { return c.eventChan }
+
+func (c *fakeVadClient) Close() error {
+	c.closeOnce.Do(func() { close(c.eventChan) })
+	return nil
+}
+
+// fakeTriggerClient reports each scripted detection once the audio reached it
+type fakeTriggerClient struct {
+	clock      *scenarioClock
+	detections []ScenarioDetection
+	fired      []bool
+	mutex      sync.Mutex
+}
+
+func newFakeTriggerClient(clock *scenarioClock, detections []ScenarioDetection) *fakeTriggerClient {
+	return &fakeTriggerClient{clock: clock, detections: detections, fired: make([]bool, len(detections))}
+}
+
+func (c *fakeTriggerClient) Detect(ctx context.Context, audioData []byte, wakeWord string) (TriggerResult, error) {
+	position := c.clock.position()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, detection := range c.detections {
+		if c.fired[i] || position < detection.At.Std() {
+			continue
+		}
+		if detection.WakeWord != "" && wakeWord != "" && !strings.EqualFold(detection.WakeWord, wakeWord) {
+			continue
+		}
+		c.fired[i] = true
+		return TriggerResult{Triggered: true, Confidence: detection.Confidence, WakeWord: detection.WakeWord}, nil
+	}
+	return TriggerResult{}, nil
+}
+
+func (c *fakeTriggerClient) Close() error { return nil }
+
+// fakeSttClient returns the scripted transcriptions in order
+type fakeSttClient struct {
+	clock   *scenarioClock
+	results []ScenarioTranscription
+	next    int
+	mutex   sync.Mutex
+}
+
+func (c *fakeSttClient) Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error) {
+	c.mutex.Lock()
+	if c.next >= len(c.results) {
+		c.mutex.Unlock()
+		return Transcription{}, fmt.Errorf("no scripted STT result left")
+	}
+	result := c.results[c.next]
+	c.next++
+	c.mutex.Unlock()
+
+	if err := c.clock.sleep(ctx, result.Delay); err != nil {
+		return Transcription{}, err
+	}
+	if result.Error != "" {
+		return Transcription{}, fmt.Errorf("%s", result.Error)
+	}
+	transcription := Transcription{Text: result.Text, LanguageCode: result.Language, Confidence: result.Confidence}
+	for _, alternative := range result.Alternatives {
+		if len(transcription.Alternatives) < alternatives {
+			transcription.Alternatives = append(transcription.Alternatives, Alternative(alternative))
+		}
+	}
+	return transcription, nil
+}
+
+// Stream is not scripted, so turns use Transcribe
+func (c *fakeSttClient) Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error) {
+	return nil, errors.New("STT streams are not scripted")
+}
+
+func (c *fakeSttClient) Close() error { return nil }
+
+// fakeLlmClient streams the scripted responses in order
+type fakeLlmClient struct {
+	clock     *scenarioClock
+	responses []ScenarioResponse
+	next      int
+	mutex     sync.Mutex
+}
+
+func (c *fakeLlmClient) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	c.mutex.Lock()
+	if c.next >= len(c.responses) {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("no scripted LLM response left")
+	}
+	response := c.responses[c.next]
+	c.next++
+	c.mutex.Unlock()
+
+	if response.Error != "" {
+		return nil, fmt.Errorf("%s", response.Error)
+	}
+
+	responseChan := make(chan LlmChunk)
+	go func() {
+		defer close(responseChan)
+		for _, text := range response.Chunks {
+			if c.clock.sleep(ctx, response.Delay) != nil {
+				return
+			}
+			select {
+			case responseChan <- LlmChunk{Text: text}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if len(response.ToolCalls) > 0 {
+			var calls []ToolCall
+			for i, call := range response.ToolCalls {
+				calls = append(calls, ToolCall{
+					ID:       fmt.Sprintf("call_%d", i),
+					Type:     "function",
+					Function: ToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+				})
+			}
+			select {
+			case responseChan <- LlmChunk{ToolCalls: calls}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return responseChan, nil
+}
+
+// fakeTtsClient returns silence whose length follows the text, so audio
+// messages can be told apart by their size
+type fakeTtsClient struct {
+	clock  *scenarioClock
+	script ScenarioTts
+}
+
+func (c *fakeTtsClient) Synthesize(ctx context.Context, text string, voice VoiceConfig, format string) ([]byte, error) {
+	if err := c.clock.sleep(ctx, c.script.Delay); err != nil {
+		return nil, err
+	}
+	if c.script.Error != "" {
+		return nil, fmt.Errorf("%s", c.script.Error)
+	}
+	if format != AudioFormatWav {
+		return nil, fmt.Errorf("fake TTS cannot synthesize %s", format)
+	}
+	return wrapWav(make([]byte, len(text)*2), ttsSampleRate), nil
+}
+
+func (c *fakeTtsClient) Close() error { return nil }
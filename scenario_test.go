@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario scripts an offline run of the pipeline: the audio a client plays,
+// what each fake backend answers and the messages the client must receive
+type Scenario struct {
+	Name     string    `yaml:"name"`
+	Audio    string    `yaml:"audio"`    // 16 kHz 16-bit mono WAV, relative to the scenario file
+	Duration Duration  `yaml:"duration"` // Silence to play when no audio file is given
+	Speed    float64   `yaml:"speed"`    // Playback speed, 1 is real time
+	Timeout  Duration  `yaml:"timeout"`  // Time allowed for the messages after the audio ended
+	User     string    `yaml:"user"`
+	Config   yaml.Node `yaml:"config"` // Overrides of the default configuration
+
+	Vad     []ScenarioSegment       `yaml:"vad"`
+	Trigger []ScenarioDetection     `yaml:"trigger"`
+	Stt     []ScenarioTranscription `yaml:"stt"`
+	Llm     []ScenarioResponse      `yaml:"llm"`
+	Tts     ScenarioTts             `yaml:"tts"`
+	Send    []ScenarioCommand       `yaml:"send"`
+
+	Expect []map[string]interface{} `yaml:"expect"`
+}
+
+// ScenarioSegment is a stretch of speech reported by the fake VAD
+type ScenarioSegment struct {
+	Start Duration `yaml:"start"`
+	End   Duration `yaml:"end"`
+}
+
+// ScenarioDetection is a wake word the fake trigger reports from a point of the audio
+type ScenarioDetection struct {
+	At         Duration `yaml:"at"`
+	WakeWord   string   `yaml:"wake_word"`
+	Confidence float64  `yaml:"confidence"`
+}
+
+// ScenarioTranscription is the answer of the fake STT to one request
+type ScenarioTranscription struct {
+	Text         string                `yaml:"text"`
+	Language     string                `yaml:"language"`
+	Confidence   float64               `yaml:"confidence"`
+	Alternatives []ScenarioAlternative `yaml:"alternatives"`
+	Delay        Duration              `yaml:"delay"`
+	Error        string                `yaml:"error"`
+}
+
+// ScenarioAlternative is an alternative transcript of the fake STT
+type ScenarioAlternative struct {
+	Text       string  `yaml:"text"`
+	Confidence float64 `yaml:"confidence"`
+}
+
+// ScenarioResponse is the answer of the fake LLM to one request
+type ScenarioResponse struct {
+	Chunks    []string           `yaml:"chunks"`
+	ToolCalls []ScenarioToolCall `yaml:"tool_calls"`
+	Delay     Duration           `yaml:"delay"` // Before each chunk
+	Error     string             `yaml:"error"`
+}
+
+// ScenarioToolCall is a tool call made by the fake LLM
+type ScenarioToolCall struct {
+	Name      string `yaml:"name"`
+	Arguments string `yaml:"arguments"`
+}
+
+// ScenarioTts configures the fake TTS
+type ScenarioTts struct {
+	Delay Duration `yaml:"delay"`
+	Error string   `yaml:"error"`
+}
+
+// ScenarioCommand is a text message the client sends at a point of the audio
+type ScenarioCommand struct {
+	At      Duration               `yaml:"at"`
+	Message map[string]interface{} `yaml:"message"`
+}
+
+// loadScenario reads a scenario file
+func loadScenario(path string) (Scenario, error) {
+	var scenario Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scenario, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&scenario); err != nil {
+		return scenario, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+
+	if scenario.Name == "" {
+		scenario.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if scenario.Speed <= 0 {
+		scenario.Speed = 1
+	}
+	if scenario.Timeout <= 0 {
+		scenario.Timeout = Duration(10 * time.Second)
+	}
+	if scenario.Audio != "" && !filepath.IsAbs(scenario.Audio) {
+		scenario.Audio = filepath.Join(filepath.Dir(path), scenario.Audio)
+	}
+	if scenario.Audio == "" && scenario.Duration <= 0 {
+		return scenario, fmt.Errorf("scenario %s: audio or duration is required", path)
+	}
+	return scenario, nil
+}
+
+// scenarioResult is the outcome of a scenario run
+type scenarioResult struct {
+	Name     string
+	Received []map[string]interface{}
+	Problems []string
+}
+
+// Passed reports whether the received messages matched the expectation
+func (r scenarioResult) Passed() bool {
+	return len(r.Problems) == 0
+}
+
+// runScenario plays the scenario through a ClientState over an in-memory
+// WebSocket with fake backends and compares the messages it receives
+func runScenario(scenario Scenario) (scenarioResult, error) {
+	result := scenarioResult{Name: scenario.Name}
+
+	// Configuration
+	config := DefaultConfig()
+	if !scenario.Config.IsZero() {
+		if err := scenario.Config.Decode(&config); err != nil {
+			return result, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if problems := config.validate(); len(problems) > 0 {
+		return result, &ConfigError{Problems: problems}
+	}
+
+	// Audio
+	audio := make([]byte, pcmBytes(scenario.Duration.Std()))
+	if scenario.Audio != "" {
+		data, err := os.ReadFile(scenario.Audio)
+		if err != nil {
+			return result, err
+		}
+		pcm, sampleRate, err := wavPCM(data)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", scenario.Audio, err)
+		}
+		if sampleRate != inputSampleRate {
+			return result, fmt.Errorf("%s: sample rate must be %d Hz", scenario.Audio, inputSampleRate)
+		}
+		audio = pcm
+	}
+
+	// Application with fake backends
+	clock := &scenarioClock{speed: scenario.Speed}
+	app := newApp(config)
+	app.vadClient = newFakeVadClient(clock, scenario.Vad)
+	app.triggerClient = newFakeTriggerClient(clock, scenario.Trigger)
+	app.sttClient = &fakeSttClient{clock: clock, results: scenario.Stt}
+	app.llmClient = &fakeLlmClient{clock: clock, responses: scenario.Llm}
+	app.ttsClient = &fakeTtsClient{clock: clock, script: scenario.Tts}
+	defer app.Close()
+
+	// In-memory WebSocket
+	listener := newPipeListener()
+	server := &http.Server{Handler: app.Routes()}
+	go server.Serve(listener)
+	defer server.Close()
+
+	dialer := websocket.Dialer{NetDialContext: listener.DialContext}
+	target := "ws://scenario/ws"
+	if scenario.User != "" {
+		target += "?user=" + scenario.User
+	}
+	conn, _, err := dialer.Dial(target, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	// Collect what the client receives
+	var received []map[string]interface{}
+	var receivedMutex sync.Mutex
+	arrived := make(chan struct{}, 1)
+	go func() {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			message := map[string]interface{}{"type": "audio", "bytes": len(data)}
+			if messageType == websocket.TextMessage {
+				message = map[string]interface{}{}
+				if err := json.Unmarshal(data, &message); err != nil {
+					message = map[string]interface{}{"type": "invalid", "text": string(data)}
+				}
+			}
+			receivedMutex.Lock()
+			received = append(received, message)
+			receivedMutex.Unlock()
+			select {
+			case arrived <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	// Play the audio in VAD sized chunks, sending the scripted commands on the way
+	commands := append([]ScenarioCommand(nil), scenario.Send...)
+	sort.SliceStable(commands, func(i, j int) bool { return commands[i].At < commands[j].At })
+	chunkSize := config.Vad.ChunkSizeBytes
+	chunkDuration := Duration(pcmDuration(chunkSize))
+	for offset := 0; offset < len(audio); offset += chunkSize {
+		for len(commands) > 0 && commands[0].At.Std() <= pcmDuration(offset) {
+			if err := conn.WriteJSON(commands[0].Message); err != nil {
+				return result, fmt.Errorf("failed to send command: %w", err)
+			}
+			commands = commands[1:]
+		}
+
+		end := offset + chunkSize
+		if end > len(audio) {
+			end = len(audio)
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, audio[offset:end]); err != nil {
+			return result, fmt.Errorf("failed to send audio: %w", err)
+		}
+		clock.sleep(context.Background(), chunkDuration)
+	}
+	for _, command := range commands {
+		if err := conn.WriteJSON(command.Message); err != nil {
+			return result, fmt.Errorf("failed to send command: %w", err)
+		}
+	}
+
+	// Wait for the expected number of messages, then briefly for unexpected extras
+	deadline := time.After(scenario.Timeout.Std())
+	settle := 200 * time.Millisecond
+	for waiting := true; waiting; {
+		receivedMutex.Lock()
+		count := len(received)
+		receivedMutex.Unlock()
+
+		wait := scenario.Timeout.Std()
+		if count >= len(scenario.Expect) {
+			wait = settle
+		}
+		select {
+		case <-arrived:
+		case <-time.After(wait):
+			waiting = count < len(scenario.Expect)
+		case <-deadline:
+			waiting = false
+		}
+	}
+
+	receivedMutex.Lock()
+	result.Received = append(result.Received, received...)
+	receivedMutex.Unlock()
+	result.Problems = compareMessages(scenario.Expect, result.Received)
+	return result, nil
+}
+
+// compareMessages checks received against expected in order. Only the fields
+// present in an expected message are compared.
+func compareMessages(expected, received []map[string]interface{}) []string {
+	var problems []string
+	for i, want := range expected {
+		if i >= len(received) {
+			problems = append(problems, fmt.Sprintf("#%d: expected %s, got nothing", i+1, formatMessage(want)))
+			continue
+		}
+		got := received[i]
+		for key, value := range want {
+			if fmt.Sprint(got[key]) != fmt.Sprint(value) {
+				problems = append(problems, fmt.Sprintf("#%d: expected %s, got %s", i+1, formatMessage(want), formatMessage(got)))
+				break
+			}
+		}
+	}
+	for i := len(expected); i < len(received); i++ {
+		problems = append(problems, fmt.Sprintf("#%d: unexpected %s", i+1, formatMessage(received[i])))
+	}
+	return problems
+}
+
+// formatMessage renders a message compactly for reports
+func formatMessage(message map[string]interface{}) string {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Sprint(message)
+	}
+	return string(data)
+}
+
+// TestScenarios runs every scenario in scenarios/. Run a single one with
+// -run 'TestScenarios/<name>'.
+func TestScenarios(t *testing.T) {
+	defer discardLogs()()
+
+	paths, err := filepath.Glob("scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios found: %v", err)
+	}
+	for _, path := range paths {
+		scenario, err := loadScenario(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		t.Run(scenario.Name, func(t *testing.T) {
+			result, err := runScenario(scenario)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Passed() {
+				return
+			}
+			var report strings.Builder
+			for _, problem := range result.Problems {
+				fmt.Fprintf(&report, "\n    %s", problem)
+			}
+			report.WriteString("\n  received:")
+			for i, message := range result.Received {
+				fmt.Fprintf(&report, "\n    #%d %s", i+1, formatMessage(message))
+			}
+			t.Error(report.String())
+		})
+	}
+}
+
+func TestScenarioAudio(t *testing.T) {
+	// Audio paths are relative to the scenario file
+	dir := t.TempDir()
+	path := filepath.Join(dir, "slow.yaml")
+	if err := os.WriteFile(path, []byte("audio: slow.wav\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scenario, err := loadScenario(path)
+	if err != nil || scenario.Audio != filepath.Join(dir, "slow.wav") {
+		t.Fatalf("audio = %q, %v", scenario.Audio, err)
+	}
+
+	// Recordings at another sample rate are refused
+	if err := os.WriteFile(scenario.Audio, wrapWav(make([]byte, 8000), 8000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runScenario(scenario); err == nil || !strings.Contains(err.Error(), "sample rate") {
+		t.Errorf("error = %v, want the sample rate refused", err)
+	}
+}
+
+// pipeListener is a net.Listener whose connections are in-memory pipes
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// Accept waits for the next DialContext
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// DialContext connects a new pipe to the listener
+func (l *pipeListener) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, errors.New("listener closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+// pipeAddr is the address of a pipeListener
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "scenario" }
+
+// discardLogs silences the standard logger while scenarios run unless debug
+// logging is enabled, and returns a function restoring it
+func discardLogs() func() {
+	if LogLevel(currentLogLevel.Load()) <= LogDebug {
+		return func() {}
+	}
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	return func() { log.SetOutput(previous) }
+}
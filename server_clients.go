@@ -3,24 +3,30 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	pb "assistant-app/grpc_modules"
+	sttpb "assistant-app/grpc_modules/stt"
+	triggerpb "assistant-app/grpc_modules/trigger"
+	ttspb "assistant-app/grpc_modules/tts"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 // Define interfaces for the service clients
 // These make it easier to test and mock the services
 
-// VadClient is the interface for the Voice Activity Detection client
 // VadClient is the interface for the Voice Activity Detection client
 type VadClient interface {
 	IsActive(audioData []byte) bool
@@ -37,32 +43,105 @@ type VadEvent struct {
 }
 
 // TriggerClient is the interface for the Trigger Detection client
+// An empty wakeWord asks the service for its default wake word.
 type TriggerClient interface {
-	IsTriggered(audioData []byte) bool
+	Detect(ctx context.Context, audioData []byte, wakeWord string) (TriggerResult, error)
 	Close() error
 }
 
+// TriggerResult is the result of wake word detection
+type TriggerResult struct {
+	Triggered  bool
+	Confidence float64
+	WakeWord   string // Wake word the service detected, if it reports one
+}
+
 // SttClient is the interface for the Speech-to-Text client
+// An empty languageCode asks the service to detect the language, and
+// alternatives is how many alternative transcripts to ask for.
 type SttClient interface {
-	Transcribe(ctx context.Context, audioBuffer [][]byte) (string, error)
+	Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error)
+	Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error)
 	Close() error
 }
 
+// SttStreamOptions selects the results of a streamed transcription
+type SttStreamOptions struct {
+	Interim      bool // Send interim results while audio arrives
+	Words        bool // Include word timings and confidence
+	Alternatives int  // Alternative transcripts of the final result
+}
+
+// SttStream transcribes an utterance while it is spoken. Send must not be
+// called after Finish.
+type SttStream interface {
+	Send(audioData []byte) error
+	Interim() <-chan Transcription // Interim results, closed when the stream ends
+	Finish(ctx context.Context) (Transcription, error)
+}
+
+// Transcription is the result of transcribing an utterance
+type Transcription struct {
+	Text         string
+	LanguageCode string  // Language detected by the STT service, if any
+	Confidence   float64 // Confidence of the service, 0 when it reports none
+	Words        []WordInfo
+	Alternatives []Alternative // Less likely transcripts, most likely first
+}
+
+// Alternative is a less likely transcript of an utterance
+type Alternative struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// WordInfo is the timing and confidence of a transcribed word. Times are
+// seconds from the start of the utterance.
+type WordInfo struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
 // LlmClient is the interface for the Language Model client
+// Tools may be nil; clients of providers without tool support ignore them.
 type LlmClient interface {
-	GetResponse(ctx context.Context, prompt string) (chan string, error)
+	GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error)
+}
+
+// LlmOptions selects the model and sampling parameters of a request.
+// Zero values fall back to the llm settings the client was created with.
+type LlmOptions struct {
+	Model    string         `json:"model"`
+	Sampling SamplingConfig `json:"sampling"`
+}
+
+// ChatMessage is a single message of the conversation sent to the LLM
+type ChatMessage struct {
+	Role       string     `json:"role"` // "system", "user", "assistant", "tool"
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// LlmChunk is a piece of a streamed LLM response
+type LlmChunk struct {
+	Text      string
+	ToolCalls []ToolCall // Complete tool calls requested by the model
 }
 
-// TtsClient is the interface for the Text-to-Speech client
+// TtsClient is the interface for the Text-to-Speech client. Synthesize
+// returns audio in format, AudioFormatWav or AudioFormatOggOpus.
 type TtsClient interface {
-	Synthesize(ctx context.Context, text string) ([]byte, error)
+	Synthesize(ctx context.Context, text string, voice VoiceConfig, format string) ([]byte, error)
 	Close() error
 }
 
 // Implementation of the VAD client
 type VadClientImpl struct {
-	conn         *grpc.ClientConn
-	client       pb.VADServiceClient
+	pool         *endpointPool[*grpc.ClientConn]
+	client       pb.VADServiceClient // Client of the replica the stream runs on
 	stream       pb.VADService_ProcessAudioClient
 	ctx          context.Context
 	cancel       context.CancelFunc
@@ -71,6 +150,7 @@ type VadClientImpl struct {
 	speechMutex  sync.RWMutex
 	audioBuffer  []byte     // Buffer to accumulate audio samples
 	bufferMutex  sync.Mutex // Mutex for the audio buffer
+	chunkSize    int        // Bytes sent to the VAD service per message
 }
 
 // IsActive checks if the audio data contains voice activity
@@ -87,26 +167,35 @@ func (c *VadClientImpl) IsActive(audioData []byte) bool {
 	return c.speechActive
 }
 
-// Update the receiveResponses function to maintain speech activity state
-func (c *VadClientImpl) receiveResponses() {
+// receiveResponses maintains the speech activity state from the responses
+// of stream. done reports the outcome of the stream to the replica pool.
+func (c *VadClientImpl) receiveResponses(stream pb.VADService_ProcessAudioClient, done func(err error)) {
 	for {
-		fmt.Println("Waiting for VAD response...")
+		logf(LogDebug, "Waiting for VAD response...")
 		select {
 		case <-c.ctx.Done():
 			log.Println("VAD client context cancelled, stopping response receiver")
+			done(c.ctx.Err())
 			return
 		default:
 
-			resp, err := c.stream.Recv()
+			resp, err := stream.Recv()
 
 			if err == io.EOF {
 				log.Println("VAD stream closed by server")
+				done(nil)
 				return
 			}
 			if err != nil {
 				log.Printf("Error receiving VAD response: %v", err)
-				// Try to reconnect
-				c.stream = nil
+				done(err)
+
+				// Reconnect with the next chunk, unless a stream was opened since
+				c.bufferMutex.Lock()
+				if c.stream == stream {
+					c.stream = nil
+				}
+				c.bufferMutex.Unlock()
 				return
 			}
 
@@ -114,7 +203,7 @@ func (c *VadClientImpl) receiveResponses() {
 			event := resp.GetEvent()
 			message := resp.GetMessage()
 
-			fmt.Printf("Received VAD event: %s - %s\n", event, message)
+			logf(LogDebug, "Received VAD event: %s - %s", event, message)
 			// Update speech activity state
 			c.speechMutex.Lock()
 			if event == "start" || event == "continue" {
@@ -136,36 +225,24 @@ func (c *VadClientImpl) receiveResponses() {
 	}
 }
 
-// NewVadClient creates a new VAD client that connects to the VAD gRPC service
-func NewVadClient(addr string) (VadClient, error) {
+// NewVadClient creates a new VAD client that connects to the VAD gRPC service.
+// The audio stream runs on one replica and moves to another when it fails.
+func NewVadClient(addr string, config VadConfig, balancing BalancingConfig, tlsConfig *tls.Config) (VadClient, error) {
 	// Create context with cancel
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Connect to the gRPC server
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Connect to the replicas of the gRPC server
+	pool, err := newGrpcPool("vad", addr, balancing, tlsConfig)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to connect to VAD service: %w", err)
 	}
 
-	// Create the gRPC client
-	client := pb.NewVADServiceClient(conn)
-
-	// Create a bidirectional stream
-	stream, err := client.ProcessAudio(ctx)
-	if err != nil {
-		cancel()
-		conn.Close()
-		return nil, fmt.Errorf("failed to create VAD stream: %w", err)
-	}
-
 	// Create event channel
-	eventChan := make(chan VadEvent, 100) // Buffered channel to avoid blocking
+	eventChan := make(chan VadEvent, config.EventBufferSize) // Buffered channel to avoid blocking
 
 	vadClient := &VadClientImpl{
-		conn:         conn,
-		client:       client,
-		stream:       stream,
+		pool:         pool,
 		ctx:          ctx,
 		cancel:       cancel,
 		eventChan:    eventChan,
@@ -173,14 +250,40 @@ func NewVadClient(addr string) (VadClient, error) {
 		speechMutex:  sync.RWMutex{},
 		audioBuffer:  make([]byte, 0, 4096), // Initial capacity
 		bufferMutex:  sync.Mutex{},
+		chunkSize:    config.ChunkSizeBytes,
 	}
 
-	// Start a goroutine to receive VAD responses
-	go vadClient.receiveResponses()
+	// Create a bidirectional stream
+	if err := vadClient.openStream(); err != nil {
+		cancel()
+		pool.Close()
+		return nil, fmt.Errorf("failed to create VAD stream: %w", err)
+	}
 
 	return vadClient, nil
 }
 
+// openStream starts an audio stream on a replica and a goroutine receiving
+// its responses
+func (c *VadClientImpl) openStream() error {
+	conn, done, err := c.pool.pick(c.ctx)
+	if err != nil {
+		return err
+	}
+
+	client := pb.NewVADServiceClient(conn)
+	stream, err := client.ProcessAudio(c.ctx)
+	if err != nil {
+		done(err)
+		return err
+	}
+	c.client = client
+	c.stream = stream
+
+	go c.receiveResponses(stream, done)
+	return nil
+}
+
 // ProcessAudio sends audio data to the VAD service
 func (c *VadClientImpl) ProcessAudio(audioData []byte) error {
 	if audioData == nil || len(audioData) == 0 {
@@ -194,14 +297,11 @@ func (c *VadClientImpl) ProcessAudio(audioData []byte) error {
 	// Add incoming audio to the buffer
 	c.audioBuffer = append(c.audioBuffer, audioData...)
 
-	// Process complete chunks of 512 samples (1024 bytes for 16-bit samples)
-	const VAD_CHUNK_SIZE_BYTES = 512 * 2 // 512 samples * 2 bytes per sample (16-bit)
-
 	// Process as many complete chunks as possible
-	for len(c.audioBuffer) >= VAD_CHUNK_SIZE_BYTES {
+	for len(c.audioBuffer) >= c.chunkSize {
 		// Extract a chunk
-		chunk := c.audioBuffer[:VAD_CHUNK_SIZE_BYTES]
-		c.audioBuffer = c.audioBuffer[VAD_CHUNK_SIZE_BYTES:]
+		chunk := c.audioBuffer[:c.chunkSize]
+		c.audioBuffer = c.audioBuffer[c.chunkSize:]
 
 		// Send the chunk to the VAD service
 		if err := c.sendChunkToVAD(chunk); err != nil {
@@ -216,14 +316,9 @@ func (c *VadClientImpl) sendChunkToVAD(chunk []byte) error {
 	// Make sure we have a valid stream
 	if c.stream == nil {
 		log.Println("VAD stream is nil, reconnecting...")
-		stream, err := c.client.ProcessAudio(c.ctx)
-		if err != nil {
+		if err := c.openStream(); err != nil {
 			return fmt.Errorf("failed to recreate VAD stream: %w", err)
 		}
-		c.stream = stream
-
-		// Restart the receiver goroutine
-		go c.receiveResponses()
 	}
 
 	// Send the chunk to the VAD service
@@ -242,125 +337,304 @@ func (c *VadClientImpl) GetEventChannel() <-chan VadEvent {
 
 // ResetVAD resets the VAD state
 func (c *VadClientImpl) ResetVAD() error {
-	_, err := c.client.ResetVAD(c.ctx, &pb.ResetRequest{})
+	c.bufferMutex.Lock()
+	client := c.client
+	c.bufferMutex.Unlock()
+	_, err := client.ResetVAD(c.ctx, &pb.ResetRequest{})
 	return err
 }
 
-// Close closes the VAD client
-
 // Close closes the VAD client
 func (c *VadClientImpl) Close() error {
 	c.cancel()
+	c.bufferMutex.Lock()
 	if c.stream != nil {
 		c.stream.CloseSend()
 	}
+	c.bufferMutex.Unlock()
 	close(c.eventChan)
-	if c.conn != nil {
-		return c.conn.Close()
-	}
+	c.pool.Close()
 	return nil
 }
 
 // Implementation of the Trigger client
 
 type triggerClientImpl struct {
-	conn *grpc.ClientConn
-	// Add the generated gRPC client here once you have the proto files
-	// client proto.TriggerServiceClient
+	serviceVersion
+	pool *endpointPool[*grpc.ClientConn]
 }
 
 // NewTriggerClient creates a new Trigger client
-func NewTriggerClient(addr string) (TriggerClient, error) {
-	// Connect to the gRPC server
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewTriggerClient(addr string, balancing BalancingConfig, tlsConfig *tls.Config) (TriggerClient, error) {
+	// Connect to the replicas of the gRPC service
+	pool, err := newGrpcPool("trigger", addr, balancing, tlsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Trigger service: %w", err)
 	}
 
-	// Create the client
-	client := &triggerClientImpl{
-		conn: conn,
-		// Initialize the generated client
-		// client: proto.NewTriggerServiceClient(conn),
-	}
-
-	return client, nil
+	return &triggerClientImpl{pool: pool}, nil
 }
 
-// IsTriggered checks if the audio data contains the trigger word
-func (c *triggerClientImpl) IsTriggered(audioData []byte) bool {
-	// Mock implementation - in a real system, this would send the audio to the gRPC service
-	// and get a response
+// Detect checks if the audio data contains the wake word
+func (c *triggerClientImpl) Detect(ctx context.Context, audioData []byte, wakeWord string) (TriggerResult, error) {
+	conn, done, err := c.pool.pick(ctx)
+	if err != nil {
+		return TriggerResult{}, err
+	}
+
+	var header metadata.MD
+	resp, err := triggerpb.NewTriggerServiceClient(conn).Detect(ctx, &triggerpb.DetectRequest{
+		AudioData:  audioData,
+		SampleRate: inputSampleRate,
+		Channels:   1,
+		WakeWord:   wakeWord,
+	}, grpc.Header(&header))
+	done(err)
+	c.record(header)
+	if err != nil {
+		return TriggerResult{}, fmt.Errorf("trigger request failed: %w", err)
+	}
 
-	// For demo purposes, just randomly return true occasionally
-	return time.Now().Unix()%10 == 0
+	return TriggerResult{
+		Triggered:  resp.GetIsTriggered(),
+		Confidence: float64(resp.GetConfidence()),
+		WakeWord:   resp.GetDetectedWakeWord(),
+	}, nil
 }
 
 // Close closes the Trigger client
 func (c *triggerClientImpl) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
+	c.pool.Close()
 	return nil
 }
 
 // Implementation of the STT client
 
 type sttClientImpl struct {
-	conn *grpc.ClientConn
-	// Add the generated gRPC client here once you have the proto files
-	// client proto.SttServiceClient
+	serviceVersion
+	pool *endpointPool[*grpc.ClientConn]
 }
 
 // NewSttClient creates a new STT client
-func NewSttClient(addr string) (SttClient, error) {
-	// Connect to the gRPC server
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewSttClient(addr string, balancing BalancingConfig, tlsConfig *tls.Config) (SttClient, error) {
+	// Connect to the replicas of the gRPC service
+	pool, err := newGrpcPool("stt", addr, balancing, tlsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to STT service: %w", err)
 	}
 
-	// Create the client
-	client := &sttClientImpl{
-		conn: conn,
-		// Initialize the generated client
-		// client: proto.NewSttServiceClient(conn),
+	return &sttClientImpl{pool: pool}, nil
+}
+
+// Transcribe transcribes the audio data
+func (c *sttClientImpl) Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error) {
+	conn, done, err := c.pool.pick(ctx)
+	if err != nil {
+		return Transcription{}, err
+	}
+
+	var header metadata.MD
+	resp, err := sttpb.NewSttServiceClient(conn).Transcribe(ctx, &sttpb.TranscribeRequest{
+		AudioData:    bytes.Join(audioBuffer, nil),
+		SampleRate:   inputSampleRate,
+		Channels:     1,
+		LanguageCode: languageCode,
+		Config: &sttpb.TranscribeConfig{
+			MaxAlternatives:            int32(1 + alternatives),
+			EnableAutomaticPunctuation: true,
+		},
+	}, grpc.Header(&header))
+	done(err)
+	c.record(header)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("STT request failed: %w", err)
 	}
 
-	return client, nil
+	return transcription(resp), nil
 }
 
-// Transcribe transcribes the audio data
-func (c *sttClientImpl) Transcribe(ctx context.Context, audioBuffer [][]byte) (string, error) {
-	// Mock implementation - in a real system, this would send the audio to the gRPC service
-	// and get a response
+// Stream starts a streamed transcription on a replica
+func (c *sttClientImpl) Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error) {
+	conn, done, err := c.pool.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	// For demo purposes, just return a fixed transcript
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := sttpb.NewSttServiceClient(conn).TranscribeStream(ctx)
+	if err != nil {
+		cancel()
+		done(err)
+		return nil, fmt.Errorf("STT stream failed: %w", err)
+	}
 
-	return "Hello, how can I help you today?", nil
+	s := &sttStream{
+		stream: stream,
+		cancel: cancel,
+		first: &sttpb.TranscribeRequest{
+			SampleRate:   inputSampleRate,
+			Channels:     1,
+			LanguageCode: languageCode,
+			Config: &sttpb.TranscribeConfig{
+				EnableInterimResults:       options.Interim,
+				MaxAlternatives:            int32(1 + options.Alternatives),
+				EnableAutomaticPunctuation: true,
+				EnableWordTimestamps:       options.Words,
+			},
+		},
+		interim:  make(chan Transcription, 16),
+		finished: make(chan struct{}),
+	}
+	go s.receive(c, done)
+	return s, nil
 }
 
 // Close closes the STT client
 func (c *sttClientImpl) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
+	c.pool.Close()
 	return nil
 }
 
-// Implementation of the LLM client
+// sttStream is a streamed transcription of the STT service
+type sttStream struct {
+	stream    sttpb.SttService_TranscribeStreamClient
+	cancel    context.CancelFunc
+	first     *sttpb.TranscribeRequest // Settings sent with the first audio, nil once sent
+	sendMutex sync.Mutex
+	closed    bool
+	interim   chan Transcription
+	finished  chan struct{} // Closed once the stream has ended
+	final     *Transcription
+	err       error
+}
+
+// Send sends audio of the utterance
+func (s *sttStream) Send(audioData []byte) error {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+	if s.closed {
+		return errors.New("STT stream finished")
+	}
+
+	req := &sttpb.TranscribeRequest{AudioData: audioData}
+	if s.first != nil {
+		req, s.first = s.first, nil
+		req.AudioData = audioData
+	}
+	return s.stream.Send(req)
+}
+
+// Interim returns the interim results of the stream
+func (s *sttStream) Interim() <-chan Transcription {
+	return s.interim
+}
+
+// Finish ends the utterance and waits for the final result. The stream is
+// cancelled if ctx is done first.
+func (s *sttStream) Finish(ctx context.Context) (Transcription, error) {
+	s.sendMutex.Lock()
+	if !s.closed {
+		s.closed = true
+		s.stream.CloseSend()
+	}
+	s.sendMutex.Unlock()
+
+	select {
+	case <-s.finished:
+	case <-ctx.Done():
+		s.cancel()
+		<-s.finished
+	}
+	if s.final != nil {
+		return *s.final, nil
+	}
+	return Transcription{}, s.err
+}
+
+// receive reads the results of the stream until it ends
+func (s *sttStream) receive(client *sttClientImpl, done func(error)) {
+	defer close(s.finished)
+	defer close(s.interim)
+	defer s.cancel()
+
+	if header, err := s.stream.Header(); err == nil {
+		client.record(header)
+	}
+	for {
+		resp, err := s.stream.Recv()
+		if err == io.EOF {
+			done(nil)
+			s.err = errors.New("STT stream ended without a final result")
+			return
+		}
+		if err != nil {
+			done(err)
+			s.err = fmt.Errorf("STT stream failed: %w", err)
+			return
+		}
+
+		result := transcription(resp)
+		if resp.GetIsFinal() {
+			done(nil)
+			s.final = &result
+			return
+		}
+		// Interim results are dropped while the reader falls behind
+		select {
+		case s.interim <- result:
+		default:
+		}
+	}
+}
+
+// transcription converts a response of the STT service
+func transcription(resp *sttpb.TranscribeResponse) Transcription {
+	result := Transcription{
+		Text:         resp.GetTranscript(),
+		LanguageCode: resp.GetLanguageCode(),
+		Confidence:   score(resp.GetConfidence()),
+	}
+	for _, word := range resp.GetWords() {
+		result.Words = append(result.Words, WordInfo{
+			Word:       word.GetWord(),
+			Start:      word.GetStartTime(),
+			End:        word.GetEndTime(),
+			Confidence: score(word.GetConfidence()),
+		})
+	}
+	for _, alternative := range resp.GetAlternatives() {
+		// Services may repeat the transcript as the first alternative
+		if alternative.GetTranscript() == result.Text {
+			continue
+		}
+		result.Alternatives = append(result.Alternatives, Alternative{
+			Text:       alternative.GetTranscript(),
+			Confidence: score(alternative.GetConfidence()),
+		})
+	}
+	return result
+}
+
+// score converts a float32 score of a service to the float64 closest to the
+// decimal it was sent as, so 0.92 is not forwarded as 0.9200000166893005
+func score(value float32) float64 {
+	converted, _ := strconv.ParseFloat(strconv.FormatFloat(float64(value), 'g', -1, 32), 64)
+	return converted
+}
+
+// Implementation of the mock LLM client, registered as the "mock" provider
 
 type llmClientImpl struct {
 	baseURL string
 	client  *http.Client
 }
 
-// NewLlmClient creates a new LLM client
-func NewLlmClient(baseURL string) LlmClient {
+// newMockLlmClient creates a new mock LLM client
+func newMockLlmClient(baseURL string, config LlmConfig) LlmClient {
 	return &llmClientImpl{
 		baseURL: baseURL,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: config.Timeout.Std(),
 		},
 	}
 }
@@ -377,13 +651,13 @@ type LLMResponse struct {
 }
 
 // GetResponse gets a response from the LLM service
-func (c *llmClientImpl) GetResponse(ctx context.Context, prompt string) (chan string, error) {
+func (c *llmClientImpl) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
 	// Create a channel to stream the response
-	responseChan := make(chan string)
+	responseChan := make(chan LlmChunk)
 
 	// Create the request
 	reqBody, err := json.Marshal(LLMRequest{
-		Prompt: prompt,
+		Prompt: formatPrompt(messages),
 		Stream: true,
 	})
 	if err != nil {
@@ -411,7 +685,7 @@ func (c *llmClientImpl) GetResponse(ctx context.Context, prompt string) (chan st
 			select {
 			case <-ctx.Done():
 				return
-			case responseChan <- string(char):
+			case responseChan <- LlmChunk{Text: string(char)}:
 				time.Sleep(50 * time.Millisecond) // Simulate streaming delay
 			}
 		}
@@ -420,46 +694,88 @@ func (c *llmClientImpl) GetResponse(ctx context.Context, prompt string) (chan st
 	return responseChan, nil
 }
 
+// formatPrompt flattens chat messages into a single completion prompt
+func formatPrompt(messages []ChatMessage) string {
+	var sb strings.Builder
+	for _, message := range messages {
+		switch message.Role {
+		case "system":
+			sb.WriteString(message.Content + "\n\n")
+		case "user":
+			sb.WriteString("User: " + message.Content + "\n")
+		case "assistant":
+			sb.WriteString("Assistant: " + message.Content + "\n")
+		}
+	}
+	sb.WriteString("Assistant:")
+	return sb.String()
+}
+
 // Implementation of the TTS client
 
 type ttsClientImpl struct {
-	conn *grpc.ClientConn
-	// Add the generated gRPC client here once you have the proto files
-	// client proto.TtsServiceClient
+	serviceVersion
+	pool *endpointPool[*grpc.ClientConn]
 }
 
 // NewTtsClient creates a new TTS client
-func NewTtsClient(addr string) (TtsClient, error) {
-	// Connect to the gRPC server
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewTtsClient(addr string, balancing BalancingConfig, tlsConfig *tls.Config) (TtsClient, error) {
+	// Connect to the replicas of the gRPC service
+	pool, err := newGrpcPool("tts", addr, balancing, tlsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to TTS service: %w", err)
 	}
 
-	// Create the client
-	client := &ttsClientImpl{
-		conn: conn,
-		// Initialize the generated client
-		// client: proto.NewTtsServiceClient(conn),
+	return &ttsClientImpl{pool: pool}, nil
+}
+
+// Synthesize synthesizes text to speech and returns it as a WAV or Ogg Opus
+// file
+func (c *ttsClientImpl) Synthesize(ctx context.Context, text string, voice VoiceConfig, format string) ([]byte, error) {
+	conn, done, err := c.pool.pick(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return client, nil
-}
+	encoding := ttspb.AudioEncoding_LINEAR16
+	if format == AudioFormatOggOpus {
+		encoding = ttspb.AudioEncoding_OGG_OPUS
+	}
 
-// Synthesize synthesizes text to speech
-func (c *ttsClientImpl) Synthesize(ctx context.Context, text string) ([]byte, error) {
-	// Mock implementation - in a real system, this would send the text to the gRPC service
-	// and get audio data response
+	var header metadata.MD
+	resp, err := ttspb.NewTtsServiceClient(conn).Synthesize(ctx, &ttspb.SynthesizeRequest{
+		Text:         text,
+		LanguageCode: voice.LanguageCode,
+		VoiceName:    voice.VoiceName,
+		AudioConfig: &ttspb.AudioConfig{
+			AudioEncoding:   encoding,
+			SpeakingRate:    float32(voice.SpeakingRate),
+			Pitch:           float32(voice.Pitch),
+			SampleRateHertz: ttsSampleRate,
+		},
+	}, grpc.Header(&header))
+	done(err)
+	c.record(header)
+	if err != nil {
+		return nil, fmt.Errorf("TTS request failed: %w", err)
+	}
 
-	// For demo purposes, just return an empty audio buffer
-	// In a real implementation, this would return WAV or MP3 audio data
-	return []byte{}, nil
+	// Browsers can only decode raw PCM with a WAV header
+	audio := resp.GetAudioContent()
+	if encoding == ttspb.AudioEncoding_OGG_OPUS {
+		if !isOgg(audio) {
+			return nil, errors.New("TTS service returned no Ogg Opus audio")
+		}
+		return audio, nil
+	}
+	if !isWav(audio) {
+		audio = wrapWav(audio, ttsSampleRate)
+	}
+	return audio, nil
 }
 
 // Close closes the TTS client
 func (c *ttsClientImpl) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
+	c.pool.Close()
 	return nil
 }
@@ -42,9 +42,9 @@ go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.28
 go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.2
 
 # Generate Go code from proto files
-protoc --go_out=. --go_opt=paths=source_relative \
-    --go-grpc_out=. --go-grpc_opt=paths=source_relative \
-    proto/vad/vad.proto proto/trigger/trigger.proto proto/stt/stt.proto proto/tts/tts.proto
+protoc --go_out=. --go_opt=module=assistant-app \
+    --go-grpc_out=. --go-grpc_opt=module=assistant-app \
+    proto/stt.proto proto/tts.proto proto/trigger.proto
 
 echo "Setup completed successfully!"
 echo "Run 'make run' to start the application."
\ No newline at end of file
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// speculationBuffer bounds the chunks of a speculative response kept before
+// the turn takes it over
+const speculationBuffer = 1024
+
+// speculation is an LLM response requested for a stable interim transcript
+// before the user finished speaking. Its chunks are buffered until the final
+// transcript confirms it, so nothing is spoken for a guess.
+type speculation struct {
+	transcript string // Normalized transcript the response was requested for
+	language   string
+	startedAt  time.Time
+	cancel     context.CancelFunc
+	ready      chan struct{} // Closed once the request returned
+	stream     chan LlmChunk // Buffered response, set before ready is closed
+	err        error         // Error of the request, set before ready is closed
+}
+
+// speculate starts an LLM request for a stable interim transcript, replacing
+// a speculation for a different transcript
+func (cs *ClientState) speculate(config AppConfig, interim Transcription) {
+	turn := cs.activeTurn()
+	if turn == nil || cs.app.llmClient == nil {
+		return
+	}
+	settings := cs.turnSettings(config)
+	language := config.applyLanguage(&settings, interim.LanguageCode)
+	transcript := normalizeTranscript(interim.Text)
+	if transcript == "" {
+		return
+	}
+
+	cs.speculationMutex.Lock()
+	defer cs.speculationMutex.Unlock()
+
+	// The user may have finished speaking while the transcript settled
+	if cs.getState() != StateTriggered {
+		return
+	}
+	if current := cs.speculation; current != nil {
+		if current.transcript == transcript && current.language == language {
+			return
+		}
+		current.cancel()
+		cs.recordEvent("llm", "speculation_discarded", "interim transcript changed")
+	}
+
+	ctx, cancel := context.WithCancel(turn.ctx)
+	spec := &speculation{
+		transcript: transcript,
+		language:   language,
+		startedAt:  time.Now(),
+		cancel:     cancel,
+		ready:      make(chan struct{}),
+	}
+	cs.speculation = spec
+
+	messages, tools := cs.turnRequest(config, settings, interim.Text)
+	go spec.run(ctx, cs.app.llmClient, messages, tools, settings.Llm)
+	logf(LogDebug, "Speculating on %q", interim.Text)
+	cs.recordEvent("llm", "speculation", fmt.Sprintf("%q", interim.Text))
+}
+
+// run requests the response and buffers it until ctx is done
+func (s *speculation) run(ctx context.Context, client LlmClient, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) {
+	stream, err := client.GetResponse(ctx, messages, tools, options)
+	if err != nil {
+		s.err = err
+		close(s.ready)
+		return
+	}
+	s.stream = make(chan LlmChunk, speculationBuffer)
+	close(s.ready)
+
+	defer close(s.stream)
+	for chunk := range stream {
+		select {
+		case s.stream <- chunk:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// takeSpeculation returns the speculative response if it was requested for
+// transcript in language, and discards it otherwise. The caller owns the
+// returned speculation and cancels it when done.
+func (cs *ClientState) takeSpeculation(ctx context.Context, transcript, language string) *speculation {
+	cs.speculationMutex.Lock()
+	spec := cs.speculation
+	cs.speculation = nil
+	cs.speculationMutex.Unlock()
+	if spec == nil {
+		return nil
+	}
+
+	if spec.transcript != normalizeTranscript(transcript) || spec.language != language {
+		spec.cancel()
+		logf(LogDebug, "Final transcript %q differs from the speculation, requesting again", transcript)
+		cs.recordEvent("llm", "speculation_discarded", "final transcript differs")
+		return nil
+	}
+
+	select {
+	case <-spec.ready:
+	case <-ctx.Done():
+		spec.cancel()
+		return nil
+	}
+	if spec.err != nil {
+		spec.cancel()
+		logf(LogDebug, "Speculative LLM request failed, requesting again: %v", spec.err)
+		cs.recordEvent("llm", "speculation_discarded", spec.err.Error())
+		return nil
+	}
+	cs.recordEvent("llm", "speculation_confirmed", fmt.Sprintf("started %dms before the final transcript", msSince(spec.startedAt)))
+	return spec
+}
+
+// dropSpeculation cancels the speculation of the current turn, if any
+func (cs *ClientState) dropSpeculation() {
+	cs.speculationMutex.Lock()
+	spec := cs.speculation
+	cs.speculation = nil
+	cs.speculationMutex.Unlock()
+
+	if spec != nil {
+		spec.cancel()
+	}
+}
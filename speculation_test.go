@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// speculationLlm answers every request with reply and keeps their contexts
+type speculationLlm struct {
+	mutex    sync.Mutex
+	requests []context.Context
+	err      error
+}
+
+func (c *speculationLlm) client(reply ...string) LlmClient {
+	return llmClientFunc(func(ctx context.Context, options LlmOptions) (chan LlmChunk, error) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.requests = append(c.requests, ctx)
+		if c.err != nil {
+			return nil, c.err
+		}
+		return streamAfter(ctx, 0, reply...), nil
+	})
+}
+
+func (c *speculationLlm) count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.requests)
+}
+
+// last returns the context of the latest request
+func (c *speculationLlm) last() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.requests[len(c.requests)-1]
+}
+
+// speculateOn speculates on text and waits for the request to be made
+func speculateOn(cs *ClientState, text string) {
+	cs.speculate(cs.app.currentConfig(), Transcription{Text: text})
+	if spec := cs.speculation; spec != nil {
+		<-spec.ready
+	}
+}
+
+// speculatingSession returns a triggered session with llm as its LLM service
+func speculatingSession(t *testing.T, llm *speculationLlm, reply ...string) *ClientState {
+	t.Helper()
+	config := DefaultConfig()
+	config.Stt.Interim = true
+	config.Speculation.Enabled = true
+	config.Speculation.Stable = Duration(30 * time.Millisecond)
+	cs, _ := triggeredSession(t, config, &streamSttClient{stream: newFakeSttStream()})
+	cs.app.llmClient = llm.client(reply...)
+	return cs
+}
+
+func TestSpeculate(t *testing.T) {
+	llm := &speculationLlm{}
+	cs := speculatingSession(t, llm)
+	speculateOn(cs, "What is the weather?")
+	if cs.speculation == nil || cs.speculation.transcript != "what is the weather" {
+		t.Fatalf("speculation = %+v", cs.speculation)
+	}
+
+	// The same words keep the request, different ones replace it
+	speculateOn(cs, "what is the weather")
+	first := llm.last()
+	if llm.count() != 1 {
+		t.Errorf("%d requests for the same words, want 1", llm.count())
+	}
+	speculateOn(cs, "what is the weather in Oslo")
+	if llm.count() != 2 || cs.speculation.transcript != "what is the weather in oslo" {
+		t.Fatalf("%d requests, speculating on %q", llm.count(), cs.speculation.transcript)
+	}
+	if first.Err() == nil {
+		t.Error("replaced speculation not cancelled")
+	}
+
+	// Nothing is requested for blank transcripts or once the user stopped
+	second := llm.last()
+	speculateOn(cs, " ?")
+	cs.setState(StateProcessing)
+	speculateOn(cs, "something else")
+	if llm.count() != 2 {
+		t.Errorf("%d requests, want 2", llm.count())
+	}
+
+	cs.dropSpeculation()
+	if cs.speculation != nil || second.Err() == nil {
+		t.Error("speculation kept after drop")
+	}
+}
+
+func TestTakeSpeculation(t *testing.T) {
+	ctx := context.Background()
+
+	// A final transcript with the same words takes the buffered response
+	llm := &speculationLlm{}
+	cs := speculatingSession(t, llm, "It is", " sunny.")
+	speculateOn(cs, "what is the weather")
+	spec := cs.takeSpeculation(ctx, "What is the weather?", cs.speculation.language)
+	if spec == nil {
+		t.Fatal("matching speculation not taken")
+	}
+	if text := readStream(spec.stream); text != "It is sunny." {
+		t.Errorf("speculated response = %q", text)
+	}
+	spec.cancel()
+	if cs.speculation != nil {
+		t.Error("speculation kept after it was taken")
+	}
+	if cs.takeSpeculation(ctx, "what is the weather", "") != nil {
+		t.Error("speculation taken twice")
+	}
+
+	// Other words or another language discard it
+	for _, final := range []struct{ transcript, language string }{{"what is the time", ""}, {"what is the weather", "de-DE"}} {
+		cs.setState(StateTriggered)
+		speculateOn(cs, "what is the weather")
+		if cs.takeSpeculation(ctx, final.transcript, final.language) != nil {
+			t.Errorf("speculation taken for %q in %q", final.transcript, final.language)
+		}
+		if llm.last().Err() == nil {
+			t.Errorf("discarded speculation for %q not cancelled", final.transcript)
+		}
+	}
+
+	// So does a failed request, which the turn makes again
+	failing := &speculationLlm{err: errors.New("overloaded")}
+	cs = speculatingSession(t, failing)
+	speculateOn(cs, "what is the weather")
+	if cs.takeSpeculation(ctx, "what is the weather", cs.speculation.language) != nil {
+		t.Error("failed speculation taken")
+	}
+}
+
+func TestStableInterimSpeculates(t *testing.T) {
+	llm := &speculationLlm{}
+	cs := speculatingSession(t, llm)
+	config := cs.app.currentConfig()
+	stream := cs.app.sttClient.(*streamSttClient).stream
+	cs.openUtterance(config)
+
+	// Only the transcript that stays unchanged for speculation.stable
+	stream.interim <- Transcription{Text: "what is"}
+	time.Sleep(10 * time.Millisecond)
+	stream.interim <- Transcription{Text: "what is the weather"}
+	time.Sleep(100 * time.Millisecond)
+	close(stream.interim)
+	<-cs.utterance.forwarded
+
+	if llm.count() != 1 || cs.speculation == nil || cs.speculation.transcript != "what is the weather" {
+		t.Errorf("%d requests, speculation %+v", llm.count(), cs.speculation)
+	}
+}
+
+func TestQuestionDropsSpeculation(t *testing.T) {
+	llm := &speculationLlm{}
+	cs := speculatingSession(t, llm)
+	speculateOn(cs, "play some jazz")
+
+	// Asking about the transcript frees the request made on its interim
+	q := &question{kind: QuestionRepeat, heard: Transcription{Text: "play some jazz", Confidence: 0.1}}
+	config := cs.app.currentConfig()
+	config.Audio.PlaybackTimeout = Duration(10 * time.Millisecond)
+	cs.askQuestion(cs.activeTurn(), config, VoiceConfig{}, &TurnRecord{}, q)
+	if cs.speculation != nil || llm.last().Err() == nil {
+		t.Error("speculation kept running while asking a question")
+	}
+}
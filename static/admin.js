@@ -0,0 +1,135 @@
+document.addEventListener('DOMContentLoaded', () => {
+    // DOM Elements
+    const sessionsBody = document.getElementById('sessions');
+    const sessionCount = document.getElementById('session-count');
+    const watchedSession = document.getElementById('watched-session');
+    const eventLog = document.getElementById('debug-log');
+
+    // Configuration
+    const API = 'admin/api/sessions';
+    const REFRESH_MS = 2000;
+    const MAX_EVENTS = 500;
+
+    let watching = null;
+    let eventSource = null;
+
+    // Format the time since a timestamp
+    function since(timestamp) {
+        const seconds = Math.floor((Date.now() - new Date(timestamp)) / 1000);
+        if (seconds < 60) return `${seconds}s`;
+        if (seconds < 3600) return `${Math.floor(seconds / 60)}m ${seconds % 60}s`;
+        return `${Math.floor(seconds / 3600)}h ${Math.floor(seconds % 3600 / 60)}m`;
+    }
+
+    // Describe the latency of a turn
+    function latency(l) {
+        if (!l) return '';
+        return `STT ${l.stt_ms}ms, LLM ${l.llm_first_token_ms}ms, audio ${l.first_audio_ms}ms, total ${l.total_ms}ms`;
+    }
+
+    // Create a table cell with text
+    function cell(text) {
+        const td = document.createElement('td');
+        td.textContent = text;
+        return td;
+    }
+
+    // Create an action button
+    function button(label, className, onClick) {
+        const btn = document.createElement('button');
+        btn.className = `btn small ${className}`;
+        btn.textContent = label;
+        btn.addEventListener('click', (event) => {
+            event.stopPropagation();
+            onClick();
+        });
+        return btn;
+    }
+
+    // Send an action for a session and refresh the list
+    async function act(method, path, confirmation) {
+        if (!confirm(confirmation)) return;
+        const response = await fetch(path, { method });
+        if (!response.ok) {
+            alert(`Failed: ${response.status} ${await response.text()}`);
+        }
+        refresh();
+    }
+
+    // Show the sessions
+    function render(sessions) {
+        sessionCount.textContent = `(${sessions.length})`;
+        sessionsBody.replaceChildren(...sessions.map((s) => {
+            const row = document.createElement('tr');
+            if (s.session_id === watching) row.className = 'watched';
+            const turn = s.current_turn
+                ? `#${s.current_turn.turn} for ${since(s.current_turn.started_at)}`
+                : `${s.turns} done`;
+            row.append(
+                cell(s.session_id),
+                cell(s.user_id),
+                cell(s.state),
+                cell(since(s.connected_at)),
+                cell(turn),
+                cell(s.last_error ? `${latency(s.last_latency)} (${s.last_error})` : latency(s.last_latency)),
+            );
+
+            const actions = document.createElement('td');
+            actions.append(
+                button('Stop turn', 'primary', () =>
+                    act('POST', `${API}/${s.session_id}/stop`, `Stop the turn of session ${s.session_id}?`)),
+                button('Disconnect', 'danger', () =>
+                    act('DELETE', `${API}/${s.session_id}`, `Disconnect session ${s.session_id}?`)),
+            );
+            row.append(actions);
+
+            row.addEventListener('click', () => watch(s.session_id));
+            return row;
+        }));
+    }
+
+    // Fetch the sessions
+    async function refresh() {
+        try {
+            const response = await fetch(API);
+            if (!response.ok) throw new Error(`${response.status}`);
+            render(await response.json());
+        } catch (error) {
+            sessionCount.textContent = `(failed to load: ${error.message})`;
+        }
+    }
+
+    // Add an event to the log
+    function logEvent(text) {
+        const line = document.createElement('div');
+        line.textContent = text;
+        eventLog.appendChild(line);
+        while (eventLog.childNodes.length > MAX_EVENTS) {
+            eventLog.removeChild(eventLog.firstChild);
+        }
+        eventLog.scrollTop = eventLog.scrollHeight;
+    }
+
+    // Stream the live events of a session
+    function watch(sessionId) {
+        if (eventSource) eventSource.close();
+        watching = sessionId;
+        watchedSession.textContent = `of ${sessionId}`;
+        eventLog.replaceChildren();
+
+        eventSource = new EventSource(`${API}/${sessionId}/events`);
+        eventSource.onmessage = (message) => {
+            const event = JSON.parse(message.data);
+            const time = new Date(event.time).toLocaleTimeString();
+            logEvent(`${time} ${event.source} ${event.type}${event.detail ? ': ' + event.detail : ''}`);
+        };
+        eventSource.addEventListener('end', () => {
+            logEvent('Session ended');
+            eventSource.close();
+        });
+        refresh();
+    }
+
+    refresh();
+    setInterval(refresh, REFRESH_MS);
+});
@@ -6,20 +6,65 @@ document.addEventListener('DOMContentLoaded', () => {
     const stopBtn = document.getElementById('stop-btn');
     const transcript = document.getElementById('transcript');
     const debugLog = document.getElementById('debug-log');
+    const personaSelect = document.getElementById('persona-select');
 
-    // WebSocket and Audio Context
+    // WebSocket, or the control data channel of a WebRTC peer connection,
+    // and Audio Context
     let socket;
+    let peer;
+    let speechAudio;
     let mediaStream;
     let audioContext;
     let sourceNode;
     let processorNode;
     let isListening = false;
     let isConnected = false;
+    let micFormat = 'pcm';
+    let micEncoder = null;
+    let micTimestamp = 0;
+    // Speech plays back to back; the server is told how much has played so
+    // it can recognize the assistant's voice in the microphone
+    let playbackQueue = []; // Scheduled speech: { start, end, position }
+    let playbackEnd = 0; // Audio context time the queued speech runs out
+    let queuedMs = 0; // Milliseconds of speech scheduled in the session
+    let playbackTimer = null;
+    let decoding = Promise.resolve(); // Frames waiting to be decoded
+    let playbackSources = new Set(); // Sources scheduled or playing
+    let playbackGeneration = 0; // Bumped when queued speech is dropped
 
     // Configuration
     const SAMPLE_RATE = 16000; // Must match what your VAD/STT services expect
     const BUFFER_SIZE = 4096;
-    const WS_URL = `${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${window.location.host}/ws`;
+    const PLAYBACK_ACK_INTERVAL = 200; // Milliseconds between playback acks
+    // Sessions are stored under the user given with ?user= on the page URL
+    const USER = new URLSearchParams(window.location.search).get('user');
+    // Speech formats this browser can decode, most compact first
+    const AUDIO_FORMATS = [
+        ['ogg_opus', 'audio/ogg; codecs=opus'],
+        ['webm_opus', 'audio/webm; codecs=opus'],
+    ].filter(([, type]) => new Audio().canPlayType(type) !== '').map(([format]) => format).concat('wav');
+    // Microphone audio is sent as Opus where the browser can encode it
+    const MIC_OPUS_CONFIG = {
+        codec: 'opus',
+        sampleRate: SAMPLE_RATE,
+        numberOfChannels: 1,
+        bitrate: 16000,
+        opus: { frameDuration: 20000 },
+    };
+    const MIC_FORMATS = (async () => {
+        try {
+            const { supported } = await AudioEncoder.isConfigSupported(MIC_OPUS_CONFIG);
+            if (supported) return ['opus', 'pcm'];
+        } catch (error) {
+            // No WebCodecs
+        }
+        return ['pcm'];
+    })();
+    // ?transport=webrtc sends the microphone and plays speech over WebRTC
+    const USE_WEBRTC = new URLSearchParams(window.location.search).get('transport') === 'webrtc';
+    const WS_BASE = `${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${window.location.host}/ws`;
+    // Codec IDs of the audio frame header
+    const AUDIO_CODECS = { 1: 'wav', 2: 'ogg_opus', 3: 'webm_opus' };
 
     // Status types and messages
     const STATUS = {
@@ -30,6 +75,7 @@ document.addEventListener('DOMContentLoaded', () => {
         PROCESSING: { class: 'thinking', text: 'Processing your request...' },
         SPEAKING: { class: 'speaking', text: 'Speaking...' },
         ERROR: { class: 'error', text: 'Error occurred' },
+        DRAINING: { class: '', text: 'Server going away' },
         DISCONNECTED: { class: '', text: 'Disconnected' }
     };
 
@@ -62,6 +108,9 @@ document.addEventListener('DOMContentLoaded', () => {
     }
 
     function addToTranscript(text, isUser = false) {
+        if (isUser) {
+            clearInterimTranscript();
+        }
         const messageDiv = document.createElement('div');
         messageDiv.className = isUser ? 'user-message' : 'assistant-message';
         messageDiv.textContent = text;
@@ -69,19 +118,64 @@ document.addEventListener('DOMContentLoaded', () => {
         transcript.scrollTop = transcript.scrollHeight;
     }
 
+    // Interim transcripts show what the server heard so far, replaced by the
+    // final transcript
+    let interimDiv = null;
+
+    function showInterimTranscript(text) {
+        if (!interimDiv) {
+            interimDiv = document.createElement('div');
+            interimDiv.className = 'user-message interim';
+            transcript.appendChild(interimDiv);
+        }
+        interimDiv.textContent = text;
+        transcript.scrollTop = transcript.scrollHeight;
+    }
+
+    function clearInterimTranscript() {
+        if (interimDiv) {
+            interimDiv.remove();
+            interimDiv = null;
+        }
+    }
+
     // WebSocket functions
-    function connectWebSocket() {
+    async function connectWebSocket() {
         // Close existing socket if any
         if (socket) {
+            socket.onclose = null;
             socket.close();
         }
+        if (peer) {
+            peer.close();
+            peer = null;
+        }
 
         updateStatus('CONNECTING');
-        
-        socket = new WebSocket(WS_URL);
+
+        if (USE_WEBRTC) {
+            try {
+                socket = await connectPeer();
+            } catch (error) {
+                log(`WebRTC connection failed: ${error}`);
+                updateStatus('ERROR', 'Connection error');
+                setTimeout(connectWebSocket, 3000);
+                return;
+            }
+        } else {
+            // Offer the audio formats this browser handles
+            const params = new URLSearchParams({
+                audio: AUDIO_FORMATS.join(','),
+                mic: (await MIC_FORMATS).join(','),
+            });
+            if (USER) params.set('user', USER);
+            socket = new WebSocket(`${WS_BASE}?${params}`);
+        }
         
         socket.onopen = () => {
             isConnected = true;
+            playbackQueue = [];
+            queuedMs = 0;
             log('WebSocket connection established');
             updateStatus('IDLE');
             
@@ -96,9 +190,9 @@ document.addEventListener('DOMContentLoaded', () => {
             updateStatus('ERROR', 'Connection error');
         };
         
-        socket.onclose = () => {
+        socket.onclose = (event) => {
             isConnected = false;
-            log('WebSocket connection closed');
+            log(`WebSocket connection closed${event.reason ? `: ${event.reason}` : ''}`);
             updateStatus('DISCONNECTED');
             
             // Disable buttons on disconnect
@@ -110,6 +204,51 @@ document.addEventListener('DOMContentLoaded', () => {
         };
     }
 
+    // Connect over WebRTC: the microphone goes out on an audio track with the
+    // browser's echo cancellation, speech comes back on a track and the
+    // control data channel carries the messages of the WebSocket
+    async function connectPeer() {
+        const { ice_servers: iceServers } = await (await fetch('rtc')).json();
+
+        if (!mediaStream) {
+            mediaStream = await navigator.mediaDevices.getUserMedia({
+                audio: { echoCancellation: true, noiseSuppression: true, autoGainControl: true },
+            });
+        }
+        const micTrack = mediaStream.getAudioTracks()[0];
+        micTrack.enabled = isListening;
+
+        peer = new RTCPeerConnection({ iceServers });
+        peer.addTrack(micTrack, mediaStream);
+        peer.ontrack = (event) => {
+            if (!speechAudio) {
+                speechAudio = new Audio();
+                speechAudio.autoplay = true;
+            }
+            speechAudio.srcObject = event.streams[0] || new MediaStream([event.track]);
+        };
+        const channel = peer.createDataChannel('control');
+
+        // Send the offer once all candidates are gathered
+        await peer.setLocalDescription(await peer.createOffer());
+        await new Promise((resolve) => {
+            if (peer.iceGatheringState === 'complete') return resolve();
+            peer.addEventListener('icegatheringstatechange', () => {
+                if (peer.iceGatheringState === 'complete') resolve();
+            });
+        });
+        const response = await fetch(USER ? `rtc?${new URLSearchParams({ user: USER })}` : 'rtc', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(peer.localDescription),
+        });
+        if (!response.ok) {
+            throw new Error(`${response.status} ${(await response.text()).trim()}`);
+        }
+        await peer.setRemoteDescription(await response.json());
+        return channel;
+    }
+
     function handleWebSocketMessage(event) {
         // Check if the message is binary (audio) or text (status update)
         if (event.data instanceof Blob) {
@@ -124,17 +263,49 @@ document.addEventListener('DOMContentLoaded', () => {
                 switch (message.type) {
                     case 'status':
                         updateStatus(message.status, message.detail);
+                        if (message.status === 'IDLE') {
+                            // A turn that ended without a final transcript
+                            clearInterimTranscript();
+                        }
                         break;
                         
                     case 'transcript':
                         if (message.isFinal) {
                             addToTranscript(message.text, true);
+                            if (message.language) {
+                                log(`Language: ${message.language}`);
+                            }
+                            if (message.words) {
+                                log(`Confidence ${message.confidence || 'n/a'}: ` +
+                                    message.words.map((w) => `${w.word}@${w.start.toFixed(2)}s`).join(' '));
+                            }
+                            if (message.alternatives) {
+                                log('Alternatives: ' +
+                                    message.alternatives.map((a) => `"${a.text}" (${a.confidence || 'n/a'})`).join(', '));
+                            }
+                        } else {
+                            showInterimTranscript(message.text);
                         }
                         break;
                         
                     case 'response':
                         addToTranscript(message.text, false);
                         break;
+
+                    case 'config':
+                        handleConfigMessage(message);
+                        break;
+
+                    case 'cancelled':
+                        flushPlayback();
+                        clearInterimTranscript();
+                        log(`Turn cancelled during ${message.stage}`);
+                        break;
+
+                    case 'close':
+                        // WebRTC sessions get the reason the server closed them here
+                        log(`Server closed the session: ${message.reason || message.code}`);
+                        break;
                         
                     default:
                         log(`Unknown message type: ${message.type}`);
@@ -145,6 +316,47 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    // Session settings
+    function handleConfigMessage(message) {
+        if (message.error) {
+            log(`Configuration rejected: ${message.error}`);
+        }
+
+        // Refresh the persona choices
+        personaSelect.innerHTML = '';
+        (message.personas || []).forEach((persona) => {
+            const option = document.createElement('option');
+            option.value = persona.name;
+            option.textContent = persona.description ? `${persona.name} - ${persona.description}` : persona.name;
+            personaSelect.appendChild(option);
+        });
+        personaSelect.disabled = personaSelect.options.length < 2;
+
+        if (message.audio && !message.error) {
+            log(`Speech audio: ${message.audio.format} at ${message.audio.sample_rate} Hz`);
+        }
+        if (message.mic && message.mic.format !== micFormat) {
+            micFormat = message.mic.format;
+            micEncoder = null;
+            log(`Microphone audio: ${micFormat} at ${message.mic.sample_rate} Hz`);
+
+            // The server keeps decoding the old format until told the new one is on its way
+            socket.send(JSON.stringify({ action: 'mic', format: micFormat }));
+        }
+
+        if (message.settings) {
+            personaSelect.value = message.settings.persona;
+            log(`Persona: ${message.settings.persona}, voice: ${message.settings.voice.voice_name || 'default'} (${message.settings.voice.language_code})`);
+        }
+    }
+
+    function configureSession(settings) {
+        if (!isConnected) {
+            return;
+        }
+        socket.send(JSON.stringify({ action: 'configure', ...settings }));
+    }
+
     // Audio processing functions
     // In the browser code that initializes the AudioContext
     async function initAudio() {
@@ -176,7 +388,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 processorNode.port.onmessage = (event) => {
                     if (event.data && isConnected && isListening) {
                         // Here event.data should be Int16Array directly
-                        socket.send(event.data.buffer);
+                        sendMicAudio(event.data);
                     }
                 };
             } else {
@@ -189,7 +401,7 @@ document.addEventListener('DOMContentLoaded', () => {
                         
                         // We should resample here, but for simplicity just convert
                         const pcmData = convertFloat32ToInt16(inputData);
-                        socket.send(pcmData.buffer);
+                        sendMicAudio(pcmData);
                     }
                 };
                 
@@ -212,16 +424,20 @@ document.addEventListener('DOMContentLoaded', () => {
             return;
         }
         
-        if (!audioContext) {
+        if (USE_WEBRTC) {
+            // Unmute the microphone track; playback starts on this click
+            mediaStream.getAudioTracks()[0].enabled = true;
+            if (speechAudio) speechAudio.play().catch((error) => log(`Speech playback error: ${error}`));
+        } else if (!audioContext) {
             initAudio().then((success) => {
                 if (success) startListening();
             });
             return;
+        } else {
+            // Connect source to processor to start capturing audio
+            sourceNode.connect(processorNode);
         }
         
-        // Connect source to processor to start capturing audio
-        sourceNode.connect(processorNode);
-        
         // Update state
         isListening = true;
         updateStatus('LISTENING');
@@ -234,11 +450,19 @@ document.addEventListener('DOMContentLoaded', () => {
     }
 
     function stopListening() {
-        if (sourceNode && processorNode) {
+        if (USE_WEBRTC && mediaStream) {
+            // Mute the microphone track
+            mediaStream.getAudioTracks()[0].enabled = false;
+        } else if (sourceNode && processorNode) {
             // Disconnect nodes to stop capturing audio
             sourceNode.disconnect(processorNode);
         }
         
+        // Cancel the turn in progress, if any
+        if (isConnected) {
+            socket.send(JSON.stringify({ action: 'stop' }));
+        }
+
         // Update state
         isListening = false;
         updateStatus('IDLE');
@@ -250,13 +474,70 @@ document.addEventListener('DOMContentLoaded', () => {
         log('Stopped listening');
     }
 
-    async function processAudioResponse(audioBlobData) {
+    // Send 16-bit PCM from the microphone, encoded as Opus if negotiated
+    function sendMicAudio(pcm) {
+        if (micFormat !== 'opus') {
+            socket.send(pcm.buffer);
+            return;
+        }
+
+        // The encoder sends one Opus packet per 20ms
+        if (!micEncoder || micEncoder.state === 'closed') {
+            micEncoder = new AudioEncoder({
+                output: (chunk) => {
+                    // Packets encoded before a switch to PCM are dropped
+                    if (!isConnected || micFormat !== 'opus') return;
+                    const packet = new Uint8Array(chunk.byteLength);
+                    chunk.copyTo(packet);
+                    socket.send(packet);
+                },
+                error: (error) => {
+                    log(`Opus encoder error: ${error}`);
+                    micEncoder = null;
+                },
+            });
+            micEncoder.configure(MIC_OPUS_CONFIG);
+        }
+        micEncoder.encode(new AudioData({
+            format: 's16',
+            sampleRate: SAMPLE_RATE,
+            numberOfChannels: 1,
+            numberOfFrames: pcm.length,
+            timestamp: micTimestamp,
+            data: pcm,
+        }));
+        micTimestamp += pcm.length * 1000000 / SAMPLE_RATE;
+    }
+
+    // Frames are decoded one after another so they play in the order sent
+    function processAudioResponse(audioBlobData) {
+        decoding = decoding.then(() => playAudioFrame(audioBlobData));
+    }
+
+    async function playAudioFrame(audioBlobData) {
+        const generation = playbackGeneration;
+        let id = null;
         try {
             // Convert blob to ArrayBuffer
-            const arrayBuffer = await audioBlobData.arrayBuffer();
+            let arrayBuffer = await audioBlobData.arrayBuffer();
+
+            // Skip the audio frame header: its size, version, codec, channels,
+            // sample rate and, since version 2, the frame id
+            const header = new DataView(arrayBuffer);
+            if (header.getUint8(1) >= 2) {
+                id = header.getUint32(8, true);
+            }
+            const codec = AUDIO_CODECS[header.getUint8(2)];
+            if (!codec) {
+                throw new Error(`unknown audio codec ${header.getUint8(2)}`);
+            }
+            arrayBuffer = arrayBuffer.slice(header.getUint8(0));
             
             // Decode the audio data
             const audioBuffer = await audioContext.decodeAudioData(arrayBuffer);
+            if (generation !== playbackGeneration) {
+                return; // Dropped by a cancelled turn while decoding
+            }
             
             // Create a buffer source node
             const source = audioContext.createBufferSource();
@@ -265,15 +546,74 @@ document.addEventListener('DOMContentLoaded', () => {
             // Connect to destination (speakers)
             source.connect(audioContext.destination);
             
-            // Play the audio
-            source.start();
+            // Play the audio after the speech still queued
+            const start = Math.max(audioContext.currentTime, playbackEnd);
+            playbackEnd = start + audioBuffer.duration;
+            playbackQueue.push({ start, end: playbackEnd, position: queuedMs });
+            queuedMs += audioBuffer.duration * 1000;
+            source.start(start);
+            playbackSources.add(source);
+            setTimeout(() => {
+                if (generation !== playbackGeneration) return;
+                sendPlaybackAck('start', id);
+                startPlaybackAcks();
+            }, (start - audioContext.currentTime) * 1000);
             
             // Log when audio ends
             source.onended = () => {
+                playbackSources.delete(source);
+                if (generation !== playbackGeneration) return;
                 log('TTS audio playback completed');
+                sendPlaybackAck('finish', id);
             };
         } catch (error) {
             log(`Error processing audio response: ${error}`);
+            // The server waits for every frame to finish
+            if (id !== null) sendPlaybackAck('finish', id);
+        }
+    }
+
+    // Drop the speech of a cancelled turn, playing or queued. The position
+    // keeps counting the dropped speech, as the server does.
+    function flushPlayback() {
+        playbackGeneration++;
+        playbackSources.forEach((source) => source.stop());
+        playbackSources.clear();
+        playbackQueue = [];
+        if (audioContext) {
+            playbackEnd = audioContext.currentTime;
+            sendPlaybackAck();
+        }
+    }
+
+    // Milliseconds of speech played in the session, and whether any is playing
+    function playbackPosition() {
+        const now = audioContext.currentTime;
+        playbackQueue = playbackQueue.filter((entry) => entry.end > now);
+        const current = playbackQueue[0];
+        if (!current) return { position: queuedMs, playing: false };
+        if (now < current.start) return { position: current.position, playing: false };
+        return { position: current.position + (now - current.start) * 1000, playing: true };
+    }
+
+    // Acknowledge a playback event with the position, sent every
+    // PLAYBACK_ACK_INTERVAL until the queue runs out
+    function sendPlaybackAck(event = 'position', id = null) {
+        const { position, playing } = playbackPosition();
+        if (isConnected) {
+            const ack = { action: 'playback', event, position_ms: Math.round(position), playing };
+            if (id !== null) ack.id = id;
+            socket.send(JSON.stringify(ack));
+        }
+        if (!playbackQueue.length) {
+            clearInterval(playbackTimer);
+            playbackTimer = null;
+        }
+    }
+
+    function startPlaybackAcks() {
+        if (!playbackTimer && playbackQueue.length) {
+            playbackTimer = setInterval(sendPlaybackAck, PLAYBACK_ACK_INTERVAL);
         }
     }
 
@@ -294,6 +634,7 @@ document.addEventListener('DOMContentLoaded', () => {
     // Event listeners
     startBtn.addEventListener('click', startListening);
     stopBtn.addEventListener('click', stopListening);
+    personaSelect.addEventListener('change', () => configureSession({ persona: personaSelect.value }));
 
     // Initialize connection
     connectWebSocket();
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves a certificate and loads it again once its files change,
+// so renewed certificates are picked up without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration // How often the files are checked; 0 never reloads
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+	mutex    sync.Mutex
+}
+
+// newCertReloader loads the certificate pair at certFile and keyFile
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+// load reads the certificate pair
+func (r *certReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", r.certFile, err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime returns when the certificate or key file last changed
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// current returns the certificate, reloading it if the files changed since
+// the last check. A certificate that fails to load keeps the previous one.
+func (r *certReloader) current() *tls.Certificate {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.interval <= 0 || time.Since(r.checked) < r.interval {
+		return r.cert
+	}
+	r.checked = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil || modTime.Equal(r.modTime) {
+		return r.cert
+	}
+	if err := r.load(); err != nil {
+		log.Printf("Keeping the current certificate: %v", err)
+	} else {
+		log.Printf("Reloaded certificate %s", r.certFile)
+	}
+	return r.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+// serverTLSConfig returns the TLS settings of the web server, or nil to serve
+// plain HTTP
+func serverTLSConfig(config TLSConfig) (*tls.Config, error) {
+	if config.Server.CertFile == "" {
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(config.Server.CertFile, config.Server.KeyFile, config.ReloadInterval.Std())
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// clientTLSConfig returns the TLS settings for connecting to a backend, or
+// nil when TLS is not enabled for it
+func clientTLSConfig(config ClientTLSConfig, reloadInterval Duration) (*tls.Config, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	// Trust only the custom CA if one is set
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file " + config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Present a client certificate for mutual TLS
+	if config.CertFile != "" {
+		reloader, err := newCertReloader(config.CertFile, config.KeyFile, reloadInterval.Std())
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// forHost returns tlsConfig verifying the server as host unless a server name
+// is configured. Replicas found by resolving a host name are dialled by IP,
+// but their certificates are issued for the name.
+func forHost(tlsConfig *tls.Config, host string) *tls.Config {
+	if tlsConfig.ServerName != "" || host == "" {
+		return tlsConfig
+	}
+	clone := tlsConfig.Clone()
+	clone.ServerName = host
+	return clone
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing test certificates
+type testCA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string // PEM of the CA certificate
+}
+
+// newTestCA creates a CA in a temporary directory
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	ca := &testCA{dir: t.TempDir(), cert: cert, key: key}
+	ca.file = filepath.Join(ca.dir, "ca.pem")
+	os.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	return ca
+}
+
+// issue writes a certificate for name to name.pem and name.key and returns
+// the certificate in DER
+func (ca *testCA) issue(t *testing.T, name string, serial int64) []byte {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(ca.dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(filepath.Join(ca.dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	return der
+}
+
+// files returns the certificate and key files of name
+func (ca *testCA) files(name string) (string, string) {
+	return filepath.Join(ca.dir, name+".pem"), filepath.Join(ca.dir, name+".key")
+}
+
+// handshake connects a client to a server and returns the client
+// certificates the server saw
+func handshake(t *testing.T, server, client *tls.Config) ([]*x509.Certificate, error) {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	peers := make(chan []*x509.Certificate, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			peers <- nil
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		tlsConn.Handshake()
+		peers <- tlsConn.ConnectionState().PeerCertificates
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), client)
+	if err != nil {
+		<-peers
+		return nil, err
+	}
+	conn.Close()
+	return <-peers, nil
+}
+
+func TestTLSConfigs(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue(t, "stt.internal", 2)
+	clientDer := ca.issue(t, "assistant", 3)
+
+	certFile, keyFile := ca.files("stt.internal")
+	server, err := serverTLSConfig(TLSConfig{Server: ServerTLSConfig{CertFile: certFile, KeyFile: keyFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	server.ClientCAs = roots
+	server.ClientAuth = tls.VerifyClientCertIfGiven
+
+	certFile, keyFile = ca.files("assistant")
+	client, err := clientTLSConfig(ClientTLSConfig{Enabled: true, CAFile: ca.file, CertFile: certFile, KeyFile: keyFile}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replicas are dialled by IP and verified by the name they were found under
+	peers, err := handshake(t, server, forHost(client, "stt.internal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || !bytes.Equal(peers[0].Raw, clientDer) {
+		t.Errorf("server saw %d client certificate(s), want the client's", len(peers))
+	}
+	if _, err := handshake(t, server, forHost(client, "tts.internal")); err == nil {
+		t.Error("handshake verified the wrong host name")
+	}
+
+	// A configured server name wins over the host
+	client.ServerName = "stt.internal"
+	if _, err := handshake(t, server, forHost(client, "10.0.0.1")); err != nil {
+		t.Errorf("handshake with server_name: %v", err)
+	}
+
+	// Without the CA the system roots don't trust the server
+	system, _ := clientTLSConfig(ClientTLSConfig{Enabled: true}, 0)
+	if _, err := handshake(t, server, forHost(system, "stt.internal")); err == nil {
+		t.Error("handshake trusted an unknown CA")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	if config, err := serverTLSConfig(TLSConfig{}); config != nil || err != nil {
+		t.Errorf("server without certificate = %v, %v, want plain HTTP", config, err)
+	}
+	if config, err := clientTLSConfig(ClientTLSConfig{CAFile: "missing.pem"}, 0); config != nil || err != nil {
+		t.Errorf("disabled client = %v, %v, want nil", config, err)
+	}
+
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate"), 0o600)
+	for _, config := range []ClientTLSConfig{
+		{Enabled: true, CAFile: filepath.Join(dir, "missing.pem")},
+		{Enabled: true, CAFile: empty},
+		{Enabled: true, CertFile: empty, KeyFile: empty},
+	} {
+		if _, err := clientTLSConfig(config, 0); err == nil {
+			t.Errorf("clientTLSConfig(%+v) succeeded", config)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+	first := ca.issue(t, "app", 2)
+	certFile, keyFile := ca.files("app")
+	reloader, err := newCertReloader(certFile, keyFile, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// touch marks the files as changed
+	touch := func(at time.Time) {
+		os.Chtimes(certFile, at, at)
+		os.Chtimes(keyFile, at, at)
+	}
+
+	renewed := ca.issue(t, "app", 3)
+	touch(time.Now().Add(time.Minute))
+	time.Sleep(2 * time.Millisecond)
+	if cert := reloader.current(); !bytes.Equal(cert.Certificate[0], renewed) || bytes.Equal(renewed, first) {
+		t.Error("renewed certificate not loaded")
+	}
+
+	// A broken renewal keeps the previous certificate
+	os.WriteFile(keyFile, []byte("truncated"), 0o600)
+	touch(time.Now().Add(2 * time.Minute))
+	time.Sleep(2 * time.Millisecond)
+	if cert, _ := reloader.GetCertificate(nil); !bytes.Equal(cert.Certificate[0], renewed) {
+		t.Error("broken certificate replaced the current one")
+	}
+
+	// Without an interval the files are never checked again
+	static, _ := newCertReloader(certFile, keyFile, 0)
+	if static != nil {
+		t.Error("loaded a broken certificate")
+	}
+	loaded := ca.issue(t, "app", 4)
+	static, err = newCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.issue(t, "app", 5)
+	touch(time.Now().Add(3 * time.Minute))
+	if cert, _ := static.GetClientCertificate(nil); !bytes.Equal(cert.Certificate[0], loaded) {
+		t.Error("certificate reloaded without an interval")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolHandler runs a tool for a session with the JSON arguments chosen by the
+// model and returns the result reported back to the model
+type ToolHandler func(ctx context.Context, cs *ClientState, args json.RawMessage) (string, error)
+
+// Tool is a function the LLM can call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON schema of the arguments object
+	Handler     ToolHandler
+}
+
+// ToolDefinition describes a tool to the LLM in the OpenAI format
+type ToolDefinition struct {
+	Type     string       `json:"type"` // "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the function part of a ToolDefinition
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a tool invocation requested by the LLM
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"` // "function"
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the tool to call and holds its JSON encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolRegistry holds the tools available to the LLM
+type ToolRegistry struct {
+	tools map[string]Tool
+	mutex sync.RWMutex
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]Tool),
+	}
+}
+
+// Register adds a tool to the registry, replacing any tool with the same name
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+// Definitions returns the definitions of the registered tools sorted by
+// name. A nil allowed list returns every tool.
+func (r *ToolRegistry) Definitions(allowed []string) []ToolDefinition {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	definitions := make([]ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		if allowed != nil && !contains(allowed, tool.Name) {
+			continue
+		}
+		definitions = append(definitions, ToolDefinition{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// toolResult is the JSON content of a tool message sent back to the LLM
+type toolResult struct {
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Execute runs a tool call with a timeout and returns the JSON encoded
+// result or error to report back to the model. Tools missing from a non-nil
+// allowed list are treated as unknown.
+func (r *ToolRegistry) Execute(ctx context.Context, cs *ClientState, call ToolCall, allowed []string, timeout time.Duration) string {
+	result, err := r.execute(ctx, cs, call, allowed, timeout)
+	if err != nil {
+		log.Printf("Tool %s failed: %v", call.Function.Name, err)
+		content, _ := json.Marshal(toolResult{Error: err.Error()})
+		return string(content)
+	}
+
+	content, _ := json.Marshal(toolResult{Result: result})
+	return string(content)
+}
+
+func (r *ToolRegistry) execute(ctx context.Context, cs *ClientState, call ToolCall, allowed []string, timeout time.Duration) (string, error) {
+	r.mutex.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mutex.RUnlock()
+	if !ok || (allowed != nil && !contains(allowed, call.Function.Name)) {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "", fmt.Errorf("arguments are not valid JSON")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Run the handler in a goroutine so a handler ignoring ctx cannot block the turn
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := tool.Handler(ctx, cs, args)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool timed out: %w", ctx.Err())
+	case out := <-done:
+		return out.result, out.err
+	}
+}
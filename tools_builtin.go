@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RegisterBuiltinTools adds the tools that ship with the assistant
+func RegisterBuiltinTools(registry *ToolRegistry) {
+	registry.Register(Tool{
+		Name:        "get_current_time",
+		Description: "Get the current date and time, optionally in a given IANA time zone such as Europe/Berlin.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {"type": "string", "description": "IANA time zone name"}
+			}
+		}`),
+		Handler: currentTimeTool,
+	})
+
+	registry.Register(Tool{
+		Name:        "set_timer",
+		Description: "Start a timer. The assistant announces when it is done.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"seconds": {"type": "integer", "description": "Duration of the timer in whole seconds"},
+				"label": {"type": "string", "description": "What the timer is for"}
+			},
+			"required": ["seconds"]
+		}`),
+		Handler: timerTool,
+	})
+
+	registry.Register(Tool{
+		Name:        "calculate",
+		Description: "Evaluate an arithmetic expression with + - * / ^ and parentheses.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"expression": {"type": "string", "description": "The expression, for example (2 + 3) * 4"}
+			},
+			"required": ["expression"]
+		}`),
+		Handler: calculatorTool,
+	})
+}
+
+// currentTimeTool returns the current time
+func currentTimeTool(ctx context.Context, cs *ClientState, args json.RawMessage) (string, error) {
+	var params struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	now := time.Now()
+	if params.Timezone != "" {
+		location, err := time.LoadLocation(params.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q", params.Timezone)
+		}
+		now = now.In(location)
+	}
+	return now.Format("Monday, 2 January 2006, 15:04 MST"), nil
+}
+
+// maxTimer is the longest timer that can be set
+const maxTimer = 24 * time.Hour
+
+// timerTool starts a timer that is announced to the session when it fires
+func timerTool(ctx context.Context, cs *ClientState, args json.RawMessage) (string, error) {
+	var params struct {
+		Seconds float64 `json:"seconds"`
+		Label   string  `json:"label"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if params.Seconds < 1 || params.Seconds > maxTimer.Seconds() || params.Seconds != math.Trunc(params.Seconds) {
+		return "", fmt.Errorf("seconds must be a whole number between 1 and %d", int(maxTimer.Seconds()))
+	}
+	duration := time.Duration(params.Seconds) * time.Second
+
+	announcement := "Your timer is done."
+	if params.Label != "" {
+		announcement = fmt.Sprintf("Your %s timer is done.", params.Label)
+	}
+	if !cs.afterFunc(duration, func() { cs.announce(announcement) }) {
+		return "", fmt.Errorf("the session has ended")
+	}
+
+	return fmt.Sprintf("Timer set for %s", duration), nil
+}
+
+// calculatorTool evaluates an arithmetic expression
+func calculatorTool(ctx context.Context, cs *ClientState, args json.RawMessage) (string, error) {
+	var params struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	value, err := evaluateExpression(params.Expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(value, 'g', 12, 64), nil
+}
+
+// evaluateExpression evaluates an arithmetic expression with the usual
+// precedence: ^ (right associative), then unary minus, then * /, then + -
+func evaluateExpression(expression string) (float64, error) {
+	p := &expressionParser{input: expression}
+	value, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+// expressionParser is a recursive descent parser for arithmetic expressions
+type expressionParser struct {
+	input string
+	pos   int
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// next returns the next non-space character, or 0 at the end of the input
+func (p *expressionParser) next() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *expressionParser) parseSum() (float64, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.next()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (p *expressionParser) parseProduct() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.next()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		if op == '*' {
+			left *= right
+		} else {
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		}
+	}
+}
+
+func (p *expressionParser) parseUnary() (float64, error) {
+	if p.next() == '-' {
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	return p.parsePower()
+}
+
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parseOperand()
+	if err != nil {
+		return 0, err
+	}
+	if p.next() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *expressionParser) parseOperand() (float64, error) {
+	switch c := p.next(); {
+	case c == '(':
+		p.pos++
+		value, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return value, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && strings.IndexByte("0123456789.eE", p.input[p.pos]) >= 0 {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return value, nil
+	case c == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	default:
+		return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTools returns a registry with the built-in tools and a tool that
+// never returns
+func testTools() *ToolRegistry {
+	registry := NewToolRegistry()
+	RegisterBuiltinTools(registry)
+	registry.Register(Tool{
+		Name: "hang",
+		Handler: func(ctx context.Context, cs *ClientState, args json.RawMessage) (string, error) {
+			select {}
+		},
+	})
+	return registry
+}
+
+// toolCall returns a call of the tool name with arguments
+func toolCall(name, arguments string) ToolCall {
+	return ToolCall{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: name, Arguments: arguments}}
+}
+
+func TestToolDefinitions(t *testing.T) {
+	registry := testTools()
+
+	var names []string
+	for _, definition := range registry.Definitions(nil) {
+		names = append(names, definition.Function.Name)
+	}
+	if strings.Join(names, ",") != "calculate,get_current_time,hang,set_timer" {
+		t.Errorf("definitions = %v, want every tool sorted by name", names)
+	}
+
+	allowed := registry.Definitions([]string{"set_timer", "unknown"})
+	if len(allowed) != 1 || allowed[0].Function.Name != "set_timer" || allowed[0].Type != "function" {
+		t.Errorf("allowed definitions = %+v", allowed)
+	}
+	if len(registry.Definitions([]string{})) != 0 {
+		t.Error("an empty allowed list offers tools")
+	}
+}
+
+func TestToolExecute(t *testing.T) {
+	registry := testTools()
+	tests := []struct {
+		name    string
+		call    ToolCall
+		allowed []string
+		want    string
+	}{
+		{"result", toolCall("calculate", `{"expression": "(2 + 3) * 4"}`), nil, `{"result":"20"}`},
+		{"error", toolCall("calculate", `{"expression": "1 / 0"}`), nil, `{"error":"division by zero"}`},
+		{"unknown", toolCall("launch", `{}`), nil, `{"error":"unknown tool \"launch\""}`},
+		{"not allowed", toolCall("calculate", `{"expression": "1"}`), []string{"set_timer"}, `{"error":"unknown tool \"calculate\""}`},
+		{"invalid arguments", toolCall("calculate", `{"expression": `), nil, `{"error":"arguments are not valid JSON"}`},
+		{"timeout", toolCall("hang", ``), nil, `{"error":"tool timed out: context deadline exceeded"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := registry.Execute(context.Background(), nil, test.call, test.allowed, 50*time.Millisecond)
+			if got != test.want {
+				t.Errorf("Execute = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	tests := map[string]float64{
+		"1 + 2 * 3":     7,
+		"(1 + 2) * 3":   9,
+		"2 ^ 3 ^ 2":     512,
+		"-2 ^ 2":        -4,
+		"10 / 4 - .5":   2,
+		"1.5e3 + -1e3":  500,
+		" 7 - - 2 ":     9,
+		"((((1))))*2/2": 1,
+	}
+	for expression, want := range tests {
+		if got, err := evaluateExpression(expression); err != nil || got != want {
+			t.Errorf("%q = %v, %v, want %v", expression, got, err, want)
+		}
+	}
+
+	for _, expression := range []string{"", "1 +", "(1 + 2", "2 * x", "1 / 0", "10 ^ 400", "1..2"} {
+		if got, err := evaluateExpression(expression); err == nil {
+			t.Errorf("%q = %v, want an error", expression, got)
+		}
+	}
+}
+
+func TestTimerTool(t *testing.T) {
+	app := newApp(DefaultConfig())
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+	dialSession(t, server, "")
+	cs := app.snapshotClients()[0]
+
+	for _, arguments := range []string{`{"seconds": 0}`, `{"seconds": 1.5}`, `{"seconds": 86401}`, `{"seconds": -5}`, `{}`} {
+		if _, err := timerTool(context.Background(), cs, json.RawMessage(arguments)); err == nil {
+			t.Errorf("timer %s was set, want an error", arguments)
+		}
+	}
+
+	result, err := timerTool(context.Background(), cs, json.RawMessage(`{"seconds": 600, "label": "tea"}`))
+	if err != nil || result != "Timer set for 10m0s" {
+		t.Fatalf("timer = %q, %v", result, err)
+	}
+	if _, err := timerTool(context.Background(), cs, json.RawMessage(`{"seconds": 86400}`)); err != nil {
+		t.Fatalf("day long timer: %v", err)
+	}
+
+	cs.closeMutex.Lock()
+	pending := len(cs.timers)
+	cs.closeMutex.Unlock()
+	if pending != 2 {
+		t.Fatalf("pending timers = %d, want 2", pending)
+	}
+
+	// Closing the session stops its timers and refuses new ones
+	cs.close()
+	cs.closeMutex.Lock()
+	pending = len(cs.timers)
+	cs.closeMutex.Unlock()
+	if pending != 0 {
+		t.Errorf("pending timers after close = %d, want 0", pending)
+	}
+	if _, err := timerTool(context.Background(), cs, json.RawMessage(`{"seconds": 5}`)); err == nil {
+		t.Error("timer set on a closed session")
+	}
+}
+
+func TestTimerFires(t *testing.T) {
+	app := newApp(DefaultConfig())
+	server := httptest.NewServer(app.Routes())
+	defer server.Close()
+	dialSession(t, server, "")
+	cs := app.snapshotClients()[0]
+
+	fired := make(chan struct{})
+	cs.afterFunc(10*time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer did not fire")
+	}
+
+	cs.closeMutex.Lock()
+	defer cs.closeMutex.Unlock()
+	if len(cs.timers) != 0 {
+		t.Errorf("fired timer still pending")
+	}
+}
+
+// toolLlm calls calculate until it sees a tool result, then answers with it
+type toolLlm struct {
+	mutex    sync.Mutex
+	requests [][]ChatMessage
+	offered  []int // Tools offered with each request
+	always   bool  // Call the tool even after a result
+}
+
+func (c *toolLlm) GetResponse(ctx context.Context, messages []ChatMessage, tools []ToolDefinition, options LlmOptions) (chan LlmChunk, error) {
+	c.mutex.Lock()
+	c.requests = append(c.requests, messages)
+	c.offered = append(c.offered, len(tools))
+	c.mutex.Unlock()
+
+	stream := make(chan LlmChunk, 1)
+	if last := messages[len(messages)-1]; last.Role == "tool" && !c.always {
+		stream <- LlmChunk{Text: "The result is " + last.Content}
+	} else {
+		stream <- LlmChunk{ToolCalls: []ToolCall{toolCall("calculate", `{"expression": "2 + 2"}`)}}
+	}
+	close(stream)
+	return stream, nil
+}
+
+func TestToolLoop(t *testing.T) {
+	config := DefaultConfig()
+	config.Tools.Filler = ""
+	config.Audio.PlaybackTimeout = Duration(100 * time.Millisecond)
+	llm := &toolLlm{}
+	cs, conn := triggeredSession(t, config, &scriptedStt{results: []Transcription{{Text: "What is two plus two?"}}})
+	cs.app.llmClient = llm
+	cs.processAudio()
+
+	// The tool result goes back to the model, which answers with it
+	if len(llm.requests) != 2 {
+		t.Fatalf("%d LLM requests, want 2", len(llm.requests))
+	}
+	messages := llm.requests[1]
+	if call, result := messages[len(messages)-2], messages[len(messages)-1]; len(call.ToolCalls) != 1 || result.ToolCallID != "call_1" || result.Content != `{"result":"4"}` {
+		t.Errorf("second request ends with %+v and %+v", call, result)
+	}
+	if got := responses(conn); len(got) == 0 || got[len(got)-1] != `The result is {"result":"4"}` {
+		t.Errorf("responses = %q", got)
+	}
+}
+
+func TestToolLoopRoundLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.Tools.Filler = ""
+	config.Tools.MaxRounds = 2
+	config.Audio.PlaybackTimeout = Duration(100 * time.Millisecond)
+	llm := &toolLlm{always: true}
+	cs, _ := triggeredSession(t, config, &scriptedStt{results: []Transcription{{Text: "What is two plus two?"}}})
+	cs.app.llmClient = llm
+	cs.processAudio()
+
+	// Once the rounds are used up no tools are offered and the turn ends
+	if len(llm.offered) != 3 || llm.offered[2] != 0 || llm.offered[0] == 0 {
+		t.Errorf("tools offered per request = %v, want none in the third", llm.offered)
+	}
+	if cs.getState() != StateIdle {
+		t.Errorf("state = %s after the turn", cs.getState())
+	}
+}
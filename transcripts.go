@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// utteranceStream streams the utterance of the current turn to STT while the
+// user speaks
+type utteranceStream struct {
+	stream    SttStream
+	cancel    context.CancelFunc
+	failed    bool          // Audio could not be sent, so the stream misses part of the utterance
+	forwarded chan struct{} // Closed once the interim results have been forwarded
+}
+
+// openUtterance starts streaming the utterance of a triggered turn to STT,
+// with the audio collected so far. Without a stream the turn transcribes the
+// whole utterance once the user stops speaking.
+func (cs *ClientState) openUtterance(config AppConfig) {
+	turn := cs.activeTurn()
+	if turn == nil || cs.app.sttClient == nil || !(config.Stt.Interim || config.Stt.Words) {
+		return
+	}
+
+	settings := cs.turnSettings(config)
+	ctx, cancel := context.WithCancel(turn.ctx)
+	stream, err := cs.app.sttClient.Stream(ctx, settings.sttLanguageHint(), SttStreamOptions{
+		Interim:      config.Stt.Interim,
+		Words:        config.Stt.Words,
+		Alternatives: config.Confirmation.sttAlternatives(),
+	})
+	if err != nil {
+		cancel()
+		logf(LogDebug, "Transcribing without a stream: %v", err)
+		return
+	}
+	utterance := &utteranceStream{stream: stream, cancel: cancel, forwarded: make(chan struct{})}
+
+	cs.audioBufferMutex.Lock()
+	defer cs.audioBufferMutex.Unlock()
+
+	// The turn may have ended while the stream was opening
+	if cs.getState() != StateTriggered || cs.utterance != nil {
+		cancel()
+		return
+	}
+	for _, chunk := range cs.audioBuffer {
+		utterance.send(chunk)
+	}
+	cs.utterance = utterance
+	go cs.forwardInterims(utterance, config)
+}
+
+// send streams a chunk of the utterance. Called with audioBufferMutex held.
+func (u *utteranceStream) send(chunk []byte) {
+	if u.failed {
+		return
+	}
+	if err := u.stream.Send(chunk); err != nil {
+		logf(LogDebug, "STT stream lost audio: %v", err)
+		u.failed = true
+	}
+}
+
+// finish returns the final transcript of the stream, waiting up to timeout.
+// The stream is closed and its interim results forwarded when it returns.
+func (u *utteranceStream) finish(ctx context.Context, timeout time.Duration) (Transcription, error) {
+	defer func() {
+		u.cancel()
+		<-u.forwarded
+	}()
+	if u.failed {
+		return Transcription{}, errors.New("STT stream lost audio")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return u.stream.Finish(ctx)
+}
+
+// dropUtterance abandons the stream of the current utterance, if any
+func (cs *ClientState) dropUtterance() {
+	cs.audioBufferMutex.Lock()
+	utterance := cs.utterance
+	cs.utterance = nil
+	cs.audioBufferMutex.Unlock()
+
+	if utterance != nil {
+		utterance.cancel()
+	}
+}
+
+// forwardInterims sends the interim results of utterance to the client while
+// the user speaks, at most one every stt.interim_interval and only when the
+// text changed. With speculation enabled, an interim transcript unchanged for
+// speculation.stable starts the LLM request.
+func (cs *ClientState) forwardInterims(utterance *utteranceStream, config AppConfig) {
+	defer close(utterance.forwarded)
+
+	var sent string
+	var sentAt time.Time
+	var pending *Transcription
+	var wait <-chan time.Time
+	var latest Transcription
+	var stable <-chan time.Time
+	for {
+		select {
+		case result, ok := <-utterance.stream.Interim():
+			if !ok {
+				return
+			}
+			if config.Speculation.Enabled && normalizeTranscript(result.Text) != normalizeTranscript(latest.Text) {
+				stable = time.After(config.Speculation.Stable.Std())
+			}
+			latest = result
+			pending = &result
+			if strings.TrimSpace(result.Text) == "" || result.Text == sent {
+				pending = nil
+			}
+		case <-wait:
+			wait = nil
+		case <-stable:
+			stable = nil
+			cs.speculate(config, latest)
+		}
+		if pending == nil || wait != nil {
+			continue
+		}
+		if remaining := config.Stt.InterimInterval.Std() - time.Since(sentAt); remaining > 0 {
+			wait = time.After(remaining)
+			continue
+		}
+
+		if cs.getState() == StateTriggered {
+			cs.sendTranscript(*pending, pending.LanguageCode, false, config.Stt)
+			cs.recordEvent("stt", "interim", fmt.Sprintf("%q", pending.Text))
+		}
+		sent, sentAt, pending = pending.Text, time.Now(), nil
+	}
+}
+
+// transcribe returns the final transcript of the utterance, from its stream
+// when one kept up and from a request for the whole audio otherwise
+func (cs *ClientState) transcribe(ctx context.Context, config AppConfig, audioBuffer [][]byte, utterance *utteranceStream, languageCode string) (Transcription, error) {
+	if utterance != nil {
+		transcription, err := utterance.finish(ctx, config.Resilience.Stt.Timeout.Std())
+		if err == nil {
+			return transcription, nil
+		}
+		if ctx.Err() != nil {
+			return Transcription{}, err
+		}
+		log.Printf("Warning: Failed to stream the utterance, transcribing it whole: %v", err)
+		cs.recordEvent("stt", "stream_error", err.Error())
+	}
+	return cs.app.sttClient.Transcribe(ctx, audioBuffer, languageCode, config.Confirmation.sttAlternatives())
+}
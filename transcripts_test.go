@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSttStream is an STT stream returning interim results pushed by the test
+type fakeSttStream struct {
+	mutex     sync.Mutex
+	sent      [][]byte
+	sendErr   error
+	interim   chan Transcription
+	final     Transcription
+	finishErr error
+}
+
+func newFakeSttStream() *fakeSttStream {
+	return &fakeSttStream{interim: make(chan Transcription, 16)}
+}
+
+func (s *fakeSttStream) Send(audioData []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent = append(s.sent, audioData)
+	return s.sendErr
+}
+
+func (s *fakeSttStream) Interim() <-chan Transcription { return s.interim }
+
+func (s *fakeSttStream) Finish(ctx context.Context) (Transcription, error) {
+	return s.final, s.finishErr
+}
+
+// streamSttClient opens stream, and transcribes whole utterances as whole
+type streamSttClient struct {
+	stream  *fakeSttStream
+	options SttStreamOptions
+	whole   int // Utterances transcribed whole
+}
+
+func (c *streamSttClient) Transcribe(ctx context.Context, audioBuffer [][]byte, languageCode string, alternatives int) (Transcription, error) {
+	c.whole++
+	return Transcription{Text: "whole"}, nil
+}
+
+func (c *streamSttClient) Stream(ctx context.Context, languageCode string, options SttStreamOptions) (SttStream, error) {
+	c.options = options
+	if c.stream == nil {
+		return nil, errors.New("no stream")
+	}
+	return c.stream, nil
+}
+
+func (c *streamSttClient) Close() error { return nil }
+
+// interimTranscripts returns the text of the interim transcripts written to conn
+func interimTranscripts(conn *frameTransport) []string {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	var texts []string
+	for _, frame := range conn.frames {
+		var message TranscriptMessage
+		if json.Unmarshal(frame, &message) == nil && message.Type == "transcript" && !message.IsFinal {
+			texts = append(texts, message.Text)
+		}
+	}
+	return texts
+}
+
+func TestOpenUtterance(t *testing.T) {
+	// Without interim transcripts or word timings the utterance is sent whole
+	client := &streamSttClient{stream: newFakeSttStream()}
+	cs, _ := triggeredSession(t, DefaultConfig(), client)
+	cs.openUtterance(cs.app.currentConfig())
+	if cs.utterance != nil || client.options != (SttStreamOptions{}) {
+		t.Fatal("stream opened with interim transcripts off")
+	}
+
+	// The audio collected since the wake word goes out first
+	config := DefaultConfig()
+	config.Stt.Interim = true
+	cs, _ = triggeredSession(t, config, client)
+	cs.audioBuffer = [][]byte{{1}, {2}}
+	cs.openUtterance(config)
+	if cs.utterance == nil || !client.options.Interim {
+		t.Fatalf("stream not opened, options %+v", client.options)
+	}
+	if len(client.stream.sent) != 2 {
+		t.Errorf("sent %d chunks, want the 2 buffered", len(client.stream.sent))
+	}
+	cs.dropUtterance()
+	if cs.utterance != nil {
+		t.Error("utterance kept after drop")
+	}
+
+	// Turns go on without a stream when it cannot be opened
+	cs, _ = triggeredSession(t, config, &streamSttClient{})
+	cs.openUtterance(config)
+	if cs.utterance != nil {
+		t.Error("utterance set without a stream")
+	}
+}
+
+func TestForwardInterims(t *testing.T) {
+	config := DefaultConfig()
+	config.Stt.Interim = true
+	config.Stt.InterimInterval = Duration(100 * time.Millisecond)
+	client := &streamSttClient{stream: newFakeSttStream()}
+	cs, conn := triggeredSession(t, config, client)
+	cs.openUtterance(config)
+
+	// The first result goes out at once; the ones within the interval are
+	// throttled to the latest, and repeats and blanks are not sent
+	for _, text := range []string{"what", "what", " ", "what is", "what is the"} {
+		client.stream.interim <- Transcription{Text: text}
+	}
+	time.Sleep(200 * time.Millisecond)
+	client.stream.interim <- Transcription{Text: "what is the"}
+	time.Sleep(50 * time.Millisecond)
+	close(client.stream.interim)
+	<-cs.utterance.forwarded
+
+	if texts, want := interimTranscripts(conn), []string{"what", "what is the"}; !slices.Equal(texts, want) {
+		t.Errorf("interim transcripts = %q, want %q", texts, want)
+	}
+}
+
+func TestForwardInterimsAfterTrigger(t *testing.T) {
+	config := DefaultConfig()
+	config.Stt.Interim = true
+	client := &streamSttClient{stream: newFakeSttStream()}
+	cs, conn := triggeredSession(t, config, client)
+	cs.openUtterance(config)
+
+	// Results arriving once the user stopped speaking are not shown
+	cs.setState(StateProcessing)
+	client.stream.interim <- Transcription{Text: "late"}
+	close(client.stream.interim)
+	<-cs.utterance.forwarded
+	if texts := interimTranscripts(conn); len(texts) != 0 {
+		t.Errorf("interim transcripts = %q after the utterance ended", texts)
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	config := DefaultConfig()
+	config.Stt.Interim = true
+	tests := []struct {
+		name      string
+		sendErr   error
+		finishErr error
+		want      string
+	}{
+		{"streamed", nil, nil, "streamed"},
+		{"stream failed", nil, errors.New("stream reset"), "whole"},
+		{"audio lost", errors.New("send failed"), nil, "whole"},
+	}
+	for _, test := range tests {
+		stream := newFakeSttStream()
+		stream.final = Transcription{Text: "streamed"}
+		stream.sendErr, stream.finishErr = test.sendErr, test.finishErr
+		client := &streamSttClient{stream: stream}
+		cs, _ := triggeredSession(t, config, client)
+		cs.audioBuffer = [][]byte{{1}}
+		cs.openUtterance(config)
+		close(stream.interim)
+
+		transcription, err := cs.transcribe(context.Background(), config, cs.audioBuffer, cs.utterance, "")
+		if err != nil || transcription.Text != test.want {
+			t.Errorf("%s: transcript = %q, %v, want %q", test.name, transcription.Text, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Stages of a turn, reported when a turn is cancelled
+const (
+	StageListening = "listening" // Waiting for the request after the wake word
+	StageStt       = "stt"
+	StageLlm       = "llm"
+	StageTools     = "tools"
+	StageTts       = "tts"
+	StagePlayback  = "playback" // Waiting for the client to play the reply
+)
+
+// CancelledMessage tells the client that a turn was cancelled and in which
+// stage. Speech the client has queued should be dropped.
+type CancelledMessage struct {
+	Type  string `json:"type"`
+	Stage string `json:"stage"`
+}
+
+// turnContext is the root context of a turn, from the wake word until the
+// session is idle again. Each stage runs in a child context, so cancelling
+// the turn unwinds all of them.
+type turnContext struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mutex     sync.Mutex
+	stage     string    // Stage in progress
+	cancelled string    // Stage the turn was cancelled in, empty while it was not
+	question  *question // Question the next utterance answers
+	questions int       // Questions asked in the turn
+}
+
+// newTurnContext creates the context of a turn of session
+func newTurnContext(session string) *turnContext {
+	ctx, cancel := context.WithCancel(withSession(context.Background(), session))
+	return &turnContext{ctx: ctx, cancel: cancel, stage: StageListening}
+}
+
+// enter starts stage in a child context of the turn. The returned function
+// cancels the child context and returns to the stage in progress before.
+func (t *turnContext) enter(stage string) (context.Context, func()) {
+	t.mutex.Lock()
+	previous := t.stage
+	t.stage = stage
+	t.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+			t.mutex.Lock()
+			t.stage = previous
+			t.mutex.Unlock()
+		})
+	}
+}
+
+// stop cancels the turn and returns the stage that was in progress
+func (t *turnContext) stop() string {
+	t.mutex.Lock()
+	if t.cancelled == "" {
+		t.cancelled = t.stage
+	}
+	stage := t.cancelled
+	t.mutex.Unlock()
+
+	t.cancel()
+	return stage
+}
+
+// cancelledIn returns the stage the turn was cancelled in, and false if it
+// was not cancelled
+func (t *turnContext) cancelledIn() (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.cancelled, t.cancelled != ""
+}
+
+// ask records that the next utterance answers q
+func (t *turnContext) ask(q *question) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.question = q
+	t.questions++
+}
+
+// takeQuestion returns the question the utterance answers, nil if there is
+// none, and how many questions the turn asked
+func (t *turnContext) takeQuestion() (*question, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	q := t.question
+	t.question = nil
+	return q, t.questions
+}
+
+// beginTurn starts the context of a new turn. Called with stateMutex held.
+func (cs *ClientState) beginTurn() {
+	if cs.turn != nil {
+		cs.turn.stop()
+	}
+	cs.turn = newTurnContext(cs.sessionID)
+}
+
+// activeTurn returns the context of the turn in progress, nil when idle
+func (cs *ClientState) activeTurn() *turnContext {
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+	return cs.turn
+}
+
+// advanceTurn moves turn to state and tells the client, unless the turn has
+// ended. It returns false if it had.
+func (cs *ClientState) advanceTurn(turn *turnContext, state State, detail string) bool {
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+
+	// The status goes out under the lock so it cannot follow the idle status
+	// of a stop
+	if cs.turn != turn {
+		return false
+	}
+	cs.state = state
+	cs.sendStatus(state, detail)
+	return true
+}
+
+// endTurn returns the session to idle after turn, reporting status to the
+// client, unless the turn was already ended
+func (cs *ClientState) endTurn(turn *turnContext, status State, detail string) {
+	cs.stateMutex.Lock()
+	defer cs.stateMutex.Unlock()
+
+	if cs.turn != turn {
+		return
+	}
+	cs.turn = nil
+	cs.state = StateIdle
+	turn.cancel()
+	cs.sendStatus(status, detail)
+}
+
+// completeTurn ends turn once it is answered, leaving the session ready for
+// the next one unless the server is going away
+func (cs *ClientState) completeTurn(turn *turnContext) {
+	if cs.app.isDraining() {
+		cs.endTurn(turn, StateDraining, "Server going away")
+	} else {
+		cs.endTurn(turn, StateIdle, "Ready")
+	}
+}
+
+// failTurn apologizes for a failed backend and ends turn with an error,
+// unless the turn was cancelled
+func (cs *ClientState) failTurn(turn *turnContext, config AppConfig, voice VoiceConfig, detail string) {
+	if turn.ctx.Err() != nil {
+		return
+	}
+	cs.apologize(turn.ctx, config, voice)
+	cs.endTurn(turn, StateError, detail)
+}
+
+// cancelTurn cancels turn if it is still in progress: every stage is
+// unwound, the speech queued for the client is dropped, the client is told
+// which stage was interrupted and the session returns to idle. It returns
+// false if the turn had already ended.
+func (cs *ClientState) cancelTurn(turn *turnContext) bool {
+	cs.stateMutex.Lock()
+	if cs.turn != turn {
+		cs.stateMutex.Unlock()
+		return false
+	}
+	cs.turn = nil
+	cs.stateMutex.Unlock()
+
+	stage := turn.stop()
+	log.Printf("Turn of session %s cancelled during %s", cs.sessionID, stage)
+	cs.recordEvent("turn", "cancelled", stage)
+	cs.flushSpeech()
+	cs.sendCancelled(stage)
+	cs.resetState()
+	return true
+}
+
+// dropTurn cancels the turn in progress without telling the client
+func (cs *ClientState) dropTurn() {
+	cs.stateMutex.Lock()
+	turn := cs.turn
+	cs.turn = nil
+	cs.stateMutex.Unlock()
+
+	if turn != nil {
+		turn.stop()
+	}
+}
+
+// flushSpeech drops the speech queued for the client. Speech is written under
+// writeMutex, so none of a cancelled turn follows.
+func (cs *ClientState) flushSpeech() {
+	cs.writeMutex.Lock()
+	defer cs.writeMutex.Unlock()
+
+	if track, ok := cs.conn.(speechTrack); ok {
+		track.flushSpeech()
+	}
+	cs.playback.flush()
+}
+
+// sendCancelled tells the client that the turn was cancelled during stage
+func (cs *ClientState) sendCancelled(stage string) {
+	jsonMsg, err := json.Marshal(CancelledMessage{Type: "cancelled", Stage: stage})
+	if err != nil {
+		log.Printf("Error marshaling cancelled message: %v", err)
+		return
+	}
+
+	if err := cs.writeMessage(websocket.TextMessage, jsonMsg); err != nil {
+		log.Printf("WebSocket write error: %v", err)
+	}
+}
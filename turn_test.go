@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTurnContextStages(t *testing.T) {
+	turn := newTurnContext("session")
+	sttCtx, leaveStt := turn.enter(StageStt)
+	_, leaveLlm := turn.enter(StageLlm)
+	if turn.stage != StageLlm {
+		t.Errorf("stage = %s, want llm", turn.stage)
+	}
+
+	// Leaving a stage returns to the one it was entered from, once
+	leaveLlm()
+	_, leaveTts := turn.enter(StageTts)
+	leaveLlm()
+	if turn.stage != StageTts {
+		t.Errorf("stage = %s after leaving llm twice, want tts", turn.stage)
+	}
+	leaveTts()
+
+	if _, cancelled := turn.cancelledIn(); cancelled {
+		t.Error("turn cancelled before stop")
+	}
+	if stage := turn.stop(); stage != StageStt {
+		t.Errorf("stopped in %s, want stt", stage)
+	}
+	leaveStt()
+	if stage, cancelled := turn.cancelledIn(); !cancelled || stage != StageStt || turn.stop() != StageStt {
+		t.Errorf("cancelled in %q, want the first stop to count", stage)
+	}
+	if sttCtx.Err() == nil || turn.ctx.Err() == nil {
+		t.Error("stages still running after stop")
+	}
+}
+
+func TestTurnQuestions(t *testing.T) {
+	turn := newTurnContext("session")
+	if q, asked := turn.takeQuestion(); q != nil || asked != 0 {
+		t.Errorf("question %v, %d asked before any", q, asked)
+	}
+	q := &question{}
+	turn.ask(q)
+	if taken, asked := turn.takeQuestion(); taken != q || asked != 1 {
+		t.Errorf("question %v, %d asked, want the one asked", taken, asked)
+	}
+	if taken, asked := turn.takeQuestion(); taken != nil || asked != 1 {
+		t.Errorf("question %v taken twice", taken)
+	}
+}
+
+func TestStopCommand(t *testing.T) {
+	app := newApp(DefaultConfig())
+	conn := &frameTransport{}
+	cs := NewClientState(conn, app, "alice")
+	cs.stateMutex.Lock()
+	cs.beginTurn()
+	cs.stateMutex.Unlock()
+	turn := cs.activeTurn()
+	cs.advanceTurn(turn, StateSpeaking, "Speaking")
+	ttsCtx, leave := turn.enter(StageTts)
+	defer leave()
+	cs.playback.sent(time.Hour)
+
+	// The stage in progress is unwound, queued speech dropped and the client told
+	cs.handleTextCommand(`{"action":"stop"}`)
+	if ttsCtx.Err() == nil {
+		t.Error("TTS still running after stop")
+	}
+	if cancelled := sentMessages(conn, "cancelled"); len(cancelled) != 1 || cancelled[0]["stage"] != StageTts {
+		t.Errorf("cancelled messages = %v, want one for tts", cancelled)
+	}
+	if cs.activeTurn() != nil || cs.getState() != StateIdle || len(cs.playback.pending) != 0 {
+		t.Errorf("state %s after stop, turn %v", cs.getState(), cs.activeTurn())
+	}
+
+	// The turn cannot go on once stopped
+	if cs.advanceTurn(turn, StateProcessing, "Processing") {
+		t.Error("stopped turn advanced")
+	}
+	statuses := len(sentMessages(conn, "status"))
+	cs.endTurn(turn, StateError, "late failure")
+	if len(sentMessages(conn, "status")) != statuses {
+		t.Error("stopped turn ended again")
+	}
+
+	// Without a turn stop only resets the session
+	cs.handleTextCommand(`{"action":"stop"}`)
+	if cancelled := sentMessages(conn, "cancelled"); len(cancelled) != 1 {
+		t.Errorf("%d cancelled messages after stopping an idle session", len(cancelled))
+	}
+	if statuses := sentMessages(conn, "status"); statuses[len(statuses)-1]["status"] != string(StateIdle) {
+		t.Errorf("last status = %v, want idle", statuses[len(statuses)-1])
+	}
+}
+
+func TestCompleteTurnWhileDraining(t *testing.T) {
+	app := newApp(DefaultConfig())
+	conn := &frameTransport{}
+	cs := NewClientState(conn, app, "alice")
+	for _, draining := range []bool{false, true} {
+		if draining {
+			app.drainMutex.Lock()
+			app.draining = true
+			app.drainMutex.Unlock()
+		}
+		cs.stateMutex.Lock()
+		cs.beginTurn()
+		cs.stateMutex.Unlock()
+		turn := cs.activeTurn()
+		cs.completeTurn(turn)
+		if turn.ctx.Err() == nil || cs.activeTurn() != nil {
+			t.Errorf("draining %v: turn still active after completion", draining)
+		}
+	}
+	statuses := sentMessages(conn, "status")
+	if len(statuses) != 2 || statuses[0]["status"] != string(StateIdle) || statuses[1]["status"] != string(StateDraining) {
+		t.Errorf("statuses = %v, want idle then draining", statuses)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// feedWakeWordDetector adds a chunk of idle audio to the detection window and
+// starts a wake word check once enough new speech has arrived. A check is
+// forced at the end of an utterance so a wake word said on its own is found.
+func (cs *ClientState) feedWakeWordDetector(chunk []byte, force bool) {
+	if cs.app.triggerClient == nil || cs.app.isDraining() {
+		return
+	}
+	config := cs.app.currentConfig()
+	windowBytes := pcmBytes(config.Trigger.Window.Std())
+
+	cs.wakeMutex.Lock()
+	cs.wakeWindow = append(cs.wakeWindow, chunk...)
+	if len(cs.wakeWindow) > windowBytes {
+		cs.wakeWindow = cs.wakeWindow[len(cs.wakeWindow)-windowBytes:]
+	}
+	cs.wakeUnchecked += len(chunk)
+
+	// Only check while the user is speaking and one check at a time
+	ready := !cs.wakeDetecting && len(cs.wakeWindow) > 0 &&
+		(force || (cs.vadActive.Load() && cs.wakeUnchecked >= pcmBytes(config.Trigger.CheckInterval.Std())))
+	var window []byte
+	if ready {
+		cs.wakeDetecting = true
+		cs.wakeUnchecked = 0
+		window = append([]byte(nil), cs.wakeWindow...)
+	}
+	cs.wakeMutex.Unlock()
+
+	if ready {
+		go cs.checkWakeWord(config, window)
+	}
+}
+
+// checkWakeWord runs wake word detection on window and triggers the session
+// if a wake word was found
+func (cs *ClientState) checkWakeWord(config AppConfig, window []byte) {
+	defer func() {
+		cs.wakeMutex.Lock()
+		cs.wakeDetecting = false
+		cs.wakeMutex.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(withSession(context.Background(), cs.sessionID), config.Trigger.Timeout.Std())
+	defer cancel()
+
+	detected, rejected, ok := detectWakeWord(ctx, cs.app.triggerClient, config.Trigger, window)
+	for _, detection := range rejected {
+		detail := fmt.Sprintf("%q confidence %.2f below %.2f", detection.wakeWord.Phrase, detection.confidence, detection.threshold)
+		logf(LogDebug, "Ignoring wake word %s", detail)
+		cs.recordEvent("trigger", "rejected", detail)
+		cs.app.metrics.inc(metricWakeWordsRejected, detection.wakeWord.Phrase)
+	}
+	if !ok {
+		return
+	}
+
+	// The session may have left IDLE while the check was running
+	wakeWord := detected.wakeWord
+	if !cs.trigger(wakeWord) {
+		return
+	}
+	log.Printf("Wake word %q detected (confidence %.2f)", wakeWord.Phrase, detected.confidence)
+	cs.recordEvent("trigger", "detected", fmt.Sprintf("%q confidence %.2f", wakeWord.Phrase, detected.confidence))
+
+	detail := "Listening to you..."
+	if wakeWord.Phrase != "" {
+		detail = fmt.Sprintf("Listening to you (%s)...", wakeWord.Phrase)
+	}
+	cs.sendStatus(StateTriggered, detail)
+
+	// Transcribe the request while the user speaks
+	cs.openUtterance(config)
+}
+
+// wakeWordDetection is a wake word the trigger service reported
+type wakeWordDetection struct {
+	wakeWord   WakeWordConfig
+	confidence float64
+	threshold  float64 // Confidence the wake word needs
+}
+
+// detectWakeWord asks the trigger service for each configured wake word and
+// returns the one detected with the highest confidence above its threshold,
+// and the detections ignored for a confidence below it. A confidence of 0
+// means the service does not report one, and the detection is taken as is.
+// Without configured wake words the service's default wake word is used.
+func detectWakeWord(ctx context.Context, client TriggerClient, config TriggerConfig, audio []byte) (wakeWordDetection, []wakeWordDetection, bool) {
+	wakeWords := config.WakeWords
+	if len(wakeWords) == 0 {
+		wakeWords = []WakeWordConfig{{}}
+	}
+
+	results := make([]TriggerResult, len(wakeWords))
+	var wg sync.WaitGroup
+	for i, wakeWord := range wakeWords {
+		wg.Add(1)
+		go func(i int, phrase string) {
+			defer wg.Done()
+			result, err := client.Detect(ctx, audio, phrase)
+			if err != nil {
+				logf(LogDebug, "Wake word detection failed for %q: %v", phrase, err)
+				return
+			}
+			results[i] = result
+		}(i, wakeWord.Phrase)
+	}
+	wg.Wait()
+
+	var best wakeWordDetection
+	var rejected []wakeWordDetection
+	found := false
+	for i, result := range results {
+		if !result.Triggered {
+			continue
+		}
+
+		// Trust the service if it names a different configured wake word
+		wakeWord := wakeWords[i]
+		if result.WakeWord != "" && !strings.EqualFold(result.WakeWord, wakeWord.Phrase) {
+			if matched, ok := config.wakeWord(result.WakeWord); ok {
+				wakeWord = matched
+			} else if len(config.WakeWords) > 0 {
+				continue
+			} else {
+				wakeWord.Phrase = result.WakeWord
+			}
+		}
+
+		threshold := wakeWord.Threshold
+		if threshold == 0 {
+			threshold = config.Threshold
+		}
+		detection := wakeWordDetection{wakeWord: wakeWord, confidence: result.Confidence, threshold: threshold}
+		if result.Confidence > 0 && result.Confidence < threshold {
+			rejected = append(rejected, detection)
+			continue
+		}
+		if !found || result.Confidence > best.confidence {
+			best, found = detection, true
+		}
+	}
+	return best, rejected, found
+}
+
+// wakeWord finds a configured wake word by phrase, ignoring case
+func (c TriggerConfig) wakeWord(phrase string) (WakeWordConfig, bool) {
+	for _, wakeWord := range c.WakeWords {
+		if strings.EqualFold(strings.TrimSpace(wakeWord.Phrase), strings.TrimSpace(phrase)) {
+			return wakeWord, true
+		}
+	}
+	return WakeWordConfig{}, false
+}
+
+// trigger moves an idle session to TRIGGERED for wakeWord. It returns false
+// if the session was not idle.
+func (cs *ClientState) trigger(wakeWord WakeWordConfig) bool {
+	cs.stateMutex.Lock()
+	if cs.state != StateIdle {
+		cs.stateMutex.Unlock()
+		return false
+	}
+	cs.state = StateTriggered
+	cs.wakeWord = wakeWord
+	cs.beginTurn()
+	cs.stateMutex.Unlock()
+
+	cs.triggered = true
+
+	// Clear the audio buffers to start fresh
+	cs.audioBufferMutex.Lock()
+	cs.audioBuffer = make([][]byte, 0)
+	cs.audioBufferMutex.Unlock()
+	cs.dropUtterance()
+	cs.dropSpeculation()
+	cs.clearWakeWindow()
+	return true
+}
+
+// clearWakeWindow drops the audio kept for wake word detection
+func (cs *ClientState) clearWakeWindow() {
+	cs.wakeMutex.Lock()
+	cs.wakeWindow = nil
+	cs.wakeUnchecked = 0
+	cs.wakeMutex.Unlock()
+}
+
+// turnSettings returns the session settings for the current turn, with the
+// persona, system prompt and tools of the wake word that started it
+func (cs *ClientState) turnSettings(config AppConfig) SessionSettings {
+	cs.stateMutex.Lock()
+	wakeWord := cs.wakeWord
+	cs.stateMutex.Unlock()
+
+	cs.preferencesMutex.Lock()
+	prefs := cs.preferences
+	cs.preferencesMutex.Unlock()
+
+	if wakeWord.Persona != "" {
+		prefs.Persona = wakeWord.Persona
+	}
+	settings := config.resolveSession(prefs)
+	if wakeWord.SystemPrompt != "" {
+		settings.SystemPrompt = wakeWord.SystemPrompt
+	}
+	settings.Tools = wakeWord.Tools
+	return settings
+}
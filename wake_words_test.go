@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubTriggerClient answers each wake word with a fixed result
+type stubTriggerClient map[string]TriggerResult
+
+func (c stubTriggerClient) Detect(ctx context.Context, audioData []byte, wakeWord string) (TriggerResult, error) {
+	result, ok := c[wakeWord]
+	if !ok {
+		return TriggerResult{}, errors.New("unavailable")
+	}
+	return result, nil
+}
+
+func (c stubTriggerClient) Close() error { return nil }
+
+func TestDetectWakeWord(t *testing.T) {
+	config := DefaultConfig().Trigger
+	config.WakeWords = []WakeWordConfig{
+		{Phrase: "hey computer"},
+		{Phrase: "hey chef", Threshold: 0.8, Persona: "chef"},
+		{Phrase: "hey pirate"},
+	}
+
+	tests := []struct {
+		name     string
+		client   stubTriggerClient
+		want     string
+		rejected int
+	}{
+		{
+			name: "highest confidence wins",
+			client: stubTriggerClient{
+				"hey computer": {Triggered: true, Confidence: 0.6},
+				"hey chef":     {Triggered: true, Confidence: 0.9},
+				"hey pirate":   {Triggered: false, Confidence: 0.95},
+			},
+			want: "hey chef",
+		},
+		{
+			name: "own threshold",
+			client: stubTriggerClient{
+				"hey computer": {Triggered: true, Confidence: 0.6},
+				"hey chef":     {Triggered: true, Confidence: 0.7},
+			},
+			want:     "hey computer",
+			rejected: 1,
+		},
+		{
+			name: "below every threshold",
+			client: stubTriggerClient{
+				"hey computer": {Triggered: true, Confidence: 0.3},
+				"hey pirate":   {Triggered: true, Confidence: 0.4},
+			},
+			rejected: 2,
+		},
+		{
+			name: "service names another configured wake word",
+			client: stubTriggerClient{
+				"hey computer": {Triggered: true, Confidence: 0.7, WakeWord: "Hey Pirate"},
+			},
+			want: "hey pirate",
+		},
+		{
+			name: "service names an unknown wake word",
+			client: stubTriggerClient{
+				"hey computer": {Triggered: true, Confidence: 0.7, WakeWord: "hey toaster"},
+			},
+		},
+		{
+			name:   "every request failed",
+			client: stubTriggerClient{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			detected, rejected, ok := detectWakeWord(context.Background(), test.client, config, []byte{0, 0})
+			if ok != (test.want != "") || detected.wakeWord.Phrase != test.want {
+				t.Errorf("detected %q (%v), want %q", detected.wakeWord.Phrase, ok, test.want)
+			}
+			if len(rejected) != test.rejected {
+				t.Errorf("rejected = %+v, want %d", rejected, test.rejected)
+			}
+		})
+	}
+}
+
+func TestDetectWakeWordWithoutConfidence(t *testing.T) {
+	// A service that does not report a confidence leaves it at 0
+	config := DefaultConfig().Trigger
+	client := stubTriggerClient{"": {Triggered: true, WakeWord: "hey assistant"}}
+
+	detected, rejected, ok := detectWakeWord(context.Background(), client, config, []byte{0, 0})
+	if !ok || detected.wakeWord.Phrase != "hey assistant" || len(rejected) != 0 {
+		t.Errorf("detected %+v (%v), rejected %+v, want the service's wake word", detected, ok, rejected)
+	}
+
+	// Configured wake words with their own threshold too
+	config.WakeWords = []WakeWordConfig{{Phrase: "hey chef", Threshold: 0.9}}
+	client = stubTriggerClient{"hey chef": {Triggered: true}}
+	if detected, _, ok := detectWakeWord(context.Background(), client, config, []byte{0, 0}); !ok || detected.wakeWord.Phrase != "hey chef" {
+		t.Errorf("detected %+v (%v), want hey chef", detected, ok)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// EBML element IDs of the WebM subset written for Opus audio
+const (
+	ebmlHeader             = 0x1a45dfa3
+	ebmlVersion            = 0x4286
+	ebmlReadVersion        = 0x42f7
+	ebmlMaxIDLength        = 0x42f2
+	ebmlMaxSizeLength      = 0x42f3
+	ebmlDocType            = 0x4282
+	ebmlDocTypeVersion     = 0x4287
+	ebmlDocTypeReadVersion = 0x4285
+	webmSegment            = 0x18538067
+	webmInfo               = 0x1549a966
+	webmTimecodeScale      = 0x2ad7b1
+	webmDuration           = 0x4489
+	webmMuxingApp          = 0x4d80
+	webmWritingApp         = 0x5741
+	webmTracks             = 0x1654ae6b
+	webmTrackEntry         = 0xae
+	webmTrackNumber        = 0xd7
+	webmTrackUID           = 0x73c5
+	webmTrackType          = 0x83
+	webmCodecID            = 0x86
+	webmCodecPrivate       = 0x63a2
+	webmCodecDelay         = 0x56aa
+	webmSeekPreRoll        = 0x56bb
+	webmAudio              = 0xe1
+	webmSamplingFrequency  = 0xb5
+	webmChannels           = 0x9f
+	webmCluster            = 0x1f43b675
+	webmTimecode           = 0xe7
+	webmSimpleBlock        = 0xa3
+)
+
+// webmClusterSpan is how long a cluster may get; block timecodes are 16-bit
+// offsets in milliseconds from the cluster's
+const webmClusterSpan = 30000
+
+// ebmlElement encodes an element with the given body
+func ebmlElement(id uint32, body ...[]byte) []byte {
+	size := 0
+	for _, part := range body {
+		size += len(part)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(12 + size)
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || buf.Len() > 0 {
+			buf.WriteByte(b)
+		}
+	}
+	buf.Write(ebmlSize(size))
+	for _, part := range body {
+		buf.Write(part)
+	}
+	return buf.Bytes()
+}
+
+// ebmlSize encodes an element size as a variable length integer
+func ebmlSize(size int) []byte {
+	length := 1
+	for uint64(size) >= 1<<(7*length)-1 {
+		length++
+	}
+	encoded := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		encoded[i] = byte(size)
+		size >>= 8
+	}
+	encoded[0] |= 0x80 >> (length - 1)
+	return encoded
+}
+
+// ebmlUint encodes an unsigned integer element
+func ebmlUint(id uint32, value uint64) []byte {
+	var body []byte
+	for shift := 56; shift >= 0; shift -= 8 {
+		if b := byte(value >> shift); b != 0 || len(body) > 0 || shift == 0 {
+			body = append(body, b)
+		}
+	}
+	return ebmlElement(id, body)
+}
+
+// ebmlFloat encodes a float element
+func ebmlFloat(id uint32, value float64) []byte {
+	return ebmlElement(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(value)))
+}
+
+// ebmlString encodes a string element
+func ebmlString(id uint32, value string) []byte {
+	return ebmlElement(id, []byte(value))
+}
+
+// writeWebmOpus wraps Opus packets in a WebM file with a single audio track.
+// head is the Opus identification header of the stream.
+func writeWebmOpus(head []byte, packets [][]byte) []byte {
+	channels, preSkip, _, err := parseOpusHead(head)
+	if err != nil {
+		channels, preSkip = 1, opusPreSkip
+	}
+
+	// Group the packets into clusters by their timecode in milliseconds
+	var clusters [][]byte
+	var blocks [][]byte
+	clusterStart, samples := 0, 0
+	for _, packet := range packets {
+		timecode := samples * 1000 / opusClockRate
+		if timecode-clusterStart >= webmClusterSpan && len(blocks) > 0 {
+			clusters = append(clusters, webmClusterOf(clusterStart, blocks))
+			clusterStart, blocks = timecode, nil
+		}
+		block := []byte{0x81} // Track 1
+		block = binary.BigEndian.AppendUint16(block, uint16(timecode-clusterStart))
+		block = append(block, 0x80) // Keyframe
+		blocks = append(blocks, ebmlElement(webmSimpleBlock, block, packet))
+		samples += opusPacketDuration(packet)
+	}
+	if len(blocks) > 0 {
+		clusters = append(clusters, webmClusterOf(clusterStart, blocks))
+	}
+
+	header := ebmlElement(ebmlHeader,
+		ebmlUint(ebmlVersion, 1),
+		ebmlUint(ebmlReadVersion, 1),
+		ebmlUint(ebmlMaxIDLength, 4),
+		ebmlUint(ebmlMaxSizeLength, 8),
+		ebmlString(ebmlDocType, "webm"),
+		ebmlUint(ebmlDocTypeVersion, 4),
+		ebmlUint(ebmlDocTypeReadVersion, 2),
+	)
+	info := ebmlElement(webmInfo,
+		ebmlUint(webmTimecodeScale, 1000000), // Milliseconds
+		ebmlFloat(webmDuration, float64(samples-preSkip)*1000/opusClockRate),
+		ebmlString(webmMuxingApp, "assistant-app"),
+		ebmlString(webmWritingApp, "assistant-app"),
+	)
+	tracks := ebmlElement(webmTracks, ebmlElement(webmTrackEntry,
+		ebmlUint(webmTrackNumber, 1),
+		ebmlUint(webmTrackUID, 1),
+		ebmlUint(webmTrackType, 2), // Audio
+		ebmlString(webmCodecID, "A_OPUS"),
+		ebmlElement(webmCodecPrivate, head),
+		ebmlUint(webmCodecDelay, uint64(preSkip)*1000000000/opusClockRate),
+		ebmlUint(webmSeekPreRoll, 80000000),
+		ebmlElement(webmAudio,
+			ebmlFloat(webmSamplingFrequency, opusClockRate),
+			ebmlUint(webmChannels, uint64(channels)),
+		),
+	))
+
+	segment := append([][]byte{info, tracks}, clusters...)
+	return append(header, ebmlElement(webmSegment, segment...)...)
+}
+
+// webmClusterOf encodes a cluster starting at timecode
+func webmClusterOf(timecode int, blocks [][]byte) []byte {
+	return ebmlElement(webmCluster, append([][]byte{ebmlUint(webmTimecode, uint64(timecode))}, blocks...)...)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEbmlSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want []byte
+	}{
+		{0, []byte{0x80}},
+		{126, []byte{0xfe}},
+		{127, []byte{0x40, 0x7f}}, // All ones is reserved for unknown sizes
+		{1000, []byte{0x43, 0xe8}},
+		{20000, []byte{0x20, 0x4e, 0x20}},
+	}
+	for _, test := range tests {
+		if got := ebmlSize(test.size); !bytes.Equal(got, test.want) {
+			t.Errorf("ebmlSize(%d) = %x, want %x", test.size, got, test.want)
+		}
+	}
+}
+
+func TestEbmlElements(t *testing.T) {
+	if got := ebmlUint(webmTrackNumber, 1); !bytes.Equal(got, []byte{0xd7, 0x81, 0x01}) {
+		t.Errorf("ebmlUint = %x", got)
+	}
+	if got := ebmlUint(webmTimecode, 0); !bytes.Equal(got, []byte{0xe7, 0x81, 0x00}) {
+		t.Errorf("ebmlUint of 0 = %x", got)
+	}
+	if got := ebmlString(webmCodecID, "A_OPUS"); !bytes.Equal(got, append([]byte{0x86, 0x86}, "A_OPUS"...)) {
+		t.Errorf("ebmlString = %x", got)
+	}
+}
+
+func TestWriteWebmOpus(t *testing.T) {
+	// 40s of 20ms packets need two clusters, as block timecodes are 16-bit
+	var packets [][]byte
+	for range 2000 {
+		packets = append(packets, testOpusPacket(31, 20))
+	}
+	webm := writeWebmOpus(opusHead(24000), packets)
+
+	if !bytes.HasPrefix(webm, []byte{0x1a, 0x45, 0xdf, 0xa3}) {
+		t.Fatal("missing EBML header")
+	}
+	if !bytes.Contains(webm, append([]byte{0x86, 0x86}, "A_OPUS"...)) || !bytes.Contains(webm, opusHead(24000)) {
+		t.Error("track does not describe the Opus stream")
+	}
+	if clusters := bytes.Count(webm, []byte{0x1f, 0x43, 0xb6, 0x75}); clusters != 2 {
+		t.Errorf("%d clusters, want 2", clusters)
+	}
+	if blocks := bytes.Count(webm, []byte{0xa3, 0x80 | 24, 0x81}); blocks != len(packets) {
+		t.Errorf("%d blocks, want %d", blocks, len(packets))
+	}
+}